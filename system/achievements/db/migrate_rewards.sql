@@ -0,0 +1,6 @@
+-- Migration of an existing Achievements database to rewards paid again until marked as paid.
+-- Badges unlocked before the migration are considered paid.
+USE Achievements;
+
+ALTER TABLE Unlocked ADD COLUMN IF NOT EXISTS Rewarded boolean NOT NULL DEFAULT TRUE;
+ALTER TABLE Unlocked ALTER COLUMN Rewarded SET DEFAULT FALSE;
@@ -0,0 +1,61 @@
+DROP DATABASE IF EXISTS Achievements;
+CREATE DATABASE IF NOT EXISTS Achievements;
+USE Achievements;
+
+DROP TABLE IF EXISTS Badges;
+CREATE TABLE IF NOT EXISTS Badges (
+  Id int NOT NULL AUTO_INCREMENT,
+  Name varchar(32) NOT NULL,
+  Description varchar(128) NOT NULL,
+  Event enum('race', 'purchase', 'upgrade') NOT NULL,
+  Track varchar(32), -- NULL matches every track
+  MaxPosition int, -- NULL matches every position
+  FullyUpgraded boolean NOT NULL DEFAULT FALSE, -- only upgrades that max out a motorcycle
+  Threshold int NOT NULL DEFAULT 1, -- matching events needed to unlock
+  Reward int NOT NULL DEFAULT 0, -- money granted when unlocked
+  PRIMARY KEY (Id),
+  UNIQUE (Name),
+  CHECK (Threshold > 0),
+  CHECK (Reward >= 0)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Events;
+CREATE TABLE IF NOT EXISTS Events (
+  Id int NOT NULL AUTO_INCREMENT,
+  Username varchar(32) NOT NULL,
+  Event enum('race', 'purchase', 'upgrade') NOT NULL,
+  Track varchar(32),
+  Position int,
+  FullyUpgraded boolean NOT NULL DEFAULT FALSE,
+  Time timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Id),
+  INDEX (Username, Event)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Unlocked;
+CREATE TABLE IF NOT EXISTS Unlocked (
+  Username varchar(32) NOT NULL,
+  BadgeId int NOT NULL,
+  Time timestamp DEFAULT CURRENT_TIMESTAMP,
+  Rewarded boolean NOT NULL DEFAULT FALSE, -- set once the reward is paid
+  PRIMARY KEY (Username, BadgeId),
+  FOREIGN KEY (BadgeId) REFERENCES Badges(Id)
+) ENGINE=InnoDB;
+
+-- Events of a user counted towards each badge
+CREATE VIEW Progress AS
+SELECT E.Username, B.Id AS BadgeId, COUNT(*) AS Progress
+FROM Badges B
+INNER JOIN Events E ON E.Event=B.Event
+  AND (B.Track IS NULL OR E.Track=B.Track)
+  AND (B.MaxPosition IS NULL OR E.Position<=B.MaxPosition)
+  AND (NOT B.FullyUpgraded OR E.FullyUpgraded)
+GROUP BY E.Username, B.Id;
+
+INSERT INTO Badges (Name, Description, Event, Track, MaxPosition, FullyUpgraded, Threshold, Reward) VALUES
+  ("First Win", "Win a race", "race", NULL, 1, FALSE, 1, 50),
+  ("Mugello Podiums", "Finish on the podium 2 times at Mugello", "race", "Mugello", 3, FALSE, 2, 20),
+  ("Collector", "Buy 2 motorcycles or parts", "purchase", NULL, NULL, FALSE, 2, 30),
+  ("Max-Level Bike", "Upgrade every stat of a motorcycle to its max level", "upgrade", NULL, NULL, TRUE, 1, 200);
+INSERT INTO Events (Username, Event, Track, Position, FullyUpgraded) VALUES ("user", "race", "Mugello", 2, FALSE), ("user", "purchase", NULL, NULL, FALSE), ("winner", "race", "Mugello", 1, FALSE);
+INSERT INTO Unlocked (Username, BadgeId, Time) VALUES ("winner", 1, CURRENT_TIMESTAMP - INTERVAL 1 HOUR);
@@ -0,0 +1,228 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+
+	"achievements/telemetry"
+)
+
+// Kinds of event that count towards badges
+const (
+	RaceEvent     = "race"
+	PurchaseEvent = "purchase"
+	UpgradeEvent  = "upgrade"
+)
+
+type Event struct {
+	Type          string
+	Track         string // races only
+	Position      int    // races only
+	FullyUpgraded bool   // upgrades only
+}
+
+type Achievement struct {
+	Id          int
+	Name        string
+	Description string
+	Reward      int
+	Unlocked    bool
+	UnlockedAt  time.Time
+	Progress    int
+	Threshold   int
+}
+
+// Reward of an unlocked badge not paid yet
+type PendingReward struct {
+	Username string
+	BadgeId  int
+	Name     string
+	Reward   int
+}
+
+type AchievementsDB interface {
+	GetAchievements(ctx context.Context, username string) ([]*Achievement, error)
+	RecordEvent(ctx context.Context, username string, event *Event) ([]*Achievement, error)
+	GetPendingRewards(ctx context.Context) ([]*PendingReward, error)
+	ClaimReward(ctx context.Context, username string, BadgeId int) (bool, error)
+	ReleaseReward(ctx context.Context, username string, BadgeId int) error
+}
+
+// Implementation for an SQL Database
+type SQL_DB struct {
+	db *sql.DB
+}
+
+func NewSQL_DB(conn *sql.DB) *SQL_DB {
+	return &SQL_DB{db: conn}
+}
+
+func (s *SQL_DB) GetAchievements(ctx context.Context, username string) ([]*Achievement, error) {
+	// Retrieve every badge with the progress of the user
+
+	rows, err := s.db.QueryContext(ctx, `SELECT B.Id, B.Name, B.Description, B.Reward, U.Time, COALESCE(P.Progress, 0), B.Threshold
+		FROM Badges B
+		LEFT JOIN Unlocked U ON U.BadgeId=B.Id AND U.Username=?
+		LEFT JOIN Progress P ON P.BadgeId=B.Id AND P.Username=?
+		ORDER BY B.Id`, username, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetAchievements failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var achievements []*Achievement
+	for rows.Next() {
+		var a Achievement
+		var unlocked_at sql.NullTime
+		if err := rows.Scan(&a.Id, &a.Name, &a.Description, &a.Reward, &unlocked_at, &a.Progress, &a.Threshold); err != nil {
+			slog.ErrorContext(ctx, "GetAchievements failed", "error", err)
+			return nil, err
+		}
+		a.Unlocked, a.UnlockedAt = unlocked_at.Valid, unlocked_at.Time
+		achievements = append(achievements, &a)
+	}
+
+	return achievements, rows.Err()
+}
+
+func (s *SQL_DB) RecordEvent(ctx context.Context, username string, event *Event) ([]*Achievement, error) {
+	// Store the event and unlock the badges that reached their threshold, returning only the new ones
+
+	switch event.Type {
+	case RaceEvent, PurchaseEvent, UpgradeEvent:
+	default:
+		return nil, errors.New("unknown event type")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Achievements.RecordEvent"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "RecordEvent failed", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var track sql.NullString
+	var position sql.NullInt64
+	if event.Type == RaceEvent {
+		track = sql.NullString{String: event.Track, Valid: true}
+		position = sql.NullInt64{Int64: int64(event.Position), Valid: true}
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO Events (Username, Event, Track, Position, FullyUpgraded) VALUES (?, ?, ?, ?, ?)", username, event.Type, track, position, event.FullyUpgraded)
+	if err != nil {
+		slog.ErrorContext(ctx, "RecordEvent failed", "error", err)
+		return nil, err
+	}
+
+	rows, err := tx.QueryContext(ctx, `SELECT B.Id, B.Name, B.Description, B.Reward, P.Progress, B.Threshold
+		FROM Badges B
+		INNER JOIN Progress P ON P.BadgeId=B.Id AND P.Username=?
+		LEFT JOIN Unlocked U ON U.BadgeId=B.Id AND U.Username=?
+		WHERE B.Event=? AND U.BadgeId IS NULL AND P.Progress>=B.Threshold`, username, username, event.Type)
+	if err != nil {
+		slog.ErrorContext(ctx, "RecordEvent failed", "error", err)
+		return nil, err
+	}
+
+	var candidates []*Achievement
+	for rows.Next() {
+		var a Achievement
+		if err := rows.Scan(&a.Id, &a.Name, &a.Description, &a.Reward, &a.Progress, &a.Threshold); err != nil {
+			rows.Close()
+			slog.ErrorContext(ctx, "RecordEvent failed", "error", err)
+			return nil, err
+		}
+		candidates = append(candidates, &a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "RecordEvent failed", "error", err)
+		return nil, err
+	}
+
+	// A badge unlocked by a concurrent event is ignored, so its reward is reported only once
+	var unlocked []*Achievement
+	for _, a := range candidates {
+		res, err := tx.ExecContext(ctx, "INSERT IGNORE INTO Unlocked (Username, BadgeId, Rewarded) VALUES (?, ?, ?)", username, a.Id, a.Reward == 0)
+		if err != nil {
+			slog.ErrorContext(ctx, "RecordEvent failed", "error", err)
+			return nil, err
+		}
+		if n, _ := res.RowsAffected(); n == 1 {
+			a.Unlocked, a.UnlockedAt = true, time.Now()
+			unlocked = append(unlocked, a)
+		}
+	}
+
+	return unlocked, tx.Commit()
+}
+
+func (s *SQL_DB) GetPendingRewards(ctx context.Context) ([]*PendingReward, error) {
+	// Retrieve the unlocked badges whose reward has not been marked as paid.
+	// Rewards are paid right after the unlock, so only the older ones are pending
+
+	rows, err := s.db.QueryContext(ctx, `SELECT U.Username, B.Id, B.Name, B.Reward
+		FROM Unlocked U
+		INNER JOIN Badges B ON U.BadgeId=B.Id
+		WHERE NOT U.Rewarded AND U.Time < CURRENT_TIMESTAMP - INTERVAL 1 MINUTE
+		ORDER BY U.Time`)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetPendingRewards failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rewards []*PendingReward
+	for rows.Next() {
+		var r PendingReward
+		if err := rows.Scan(&r.Username, &r.BadgeId, &r.Name, &r.Reward); err != nil {
+			slog.ErrorContext(ctx, "GetPendingRewards failed", "error", err)
+			return nil, err
+		}
+		rewards = append(rewards, &r)
+	}
+
+	return rewards, rows.Err()
+}
+
+func (s *SQL_DB) ClaimReward(ctx context.Context, username string, BadgeId int) (bool, error) {
+	// Mark the reward of an unlocked badge as paid before paying it, only the caller changing the row pays it
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Achievements.ClaimReward"))
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "UPDATE Unlocked SET Rewarded=TRUE WHERE Username=? AND BadgeId=? AND NOT Rewarded", username, BadgeId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimReward failed", "error", err)
+		return false, err
+	}
+
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimReward failed", "error", err)
+		return false, err
+	}
+
+	return claimed == 1, nil
+}
+
+func (s *SQL_DB) ReleaseReward(ctx context.Context, username string, BadgeId int) error {
+	// Give back a claimed reward that could not be paid
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Achievements.ReleaseReward"))
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "UPDATE Unlocked SET Rewarded=FALSE WHERE Username=? AND BadgeId=?", username, BadgeId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReleaseReward failed", "error", err)
+	}
+
+	return err
+}
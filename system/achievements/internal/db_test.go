@@ -0,0 +1,127 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"testing"
+)
+
+func NewSQLConnection(t *testing.T) *sql.DB {
+	db, err := sql.Open("mysql", "root:admin@tcp(test_achievements_db:3306)/Achievements?parseTime=true")
+	if err != nil {
+		t.Errorf("failed to connect to db: %s", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Errorf("error pinging database: %v", err)
+	}
+
+	return db
+}
+
+func TestDBGetAchievements(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	achievements, err := db.GetAchievements(context.Background(), "winner")
+	if err != nil || len(achievements) != 4 {
+		t.Errorf("Unable to get achievements")
+		return
+	}
+
+	if !achievements[0].Unlocked || achievements[0].Name != "First Win" {
+		t.Errorf("First Win should be unlocked")
+	}
+
+	if achievements[1].Unlocked || achievements[1].Progress != 1 || achievements[1].Threshold != 2 {
+		t.Errorf("Wrong progress of Mugello Podiums: %d/%d", achievements[1].Progress, achievements[1].Threshold)
+	}
+}
+
+func TestDBRecordEvent(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// Second podium at Mugello, but not a win
+	unlocked, err := db.RecordEvent(context.Background(), "user", &Event{Type: RaceEvent, Track: "Mugello", Position: 3})
+	if err != nil {
+		t.Errorf("Unable to record event: %s", err)
+		return
+	}
+	if len(unlocked) != 1 || unlocked[0].Name != "Mugello Podiums" || unlocked[0].Reward != 20 {
+		t.Errorf("Mugello Podiums not unlocked")
+	}
+
+	// Podiums on another track do not count, and unlocked badges are not given twice
+	unlocked, err = db.RecordEvent(context.Background(), "user", &Event{Type: RaceEvent, Track: "Franciacorta", Position: 1})
+	if err != nil || len(unlocked) != 1 || unlocked[0].Name != "First Win" {
+		t.Errorf("First Win not unlocked")
+	}
+
+	unlocked, err = db.RecordEvent(context.Background(), "user", &Event{Type: RaceEvent, Track: "Mugello", Position: 1})
+	if err != nil || len(unlocked) != 0 {
+		t.Errorf("Badges unlocked twice")
+	}
+
+	// Only upgrades maxing out a motorcycle count
+	unlocked, err = db.RecordEvent(context.Background(), "user", &Event{Type: UpgradeEvent})
+	if err != nil || len(unlocked) != 0 {
+		t.Errorf("Max-Level Bike unlocked by a partial upgrade")
+	}
+
+	unlocked, err = db.RecordEvent(context.Background(), "user", &Event{Type: UpgradeEvent, FullyUpgraded: true})
+	if err != nil || len(unlocked) != 1 || unlocked[0].Name != "Max-Level Bike" {
+		t.Errorf("Max-Level Bike not unlocked")
+	}
+
+	if _, err = db.RecordEvent(context.Background(), "user", &Event{Type: "login"}); err == nil {
+		t.Errorf("Unknown event recorded")
+	}
+}
+
+func TestDBPendingRewards(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// Badges just unlocked are being paid, only the older ones are pending
+	rewards, err := db.GetPendingRewards(context.Background())
+	if err != nil || len(rewards) != 1 || rewards[0].Username != "winner" || rewards[0].Name != "First Win" || rewards[0].Reward != 50 {
+		t.Errorf("Wrong pending rewards")
+		return
+	}
+
+	claimed, err := db.ClaimReward(context.Background(), "winner", rewards[0].BadgeId)
+	if err != nil || !claimed {
+		t.Errorf("Unable to claim reward: %s", err)
+		return
+	}
+
+	// Another replica can not pay it again
+	claimed, err = db.ClaimReward(context.Background(), "winner", rewards[0].BadgeId)
+	if err != nil || claimed {
+		t.Errorf("Reward claimed twice")
+	}
+
+	pending, err := db.GetPendingRewards(context.Background())
+	if err != nil || len(pending) != 0 {
+		t.Errorf("Reward still pending after being claimed")
+	}
+
+	// A released reward is pending again
+	if err := db.ReleaseReward(context.Background(), "winner", rewards[0].BadgeId); err != nil {
+		t.Errorf("Unable to release reward: %s", err)
+	}
+
+	pending, err = db.GetPendingRewards(context.Background())
+	if err != nil || len(pending) != 1 {
+		t.Errorf("Reward not pending after being released")
+	}
+}
@@ -0,0 +1,125 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	pb "achievements/proto"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Server struct {
+	pb.UnimplementedAchievementsServer
+	pb.UnimplementedStillAliveServer
+	db AchievementsDB
+}
+
+func NewServer(conn AchievementsDB) *Server {
+	return &Server{db: conn}
+}
+
+var eventTypes = map[pb.AchievementEventType]string{
+	pb.AchievementEventType_RACE:     RaceEvent,
+	pb.AchievementEventType_PURCHASE: PurchaseEvent,
+	pb.AchievementEventType_UPGRADE:  UpgradeEvent,
+}
+
+func achievementToPb(a *Achievement) *pb.Achievement {
+	info := &pb.Achievement{
+		Id:          int32(a.Id),
+		Name:        a.Name,
+		Description: a.Description,
+		Reward:      int32(a.Reward),
+		Unlocked:    a.Unlocked,
+		Progress:    int32(a.Progress),
+		Threshold:   int32(a.Threshold),
+	}
+	if a.Unlocked {
+		info.UnlockedAt = timestamppb.New(a.UnlockedAt)
+	}
+	return info
+}
+
+func (s *Server) GetAchievements(in *pb.PlayerUsername, stream pb.Achievements_GetAchievementsServer) error {
+	achievements, err := s.db.GetAchievements(stream.Context(), in.Username)
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetAchievements failed", "error", err)
+		return err
+	}
+
+	slog.DebugContext(stream.Context(), "Retrieving achievements", "username", in.Username)
+
+	for _, v := range achievements {
+		stream.Send(achievementToPb(v))
+	}
+
+	return nil
+}
+
+func (s *Server) RecordEvent(in *pb.AchievementEvent, stream pb.Achievements_RecordEventServer) error {
+	event_type, ok := eventTypes[in.Type]
+	if !ok {
+		return errors.New("unknown achievement event")
+	}
+
+	event := &Event{
+		Type:          event_type,
+		Track:         in.TrackName,
+		Position:      int(in.Position),
+		FullyUpgraded: in.FullyUpgraded,
+	}
+
+	unlocked, err := s.db.RecordEvent(stream.Context(), in.Username, event)
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "RecordEvent failed", "error", err)
+		return err
+	}
+
+	for _, v := range unlocked {
+		slog.InfoContext(stream.Context(), "Badge unlocked", "badge", v.Name, "username", in.Username)
+		stream.Send(achievementToPb(v))
+	}
+
+	return nil
+}
+
+func (s *Server) GetPendingRewards(_ *emptypb.Empty, stream pb.Achievements_GetPendingRewardsServer) error {
+	rewards, err := s.db.GetPendingRewards(stream.Context())
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetPendingRewards failed", "error", err)
+		return err
+	}
+
+	for _, v := range rewards {
+		stream.Send(&pb.PendingReward{Username: v.Username, BadgeId: int32(v.BadgeId), Name: v.Name, Reward: int32(v.Reward)})
+	}
+
+	return nil
+}
+
+func (s *Server) ClaimReward(ctx context.Context, in *pb.PendingReward) (*pb.RewardClaim, error) {
+	claimed, err := s.db.ClaimReward(ctx, in.Username, int(in.BadgeId))
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimReward failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Claiming badge reward", "badge_id", in.BadgeId, "username", in.Username, "claimed", claimed)
+
+	return &pb.RewardClaim{Claimed: claimed}, nil
+}
+
+func (s *Server) ReleaseReward(ctx context.Context, in *pb.PendingReward) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Releasing badge reward", "badge_id", in.BadgeId, "username", in.Username)
+
+	return nil, s.db.ReleaseReward(ctx, in.Username, int(in.BadgeId))
+}
+
+func (s *Server) StillAlive(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, nil
+}
@@ -0,0 +1,13 @@
+-- Migration of an existing Auth database to friends lists.
+USE Auth;
+
+CREATE TABLE IF NOT EXISTS Friendships (
+  Requester varchar(32) NOT NULL,
+  Addressee varchar(32) NOT NULL,
+  Status enum('pending', 'accepted') NOT NULL DEFAULT 'pending',
+  Since timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, -- request time, then acceptance time
+  PRIMARY KEY (Requester, Addressee),
+  FOREIGN KEY (Requester) REFERENCES Users(Username),
+  FOREIGN KEY (Addressee) REFERENCES Users(Username),
+  CHECK (Requester <> Addressee)
+) ENGINE=InnoDB;
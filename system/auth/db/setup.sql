@@ -11,6 +11,18 @@ CREATE TABLE IF NOT EXISTS Users (
   PRIMARY KEY (Username)
 ) ENGINE=InnoDB;
 
+DROP TABLE IF EXISTS Friendships;
+CREATE TABLE IF NOT EXISTS Friendships (
+  Requester varchar(32) NOT NULL,
+  Addressee varchar(32) NOT NULL,
+  Status enum('pending', 'accepted') NOT NULL DEFAULT 'pending',
+  Since timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP, -- request time, then acceptance time
+  PRIMARY KEY (Requester, Addressee),
+  FOREIGN KEY (Requester) REFERENCES Users(Username),
+  FOREIGN KEY (Addressee) REFERENCES Users(Username),
+  CHECK (Requester <> Addressee)
+) ENGINE=InnoDB;
+
 INSERT INTO Users VALUES ("Lorenzo", "12345", "lorenzo@gmail.com", "123456789");
 INSERT INTO Users VALUES ("Matteo", "abcde", "matteo@gmail.com", "123456789");
-
+INSERT INTO Friendships (Requester, Addressee, Status) VALUES ("Lorenzo", "Matteo", "accepted");
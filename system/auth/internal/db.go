@@ -1,14 +1,30 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
+
+	"auth/telemetry"
 )
 
 // Interface with the Auth Database
 type AuthDB interface {
-	Login(username string, password string) (bool, error)
-	Register(username string, password string, email string, phone string) (bool, error)
+	Login(ctx context.Context, username string, password string) (bool, error)
+	Register(ctx context.Context, username string, password string, email string, phone string) (bool, error)
+	SendFriendRequest(ctx context.Context, username string, friend string) error
+	AcceptFriendRequest(ctx context.Context, username string, friend string) error
+	DeclineFriendRequest(ctx context.Context, username string, friend string) error
+	RemoveFriend(ctx context.Context, username string, friend string) error
+	GetFriends(ctx context.Context, username string) (*Friends, error)
+}
+
+// Friends of a user and friend requests still waiting for an answer
+type Friends struct {
+	friends  []string
+	received []string
+	sent     []string
 }
 
 // Implementation for an SQL Database
@@ -20,40 +36,40 @@ func NewSQL_DB(conn *sql.DB) *SQL_DB {
 	return &SQL_DB{db: conn}
 }
 
-func (s *SQL_DB) Login(username string, password string) (bool, error) {
+func (s *SQL_DB) Login(ctx context.Context, username string, password string) (bool, error) {
 	// Perform Login inside the DB
 	// if credentials are not correct no row will be retrieved resulting in an erroneous login action
 
-	stmt, err := s.db.Prepare("SELECT Username FROM Users WHERE Username=? AND Password=?")
+	stmt, err := s.db.PrepareContext(ctx, "SELECT Username FROM Users WHERE Username=? AND Password=?")
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Login failed", "error", err)
 		return false, err
 	}
 
 	var temp string
-	err = stmt.QueryRow(username, password).Scan(&temp)
+	err = stmt.QueryRowContext(ctx, username, password).Scan(&temp)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Login failed", "error", err)
 		return false, err
 	}
 
 	return true, nil
 }
 
-func (s *SQL_DB) Register(username string, password string, email string, phone string) (bool, error) {
+func (s *SQL_DB) Register(ctx context.Context, username string, password string, email string, phone string) (bool, error) {
 	// Perform Registration inside the DB
 	// if username is already present the registration fails, the error is picked when executing the statement and also by checking the affected rows
 
-	stmt, err := s.db.Prepare("INSERT INTO Users VALUES (?, ?, ?, ?)")
+	stmt, err := s.db.PrepareContext(ctx, "INSERT INTO Users VALUES (?, ?, ?, ?)")
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Register failed", "error", err)
 		return false, err
 	}
 	defer stmt.Close()
 
-	res, err := stmt.Exec(username, password, email, phone)
+	res, err := stmt.ExecContext(ctx, username, password, email, phone)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Register failed", "error", err)
 		return false, err
 	}
 
@@ -61,3 +77,150 @@ func (s *SQL_DB) Register(username string, password string, email string, phone
 
 	return rows_affected != 0, err
 }
+
+func (s *SQL_DB) SendFriendRequest(ctx context.Context, username string, friend string) error {
+	// Ask friend to become a friend of username
+	// if friend already asked the same to username the request is accepted instead
+
+	if username == friend {
+		return errors.New("unable to befriend yourself")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Authentication.SendFriendRequest"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "SendFriendRequest failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var found int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Users WHERE Username=?", friend).Scan(&found)
+	if err != nil {
+		slog.ErrorContext(ctx, "SendFriendRequest failed", "error", err)
+		return err
+	}
+	if found == 0 {
+		return errors.New("user not found")
+	}
+
+	var requester, status string
+	err = tx.QueryRowContext(ctx, "SELECT Requester, Status FROM Friendships WHERE (Requester=? AND Addressee=?) OR (Requester=? AND Addressee=?) FOR UPDATE",
+		username, friend, friend, username).Scan(&requester, &status)
+	switch {
+	case err == sql.ErrNoRows:
+		_, err = tx.ExecContext(ctx, "INSERT INTO Friendships (Requester, Addressee) VALUES (?, ?)", username, friend)
+	case err != nil:
+	case status == "accepted":
+		return errors.New("already friends")
+	case requester == username:
+		return errors.New("friend request already sent")
+	default:
+		_, err = tx.ExecContext(ctx, "UPDATE Friendships SET Status='accepted', Since=CURRENT_TIMESTAMP WHERE Requester=? AND Addressee=?", friend, username)
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "SendFriendRequest failed", "error", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) AcceptFriendRequest(ctx context.Context, username string, friend string) error {
+	// Accept the pending request sent by friend to username
+
+	res, err := s.db.ExecContext(ctx, "UPDATE Friendships SET Status='accepted', Since=CURRENT_TIMESTAMP WHERE Requester=? AND Addressee=? AND Status='pending'", friend, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "AcceptFriendRequest failed", "error", err)
+		return err
+	}
+
+	rows_affected, err := res.RowsAffected()
+	if err != nil {
+		slog.ErrorContext(ctx, "AcceptFriendRequest failed", "error", err)
+		return err
+	}
+	if rows_affected == 0 {
+		return errors.New("friend request not found")
+	}
+
+	return nil
+}
+
+func (s *SQL_DB) DeclineFriendRequest(ctx context.Context, username string, friend string) error {
+	// Delete the pending request sent by friend to username
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM Friendships WHERE Requester=? AND Addressee=? AND Status='pending'", friend, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeclineFriendRequest failed", "error", err)
+		return err
+	}
+
+	rows_affected, err := res.RowsAffected()
+	if err != nil {
+		slog.ErrorContext(ctx, "DeclineFriendRequest failed", "error", err)
+		return err
+	}
+	if rows_affected == 0 {
+		return errors.New("friend request not found")
+	}
+
+	return nil
+}
+
+func (s *SQL_DB) RemoveFriend(ctx context.Context, username string, friend string) error {
+	// Delete the friendship whoever asked for it
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM Friendships WHERE ((Requester=? AND Addressee=?) OR (Requester=? AND Addressee=?)) AND Status='accepted'",
+		username, friend, friend, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "RemoveFriend failed", "error", err)
+		return err
+	}
+
+	rows_affected, err := res.RowsAffected()
+	if err != nil {
+		slog.ErrorContext(ctx, "RemoveFriend failed", "error", err)
+		return err
+	}
+	if rows_affected == 0 {
+		return errors.New("friend not found")
+	}
+
+	return nil
+}
+
+func (s *SQL_DB) GetFriends(ctx context.Context, username string) (*Friends, error) {
+	// Retrieve friends and pending requests of username in both directions
+
+	rows, err := s.db.QueryContext(ctx, "SELECT Requester, Addressee, Status FROM Friendships WHERE Requester=? OR Addressee=? ORDER BY Since DESC", username, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetFriends failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	friends := &Friends{}
+	for rows.Next() {
+		var requester, addressee, status string
+		if err := rows.Scan(&requester, &addressee, &status); err != nil {
+			slog.ErrorContext(ctx, "GetFriends failed", "error", err)
+			return nil, err
+		}
+
+		switch {
+		case status == "accepted" && requester == username:
+			friends.friends = append(friends.friends, addressee)
+		case status == "accepted":
+			friends.friends = append(friends.friends, requester)
+		case requester == username:
+			friends.sent = append(friends.sent, addressee)
+		default:
+			friends.received = append(friends.received, requester)
+		}
+	}
+
+	return friends, rows.Err()
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
 
 	_ "github.com/go-sql-driver/mysql"
@@ -25,7 +26,7 @@ func TestDBLoginCorrect(t *testing.T) {
 	defer conn.Close()
 
 	db := NewSQL_DB(conn)
-	res, err := db.Login("test", "12345")
+	res, err := db.Login(context.Background(), "test", "12345")
 
 	if res && err == nil {
 		return
@@ -40,7 +41,7 @@ func TestDBLoginWrongPassword(t *testing.T) {
 
 	db := NewSQL_DB(conn)
 
-	res, _ := db.Login("test", "67890")
+	res, _ := db.Login(context.Background(), "test", "67890")
 
 	if !res {
 		return
@@ -55,7 +56,7 @@ func TestDBLoginUserNotRegistered(t *testing.T) {
 
 	db := NewSQL_DB(conn)
 
-	res, _ := db.Login("not_registered", "00000")
+	res, _ := db.Login(context.Background(), "not_registered", "00000")
 
 	if !res {
 		return
@@ -70,13 +71,13 @@ func TestDBRegisterCorrect(t *testing.T) {
 
 	db := NewSQL_DB(conn)
 
-	res, err := db.Register("user", "password", "user@test.com", "123456789")
+	res, err := db.Register(context.Background(), "user", "password", "user@test.com", "123456789")
 
 	if !res || err != nil {
 		t.Errorf("Register not accepted but should be (correct details provided)")
 	}
 
-	res, err = db.Login("user", "password")
+	res, err = db.Login(context.Background(), "user", "password")
 
 	if res && err == nil {
 		return
@@ -92,13 +93,13 @@ func TestDBRegisterUserAlreadyRegistered(t *testing.T) {
 
 	db := NewSQL_DB(conn)
 
-	res, _ := db.Register("foo", "123", "foo@test.com", "123456789")
+	res, _ := db.Register(context.Background(), "foo", "123", "foo@test.com", "123456789")
 
 	if res {
 		t.Errorf("Register accepted but should not be (user already registered)")
 	}
 
-	res, _ = db.Login("foo", "123")
+	res, _ = db.Login(context.Background(), "foo", "123")
 
 	if !res {
 		return
@@ -106,3 +107,67 @@ func TestDBRegisterUserAlreadyRegistered(t *testing.T) {
 
 	t.Errorf("After Registering Login accepted but should not be (wrong password provided)")
 }
+
+func TestDBSendFriendRequest(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	if err := db.SendFriendRequest(context.Background(), "foo", "baz"); err != nil {
+		t.Errorf("Friend request not sent but should be: %s", err)
+	}
+	if err := db.SendFriendRequest(context.Background(), "foo", "baz"); err == nil {
+		t.Errorf("Friend request sent twice")
+	}
+	if err := db.SendFriendRequest(context.Background(), "foo", "test"); err == nil {
+		t.Errorf("Friend request sent to a friend")
+	}
+	if err := db.SendFriendRequest(context.Background(), "foo", "nobody"); err == nil {
+		t.Errorf("Friend request sent to a user not registered")
+	}
+
+	friends, err := db.GetFriends(context.Background(), "baz")
+	if err != nil || len(friends.received) != 1 || friends.received[0] != "foo" {
+		t.Errorf("Friend request not received")
+	}
+
+	// Asking back accepts the request
+	if err := db.SendFriendRequest(context.Background(), "baz", "foo"); err != nil {
+		t.Errorf("Friend request not accepted: %s", err)
+	}
+	friends, err = db.GetFriends(context.Background(), "foo")
+	if err != nil || len(friends.friends) != 2 || len(friends.sent) != 0 {
+		t.Errorf("Friend not added after crossed requests")
+	}
+}
+
+func TestDBAnswerFriendRequest(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// Only the addressee can answer
+	if err := db.AcceptFriendRequest(context.Background(), "bar", "test"); err == nil {
+		t.Errorf("Friend request accepted by the requester")
+	}
+	if err := db.AcceptFriendRequest(context.Background(), "test", "bar"); err != nil {
+		t.Errorf("Friend request not accepted: %s", err)
+	}
+	if err := db.DeclineFriendRequest(context.Background(), "test", "bar"); err == nil {
+		t.Errorf("Accepted friend request declined")
+	}
+
+	friends, err := db.GetFriends(context.Background(), "test")
+	if err != nil || len(friends.friends) != 2 || len(friends.received) != 0 {
+		t.Errorf("Unable to get friends")
+	}
+
+	if err := db.RemoveFriend(context.Background(), "test", "bar"); err != nil {
+		t.Errorf("Friend not removed: %s", err)
+	}
+	if err := db.RemoveFriend(context.Background(), "test", "bar"); err == nil {
+		t.Errorf("Friend removed twice")
+	}
+}
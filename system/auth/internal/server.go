@@ -2,7 +2,7 @@ package internal
 
 import (
 	"context"
-	"log"
+	"log/slog"
 
 	pb "auth/proto"
 
@@ -22,8 +22,8 @@ func NewServer(conn AuthDB) *Server {
 func (s *Server) Login(ctx context.Context, in *pb.PlayerCredentials) (*pb.AuthResult, error) {
 	// Procedure to perform Login in the system
 
-	res, err := s.db.Login(in.Username, in.Password)
-	log.Printf("Login (%s:%s) with result %t", in.Username, in.Password, res)
+	res, err := s.db.Login(ctx, in.Username, in.Password)
+	slog.InfoContext(ctx, "Login", "username", in.Username, "result", res)
 
 	return &pb.AuthResult{Result: res}, err
 }
@@ -31,8 +31,8 @@ func (s *Server) Login(ctx context.Context, in *pb.PlayerCredentials) (*pb.AuthR
 func (s *Server) Register(ctx context.Context, in *pb.PlayerDetails) (*pb.AuthResult, error) {
 	// Procedure to perform Registration in the system
 
-	res, err := s.db.Register(in.Username, in.Password, in.Email, in.Phone)
-	log.Printf("Register (%s:%s:%s:%s) with result %t", in.Username, in.Password, in.Email, in.Phone, res)
+	res, err := s.db.Register(ctx, in.Username, in.Password, in.Email, in.Phone)
+	slog.InfoContext(ctx, "Register", "username", in.Username, "result", res)
 
 	return &pb.AuthResult{Result: res}, err
 }
@@ -40,3 +40,56 @@ func (s *Server) Register(ctx context.Context, in *pb.PlayerDetails) (*pb.AuthRe
 func (s *Server) StillAlive(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
 	return nil, nil
 }
+
+func (s *Server) SendFriendRequest(ctx context.Context, in *pb.FriendRequest) (*emptypb.Empty, error) {
+	err := s.db.SendFriendRequest(ctx, in.Username, in.Friend)
+	if err != nil {
+		slog.ErrorContext(ctx, "SendFriendRequest failed", "error", err)
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Friend request sent", "username", in.Username, "friend", in.Friend)
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) AcceptFriendRequest(ctx context.Context, in *pb.FriendRequest) (*emptypb.Empty, error) {
+	err := s.db.AcceptFriendRequest(ctx, in.Username, in.Friend)
+	if err != nil {
+		slog.ErrorContext(ctx, "AcceptFriendRequest failed", "error", err)
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Friend request accepted", "username", in.Username, "friend", in.Friend)
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) DeclineFriendRequest(ctx context.Context, in *pb.FriendRequest) (*emptypb.Empty, error) {
+	err := s.db.DeclineFriendRequest(ctx, in.Username, in.Friend)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeclineFriendRequest failed", "error", err)
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Friend request declined", "username", in.Username, "friend", in.Friend)
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) RemoveFriend(ctx context.Context, in *pb.FriendRequest) (*emptypb.Empty, error) {
+	err := s.db.RemoveFriend(ctx, in.Username, in.Friend)
+	if err != nil {
+		slog.ErrorContext(ctx, "RemoveFriend failed", "error", err)
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Friend removed", "username", in.Username, "friend", in.Friend)
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) GetFriends(ctx context.Context, in *pb.PlayerUsername) (*pb.Friends, error) {
+	friends, err := s.db.GetFriends(ctx, in.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &pb.Friends{Friends: friends.friends, Received: friends.received, Sent: friends.sent}, nil
+}
@@ -23,7 +23,7 @@ func NewMockDB(creds map[string]string) *MockDB {
 	return &MockDB{credentials: creds}
 }
 
-func (m *MockDB) Login(username string, password string) (bool, error) {
+func (m *MockDB) Login(_ context.Context, username string, password string) (bool, error) {
 	pass, ok := m.credentials[username]
 
 	if !ok {
@@ -33,7 +33,7 @@ func (m *MockDB) Login(username string, password string) (bool, error) {
 	return password == pass, nil
 }
 
-func (m *MockDB) Register(username string, password string, email string, phone string) (bool, error) {
+func (m *MockDB) Register(_ context.Context, username string, password string, email string, phone string) (bool, error) {
 	_, ok := m.credentials[username]
 
 	if ok {
@@ -44,6 +44,26 @@ func (m *MockDB) Register(username string, password string, email string, phone
 	return true, nil
 }
 
+func (m *MockDB) SendFriendRequest(_ context.Context, username string, friend string) error {
+	return errors.New("not implemented")
+}
+
+func (m *MockDB) AcceptFriendRequest(_ context.Context, username string, friend string) error {
+	return errors.New("not implemented")
+}
+
+func (m *MockDB) DeclineFriendRequest(_ context.Context, username string, friend string) error {
+	return errors.New("not implemented")
+}
+
+func (m *MockDB) RemoveFriend(_ context.Context, username string, friend string) error {
+	return errors.New("not implemented")
+}
+
+func (m *MockDB) GetFriends(_ context.Context, username string) (*Friends, error) {
+	return nil, errors.New("not implemented")
+}
+
 func TestLoginCorrect(t *testing.T) {
 	server := NewServer(NewMockDB(nil))
 	res, _ := server.Login(context.TODO(), &pb.PlayerCredentials{Username: "test", Password: "12345"})
@@ -2,17 +2,18 @@ package main
 
 import (
 	"context"
-	"database/sql"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"os"
 	"time"
 
 	"auth/internal"
+	"auth/telemetry"
 
 	pb "auth/proto"
 
@@ -21,15 +22,25 @@ import (
 )
 
 func main() {
+	// Structured log of the service, level set by LOG_LEVEL
+	telemetry.InitLogging("auth")
+
 	// Listener for Service
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", os.Getenv("SERVICE_PORT")))
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	log.Printf("server listening at %v", lis.Addr())
+	slog.Info("server listening", "address", lis.Addr().String())
+
+	// Metrics of the service for Prometheus
+	go telemetry.ServeMetrics(os.Getenv("METRICS_PORT"))
+
+	// Traces of the service, exported as set by TRACING_EXPORTER
+	shutdown := telemetry.InitTracing("auth")
+	defer shutdown(context.Background())
 
 	// Database connection for Auth DB
-	db, err := sql.Open("mysql", "root:admin@tcp(auth_db:3306)/Auth")
+	db, err := telemetry.OpenDB(mysql.MySQLDriver{}, "root:admin@tcp(auth_db:3306)/Auth")
 	if err != nil {
 		log.Fatalf("failed to connect to db: %s", err)
 	}
@@ -39,7 +50,7 @@ func main() {
 	}
 
 	// Creating gRPC server
-	s := grpc.NewServer()
+	s := grpc.NewServer(telemetry.ServerOption())
 	server := internal.NewServer(internal.NewSQL_DB(db))
 	pb.RegisterAuthenticationServer(s, server)
 	pb.RegisterStillAliveServer(s, server)
@@ -54,27 +65,30 @@ func main() {
 }
 
 func registerToOrchestrator() {
-	log.Printf("Trying to connect to Orchestrator")
-	conn, err := grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	slog.Info("Trying to connect to Orchestrator")
+	conn, err := grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()), telemetry.DialOption())
 	for err != nil {
 		// Wait if unable to connect to orchestrator
-		log.Print(err.Error())
+		slog.Warn("unable to connect to Orchestrator", "error", err)
 		time.Sleep(500 * time.Millisecond)
-		conn, err = grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		conn, err = grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()), telemetry.DialOption())
 	}
 	defer conn.Close()
 
 	c := pb.NewOrchestratorClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
 
-	// Call to registration of the service
-	_, err = c.RegisterAuth(ctx, nil)
-	for err != nil {
+	// Call to registration of the service, every attempt with its own deadline
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), telemetry.Deadline("Orchestrator.RegisterAuth"))
+		_, err := c.RegisterAuth(ctx, nil)
+		cancel()
+		if err == nil {
+			break
+		}
+
 		// Wait if errors during registration
-		log.Print(err.Error())
+		slog.Warn("registration to Orchestrator failed", "error", err)
 		time.Sleep(500 * time.Millisecond)
-		_, err = c.RegisterAuth(ctx, nil)
 	}
-	log.Printf("Registered to Orchestrator")
+	slog.Info("Registered to Orchestrator")
 }
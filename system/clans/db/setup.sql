@@ -0,0 +1,61 @@
+DROP DATABASE IF EXISTS Clans;
+CREATE DATABASE IF NOT EXISTS Clans;
+USE Clans;
+
+DROP TABLE IF EXISTS Clans;
+CREATE TABLE IF NOT EXISTS Clans (
+  Id int NOT NULL AUTO_INCREMENT,
+  Name varchar(32) NOT NULL,
+  Tag varchar(4) NOT NULL, -- shown next to the username of the members
+  Treasury int NOT NULL DEFAULT 0, -- funded by the members from their Garage wallets
+  Points int NOT NULL DEFAULT 0, -- race results of the members while in the clan
+  Races int NOT NULL DEFAULT 0,
+  Wins int NOT NULL DEFAULT 0,
+  CreatedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Id),
+  UNIQUE (Name),
+  UNIQUE (Tag),
+  CHECK (Treasury >= 0)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Members;
+CREATE TABLE IF NOT EXISTS Members (
+  Username varchar(32) NOT NULL, -- a player belongs to one clan at most
+  ClanId int NOT NULL,
+  Role enum('owner', 'member') NOT NULL DEFAULT 'member',
+  Points int NOT NULL DEFAULT 0, -- contribution to the clan results
+  Races int NOT NULL DEFAULT 0,
+  Wins int NOT NULL DEFAULT 0,
+  JoinedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Username),
+  FOREIGN KEY (ClanId) REFERENCES Clans(Id)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Invites;
+CREATE TABLE IF NOT EXISTS Invites (
+  ClanId int NOT NULL,
+  Username varchar(32) NOT NULL,
+  InvitedBy varchar(32) NOT NULL,
+  CreatedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (ClanId, Username),
+  FOREIGN KEY (ClanId) REFERENCES Clans(Id)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Transactions;
+CREATE TABLE IF NOT EXISTS Transactions (
+  Id int NOT NULL AUTO_INCREMENT,
+  ClanId int NOT NULL,
+  Username varchar(32) NOT NULL, -- member depositing or receiving the money
+  Amount int NOT NULL, -- positive for deposits, negative for withdrawals
+  Time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Id),
+  FOREIGN KEY (ClanId) REFERENCES Clans(Id)
+) ENGINE=InnoDB;
+
+CREATE VIEW ClanStandings AS
+SELECT C.Id, C.Name, C.Tag, C.Treasury, C.Points, C.Races, C.Wins, (SELECT COUNT(*) FROM Members M WHERE M.ClanId=C.Id) AS Members, RANK() OVER (ORDER BY C.Points DESC, C.Wins DESC) AS Position
+FROM Clans C;
+
+INSERT INTO Clans (Id, Name, Tag, Treasury, Points, Races, Wins) VALUES (1, "Tavullia Riders", "VR46", 500, 120, 12, 3);
+INSERT INTO Members (Username, ClanId, Role, Points, Races, Wins) VALUES ("Lorenzo", 1, "owner", 80, 7, 2), ("Matteo", 1, "member", 40, 5, 1);
+INSERT INTO Transactions (ClanId, Username, Amount) VALUES (1, "Lorenzo", 500);
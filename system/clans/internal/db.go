@@ -0,0 +1,676 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+
+	"clans/telemetry"
+)
+
+// Roles of the members, the owner manages invites and the treasury
+const (
+	OwnerRole  = "owner"
+	MemberRole = "member"
+)
+
+type Clan struct {
+	Id       int
+	Name     string
+	Tag      string
+	Treasury int
+	Points   int
+	Races    int
+	Wins     int
+	Members  int
+	Position int // in the clan leaderboard
+}
+
+type Member struct {
+	Username string
+	Role     string
+	Points   int // earned for the clan since joining
+	Races    int
+	Wins     int
+	JoinedAt time.Time
+}
+
+// Deposit of a member into the treasury or withdrawal paid to a member
+type Transaction struct {
+	Username string
+	Amount   int // negative for withdrawals
+	Time     time.Time
+}
+
+// Result of a member in a race, added to the clan standings
+type RaceResult struct {
+	Username string
+	Points   int
+	Won      bool
+}
+
+// Tags are short uppercase codes shown on the leaderboard rows
+var tagPattern = regexp.MustCompile(`^[A-Z0-9]{2,4}$`)
+
+const (
+	minClanNameLength = 3
+	maxClanNameLength = 32
+)
+
+// Rows of the clan leaderboard, players whose tags can be asked at once and transactions shown on the clan page
+const (
+	clanLeaderboardLimit = 50
+	maxTagsQuery         = 100
+	transactionsLimit    = 20
+)
+
+type ClansDB interface {
+	CreateClan(ctx context.Context, owner string, name string, tag string) (id int, e error)
+	GetClan(ctx context.Context, id int) (*Clan, error)
+	GetPlayerClan(ctx context.Context, username string) (*Clan, error)
+	GetMembers(ctx context.Context, id int) ([]*Member, error)
+	GetTransactions(ctx context.Context, id int) ([]*Transaction, error)
+	GetClanLeaderboard(ctx context.Context) ([]*Clan, error)
+	InviteToClan(ctx context.Context, inviter string, username string) error
+	GetInvites(ctx context.Context, username string) ([]*Clan, error)
+	AcceptInvite(ctx context.Context, username string, id int) error
+	DeclineInvite(ctx context.Context, username string, id int) error
+	LeaveClan(ctx context.Context, username string) error
+	Deposit(ctx context.Context, username string, amount int) error
+	Withdraw(ctx context.Context, owner string, member string, amount int) (TransactionId int, e error)
+	CancelWithdrawal(ctx context.Context, TransactionId int) error
+	GetClanTags(ctx context.Context, usernames []string) (map[string]string, error)
+	RecordRaceResults(ctx context.Context, results []RaceResult) error
+}
+
+// Implementation for an SQL Database
+type SQL_DB struct {
+	db *sql.DB
+}
+
+func NewSQL_DB(conn *sql.DB) *SQL_DB {
+	return &SQL_DB{db: conn}
+}
+
+// Clan of the player inside a transaction, error if not in a clan
+func memberClan(ctx context.Context, tx *sql.Tx, username string) (id int, role string, e error) {
+	e = tx.QueryRowContext(ctx, "SELECT ClanId, Role FROM Members WHERE Username=?", username).Scan(&id, &role)
+	if e == sql.ErrNoRows {
+		return -1, "", errors.New("player not in a clan")
+	} else if e != nil {
+		slog.ErrorContext(ctx, "memberClan failed", "error", e)
+	}
+	return id, role, e
+}
+
+func (s *SQL_DB) CreateClan(ctx context.Context, owner string, name string, tag string) (id int, e error) {
+	name = strings.TrimSpace(name)
+	tag = strings.ToUpper(strings.TrimSpace(tag))
+	if len(name) < minClanNameLength || len(name) > maxClanNameLength {
+		return -1, errors.New("invalid clan name")
+	}
+	if !tagPattern.MatchString(tag) {
+		return -1, errors.New("clan tag must be 2 to 4 letters or digits")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.CreateClan"))
+	defer cancel()
+
+	// Begin transaction to create the clan
+	// steps: check the owner is not in a clan, check name and tag are free, insert clan and its owner
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateClan failed", "error", err)
+		return -1, err
+	}
+	defer tx.Rollback()
+
+	var found int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Members WHERE Username=?", owner).Scan(&found); err != nil {
+		slog.ErrorContext(ctx, "CreateClan failed", "error", err)
+		return -1, err
+	}
+	if found != 0 {
+		return -1, errors.New("already in a clan")
+	}
+
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Clans WHERE Name=? OR Tag=?", name, tag).Scan(&found); err != nil {
+		slog.ErrorContext(ctx, "CreateClan failed", "error", err)
+		return -1, err
+	}
+	if found != 0 {
+		return -1, errors.New("clan name or tag already taken")
+	}
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO Clans (Name, Tag) VALUES (?, ?)", name, tag)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateClan failed", "error", err)
+		return -1, err
+	}
+	last_id, err := res.LastInsertId()
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateClan failed", "error", err)
+		return -1, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO Members (Username, ClanId, Role) VALUES (?, ?, ?)", owner, last_id, OwnerRole)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateClan failed", "error", err)
+		return -1, err
+	}
+
+	// Invites are pointless once in a clan
+	if _, err = tx.ExecContext(ctx, "DELETE FROM Invites WHERE Username=?", owner); err != nil {
+		slog.ErrorContext(ctx, "CreateClan failed", "error", err)
+		return -1, err
+	}
+
+	return int(last_id), tx.Commit()
+}
+
+const clanQuery = "SELECT Id, Name, Tag, Treasury, Points, Races, Wins, Members, Position FROM ClanStandings"
+
+func scanClan(row interface{ Scan(...any) error }) (*Clan, error) {
+	var c Clan
+	err := row.Scan(&c.Id, &c.Name, &c.Tag, &c.Treasury, &c.Points, &c.Races, &c.Wins, &c.Members, &c.Position)
+	return &c, err
+}
+
+func (s *SQL_DB) queryClans(ctx context.Context, query string, args ...any) ([]*Clan, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "queryClans failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var clans []*Clan
+	for rows.Next() {
+		c, err := scanClan(rows)
+		if err != nil {
+			slog.ErrorContext(ctx, "queryClans failed", "error", err)
+			return nil, err
+		}
+		clans = append(clans, c)
+	}
+
+	return clans, rows.Err()
+}
+
+func (s *SQL_DB) GetClan(ctx context.Context, id int) (*Clan, error) {
+	c, err := scanClan(s.db.QueryRowContext(ctx, clanQuery+" WHERE Id=?", id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("clan not found")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "GetClan failed", "error", err)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (s *SQL_DB) GetPlayerClan(ctx context.Context, username string) (*Clan, error) {
+	// Retrieve the clan of the player
+
+	var id int
+	err := s.db.QueryRowContext(ctx, "SELECT ClanId FROM Members WHERE Username=?", username).Scan(&id)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("player not in a clan")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "GetPlayerClan failed", "error", err)
+		return nil, err
+	}
+
+	return s.GetClan(ctx, id)
+}
+
+func (s *SQL_DB) GetMembers(ctx context.Context, id int) ([]*Member, error) {
+	// Retrieve members of the clan, owner first then by contribution
+
+	rows, err := s.db.QueryContext(ctx, "SELECT Username, Role, Points, Races, Wins, JoinedAt FROM Members WHERE ClanId=? ORDER BY Role='owner' DESC, Points DESC, Username", id)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetMembers failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*Member
+	for rows.Next() {
+		var m Member
+		if err := rows.Scan(&m.Username, &m.Role, &m.Points, &m.Races, &m.Wins, &m.JoinedAt); err != nil {
+			slog.ErrorContext(ctx, "GetMembers failed", "error", err)
+			return nil, err
+		}
+		members = append(members, &m)
+	}
+
+	return members, rows.Err()
+}
+
+func (s *SQL_DB) GetTransactions(ctx context.Context, id int) ([]*Transaction, error) {
+	// Retrieve the latest movements of the treasury
+
+	rows, err := s.db.QueryContext(ctx, "SELECT Username, Amount, Time FROM Transactions WHERE ClanId=? ORDER BY Id DESC LIMIT ?", id, transactionsLimit)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetTransactions failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var transactions []*Transaction
+	for rows.Next() {
+		var t Transaction
+		if err := rows.Scan(&t.Username, &t.Amount, &t.Time); err != nil {
+			slog.ErrorContext(ctx, "GetTransactions failed", "error", err)
+			return nil, err
+		}
+		transactions = append(transactions, &t)
+	}
+
+	return transactions, rows.Err()
+}
+
+func (s *SQL_DB) GetClanLeaderboard(ctx context.Context) ([]*Clan, error) {
+	// Retrieve clans by points of their members, wins break ties
+
+	return s.queryClans(ctx, clanQuery+" ORDER BY Position, Name LIMIT ?", clanLeaderboardLimit)
+}
+
+func (s *SQL_DB) InviteToClan(ctx context.Context, inviter string, username string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.InviteToClan"))
+	defer cancel()
+
+	// Begin transaction to invite a player
+	// steps: check the inviter owns a clan, check the player is not already a member, insert invite
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "InviteToClan failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	id, role, err := memberClan(ctx, tx, inviter)
+	if err != nil {
+		return err
+	}
+	if role != OwnerRole {
+		return errors.New("only the owner can invite")
+	}
+
+	var found int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Members WHERE Username=?", username).Scan(&found); err != nil {
+		slog.ErrorContext(ctx, "InviteToClan failed", "error", err)
+		return err
+	}
+	if found != 0 {
+		return errors.New("player already in a clan")
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO Invites (ClanId, Username, InvitedBy) VALUES (?, ?, ?)", id, username, inviter)
+	if err != nil {
+		slog.ErrorContext(ctx, "InviteToClan failed", "error", err)
+		return errors.New("player already invited")
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) GetInvites(ctx context.Context, username string) ([]*Clan, error) {
+	// Retrieve clans that invited the player, most recent first
+
+	return s.queryClans(ctx, "SELECT S.Id, S.Name, S.Tag, S.Treasury, S.Points, S.Races, S.Wins, S.Members, S.Position FROM ClanStandings S INNER JOIN Invites I ON I.ClanId=S.Id WHERE I.Username=? ORDER BY I.CreatedAt DESC, S.Id", username)
+}
+
+func (s *SQL_DB) AcceptInvite(ctx context.Context, username string, id int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.AcceptInvite"))
+	defer cancel()
+
+	// Begin transaction to join the clan
+	// steps: check invite and that the player is not in a clan, insert member, delete every invite of the player
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "AcceptInvite failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var found int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Invites WHERE ClanId=? AND Username=?", id, username).Scan(&found); err != nil {
+		slog.ErrorContext(ctx, "AcceptInvite failed", "error", err)
+		return err
+	}
+	if found == 0 {
+		return errors.New("invite not found")
+	}
+
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Members WHERE Username=?", username).Scan(&found); err != nil {
+		slog.ErrorContext(ctx, "AcceptInvite failed", "error", err)
+		return err
+	}
+	if found != 0 {
+		return errors.New("already in a clan")
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO Members (Username, ClanId, Role) VALUES (?, ?, ?)", username, id, MemberRole)
+	if err != nil {
+		slog.ErrorContext(ctx, "AcceptInvite failed", "error", err)
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM Invites WHERE Username=?", username); err != nil {
+		slog.ErrorContext(ctx, "AcceptInvite failed", "error", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) DeclineInvite(ctx context.Context, username string, id int) error {
+	res, err := s.db.ExecContext(ctx, "DELETE FROM Invites WHERE ClanId=? AND Username=?", id, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeclineInvite failed", "error", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("invite not found")
+	}
+
+	return nil
+}
+
+func (s *SQL_DB) LeaveClan(ctx context.Context, username string) error {
+	// The oldest member becomes owner when the owner leaves
+	// the clan is disbanded when its last member leaves, who has to withdraw the treasury first
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.LeaveClan"))
+	defer cancel()
+
+	// Begin transaction to leave the clan
+	// steps: lock clan, delete member, pass ownership or disband the clan
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "LeaveClan failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	id, role, err := memberClan(ctx, tx, username)
+	if err != nil {
+		return err
+	}
+
+	var treasury int
+	if err = tx.QueryRowContext(ctx, "SELECT Treasury FROM Clans WHERE Id=? FOR UPDATE", id).Scan(&treasury); err != nil {
+		slog.ErrorContext(ctx, "LeaveClan failed", "error", err)
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM Members WHERE Username=?", username); err != nil {
+		slog.ErrorContext(ctx, "LeaveClan failed", "error", err)
+		return err
+	}
+
+	var successor string
+	err = tx.QueryRowContext(ctx, "SELECT Username FROM Members WHERE ClanId=? ORDER BY JoinedAt, Username LIMIT 1", id).Scan(&successor)
+	if err == sql.ErrNoRows {
+		if treasury != 0 {
+			return errors.New("withdraw the treasury before disbanding the clan")
+		}
+		for _, table := range []string{"Invites", "Transactions"} {
+			if _, err = tx.ExecContext(ctx, "DELETE FROM "+table+" WHERE ClanId=?", id); err != nil {
+				slog.ErrorContext(ctx, "LeaveClan failed", "error", err)
+				return err
+			}
+		}
+		if _, err = tx.ExecContext(ctx, "DELETE FROM Clans WHERE Id=?", id); err != nil {
+			slog.ErrorContext(ctx, "LeaveClan failed", "error", err)
+			return err
+		}
+		return tx.Commit()
+	} else if err != nil {
+		slog.ErrorContext(ctx, "LeaveClan failed", "error", err)
+		return err
+	}
+
+	if role == OwnerRole {
+		if _, err = tx.ExecContext(ctx, "UPDATE Members SET Role=? WHERE Username=?", OwnerRole, successor); err != nil {
+			slog.ErrorContext(ctx, "LeaveClan failed", "error", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) Deposit(ctx context.Context, username string, amount int) error {
+	// Money already taken from the Garage wallet of the member
+
+	if amount <= 0 {
+		return errors.New("deposit must be positive")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.Deposit"))
+	defer cancel()
+
+	// Begin transaction
+	// steps: find the clan of the member, increase treasury, record transaction
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Deposit failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	id, _, err := memberClan(ctx, tx, username)
+	if err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE Clans SET Treasury=Treasury+? WHERE Id=?", amount, id); err != nil {
+		slog.ErrorContext(ctx, "Deposit failed", "error", err)
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO Transactions (ClanId, Username, Amount) VALUES (?, ?, ?)", id, username, amount)
+	if err != nil {
+		slog.ErrorContext(ctx, "Deposit failed", "error", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) Withdraw(ctx context.Context, owner string, member string, amount int) (TransactionId int, e error) {
+	// Money then given to the Garage wallet of the member
+	// the transaction returned cancels the withdrawal if the wallet can not receive it
+
+	if amount <= 0 {
+		return 0, errors.New("withdrawal must be positive")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.Withdraw"))
+	defer cancel()
+
+	// Begin transaction
+	// steps: check the owner and that the receiver is a member of the same clan, lock clan, decrease treasury, record transaction
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Withdraw failed", "error", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	id, role, err := memberClan(ctx, tx, owner)
+	if err != nil {
+		return 0, err
+	}
+	if role != OwnerRole {
+		return 0, errors.New("only the owner can withdraw")
+	}
+
+	member_id, _, err := memberClan(ctx, tx, member)
+	if err != nil {
+		return 0, err
+	}
+	if member_id != id {
+		return 0, errors.New("receiver not in the clan")
+	}
+
+	var treasury int
+	if err = tx.QueryRowContext(ctx, "SELECT Treasury FROM Clans WHERE Id=? FOR UPDATE", id).Scan(&treasury); err != nil {
+		slog.ErrorContext(ctx, "Withdraw failed", "error", err)
+		return 0, err
+	}
+	if treasury < amount {
+		return 0, errors.New("not enough money in the treasury")
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE Clans SET Treasury=Treasury-? WHERE Id=?", amount, id); err != nil {
+		slog.ErrorContext(ctx, "Withdraw failed", "error", err)
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO Transactions (ClanId, Username, Amount) VALUES (?, ?, ?)", id, member, -amount)
+	if err != nil {
+		slog.ErrorContext(ctx, "Withdraw failed", "error", err)
+		return 0, err
+	}
+
+	last_id, err := res.LastInsertId()
+	if err != nil {
+		slog.ErrorContext(ctx, "Withdraw failed", "error", err)
+		return 0, err
+	}
+
+	return int(last_id), tx.Commit()
+}
+
+func (s *SQL_DB) CancelWithdrawal(ctx context.Context, TransactionId int) error {
+	// Money of a withdrawal not received by the Garage wallet goes back to the treasury
+	// the withdrawal is removed from the history, as it never happened
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.CancelWithdrawal"))
+	defer cancel()
+
+	// Begin transaction
+	// steps: lock withdrawal, increase treasury, delete withdrawal
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "CancelWithdrawal failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var id, amount int
+	err = tx.QueryRowContext(ctx, "SELECT ClanId, Amount FROM Transactions WHERE Id=? FOR UPDATE", TransactionId).Scan(&id, &amount)
+	if err == sql.ErrNoRows || (err == nil && amount >= 0) {
+		return errors.New("withdrawal not found")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "CancelWithdrawal failed", "error", err)
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE Clans SET Treasury=Treasury-? WHERE Id=?", amount, id); err != nil {
+		slog.ErrorContext(ctx, "CancelWithdrawal failed", "error", err)
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, "DELETE FROM Transactions WHERE Id=?", TransactionId); err != nil {
+		slog.ErrorContext(ctx, "CancelWithdrawal failed", "error", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) GetClanTags(ctx context.Context, usernames []string) (map[string]string, error) {
+	// Retrieve tags of the players in a clan, the others are missing from the result
+
+	tags := make(map[string]string)
+	if len(usernames) == 0 {
+		return tags, nil
+	}
+	if len(usernames) > maxTagsQuery {
+		return nil, errors.New("too many players")
+	}
+
+	args := make([]any, 0, len(usernames))
+	for _, u := range usernames {
+		args = append(args, u)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(usernames)), ", ")
+
+	rows, err := s.db.QueryContext(ctx, "SELECT M.Username, C.Tag FROM Members M INNER JOIN Clans C ON M.ClanId=C.Id WHERE M.Username IN ("+placeholders+")", args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetClanTags failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var username, tag string
+		if err := rows.Scan(&username, &tag); err != nil {
+			slog.ErrorContext(ctx, "GetClanTags failed", "error", err)
+			return nil, err
+		}
+		tags[username] = tag
+	}
+
+	return tags, rows.Err()
+}
+
+func (s *SQL_DB) RecordRaceResults(ctx context.Context, results []RaceResult) error {
+	// Add the results of a race to the members and their clans, players without a clan are skipped
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.RecordRaceResults"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "RecordRaceResults failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// A clan with several members in the same race counts it once, points and wins are summed over its members
+	raced := make(map[int]bool)
+	for _, r := range results {
+		wins := 0
+		if r.Won {
+			wins = 1
+		}
+
+		var id int
+		err = tx.QueryRowContext(ctx, "SELECT ClanId FROM Members WHERE Username=?", r.Username).Scan(&id)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			slog.ErrorContext(ctx, "RecordRaceResults failed", "error", err)
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE Members SET Points=Points+?, Races=Races+1, Wins=Wins+? WHERE Username=?", r.Points, wins, r.Username)
+		if err != nil {
+			slog.ErrorContext(ctx, "RecordRaceResults failed", "error", err)
+			return err
+		}
+
+		races := 0
+		if !raced[id] {
+			races = 1
+			raced[id] = true
+		}
+
+		_, err = tx.ExecContext(ctx, "UPDATE Clans SET Points=Points+?, Races=Races+?, Wins=Wins+? WHERE Id=?", r.Points, races, wins, id)
+		if err != nil {
+			slog.ErrorContext(ctx, "RecordRaceResults failed", "error", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,210 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"testing"
+)
+
+func NewSQLConnection(t *testing.T) *sql.DB {
+	db, err := sql.Open("mysql", "root:admin@tcp(test_clans_db:3306)/Clans?parseTime=true")
+	if err != nil {
+		t.Errorf("failed to connect to db: %s", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Errorf("error pinging database: %v", err)
+	}
+
+	return db
+}
+
+func TestDBCreateClan(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	id, err := db.CreateClan(context.Background(), "founder", "Green Team", "grn")
+	if err != nil {
+		t.Errorf("Unable to create clan: %v", err)
+		return
+	}
+
+	clan, err := db.GetPlayerClan(context.Background(), "founder")
+	if err != nil || clan.Id != id || clan.Tag != "GRN" || clan.Members != 1 {
+		t.Errorf("Wrong clan after creation")
+	}
+
+	if _, err = db.CreateClan(context.Background(), "founder", "Another Team", "ANO"); err == nil {
+		t.Errorf("Player created a second clan")
+	}
+	if _, err = db.CreateClan(context.Background(), "other", "Red Team", "NEW"); err == nil {
+		t.Errorf("Clan name used twice")
+	}
+	if _, err = db.CreateClan(context.Background(), "other", "Long Tag", "TOOLONG"); err == nil {
+		t.Errorf("Invalid tag accepted")
+	}
+}
+
+func TestDBInviteToClan(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// Only the owner invites, players already in a clan cannot be invited
+	if err := db.InviteToClan(context.Background(), "member", "newbie"); err == nil {
+		t.Errorf("Member able to invite")
+	}
+	if err := db.InviteToClan(context.Background(), "owner", "solo"); err == nil {
+		t.Errorf("Player of another clan invited")
+	}
+	if err := db.InviteToClan(context.Background(), "owner", "newbie"); err != nil {
+		t.Errorf("Unable to invite: %v", err)
+	}
+
+	invites, err := db.GetInvites(context.Background(), "guest")
+	if err != nil || len(invites) != 2 {
+		t.Errorf("Wrong invites")
+		return
+	}
+
+	if err = db.AcceptInvite(context.Background(), "guest", 1); err != nil {
+		t.Errorf("Unable to accept invite: %v", err)
+	}
+
+	// Every other invite is dropped once in a clan
+	if invites, err = db.GetInvites(context.Background(), "guest"); err != nil || len(invites) != 0 {
+		t.Errorf("Invites left after joining")
+	}
+	if err = db.AcceptInvite(context.Background(), "newbie", 2); err == nil {
+		t.Errorf("Joined without an invite")
+	}
+	if err = db.DeclineInvite(context.Background(), "newbie", 1); err != nil {
+		t.Errorf("Unable to decline invite: %v", err)
+	}
+
+	members, err := db.GetMembers(context.Background(), 1)
+	if err != nil || len(members) != 3 || members[0].Username != "owner" || members[0].Role != OwnerRole {
+		t.Errorf("Wrong members after joining")
+	}
+}
+
+func TestDBTreasury(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	if err := db.Deposit(context.Background(), "member", 50); err != nil {
+		t.Errorf("Unable to deposit: %v", err)
+	}
+	if err := db.Deposit(context.Background(), "nobody", 50); err == nil {
+		t.Errorf("Deposit without a clan")
+	}
+
+	// Only the owner pays members of the same clan, within the treasury
+	if _, err := db.Withdraw(context.Background(), "member", "member", 10); err == nil {
+		t.Errorf("Member able to withdraw")
+	}
+	if _, err := db.Withdraw(context.Background(), "owner", "solo", 10); err == nil {
+		t.Errorf("Withdrawal paid to another clan")
+	}
+	if _, err := db.Withdraw(context.Background(), "owner", "member", 1000); err == nil {
+		t.Errorf("Withdrawal larger than the treasury")
+	}
+	if _, err := db.Withdraw(context.Background(), "owner", "member", 120); err != nil {
+		t.Errorf("Unable to withdraw: %v", err)
+	}
+
+	// A withdrawal the wallet did not receive leaves no trace
+	transaction, err := db.Withdraw(context.Background(), "owner", "member", 10)
+	if err != nil {
+		t.Errorf("Unable to withdraw: %v", err)
+	}
+	if err = db.CancelWithdrawal(context.Background(), transaction); err != nil {
+		t.Errorf("Unable to cancel withdrawal: %v", err)
+	}
+	if err = db.CancelWithdrawal(context.Background(), transaction); err == nil {
+		t.Errorf("Withdrawal cancelled twice")
+	}
+
+	clan, err := db.GetClan(context.Background(), 1)
+	if err != nil || clan.Treasury != 30 {
+		t.Errorf("Wrong treasury")
+	}
+
+	transactions, err := db.GetTransactions(context.Background(), 1)
+	if err != nil || len(transactions) != 2 || transactions[0].Amount != -120 || transactions[1].Amount != 50 {
+		t.Errorf("Wrong transactions")
+	}
+}
+
+func TestDBLeaveClan(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// The last member disbands the clan after withdrawing the treasury
+	if err := db.Deposit(context.Background(), "solo", 40); err != nil {
+		t.Errorf("Unable to deposit: %v", err)
+	}
+	if err := db.LeaveClan(context.Background(), "solo"); err == nil {
+		t.Errorf("Clan disbanded with money in the treasury")
+	}
+	if _, err := db.Withdraw(context.Background(), "solo", "solo", 40); err != nil {
+		t.Errorf("Unable to withdraw: %v", err)
+	}
+	if err := db.LeaveClan(context.Background(), "solo"); err != nil {
+		t.Errorf("Unable to disband the clan: %v", err)
+	}
+	if _, err := db.GetClan(context.Background(), 2); err == nil {
+		t.Errorf("Clan not disbanded")
+	}
+
+	if err := db.LeaveClan(context.Background(), "solo"); err == nil {
+		t.Errorf("Left a clan twice")
+	}
+}
+
+func TestDBRecordRaceResults(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	var before, races int
+	if err := conn.QueryRow("SELECT Points, Races FROM Clans WHERE Id=1").Scan(&before, &races); err != nil {
+		t.Errorf("Unable to read points: %v", err)
+		return
+	}
+
+	err := db.RecordRaceResults(context.Background(), []RaceResult{{Username: "owner", Points: 10, Won: true}, {Username: "member", Points: 5}, {Username: "nobody", Points: 1}})
+	if err != nil {
+		t.Errorf("Unable to record results: %v", err)
+	}
+
+	clan, err := db.GetClan(context.Background(), 1)
+	if err != nil || clan.Points != before+15 || clan.Position != 1 {
+		t.Errorf("Wrong clan results")
+	}
+
+	// Two members in the same race count as one race of the clan
+	if clan.Races != races+1 {
+		t.Errorf("Wrong clan races: %d", clan.Races)
+	}
+
+	tags, err := db.GetClanTags(context.Background(), []string{"owner", "member", "nobody"})
+	if err != nil || len(tags) != 2 || tags["owner"] != "RED" {
+		t.Errorf("Wrong clan tags")
+	}
+
+	board, err := db.GetClanLeaderboard(context.Background())
+	if err != nil || len(board) == 0 || board[0].Id != 1 {
+		t.Errorf("Wrong clan leaderboard")
+	}
+}
@@ -0,0 +1,220 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+
+	pb "clans/proto"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type Server struct {
+	pb.UnimplementedClansServer
+	pb.UnimplementedStillAliveServer
+	db ClansDB
+}
+
+func NewServer(conn ClansDB) *Server {
+	return &Server{db: conn}
+}
+
+func clanToPb(c *Clan) *pb.ClanInfo {
+	return &pb.ClanInfo{
+		Id:       int32(c.Id),
+		Name:     c.Name,
+		Tag:      c.Tag,
+		Treasury: int32(c.Treasury),
+		Points:   int32(c.Points),
+		Races:    int32(c.Races),
+		Wins:     int32(c.Wins),
+		Members:  int32(c.Members),
+		Position: int32(c.Position),
+	}
+}
+
+func (s *Server) CreateClan(ctx context.Context, in *pb.ClanSettings) (*pb.ClanInfo, error) {
+	id, err := s.db.CreateClan(ctx, in.Owner, in.Name, in.Tag)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateClan failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Clan created", "clan", in.Name, "username", in.Owner)
+
+	clan, err := s.db.GetClan(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateClan failed", "error", err)
+		return nil, err
+	}
+
+	return clanToPb(clan), nil
+}
+
+func (s *Server) GetClan(ctx context.Context, in *pb.ClanReference) (*pb.ClanInfo, error) {
+	clan, err := s.db.GetClan(ctx, int(in.ClanId))
+	if err != nil {
+		slog.ErrorContext(ctx, "GetClan failed", "error", err)
+		return nil, err
+	}
+
+	return clanToPb(clan), nil
+}
+
+func (s *Server) GetPlayerClan(ctx context.Context, in *pb.PlayerUsername) (*pb.ClanInfo, error) {
+	clan, err := s.db.GetPlayerClan(ctx, in.Username)
+	if err != nil {
+		return nil, err
+	}
+
+	return clanToPb(clan), nil
+}
+
+func (s *Server) GetClanMembers(in *pb.ClanReference, stream pb.Clans_GetClanMembersServer) error {
+	members, err := s.db.GetMembers(stream.Context(), int(in.ClanId))
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetClanMembers failed", "error", err)
+		return err
+	}
+
+	for _, v := range members {
+		stream.Send(&pb.ClanMember{
+			Username: v.Username,
+			Role:     v.Role,
+			Points:   int32(v.Points),
+			Races:    int32(v.Races),
+			Wins:     int32(v.Wins),
+			JoinedAt: timestamppb.New(v.JoinedAt),
+		})
+	}
+
+	return nil
+}
+
+func (s *Server) GetClanTransactions(in *pb.ClanReference, stream pb.Clans_GetClanTransactionsServer) error {
+	transactions, err := s.db.GetTransactions(stream.Context(), int(in.ClanId))
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetClanTransactions failed", "error", err)
+		return err
+	}
+
+	for _, v := range transactions {
+		stream.Send(&pb.ClanTransaction{Username: v.Username, Amount: int32(v.Amount), Time: timestamppb.New(v.Time)})
+	}
+
+	return nil
+}
+
+func (s *Server) GetClanLeaderboard(_ *emptypb.Empty, stream pb.Clans_GetClanLeaderboardServer) error {
+	clans, err := s.db.GetClanLeaderboard(stream.Context())
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetClanLeaderboard failed", "error", err)
+		return err
+	}
+
+	for _, v := range clans {
+		stream.Send(clanToPb(v))
+	}
+
+	return nil
+}
+
+func (s *Server) InviteToClan(ctx context.Context, in *pb.ClanInvite) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Clan invite", "inviter", in.Inviter, "username", in.Username)
+
+	return nil, s.db.InviteToClan(ctx, in.Inviter, in.Username)
+}
+
+func (s *Server) GetClanInvites(in *pb.PlayerUsername, stream pb.Clans_GetClanInvitesServer) error {
+	clans, err := s.db.GetInvites(stream.Context(), in.Username)
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetClanInvites failed", "error", err)
+		return err
+	}
+
+	for _, v := range clans {
+		stream.Send(clanToPb(v))
+	}
+
+	return nil
+}
+
+func (s *Server) AcceptClanInvite(ctx context.Context, in *pb.ClanInvite) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Clan joined", "clan_id", in.ClanId, "username", in.Username)
+
+	return nil, s.db.AcceptInvite(ctx, in.Username, int(in.ClanId))
+}
+
+func (s *Server) DeclineClanInvite(ctx context.Context, in *pb.ClanInvite) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Clan invite declined", "clan_id", in.ClanId, "username", in.Username)
+
+	return nil, s.db.DeclineInvite(ctx, in.Username, int(in.ClanId))
+}
+
+func (s *Server) LeaveClan(ctx context.Context, in *pb.PlayerUsername) (*emptypb.Empty, error) {
+	if err := s.db.LeaveClan(ctx, in.Username); err != nil {
+		slog.ErrorContext(ctx, "LeaveClan failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Clan left", "username", in.Username)
+
+	return nil, nil
+}
+
+func (s *Server) DepositToClan(ctx context.Context, in *pb.ClanTransfer) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Treasury deposit", "username", in.Username, "amount", in.Amount)
+
+	return nil, s.db.Deposit(ctx, in.Username, int(in.Amount))
+}
+
+func (s *Server) WithdrawFromClan(ctx context.Context, in *pb.ClanTransfer) (*pb.ClanTransactionReference, error) {
+	id, err := s.db.Withdraw(ctx, in.Username, in.Member, int(in.Amount))
+	if err != nil {
+		slog.ErrorContext(ctx, "WithdrawFromClan failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Treasury withdrawal", "username", in.Username, "amount", in.Amount, "member", in.Member)
+
+	return &pb.ClanTransactionReference{TransactionId: int32(id)}, nil
+}
+
+func (s *Server) CancelWithdrawal(ctx context.Context, in *pb.ClanTransactionReference) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Treasury withdrawal cancelled", "transaction_id", in.TransactionId)
+
+	return nil, s.db.CancelWithdrawal(ctx, int(in.TransactionId))
+}
+
+func (s *Server) GetClanTags(in *pb.PlayerList, stream pb.Clans_GetClanTagsServer) error {
+	tags, err := s.db.GetClanTags(stream.Context(), in.Usernames)
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetClanTags failed", "error", err)
+		return err
+	}
+
+	for username, tag := range tags {
+		stream.Send(&pb.ClanTag{Username: username, Tag: tag})
+	}
+
+	return nil
+}
+
+func (s *Server) RecordClanResults(ctx context.Context, in *pb.ClanRaceResults) (*emptypb.Empty, error) {
+	var results []RaceResult
+	for _, r := range in.Results {
+		results = append(results, RaceResult{Username: r.Username, Points: int(r.Points), Won: r.Won})
+	}
+
+	return nil, s.db.RecordRaceResults(ctx, results)
+}
+
+func (s *Server) StillAlive(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, nil
+}
@@ -0,0 +1,46 @@
+DROP DATABASE IF EXISTS EventBus;
+CREATE DATABASE IF NOT EXISTS EventBus;
+USE EventBus;
+
+-- Append-only log of the domain events, the Id gives the delivery order
+DROP TABLE IF EXISTS Events;
+CREATE TABLE IF NOT EXISTS Events (
+  Id int NOT NULL AUTO_INCREMENT,
+  Type enum('race_completed', 'motorcycle_bought', 'player_registered') NOT NULL,
+  Username varchar(32) NOT NULL,
+  TrackName varchar(32), -- race completed only
+  Position int, -- race completed only
+  TotalMotorcycles int, -- race completed only
+  MotorcycleId int, -- race completed and motorcycle bought only
+  MotorcycleName varchar(32), -- race completed and motorcycle bought only
+  Price int, -- motorcycle bought only
+  Time timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Id),
+  INDEX (Type)
+) ENGINE=InnoDB;
+
+-- Single row locked by every publisher, so events are committed in the order of their Id
+-- and a subscriber never moves past an event still being published
+DROP TABLE IF EXISTS EventSequence;
+CREATE TABLE IF NOT EXISTS EventSequence (
+  Id int NOT NULL DEFAULT 1,
+  LastId int NOT NULL DEFAULT 0, -- last event published
+  PRIMARY KEY (Id),
+  CHECK (Id = 1)
+) ENGINE=InnoDB;
+
+-- Durable subscriptions, events after Acked are delivered again when a consumer reconnects
+DROP TABLE IF EXISTS Subscriptions;
+CREATE TABLE IF NOT EXISTS Subscriptions (
+  Name varchar(32) NOT NULL,
+  Acked int NOT NULL DEFAULT 0,
+  CreatedAt timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Name)
+) ENGINE=InnoDB;
+
+INSERT INTO Events (Id, Type, Username, TrackName, Position, TotalMotorcycles, MotorcycleId, MotorcycleName, Price) VALUES
+  (1, "player_registered", "rider", NULL, NULL, NULL, NULL, NULL, NULL),
+  (2, "motorcycle_bought", "rider", NULL, NULL, NULL, 1, "Ducati Panigale", 100),
+  (3, "race_completed", "rider", "Mugello", 1, 3, 1, "Ducati Panigale", NULL);
+INSERT INTO EventSequence (Id, LastId) VALUES (1, 3);
+INSERT INTO Subscriptions (Name, Acked) VALUES ("consumer", 1);
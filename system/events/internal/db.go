@@ -0,0 +1,199 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// Kinds of domain event carried by the bus
+const (
+	RaceCompleted    = "race_completed"
+	MotorcycleBought = "motorcycle_bought"
+	PlayerRegistered = "player_registered"
+)
+
+const deliveryBatch = 100 // events read at once for a subscriber
+
+type Event struct {
+	Id               int
+	Type             string
+	Username         string
+	Time             time.Time
+	TrackName        string // race completed only
+	Position         int    // race completed only
+	TotalMotorcycles int    // race completed only
+	MotorcycleId     int    // race completed and motorcycle bought only
+	MotorcycleName   string // race completed and motorcycle bought only
+	Price            int    // motorcycle bought only
+}
+
+type EventsDB interface {
+	Publish(ctx context.Context, event *Event) (int, error)
+	Subscribe(ctx context.Context, name string, from_start bool) (int, error)
+	GetEventsAfter(ctx context.Context, last_id int, types []string) ([]*Event, error)
+	GetAcked(ctx context.Context, name string) (int, error)
+	Ack(ctx context.Context, name string, event_id int) error
+}
+
+// Implementation for an SQL Database
+type SQL_DB struct {
+	db *sql.DB
+}
+
+func NewSQL_DB(conn *sql.DB) *SQL_DB {
+	return &SQL_DB{db: conn}
+}
+
+func nullString(s string, valid bool) sql.NullString {
+	return sql.NullString{String: s, Valid: valid}
+}
+
+func nullInt(i int, valid bool) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(i), Valid: valid}
+}
+
+func (s *SQL_DB) Publish(ctx context.Context, event *Event) (int, error) {
+	// Append the event to the log, only the fields of its type are stored
+
+	if event.Username == "" {
+		return 0, errors.New("event without a player")
+	}
+
+	race := event.Type == RaceCompleted
+	bought := event.Type == MotorcycleBought
+	switch event.Type {
+	case RaceCompleted, MotorcycleBought, PlayerRegistered:
+	default:
+		return 0, errors.New("unknown event type")
+	}
+
+	// Begin transaction
+	// steps: lock the sequence, insert the event, move the sequence to it
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Publish failed", "error", err)
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var last int
+	if err = tx.QueryRowContext(ctx, "SELECT LastId FROM EventSequence WHERE Id=1 FOR UPDATE").Scan(&last); err != nil {
+		slog.ErrorContext(ctx, "Publish failed", "error", err)
+		return 0, err
+	}
+
+	res, err := tx.ExecContext(ctx, `INSERT INTO Events (Type, Username, TrackName, Position, TotalMotorcycles, MotorcycleId, MotorcycleName, Price)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.Type, event.Username,
+		nullString(event.TrackName, race), nullInt(event.Position, race), nullInt(event.TotalMotorcycles, race),
+		nullInt(event.MotorcycleId, race || bought), nullString(event.MotorcycleName, race || bought), nullInt(event.Price, bought))
+	if err != nil {
+		slog.ErrorContext(ctx, "Publish failed", "error", err)
+		return 0, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		slog.ErrorContext(ctx, "Publish failed", "error", err)
+		return 0, err
+	}
+
+	if _, err = tx.ExecContext(ctx, "UPDATE EventSequence SET LastId=? WHERE Id=1", id); err != nil {
+		slog.ErrorContext(ctx, "Publish failed", "error", err)
+		return 0, err
+	}
+
+	return int(id), tx.Commit()
+}
+
+func (s *SQL_DB) Subscribe(ctx context.Context, name string, from_start bool) (int, error) {
+	// Create the subscription if it is new and return the last acknowledged event.
+	// A new subscription skips the events already in the log unless it starts from the beginning
+
+	if name == "" {
+		return 0, errors.New("subscription without a name")
+	}
+
+	start := "LastId"
+	if from_start {
+		start = "0"
+	}
+
+	_, err := s.db.ExecContext(ctx, "INSERT IGNORE INTO Subscriptions (Name, Acked) SELECT ?, "+start+" FROM EventSequence WHERE Id=1", name)
+	if err != nil {
+		slog.ErrorContext(ctx, "Subscribe failed", "error", err)
+		return 0, err
+	}
+
+	return s.GetAcked(ctx, name)
+}
+
+func (s *SQL_DB) GetEventsAfter(ctx context.Context, last_id int, types []string) ([]*Event, error) {
+	// Events following the given one in the order they were published, filtered by type
+
+	if len(types) == 0 {
+		return nil, nil
+	}
+
+	args := []any{last_id}
+	for _, t := range types {
+		args = append(args, t)
+	}
+	args = append(args, deliveryBatch)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(types)), ", ")
+
+	rows, err := s.db.QueryContext(ctx, `SELECT Id, Type, Username, Time, COALESCE(TrackName, ''), COALESCE(Position, 0), COALESCE(TotalMotorcycles, 0),
+		COALESCE(MotorcycleId, 0), COALESCE(MotorcycleName, ''), COALESCE(Price, 0)
+		FROM Events
+		WHERE Id>? AND Type IN (`+placeholders+`)
+		ORDER BY Id
+		LIMIT ?`, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetEventsAfter failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []*Event
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.Id, &e.Type, &e.Username, &e.Time, &e.TrackName, &e.Position, &e.TotalMotorcycles, &e.MotorcycleId, &e.MotorcycleName, &e.Price); err != nil {
+			slog.ErrorContext(ctx, "GetEventsAfter failed", "error", err)
+			return nil, err
+		}
+		events = append(events, &e)
+	}
+
+	return events, rows.Err()
+}
+
+func (s *SQL_DB) GetAcked(ctx context.Context, name string) (int, error) {
+	var acked int
+	err := s.db.QueryRowContext(ctx, "SELECT Acked FROM Subscriptions WHERE Name=?", name).Scan(&acked)
+	if err == sql.ErrNoRows {
+		return 0, errors.New("subscription not found")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "GetAcked failed", "error", err)
+		return 0, err
+	}
+
+	return acked, nil
+}
+
+func (s *SQL_DB) Ack(ctx context.Context, name string, event_id int) error {
+	// Acknowledgements only move forward, so a late one from another replica is harmless
+
+	if _, err := s.GetAcked(ctx, name); err != nil {
+		return err
+	}
+
+	_, err := s.db.ExecContext(ctx, "UPDATE Subscriptions SET Acked=? WHERE Name=? AND Acked<?", event_id, name, event_id)
+	if err != nil {
+		slog.ErrorContext(ctx, "Ack failed", "error", err)
+	}
+	return err
+}
@@ -0,0 +1,107 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"testing"
+)
+
+func NewSQLConnection(t *testing.T) *sql.DB {
+	db, err := sql.Open("mysql", "root:admin@tcp(test_events_db:3306)/EventBus?parseTime=true")
+	if err != nil {
+		t.Errorf("failed to connect to db: %s", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Errorf("error pinging database: %v", err)
+	}
+
+	return db
+}
+
+func TestDBGetEventsAfter(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// The existing subscription resumes after its acknowledged event
+	last, err := db.Subscribe(context.Background(), "consumer", true)
+	if err != nil || last != 1 {
+		t.Errorf("Wrong resume point of an existing subscription: %d", last)
+	}
+
+	events, err := db.GetEventsAfter(context.Background(), last, []string{RaceCompleted, MotorcycleBought})
+	if err != nil || len(events) != 2 {
+		t.Errorf("Unable to get events")
+		return
+	}
+	if events[0].Type != MotorcycleBought || events[0].Price != 100 || events[1].TrackName != "Mugello" || events[1].TotalMotorcycles != 3 {
+		t.Errorf("Wrong events")
+	}
+
+	if events, err = db.GetEventsAfter(context.Background(), 0, []string{PlayerRegistered}); err != nil || len(events) != 1 || events[0].Username != "rider" {
+		t.Errorf("Wrong events filtered by type")
+	}
+}
+
+func TestDBSubscribe(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// New subscriptions start after the events in the log, or from the first one if asked
+	if last, err := db.Subscribe(context.Background(), "late", false); err != nil || last != 3 {
+		t.Errorf("Wrong start of a new subscription: %d", last)
+	}
+	if last, err := db.Subscribe(context.Background(), "replay", true); err != nil || last != 0 {
+		t.Errorf("Wrong start of a replaying subscription: %d", last)
+	}
+	if _, err := db.Subscribe(context.Background(), "", false); err == nil {
+		t.Errorf("Subscription without a name")
+	}
+}
+
+func TestDBPublishAndAck(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	id, err := db.Publish(context.Background(), &Event{Type: PlayerRegistered, Username: "newbie", TrackName: "ignored"})
+	if err != nil {
+		t.Errorf("Unable to publish: %v", err)
+		return
+	}
+	if _, err = db.Publish(context.Background(), &Event{Type: "unknown", Username: "newbie"}); err == nil {
+		t.Errorf("Unknown event published")
+	}
+
+	events, err := db.GetEventsAfter(context.Background(), id-1, []string{PlayerRegistered})
+	if err != nil || len(events) != 1 || events[0].Id != id || events[0].TrackName != "" {
+		t.Errorf("Published event not delivered")
+	}
+
+	// New subscriptions start after the last published event
+	if last, err := db.Subscribe(context.Background(), "newest", false); err != nil || last != id {
+		t.Errorf("Wrong start of a new subscription: %d", last)
+	}
+
+	if err = db.Ack(context.Background(), "consumer", id); err != nil {
+		t.Errorf("Unable to ack: %v", err)
+	}
+	// A late acknowledgement does not move the subscription back
+	if err = db.Ack(context.Background(), "consumer", 2); err != nil {
+		t.Errorf("Unable to ack: %v", err)
+	}
+	if acked, err := db.GetAcked(context.Background(), "consumer"); err != nil || acked != id {
+		t.Errorf("Wrong acknowledged event: %d", acked)
+	}
+
+	if err = db.Ack(context.Background(), "missing", id); err == nil {
+		t.Errorf("Ack of a missing subscription")
+	}
+}
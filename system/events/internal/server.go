@@ -0,0 +1,158 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	pb "events/proto"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	pollInterval      = 500 * time.Millisecond
+	redeliveryTimeout = 30 * time.Second // events not acknowledged in time are sent again
+)
+
+// Event delivered to a subscriber, waiting for its acknowledgement
+type sentEvent struct {
+	id      int
+	sent_at time.Time
+}
+
+type Server struct {
+	pb.UnimplementedEventsServer
+	pb.UnimplementedStillAliveServer
+	db EventsDB
+}
+
+func NewServer(conn EventsDB) *Server {
+	return &Server{db: conn}
+}
+
+var eventTypes = map[pb.DomainEventType]string{
+	pb.DomainEventType_RACE_COMPLETED:    RaceCompleted,
+	pb.DomainEventType_MOTORCYCLE_BOUGHT: MotorcycleBought,
+	pb.DomainEventType_PLAYER_REGISTERED: PlayerRegistered,
+}
+
+func eventToPb(e *Event) *pb.DomainEvent {
+	info := &pb.DomainEvent{
+		Id:               int32(e.Id),
+		Username:         e.Username,
+		Time:             timestamppb.New(e.Time),
+		TrackName:        e.TrackName,
+		Position:         int32(e.Position),
+		TotalMotorcycles: int32(e.TotalMotorcycles),
+		MotorcycleId:     int32(e.MotorcycleId),
+		MotorcycleName:   e.MotorcycleName,
+		Price:            int32(e.Price),
+	}
+	for t, name := range eventTypes {
+		if name == e.Type {
+			info.Type = t
+		}
+	}
+
+	return info
+}
+
+func (s *Server) PublishEvent(ctx context.Context, in *pb.DomainEvent) (*pb.EventReceipt, error) {
+	event_type, ok := eventTypes[in.Type]
+	if !ok {
+		return nil, errors.New("unknown event type")
+	}
+
+	id, err := s.db.Publish(ctx, &Event{
+		Type:             event_type,
+		Username:         in.Username,
+		TrackName:        in.TrackName,
+		Position:         int(in.Position),
+		TotalMotorcycles: int(in.TotalMotorcycles),
+		MotorcycleId:     int(in.MotorcycleId),
+		MotorcycleName:   in.MotorcycleName,
+		Price:            int(in.Price),
+	})
+	if err != nil {
+		slog.ErrorContext(ctx, "PublishEvent failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Event published", "event_id", id, "event_type", event_type, "username", in.Username)
+
+	return &pb.EventReceipt{EventId: int32(id)}, nil
+}
+
+func (s *Server) Subscribe(in *pb.EventSubscription, stream pb.Events_SubscribeServer) error {
+	// Stream the events of the subscription until the subscriber leaves.
+	// Events are read from the database since they could be published through any replica,
+	// delivery restarts from the last acknowledged event when acknowledgements stop coming
+
+	var types []string
+	for _, t := range in.Types {
+		event_type, ok := eventTypes[t]
+		if !ok {
+			return errors.New("unknown event type")
+		}
+		types = append(types, event_type)
+	}
+
+	last, err := s.db.Subscribe(stream.Context(), in.Name, in.FromStart)
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "Subscribe failed", "error", err)
+		return err
+	}
+
+	slog.InfoContext(stream.Context(), "Subscription connected", "subscription", in.Name, "after", last)
+
+	// Events sent and not acknowledged yet, oldest first
+	var pending []sentEvent
+	for {
+		if len(pending) > 0 && time.Since(pending[0].sent_at) > redeliveryTimeout {
+			last = pending[0].id - 1
+			slog.WarnContext(stream.Context(), "Subscription redelivering", "subscription", in.Name, "after", last)
+			pending = nil
+		}
+
+		events, err := s.db.GetEventsAfter(stream.Context(), last, types)
+		if err != nil {
+			slog.ErrorContext(stream.Context(), "Subscribe failed", "error", err)
+			return err
+		}
+
+		for _, v := range events {
+			if err := stream.Send(eventToPb(v)); err != nil {
+				slog.ErrorContext(stream.Context(), "Subscribe failed", "error", err)
+				return err
+			}
+			last = v.Id
+			pending = append(pending, sentEvent{id: v.Id, sent_at: time.Now()})
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-time.After(pollInterval):
+		}
+
+		acked, err := s.db.GetAcked(stream.Context(), in.Name)
+		if err != nil {
+			slog.ErrorContext(stream.Context(), "Subscribe failed", "error", err)
+			return err
+		}
+		for len(pending) > 0 && pending[0].id <= acked {
+			pending = pending[1:]
+		}
+	}
+}
+
+func (s *Server) AckEvent(ctx context.Context, in *pb.EventAck) (*emptypb.Empty, error) {
+	return nil, s.db.Ack(ctx, in.Name, int(in.EventId))
+}
+
+func (s *Server) StillAlive(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, nil
+}
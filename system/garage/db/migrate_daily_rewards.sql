@@ -0,0 +1,10 @@
+-- Migration of an existing Garage database to daily login rewards.
+USE Garage;
+
+CREATE TABLE IF NOT EXISTS DailyRewards (
+  Username varchar(32) NOT NULL,
+  LastClaim date, -- NULL until the first claim
+  Streak int NOT NULL DEFAULT 0, -- consecutive days with a claim
+  PRIMARY KEY (Username),
+  FOREIGN KEY (Username) REFERENCES Users(Username)
+) ENGINE=InnoDB;
@@ -0,0 +1,36 @@
+-- Migration of an existing Garage database to motorcycle liveries.
+-- Apply after migrate_wear.sql.
+USE Garage;
+
+CREATE TABLE IF NOT EXISTS Paints (
+  Id int NOT NULL AUTO_INCREMENT,
+  Name varchar(32) NOT NULL,
+  Color char(7) NOT NULL, -- hex color used by the web pages
+  Price int NOT NULL,
+  PRIMARY KEY (Id),
+  CHECK (Price > 0)
+) ENGINE=InnoDB;
+
+-- Paints shipped with setup.sql
+INSERT IGNORE INTO Paints VALUES (1, "Racing Red", "#c8102e", 30), (2, "Factory Orange", "#ff6600", 30), (3, "Midnight Black", "#111111", 20), (4, "Pearl White", "#f5f5f0", 20);
+
+ALTER TABLE Owners
+  ADD COLUMN IF NOT EXISTS PaintId int,
+  ADD COLUMN IF NOT EXISTS RaceNumber int,
+  ADD COLUMN IF NOT EXISTS RiderName varchar(32),
+  ADD CONSTRAINT OwnersPaint FOREIGN KEY IF NOT EXISTS (PaintId) REFERENCES Paints(Id),
+  ADD CONSTRAINT IF NOT EXISTS RaceNumberRange CHECK (RaceNumber BETWEEN 1 AND 99);
+
+CREATE OR REPLACE VIEW DetailedOwnership AS
+SELECT O.Username, O.MotorcycleId, (O.EngineLevel+O.AgilityLevel+O.BrakesLevel+O.AerodynamicsLevel) DIV 4 AS Level,
+  O.EngineLevel, O.AgilityLevel, O.BrakesLevel, O.AerodynamicsLevel, M.Name, M.PriceToBuy, M.MaxLevel,
+  (M.Engine+M.EngineIncrement*O.EngineLevel+COALESCE(E.Engine, 0))*(200-O.Wear) DIV 200 AS Engine, M.EngineIncrement, M.EngineMaxLevel, M.EnginePriceToUpgrade,
+  (M.Agility+M.AgilityIncrement*O.AgilityLevel+COALESCE(E.Agility, 0))*(200-O.Wear) DIV 200 AS Agility, M.AgilityIncrement, M.AgilityMaxLevel, M.AgilityPriceToUpgrade,
+  (M.Brakes+M.BrakesIncrement*O.BrakesLevel+COALESCE(E.Brakes, 0))*(200-O.Wear) DIV 200 AS Brakes, M.BrakesIncrement, M.BrakesMaxLevel, M.BrakesPriceToUpgrade,
+  (M.Aerodynamics+M.AerodynamicsIncrement*O.AerodynamicsLevel+COALESCE(E.Aerodynamics, 0))*(200-O.Wear) DIV 200 AS Aerodynamics, M.AerodynamicsIncrement, M.AerodynamicsMaxLevel, M.AerodynamicsPriceToUpgrade,
+  M.PriceCurve, M.PriceCurveFactor, O.Wear,
+  COALESCE(P.Name, '') AS Paint, COALESCE(P.Color, '') AS PaintColor, COALESCE(O.RaceNumber, 0) AS RaceNumber, COALESCE(O.RiderName, '') AS RiderName
+FROM Owners O
+INNER JOIN DetailedMotorcycles M ON O.MotorcycleId=M.Id
+LEFT JOIN EquippedParts E ON O.Username=E.Username AND O.MotorcycleId=E.MotorcycleId
+LEFT JOIN Paints P ON O.PaintId=P.Id;
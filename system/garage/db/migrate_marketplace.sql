@@ -0,0 +1,48 @@
+-- Migration of an existing Garage database to the player-to-player marketplace and the auctions.
+USE Garage;
+
+CREATE TABLE IF NOT EXISTS Listings (
+  Id int NOT NULL AUTO_INCREMENT,
+  Seller varchar(32) NOT NULL,
+  MotorcycleId int NOT NULL,
+  Price int NOT NULL,
+  ExpiresAt timestamp NOT NULL,
+  PRIMARY KEY (Id),
+  UNIQUE (Seller, MotorcycleId),
+  FOREIGN KEY (Seller, MotorcycleId) REFERENCES Owners(Username, MotorcycleId) ON DELETE CASCADE,
+  CHECK (Price > 0)
+) ENGINE=InnoDB;
+
+-- Auctions without a seller are brand new motorcycles put up by the admin
+CREATE TABLE IF NOT EXISTS Auctions (
+  Id int NOT NULL AUTO_INCREMENT,
+  Seller varchar(32),
+  MotorcycleId int NOT NULL,
+  Level int NOT NULL DEFAULT 1,
+  ReservePrice int NOT NULL,
+  EndsAt timestamp NOT NULL,
+  Settled boolean NOT NULL DEFAULT FALSE,
+  PRIMARY KEY (Id),
+  FOREIGN KEY (Seller) REFERENCES Users(Username),
+  FOREIGN KEY (MotorcycleId) REFERENCES Motorcycles(Id),
+  CHECK (ReservePrice > 0)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS Bids (
+  Id int NOT NULL AUTO_INCREMENT,
+  AuctionId int NOT NULL,
+  Username varchar(32) NOT NULL,
+  Amount int NOT NULL,
+  Time timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Id),
+  FOREIGN KEY (AuctionId) REFERENCES Auctions(Id),
+  FOREIGN KEY (Username) REFERENCES Users(Username)
+) ENGINE=InnoDB;
+
+CREATE OR REPLACE VIEW DetailedAuctions AS
+SELECT A.Id, COALESCE(A.Seller, '') AS Seller, A.MotorcycleId, M.Name, COALESCE(O.Level, A.Level) AS Level, A.ReservePrice, A.EndsAt,
+  COALESCE(B.Username, '') AS HighestBidder, COALESCE(B.Amount, 0) AS HighestBid, A.Settled
+FROM Auctions A
+INNER JOIN Motorcycles M ON A.MotorcycleId=M.Id
+LEFT JOIN Owners O ON A.Seller=O.Username AND A.MotorcycleId=O.MotorcycleId
+LEFT JOIN Bids B ON B.Id=(SELECT MAX(Id) FROM Bids WHERE AuctionId=A.Id);
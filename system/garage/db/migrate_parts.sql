@@ -0,0 +1,50 @@
+-- Migration of an existing Garage database to equippable parts.
+-- Apply after migrate_price_curves.sql.
+USE Garage;
+
+CREATE TABLE IF NOT EXISTS Parts (
+  Id int NOT NULL AUTO_INCREMENT,
+  Name varchar(32) NOT NULL,
+  Type enum('tires', 'exhaust', 'ecu') NOT NULL,
+  Price int NOT NULL,
+  Engine int NOT NULL DEFAULT 0,
+  Agility int NOT NULL DEFAULT 0,
+  Brakes int NOT NULL DEFAULT 0,
+  Aerodynamics int NOT NULL DEFAULT 0,
+  PRIMARY KEY (Id),
+  CHECK (Price > 0)
+) ENGINE=InnoDB;
+
+-- Parts owned by users, MotorcycleId is set when equipped to a motorcycle of the same user
+CREATE TABLE IF NOT EXISTS Inventory (
+  Id int NOT NULL AUTO_INCREMENT,
+  Username varchar(32) NOT NULL,
+  PartId int NOT NULL,
+  MotorcycleId int,
+  PRIMARY KEY (Id),
+  FOREIGN KEY (Username) REFERENCES Users(Username),
+  FOREIGN KEY (PartId) REFERENCES Parts(Id),
+  FOREIGN KEY (MotorcycleId) REFERENCES Motorcycles(Id)
+) ENGINE=InnoDB;
+
+-- Parts shipped with setup.sql
+INSERT IGNORE INTO Parts VALUES (1, "Racing Slicks", "tires", 40, 0, 3, 2, 0), (2, "Rain Tires", "tires", 25, 0, 1, 3, 0), (3, "Titanium Exhaust", "exhaust", 60, 4, 0, 0, 1), (4, "Race ECU Map", "ecu", 80, 6, -1, 0, 0);
+
+CREATE OR REPLACE VIEW EquippedParts AS
+SELECT I.Username, I.MotorcycleId, SUM(P.Engine) AS Engine, SUM(P.Agility) AS Agility, SUM(P.Brakes) AS Brakes, SUM(P.Aerodynamics) AS Aerodynamics
+FROM Inventory I
+INNER JOIN Parts P ON I.PartId=P.Id
+WHERE I.MotorcycleId IS NOT NULL
+GROUP BY I.Username, I.MotorcycleId;
+
+CREATE OR REPLACE VIEW DetailedOwnership AS
+SELECT O.Username, O.MotorcycleId, (O.EngineLevel+O.AgilityLevel+O.BrakesLevel+O.AerodynamicsLevel) DIV 4 AS Level,
+  O.EngineLevel, O.AgilityLevel, O.BrakesLevel, O.AerodynamicsLevel, M.Name, M.PriceToBuy, M.MaxLevel,
+  M.Engine+M.EngineIncrement*O.EngineLevel+COALESCE(E.Engine, 0) AS Engine, M.EngineIncrement, M.EngineMaxLevel, M.EnginePriceToUpgrade,
+  M.Agility+M.AgilityIncrement*O.AgilityLevel+COALESCE(E.Agility, 0) AS Agility, M.AgilityIncrement, M.AgilityMaxLevel, M.AgilityPriceToUpgrade,
+  M.Brakes+M.BrakesIncrement*O.BrakesLevel+COALESCE(E.Brakes, 0) AS Brakes, M.BrakesIncrement, M.BrakesMaxLevel, M.BrakesPriceToUpgrade,
+  M.Aerodynamics+M.AerodynamicsIncrement*O.AerodynamicsLevel+COALESCE(E.Aerodynamics, 0) AS Aerodynamics, M.AerodynamicsIncrement, M.AerodynamicsMaxLevel, M.AerodynamicsPriceToUpgrade,
+  M.PriceCurve, M.PriceCurveFactor
+FROM Owners O
+INNER JOIN DetailedMotorcycles M ON O.MotorcycleId=M.Id
+LEFT JOIN EquippedParts E ON O.Username=E.Username AND O.MotorcycleId=E.MotorcycleId;
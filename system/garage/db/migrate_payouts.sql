@@ -0,0 +1,8 @@
+-- Migration of an existing Garage database to payouts claimed before paying championship rewards.
+USE Garage;
+
+CREATE TABLE IF NOT EXISTS Payouts (
+  PayoutKey varchar(128) NOT NULL, -- each reward of a championship or of one of its rounds, paid by the Orchestrator
+  ClaimedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (PayoutKey)
+) ENGINE=InnoDB;
@@ -0,0 +1,43 @@
+-- Migration of an existing Garage database to configurable upgrade price curves.
+-- The motorcycles shipped with setup.sql get its curves, so their upgrade prices change,
+-- any other motorcycle gets a flat linear curve and keeps its prices until configured.
+-- Apply after migrate_stat_levels.sql, it can be applied again.
+USE Garage;
+
+ALTER TABLE Motorcycles
+  ADD COLUMN IF NOT EXISTS PriceCurve enum('linear', 'exponential', 'table') NOT NULL DEFAULT 'linear',
+  ADD COLUMN IF NOT EXISTS PriceCurveFactor int NOT NULL DEFAULT 0,
+  ADD CONSTRAINT IF NOT EXISTS PriceCurveFactorPositive CHECK (PriceCurveFactor >= 0);
+
+CREATE TABLE IF NOT EXISTS UpgradePrices (
+  MotorcycleId int NOT NULL,
+  Stat enum('Engine', 'Agility', 'Brakes', 'Aerodynamics') NOT NULL,
+  Level int NOT NULL,
+  Price int NOT NULL,
+  PRIMARY KEY (MotorcycleId, Stat, Level),
+  FOREIGN KEY (MotorcycleId) REFERENCES Motorcycles(Id),
+  CHECK (Price > 0)
+) ENGINE=InnoDB;
+
+-- Curves of the motorcycles shipped with setup.sql
+UPDATE Motorcycles SET PriceCurve='exponential', PriceCurveFactor=10 WHERE Id=1 AND Name="Ducati Panigale V4";
+UPDATE Motorcycles SET PriceCurve='linear', PriceCurveFactor=2 WHERE Id=2 AND Name="KTM SuperDuke 1290 RR";
+
+CREATE OR REPLACE VIEW DetailedMotorcycles AS
+SELECT Id, Name, PriceToBuy, (EngineMaxLevel+AgilityMaxLevel+BrakesMaxLevel+AerodynamicsMaxLevel) DIV 4 AS MaxLevel,
+  Engine, EngineIncrement, EngineMaxLevel, EnginePriceToUpgrade,
+  Agility, AgilityIncrement, AgilityMaxLevel, AgilityPriceToUpgrade,
+  Brakes, BrakesIncrement, BrakesMaxLevel, BrakesPriceToUpgrade,
+  Aerodynamics, AerodynamicsIncrement, AerodynamicsMaxLevel, AerodynamicsPriceToUpgrade, PriceCurve, PriceCurveFactor
+FROM Motorcycles;
+
+CREATE OR REPLACE VIEW DetailedOwnership AS
+SELECT O.Username, O.MotorcycleId, (O.EngineLevel+O.AgilityLevel+O.BrakesLevel+O.AerodynamicsLevel) DIV 4 AS Level,
+  O.EngineLevel, O.AgilityLevel, O.BrakesLevel, O.AerodynamicsLevel, M.Name, M.PriceToBuy, M.MaxLevel,
+  M.Engine+M.EngineIncrement*O.EngineLevel AS Engine, M.EngineIncrement, M.EngineMaxLevel, M.EnginePriceToUpgrade,
+  M.Agility+M.AgilityIncrement*O.AgilityLevel AS Agility, M.AgilityIncrement, M.AgilityMaxLevel, M.AgilityPriceToUpgrade,
+  M.Brakes+M.BrakesIncrement*O.BrakesLevel AS Brakes, M.BrakesIncrement, M.BrakesMaxLevel, M.BrakesPriceToUpgrade,
+  M.Aerodynamics+M.AerodynamicsIncrement*O.AerodynamicsLevel AS Aerodynamics, M.AerodynamicsIncrement, M.AerodynamicsMaxLevel, M.AerodynamicsPriceToUpgrade,
+  M.PriceCurve, M.PriceCurveFactor
+FROM Owners O
+INNER JOIN DetailedMotorcycles M ON O.MotorcycleId=M.Id;
@@ -0,0 +1,92 @@
+-- Migration of an existing Garage database to per-stat upgrades.
+-- Every stat gets the level, max level and upgrade price the motorcycle had as a whole.
+-- Apply after migrate_marketplace.sql and before migrate_price_curves.sql.
+USE Garage;
+
+DROP TRIGGER IF EXISTS OwnersBeforeUpdate;
+
+DELIMITER $$
+
+CREATE OR REPLACE PROCEDURE MigrateStatLevels()
+BEGIN
+  -- Nothing left to do once the single level is gone
+  IF EXISTS (SELECT * FROM information_schema.COLUMNS WHERE TABLE_SCHEMA='Garage' AND TABLE_NAME='Owners' AND COLUMN_NAME='Level') THEN
+    ALTER TABLE Motorcycles
+      ADD COLUMN IF NOT EXISTS EngineMaxLevel int NOT NULL DEFAULT 0 AFTER EngineIncrement,
+      ADD COLUMN IF NOT EXISTS EnginePriceToUpgrade int NOT NULL DEFAULT 0 AFTER EngineMaxLevel,
+      ADD COLUMN IF NOT EXISTS AgilityMaxLevel int NOT NULL DEFAULT 0 AFTER AgilityIncrement,
+      ADD COLUMN IF NOT EXISTS AgilityPriceToUpgrade int NOT NULL DEFAULT 0 AFTER AgilityMaxLevel,
+      ADD COLUMN IF NOT EXISTS BrakesMaxLevel int NOT NULL DEFAULT 0 AFTER BrakesIncrement,
+      ADD COLUMN IF NOT EXISTS BrakesPriceToUpgrade int NOT NULL DEFAULT 0 AFTER BrakesMaxLevel,
+      ADD COLUMN IF NOT EXISTS AerodynamicsMaxLevel int NOT NULL DEFAULT 0 AFTER AerodynamicsIncrement,
+      ADD COLUMN IF NOT EXISTS AerodynamicsPriceToUpgrade int NOT NULL DEFAULT 0 AFTER AerodynamicsMaxLevel;
+
+    ALTER TABLE Owners
+      ADD COLUMN IF NOT EXISTS EngineLevel int NOT NULL DEFAULT 1 AFTER MotorcycleId,
+      ADD COLUMN IF NOT EXISTS AgilityLevel int NOT NULL DEFAULT 1 AFTER EngineLevel,
+      ADD COLUMN IF NOT EXISTS BrakesLevel int NOT NULL DEFAULT 1 AFTER AgilityLevel,
+      ADD COLUMN IF NOT EXISTS AerodynamicsLevel int NOT NULL DEFAULT 1 AFTER BrakesLevel;
+
+    UPDATE Motorcycles SET EngineMaxLevel=MaxLevel, EnginePriceToUpgrade=PriceToUpgrade,
+      AgilityMaxLevel=MaxLevel, AgilityPriceToUpgrade=PriceToUpgrade,
+      BrakesMaxLevel=MaxLevel, BrakesPriceToUpgrade=PriceToUpgrade,
+      AerodynamicsMaxLevel=MaxLevel, AerodynamicsPriceToUpgrade=PriceToUpgrade;
+
+    UPDATE Owners SET EngineLevel=Level, AgilityLevel=Level, BrakesLevel=Level, AerodynamicsLevel=Level;
+
+    ALTER TABLE Motorcycles DROP COLUMN PriceToUpgrade, DROP COLUMN MaxLevel;
+    ALTER TABLE Owners DROP COLUMN Level;
+  END IF;
+END$$
+
+CREATE TRIGGER OwnersBeforeUpdate
+BEFORE UPDATE ON Owners
+FOR EACH ROW
+BEGIN
+  DECLARE engine_max_level INT;
+  DECLARE agility_max_level INT;
+  DECLARE brakes_max_level INT;
+  DECLARE aerodynamics_max_level INT;
+
+  SELECT EngineMaxLevel, AgilityMaxLevel, BrakesMaxLevel, AerodynamicsMaxLevel
+  INTO engine_max_level, agility_max_level, brakes_max_level, aerodynamics_max_level
+  FROM Motorcycles
+  WHERE Id = NEW.MotorcycleId;
+
+  IF NEW.EngineLevel > engine_max_level OR NEW.AgilityLevel > agility_max_level
+    OR NEW.BrakesLevel > brakes_max_level OR NEW.AerodynamicsLevel > aerodynamics_max_level THEN
+    SIGNAL SQLSTATE '45000'
+    SET MESSAGE_TEXT = 'Max Level Reached';
+  END IF;
+END$$
+
+DELIMITER ;
+
+CALL MigrateStatLevels();
+DROP PROCEDURE MigrateStatLevels;
+
+CREATE OR REPLACE VIEW DetailedMotorcycles AS
+SELECT Id, Name, PriceToBuy, (EngineMaxLevel+AgilityMaxLevel+BrakesMaxLevel+AerodynamicsMaxLevel) DIV 4 AS MaxLevel,
+  Engine, EngineIncrement, EngineMaxLevel, EnginePriceToUpgrade,
+  Agility, AgilityIncrement, AgilityMaxLevel, AgilityPriceToUpgrade,
+  Brakes, BrakesIncrement, BrakesMaxLevel, BrakesPriceToUpgrade,
+  Aerodynamics, AerodynamicsIncrement, AerodynamicsMaxLevel, AerodynamicsPriceToUpgrade
+FROM Motorcycles;
+
+CREATE OR REPLACE VIEW DetailedOwnership AS
+SELECT O.Username, O.MotorcycleId, (O.EngineLevel+O.AgilityLevel+O.BrakesLevel+O.AerodynamicsLevel) DIV 4 AS Level,
+  O.EngineLevel, O.AgilityLevel, O.BrakesLevel, O.AerodynamicsLevel, M.Name, M.PriceToBuy, M.MaxLevel,
+  M.Engine+M.EngineIncrement*O.EngineLevel AS Engine, M.EngineIncrement, M.EngineMaxLevel, M.EnginePriceToUpgrade,
+  M.Agility+M.AgilityIncrement*O.AgilityLevel AS Agility, M.AgilityIncrement, M.AgilityMaxLevel, M.AgilityPriceToUpgrade,
+  M.Brakes+M.BrakesIncrement*O.BrakesLevel AS Brakes, M.BrakesIncrement, M.BrakesMaxLevel, M.BrakesPriceToUpgrade,
+  M.Aerodynamics+M.AerodynamicsIncrement*O.AerodynamicsLevel AS Aerodynamics, M.AerodynamicsIncrement, M.AerodynamicsMaxLevel, M.AerodynamicsPriceToUpgrade
+FROM Owners O
+INNER JOIN DetailedMotorcycles M ON O.MotorcycleId=M.Id;
+
+CREATE OR REPLACE VIEW DetailedAuctions AS
+SELECT A.Id, COALESCE(A.Seller, '') AS Seller, A.MotorcycleId, M.Name, COALESCE(D.Level, A.Level) AS Level, A.ReservePrice, A.EndsAt,
+  COALESCE(B.Username, '') AS HighestBidder, COALESCE(B.Amount, 0) AS HighestBid, A.Settled
+FROM Auctions A
+INNER JOIN Motorcycles M ON A.MotorcycleId=M.Id
+LEFT JOIN DetailedOwnership D ON A.Seller=D.Username AND A.MotorcycleId=D.MotorcycleId
+LEFT JOIN Bids B ON B.Id=(SELECT MAX(Id) FROM Bids WHERE AuctionId=A.Id);
@@ -0,0 +1,19 @@
+-- Migration of an existing Garage database to motorcycle wear.
+-- Apply after migrate_parts.sql.
+USE Garage;
+
+ALTER TABLE Owners
+  ADD COLUMN IF NOT EXISTS Wear int NOT NULL DEFAULT 0,
+  ADD CONSTRAINT IF NOT EXISTS WearRange CHECK (Wear BETWEEN 0 AND 100);
+
+CREATE OR REPLACE VIEW DetailedOwnership AS
+SELECT O.Username, O.MotorcycleId, (O.EngineLevel+O.AgilityLevel+O.BrakesLevel+O.AerodynamicsLevel) DIV 4 AS Level,
+  O.EngineLevel, O.AgilityLevel, O.BrakesLevel, O.AerodynamicsLevel, M.Name, M.PriceToBuy, M.MaxLevel,
+  (M.Engine+M.EngineIncrement*O.EngineLevel+COALESCE(E.Engine, 0))*(200-O.Wear) DIV 200 AS Engine, M.EngineIncrement, M.EngineMaxLevel, M.EnginePriceToUpgrade,
+  (M.Agility+M.AgilityIncrement*O.AgilityLevel+COALESCE(E.Agility, 0))*(200-O.Wear) DIV 200 AS Agility, M.AgilityIncrement, M.AgilityMaxLevel, M.AgilityPriceToUpgrade,
+  (M.Brakes+M.BrakesIncrement*O.BrakesLevel+COALESCE(E.Brakes, 0))*(200-O.Wear) DIV 200 AS Brakes, M.BrakesIncrement, M.BrakesMaxLevel, M.BrakesPriceToUpgrade,
+  (M.Aerodynamics+M.AerodynamicsIncrement*O.AerodynamicsLevel+COALESCE(E.Aerodynamics, 0))*(200-O.Wear) DIV 200 AS Aerodynamics, M.AerodynamicsIncrement, M.AerodynamicsMaxLevel, M.AerodynamicsPriceToUpgrade,
+  M.PriceCurve, M.PriceCurveFactor, O.Wear
+FROM Owners O
+INNER JOIN DetailedMotorcycles M ON O.MotorcycleId=M.Id
+LEFT JOIN EquippedParts E ON O.Username=E.Username AND O.MotorcycleId=E.MotorcycleId;
@@ -15,37 +15,189 @@ CREATE TABLE IF NOT EXISTS Motorcycles (
   Id int NOT NULL AUTO_INCREMENT,
   Name varchar(32) NOT NULL,
   PriceToBuy int NOT NULL,
-  PriceToUpgrade int NOT NULL,
-  MaxLevel int NOT NULL,
   Engine int NOT NULL,
   EngineIncrement int NOT NULL,
+  EngineMaxLevel int NOT NULL,
+  EnginePriceToUpgrade int NOT NULL,
   Agility int NOT NULL,
   AgilityIncrement int NOT NULL,
+  AgilityMaxLevel int NOT NULL,
+  AgilityPriceToUpgrade int NOT NULL,
   Brakes int NOT NULL,
   BrakesIncrement int NOT NULL,
+  BrakesMaxLevel int NOT NULL,
+  BrakesPriceToUpgrade int NOT NULL,
   Aerodynamics int NOT NULL,
   AerodynamicsIncrement int NOT NULL,
-  PRIMARY KEY (Id)
+  AerodynamicsMaxLevel int NOT NULL,
+  AerodynamicsPriceToUpgrade int NOT NULL,
+  PriceCurve enum('linear', 'exponential', 'table') NOT NULL DEFAULT 'linear',
+  PriceCurveFactor int NOT NULL DEFAULT 0, -- money added per level (linear) or percent increase per level (exponential)
+  PRIMARY KEY (Id),
+  CHECK (PriceCurveFactor >= 0)
+) ENGINE=InnoDB;
+
+-- Prices of table-driven curves, Level is the one being upgraded from (missing levels cost the base price)
+DROP TABLE IF EXISTS UpgradePrices;
+CREATE TABLE IF NOT EXISTS UpgradePrices (
+  MotorcycleId int NOT NULL,
+  Stat enum('Engine', 'Agility', 'Brakes', 'Aerodynamics') NOT NULL,
+  Level int NOT NULL,
+  Price int NOT NULL,
+  PRIMARY KEY (MotorcycleId, Stat, Level),
+  FOREIGN KEY (MotorcycleId) REFERENCES Motorcycles(Id),
+  CHECK (Price > 0)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Paints;
+CREATE TABLE IF NOT EXISTS Paints (
+  Id int NOT NULL AUTO_INCREMENT,
+  Name varchar(32) NOT NULL,
+  Color char(7) NOT NULL, -- hex color used by the web pages
+  Price int NOT NULL,
+  PRIMARY KEY (Id),
+  CHECK (Price > 0)
 ) ENGINE=InnoDB;
 
 DROP TABLE IF EXISTS Owners;
 CREATE TABLE IF NOT EXISTS Owners (
   Username varchar(32) NOT NULL,
   MotorcycleId int NOT NULL,
-  Level int NOT NULL DEFAULT 1,
+  EngineLevel int NOT NULL DEFAULT 1,
+  AgilityLevel int NOT NULL DEFAULT 1,
+  BrakesLevel int NOT NULL DEFAULT 1,
+  AerodynamicsLevel int NOT NULL DEFAULT 1,
+  Wear int NOT NULL DEFAULT 0, -- percentage, effective stats lose half of it
+  PaintId int,
+  RaceNumber int,
+  RiderName varchar(32),
   PRIMARY KEY (Username, MotorcycleId),
   FOREIGN KEY (Username) REFERENCES Users(Username),
+  FOREIGN KEY (MotorcycleId) REFERENCES Motorcycles(Id),
+  FOREIGN KEY (PaintId) REFERENCES Paints(Id),
+  CHECK (Wear BETWEEN 0 AND 100),
+  CHECK (RaceNumber BETWEEN 1 AND 99)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Parts;
+CREATE TABLE IF NOT EXISTS Parts (
+  Id int NOT NULL AUTO_INCREMENT,
+  Name varchar(32) NOT NULL,
+  Type enum('tires', 'exhaust', 'ecu') NOT NULL,
+  Price int NOT NULL,
+  Engine int NOT NULL DEFAULT 0,
+  Agility int NOT NULL DEFAULT 0,
+  Brakes int NOT NULL DEFAULT 0,
+  Aerodynamics int NOT NULL DEFAULT 0,
+  PRIMARY KEY (Id),
+  CHECK (Price > 0)
+) ENGINE=InnoDB;
+
+-- Parts owned by users, MotorcycleId is set when equipped to a motorcycle of the same user
+DROP TABLE IF EXISTS Inventory;
+CREATE TABLE IF NOT EXISTS Inventory (
+  Id int NOT NULL AUTO_INCREMENT,
+  Username varchar(32) NOT NULL,
+  PartId int NOT NULL,
+  MotorcycleId int,
+  PRIMARY KEY (Id),
+  FOREIGN KEY (Username) REFERENCES Users(Username),
+  FOREIGN KEY (PartId) REFERENCES Parts(Id),
   FOREIGN KEY (MotorcycleId) REFERENCES Motorcycles(Id)
 ) ENGINE=InnoDB;
 
+DROP TABLE IF EXISTS Listings;
+CREATE TABLE IF NOT EXISTS Listings (
+  Id int NOT NULL AUTO_INCREMENT,
+  Seller varchar(32) NOT NULL,
+  MotorcycleId int NOT NULL,
+  Price int NOT NULL,
+  ExpiresAt timestamp NOT NULL,
+  PRIMARY KEY (Id),
+  UNIQUE (Seller, MotorcycleId),
+  FOREIGN KEY (Seller, MotorcycleId) REFERENCES Owners(Username, MotorcycleId) ON DELETE CASCADE,
+  CHECK (Price > 0)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Auctions;
+CREATE TABLE IF NOT EXISTS Auctions (
+  Id int NOT NULL AUTO_INCREMENT,
+  Seller varchar(32),
+  MotorcycleId int NOT NULL,
+  Level int NOT NULL DEFAULT 1,
+  ReservePrice int NOT NULL,
+  EndsAt timestamp NOT NULL,
+  Settled boolean NOT NULL DEFAULT FALSE,
+  PRIMARY KEY (Id),
+  FOREIGN KEY (Seller) REFERENCES Users(Username),
+  FOREIGN KEY (MotorcycleId) REFERENCES Motorcycles(Id),
+  CHECK (ReservePrice > 0)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Bids;
+CREATE TABLE IF NOT EXISTS Bids (
+  Id int NOT NULL AUTO_INCREMENT,
+  AuctionId int NOT NULL,
+  Username varchar(32) NOT NULL,
+  Amount int NOT NULL,
+  Time timestamp DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Id),
+  FOREIGN KEY (AuctionId) REFERENCES Auctions(Id),
+  FOREIGN KEY (Username) REFERENCES Users(Username)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS DailyRewards;
+CREATE TABLE IF NOT EXISTS DailyRewards (
+  Username varchar(32) NOT NULL,
+  LastClaim date, -- NULL until the first claim
+  Streak int NOT NULL DEFAULT 0, -- consecutive days with a claim
+  PRIMARY KEY (Username),
+  FOREIGN KEY (Username) REFERENCES Users(Username)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Payouts;
+CREATE TABLE IF NOT EXISTS Payouts (
+  PayoutKey varchar(128) NOT NULL, -- each reward of a championship or of one of its rounds, paid by the Orchestrator
+  ClaimedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (PayoutKey)
+) ENGINE=InnoDB;
+
+CREATE VIEW DetailedMotorcycles AS
+SELECT Id, Name, PriceToBuy, (EngineMaxLevel+AgilityMaxLevel+BrakesMaxLevel+AerodynamicsMaxLevel) DIV 4 AS MaxLevel,
+  Engine, EngineIncrement, EngineMaxLevel, EnginePriceToUpgrade,
+  Agility, AgilityIncrement, AgilityMaxLevel, AgilityPriceToUpgrade,
+  Brakes, BrakesIncrement, BrakesMaxLevel, BrakesPriceToUpgrade,
+  Aerodynamics, AerodynamicsIncrement, AerodynamicsMaxLevel, AerodynamicsPriceToUpgrade, PriceCurve, PriceCurveFactor
+FROM Motorcycles;
+
+CREATE VIEW EquippedParts AS
+SELECT I.Username, I.MotorcycleId, SUM(P.Engine) AS Engine, SUM(P.Agility) AS Agility, SUM(P.Brakes) AS Brakes, SUM(P.Aerodynamics) AS Aerodynamics
+FROM Inventory I
+INNER JOIN Parts P ON I.PartId=P.Id
+WHERE I.MotorcycleId IS NOT NULL
+GROUP BY I.Username, I.MotorcycleId;
+
 CREATE VIEW DetailedOwnership AS
-SELECT O.Username, O.MotorcycleId, O.Level, M.Name, M.PriceToBuy, M.PriceToUpgrade, M.MaxLevel, 
-  M.Engine+M.EngineIncrement*O.Level, M.EngineIncrement,
-  M.Agility+M.AgilityIncrement*O.Level, M.AgilityIncrement,
-  M.Brakes+M.BrakesIncrement*O.Level, M.BrakesIncrement,
-  M.Aerodynamics+M.AerodynamicsIncrement*O.Level, M.AerodynamicsIncrement
+SELECT O.Username, O.MotorcycleId, (O.EngineLevel+O.AgilityLevel+O.BrakesLevel+O.AerodynamicsLevel) DIV 4 AS Level,
+  O.EngineLevel, O.AgilityLevel, O.BrakesLevel, O.AerodynamicsLevel, M.Name, M.PriceToBuy, M.MaxLevel,
+  (M.Engine+M.EngineIncrement*O.EngineLevel+COALESCE(E.Engine, 0))*(200-O.Wear) DIV 200 AS Engine, M.EngineIncrement, M.EngineMaxLevel, M.EnginePriceToUpgrade,
+  (M.Agility+M.AgilityIncrement*O.AgilityLevel+COALESCE(E.Agility, 0))*(200-O.Wear) DIV 200 AS Agility, M.AgilityIncrement, M.AgilityMaxLevel, M.AgilityPriceToUpgrade,
+  (M.Brakes+M.BrakesIncrement*O.BrakesLevel+COALESCE(E.Brakes, 0))*(200-O.Wear) DIV 200 AS Brakes, M.BrakesIncrement, M.BrakesMaxLevel, M.BrakesPriceToUpgrade,
+  (M.Aerodynamics+M.AerodynamicsIncrement*O.AerodynamicsLevel+COALESCE(E.Aerodynamics, 0))*(200-O.Wear) DIV 200 AS Aerodynamics, M.AerodynamicsIncrement, M.AerodynamicsMaxLevel, M.AerodynamicsPriceToUpgrade,
+  M.PriceCurve, M.PriceCurveFactor, O.Wear,
+  COALESCE(P.Name, '') AS Paint, COALESCE(P.Color, '') AS PaintColor, COALESCE(O.RaceNumber, 0) AS RaceNumber, COALESCE(O.RiderName, '') AS RiderName
 FROM Owners O
-INNER JOIN Motorcycles M ON O.MotorcycleId=M.Id;
+INNER JOIN DetailedMotorcycles M ON O.MotorcycleId=M.Id
+LEFT JOIN EquippedParts E ON O.Username=E.Username AND O.MotorcycleId=E.MotorcycleId
+LEFT JOIN Paints P ON O.PaintId=P.Id;
+
+CREATE VIEW DetailedAuctions AS
+SELECT A.Id, COALESCE(A.Seller, '') AS Seller, A.MotorcycleId, M.Name, COALESCE(D.Level, A.Level) AS Level, A.ReservePrice, A.EndsAt,
+  COALESCE(B.Username, '') AS HighestBidder, COALESCE(B.Amount, 0) AS HighestBid, A.Settled
+FROM Auctions A
+INNER JOIN Motorcycles M ON A.MotorcycleId=M.Id
+LEFT JOIN DetailedOwnership D ON A.Seller=D.Username AND A.MotorcycleId=D.MotorcycleId
+LEFT JOIN Bids B ON B.Id=(SELECT MAX(Id) FROM Bids WHERE AuctionId=A.Id);
 
 DELIMITER $$
 
@@ -53,13 +205,18 @@ CREATE TRIGGER OwnersBeforeUpdate
 BEFORE UPDATE ON Owners
 FOR EACH ROW
 BEGIN
-  DECLARE max_level INT;
+  DECLARE engine_max_level INT;
+  DECLARE agility_max_level INT;
+  DECLARE brakes_max_level INT;
+  DECLARE aerodynamics_max_level INT;
 
-  SELECT MaxLevel INTO max_level
+  SELECT EngineMaxLevel, AgilityMaxLevel, BrakesMaxLevel, AerodynamicsMaxLevel
+  INTO engine_max_level, agility_max_level, brakes_max_level, aerodynamics_max_level
   FROM Motorcycles
   WHERE Id = NEW.MotorcycleId;
 
-  IF NEW.Level > max_level THEN
+  IF NEW.EngineLevel > engine_max_level OR NEW.AgilityLevel > agility_max_level
+    OR NEW.BrakesLevel > brakes_max_level OR NEW.AerodynamicsLevel > aerodynamics_max_level THEN
     SIGNAL SQLSTATE '45000'
     SET MESSAGE_TEXT = 'Max Level Reached';
   END IF;
@@ -67,6 +224,14 @@ END$$
 
 DELIMITER ;
 
-INSERT INTO Users VALUES ("user", 1000), ("foo", 20), ("test", 0);
-INSERT INTO Motorcycles VALUES (1, "Ducati Panigale V4", 100, 20, 15, 10, 3, 8, 2, 12, 2, 15, 5), (2, "KTM SuperDuke 1290 RR", 120, 15, 10, 16, 5, 5, 1, 10, 3, 8, 3);
-INSERT INTO Owners VALUES ("user", 1, 1), ("foo", 2, 9), ("test", 1, 1);
\ No newline at end of file
+INSERT INTO Users VALUES ("user", 1000), ("foo", 50), ("test", 0), ("seller", 0), ("buyer1", 500), ("buyer2", 500), ("auctioneer", 0), ("bidder1", 500), ("bidder2", 350), ("tuner", 200), ("mechanic", 100), ("racer", 20), ("stylist", 100), ("loyal", 0), ("returning", 0), ("claimed", 0), ("donor", 50);
+INSERT INTO Motorcycles VALUES (1, "Ducati Panigale V4", 100, 10, 3, 15, 25, 8, 2, 12, 15, 12, 2, 15, 20, 15, 5, 10, 20, "exponential", 10), (2, "KTM SuperDuke 1290 RR", 120, 16, 5, 10, 15, 5, 1, 12, 10, 10, 3, 10, 15, 8, 3, 10, 15, "linear", 2), (3, "Yamaha YZF-R1", 110, 14, 4, 10, 20, 7, 2, 10, 15, 11, 2, 10, 15, 12, 3, 10, 15, "table", 0);
+INSERT INTO UpgradePrices VALUES (3, "Engine", 1, 40), (3, "Engine", 2, 60);
+INSERT INTO Paints (Name, Color, Price) VALUES ("Racing Red", "#c8102e", 30), ("Factory Orange", "#ff6600", 30), ("Midnight Black", "#111111", 20), ("Pearl White", "#f5f5f0", 20);
+INSERT INTO Parts (Name, Type, Price, Engine, Agility, Brakes, Aerodynamics) VALUES ("Racing Slicks", "tires", 40, 0, 3, 2, 0), ("Rain Tires", "tires", 25, 0, 1, 3, 0), ("Titanium Exhaust", "exhaust", 60, 4, 0, 0, 1), ("Race ECU Map", "ecu", 80, 6, -1, 0, 0);
+INSERT INTO Owners VALUES ("user", 1, 1, 1, 1, 1, 0, NULL, NULL, NULL), ("foo", 2, 9, 9, 9, 9, 0, NULL, NULL, NULL), ("test", 1, 1, 1, 1, 1, 0, NULL, NULL, NULL), ("seller", 1, 3, 3, 3, 3, 0, NULL, NULL, NULL), ("seller", 2, 7, 7, 7, 7, 0, NULL, NULL, NULL), ("auctioneer", 2, 4, 4, 4, 4, 0, NULL, NULL, NULL), ("tuner", 1, 3, 3, 3, 3, 0, NULL, NULL, NULL), ("tuner", 3, 1, 1, 1, 1, 0, NULL, NULL, NULL), ("mechanic", 1, 1, 1, 1, 1, 0, NULL, NULL, NULL), ("mechanic", 2, 1, 1, 1, 1, 0, NULL, NULL, NULL), ("racer", 1, 1, 1, 1, 1, 60, NULL, NULL, NULL), ("racer", 2, 1, 1, 1, 1, 10, NULL, NULL, NULL), ("stylist", 2, 1, 1, 1, 1, 0, 3, 46, "Vale");
+INSERT INTO Inventory VALUES (1, "mechanic", 1, 1), (2, "mechanic", 2, NULL), (3, "mechanic", 3, NULL);
+INSERT INTO Listings VALUES (1, "seller", 2, 100, CURRENT_TIMESTAMP + INTERVAL 1 DAY), (2, "seller", 1, 50, CURRENT_TIMESTAMP - INTERVAL 1 DAY);
+INSERT INTO Auctions VALUES (1, NULL, 1, 5, 100, CURRENT_TIMESTAMP + INTERVAL 1 HOUR, FALSE), (2, "auctioneer", 2, 1, 50, CURRENT_TIMESTAMP - INTERVAL 1 MINUTE, FALSE), (3, NULL, 2, 3, 10, CURRENT_TIMESTAMP + INTERVAL 30 SECOND, FALSE);
+INSERT INTO Bids (AuctionId, Username, Amount) VALUES (2, "bidder2", 150);
+INSERT INTO DailyRewards VALUES ("loyal", CURRENT_DATE - INTERVAL 1 DAY, 2), ("returning", CURRENT_DATE - INTERVAL 3 DAY, 5), ("claimed", CURRENT_DATE, 1);
\ No newline at end of file
@@ -4,69 +4,260 @@ import (
 	"context"
 	"database/sql"
 	"errors"
-	"log"
+	"fmt"
+	"log/slog"
 	"time"
+	"unicode/utf8"
+
+	"garage/telemetry"
 )
 
 type Motorcycle struct {
-	Id                    int
-	Name                  string
-	PriceToBuy            int
-	PriceToUpgrade        int
-	MaxLevel              int
-	Engine                int
-	EngineIncrement       int
-	Agility               int
-	AgilityIncrement      int
-	Brakes                int
-	BrakesIncrement       int
-	Aerodynamics          int
-	AerodynamicsIncrement int
+	Id                         int
+	Name                       string
+	PriceToBuy                 int
+	MaxLevel                   int
+	Engine                     int
+	EngineIncrement            int
+	EngineMaxLevel             int
+	EnginePriceToUpgrade       int
+	Agility                    int
+	AgilityIncrement           int
+	AgilityMaxLevel            int
+	AgilityPriceToUpgrade      int
+	Brakes                     int
+	BrakesIncrement            int
+	BrakesMaxLevel             int
+	BrakesPriceToUpgrade       int
+	Aerodynamics               int
+	AerodynamicsIncrement      int
+	AerodynamicsMaxLevel       int
+	AerodynamicsPriceToUpgrade int
+	PriceCurve                 string
+	PriceCurveFactor           int
 }
 
 type Ownership struct {
-	Username              string
-	MotorcycleId          int
-	Name                  string
-	Level                 int
-	PriceToBuy            int
-	PriceToUpgrade        int
-	MaxLevel              int
-	Engine                int
-	EngineIncrement       int
-	Agility               int
-	AgilityIncrement      int
-	Brakes                int
-	BrakesIncrement       int
-	Aerodynamics          int
-	AerodynamicsIncrement int
+	Username                   string
+	MotorcycleId               int
+	Level                      int
+	EngineLevel                int
+	AgilityLevel               int
+	BrakesLevel                int
+	AerodynamicsLevel          int
+	Name                       string
+	PriceToBuy                 int
+	MaxLevel                   int
+	Engine                     int
+	EngineIncrement            int
+	EngineMaxLevel             int
+	EnginePriceToUpgrade       int
+	Agility                    int
+	AgilityIncrement           int
+	AgilityMaxLevel            int
+	AgilityPriceToUpgrade      int
+	Brakes                     int
+	BrakesIncrement            int
+	BrakesMaxLevel             int
+	BrakesPriceToUpgrade       int
+	Aerodynamics               int
+	AerodynamicsIncrement      int
+	AerodynamicsMaxLevel       int
+	AerodynamicsPriceToUpgrade int
+	PriceCurve                 string
+	PriceCurveFactor           int
+	Wear                       int
+	Paint                      string
+	PaintColor                 string
+	RaceNumber                 int
+	RiderName                  string
+
+	// Evaluated on the price curve, not stored
+	EngineNextUpgradePrice       int
+	AgilityNextUpgradePrice      int
+	BrakesNextUpgradePrice       int
+	AerodynamicsNextUpgradePrice int
+	RepairPrice                  int
+}
+
+// Stats that can be upgraded individually, mapped to the prefix of their columns
+var upgradableStats = map[string]string{
+	"engine":       "Engine",
+	"agility":      "Agility",
+	"brakes":       "Brakes",
+	"aerodynamics": "Aerodynamics",
+}
+
+// Destinations for scanning a row of DetailedMotorcycles
+func (m *Motorcycle) fields() []any {
+	return []any{&m.Id, &m.Name, &m.PriceToBuy, &m.MaxLevel,
+		&m.Engine, &m.EngineIncrement, &m.EngineMaxLevel, &m.EnginePriceToUpgrade,
+		&m.Agility, &m.AgilityIncrement, &m.AgilityMaxLevel, &m.AgilityPriceToUpgrade,
+		&m.Brakes, &m.BrakesIncrement, &m.BrakesMaxLevel, &m.BrakesPriceToUpgrade,
+		&m.Aerodynamics, &m.AerodynamicsIncrement, &m.AerodynamicsMaxLevel, &m.AerodynamicsPriceToUpgrade,
+		&m.PriceCurve, &m.PriceCurveFactor}
+}
+
+// Destinations for scanning a row of DetailedOwnership
+func (o *Ownership) fields() []any {
+	return []any{&o.Username, &o.MotorcycleId, &o.Level,
+		&o.EngineLevel, &o.AgilityLevel, &o.BrakesLevel, &o.AerodynamicsLevel, &o.Name, &o.PriceToBuy, &o.MaxLevel,
+		&o.Engine, &o.EngineIncrement, &o.EngineMaxLevel, &o.EnginePriceToUpgrade,
+		&o.Agility, &o.AgilityIncrement, &o.AgilityMaxLevel, &o.AgilityPriceToUpgrade,
+		&o.Brakes, &o.BrakesIncrement, &o.BrakesMaxLevel, &o.BrakesPriceToUpgrade,
+		&o.Aerodynamics, &o.AerodynamicsIncrement, &o.AerodynamicsMaxLevel, &o.AerodynamicsPriceToUpgrade,
+		&o.PriceCurve, &o.PriceCurveFactor, &o.Wear,
+		&o.Paint, &o.PaintColor, &o.RaceNumber, &o.RiderName}
+}
+
+type Part struct {
+	Id           int
+	Name         string
+	Type         string // tires, exhaust or ecu, at most one of each type equipped per motorcycle
+	Price        int
+	Engine       int
+	Agility      int
+	Brakes       int
+	Aerodynamics int
+}
+
+type Item struct {
+	Id           int
+	MotorcycleId int // 0 if not equipped
+	Part
+}
+
+type Paint struct {
+	Id    int
+	Name  string
+	Color string
+	Price int
+}
+
+type DailyReward struct {
+	Claimed bool // false if the reward of today was already claimed
+	Streak  int
+	Money   int
 }
 
+// Prices of personalising a motorcycle besides its paint
+const (
+	raceNumberPrice = 10
+	riderNamePrice  = 10
+)
+
+type Listing struct {
+	Id        int
+	Price     int
+	ExpiresAt time.Time
+	Ownership
+}
+
+type Auction struct {
+	Id             int
+	Seller         string // empty for auctions on behalf of the house
+	MotorcycleId   int
+	MotorcycleName string
+	Level          int
+	ReservePrice   int
+	EndsAt         time.Time
+	HighestBidder  string
+	HighestBid     int
+	Settled        bool
+}
+
+type Bid struct {
+	Id        int
+	AuctionId int
+	Username  string
+	Amount    int
+	Time      time.Time
+}
+
+// Bids placed when less than this is left extend the auction, preventing last second sniping
+const auctionExtension = 2 * time.Minute
+
+var errMotorcycleNotOwned = errors.New("motorcycle not owned")
+
 type GarageDB interface {
-	GetRemainingMotorcycles(username string) ([]*Motorcycle, error)
-	GetUserMotorcycles(username string) ([]*Ownership, error)
-	GetUserMotorcycleStats(username string, MotorcycleId int) (*Ownership, error)
-	GetUserMoney(username string) (int, error)
-	IncreaseUserMoney(username string, value int) error
-	BuyMotorcycle(username string, MotorcycleId int) error
-	UpgradeMotorcycle(username string, MotorcycleId int) error
+	GetRemainingMotorcycles(ctx context.Context, username string) ([]*Motorcycle, error)
+	GetUserMotorcycles(ctx context.Context, username string) ([]*Ownership, error)
+	GetUserMotorcycleStats(ctx context.Context, username string, MotorcycleId int) (*Ownership, error)
+	GetUserMoney(ctx context.Context, username string) (int, error)
+	IncreaseUserMoney(ctx context.Context, username string, value int) error
+	DecreaseUserMoney(ctx context.Context, username string, value int) error
+	BuyMotorcycle(ctx context.Context, username string, MotorcycleId int) error
+	UpgradeMotorcycle(ctx context.Context, username string, MotorcycleId int, stat string) error
+	GetListings(ctx context.Context) ([]*Listing, error)
+	CreateListing(ctx context.Context, username string, MotorcycleId int, price int, duration time.Duration) error
+	CancelListing(ctx context.Context, username string, ListingId int) error
+	BuyListing(ctx context.Context, username string, ListingId int) error
+	RemoveExpiredListings(ctx context.Context) error
+	GetAuctions(ctx context.Context) ([]*Auction, error)
+	GetAuction(ctx context.Context, AuctionId int) (*Auction, error)
+	GetBids(ctx context.Context, AuctionId int, AfterBidId int) ([]*Bid, error)
+	CreateAuction(ctx context.Context, username string, MotorcycleId int, level int, reserve int, duration time.Duration) error
+	PlaceBid(ctx context.Context, username string, AuctionId int, amount int) (outbid string, e error)
+	SettleAuctions(ctx context.Context) error
+	GetParts(ctx context.Context) ([]*Part, error)
+	GetInventory(ctx context.Context, username string) ([]*Item, error)
+	BuyPart(ctx context.Context, username string, PartId int) error
+	AwardPart(ctx context.Context, username string) (*Part, error)
+	EquipPart(ctx context.Context, username string, ItemId int, MotorcycleId int) error
+	UnequipPart(ctx context.Context, username string, ItemId int) error
+	ApplyWear(ctx context.Context, username string, MotorcycleId int, wear int) error
+	RepairMotorcycle(ctx context.Context, username string, MotorcycleId int) error
+	GetPaints(ctx context.Context) ([]*Paint, error)
+	CustomizeMotorcycle(ctx context.Context, username string, MotorcycleId int, PaintId int, RaceNumber int, RiderName string) error
+	ClaimDailyReward(ctx context.Context, username string, reward int, bonus int, max_streak int) (*DailyReward, error)
+	ClaimPayout(ctx context.Context, key string) (bool, error)
+	ReleasePayout(ctx context.Context, key string) error
 }
 
 // Implementation for an SQL Database
 type SQL_DB struct {
-	db *sql.DB
+	db     *sql.DB
+	racing RacingStatus
+}
+
+func NewSQL_DB(conn *sql.DB, racing RacingStatus) *SQL_DB {
+	return &SQL_DB{db: conn, racing: racing}
 }
 
-func NewSQL_DB(conn *sql.DB) *SQL_DB {
-	return &SQL_DB{db: conn}
+func (s *SQL_DB) lockForTransfer(ctx context.Context, tx *sql.Tx, username string, MotorcycleId int) error {
+	// Lock the ownership of a motorcycle about to change owner, which must still belong to username
+	// and must not have been entered in a race since it was put on sale
+
+	var owned int
+	err := tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Owners WHERE Username=? AND MotorcycleId=? FOR UPDATE", username, MotorcycleId).Scan(&owned)
+	if err != nil {
+		slog.ErrorContext(ctx, "lockForTransfer failed", "error", err)
+		return err
+	}
+
+	if owned == 0 {
+		return errMotorcycleNotOwned
+	}
+
+	racing, err := s.racing.IsRacing(ctx, username, MotorcycleId)
+	if err != nil {
+		slog.ErrorContext(ctx, "lockForTransfer failed", "error", err)
+		return err
+	}
+
+	if racing {
+		return errors.New("motorcycle is racing")
+	}
+
+	return nil
 }
 
-func (s *SQL_DB) GetUserMotorcycles(username string) ([]*Ownership, error) {
+func (s *SQL_DB) GetUserMotorcycles(ctx context.Context, username string) ([]*Ownership, error) {
 	// Retrieve motorcycles owned by user
 
-	rows, err := s.db.Query("SELECT * FROM DetailedOwnership WHERE Username=?", username)
+	rows, err := s.db.QueryContext(ctx, "SELECT * FROM DetailedOwnership WHERE Username=?", username)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "GetUserMotorcycles failed", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -75,46 +266,52 @@ func (s *SQL_DB) GetUserMotorcycles(username string) ([]*Ownership, error) {
 
 	for rows.Next() {
 		var row Ownership
-		err := rows.Scan(&row.Username, &row.MotorcycleId, &row.Level,
-			&row.Name, &row.PriceToBuy, &row.PriceToUpgrade, &row.MaxLevel, &row.Engine, &row.EngineIncrement,
-			&row.Agility, &row.AgilityIncrement, &row.Brakes, &row.BrakesIncrement, &row.Aerodynamics, &row.AerodynamicsIncrement)
+		err := rows.Scan(row.fields()...)
 		if err != nil {
-			log.Println(err)
+			slog.ErrorContext(ctx, "GetUserMotorcycles failed", "error", err)
 			return nil, err
 		}
 		owned = append(owned, &row)
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "GetUserMotorcycles failed", "error", err)
 		return nil, err
 	}
 
+	for _, o := range owned {
+		if err := o.setPrices(ctx, s.db); err != nil {
+			return nil, err
+		}
+	}
+
 	return owned, nil
 }
 
-func (s *SQL_DB) GetUserMotorcycleStats(username string, MotorcycleId int) (*Ownership, error) {
+func (s *SQL_DB) GetUserMotorcycleStats(ctx context.Context, username string, MotorcycleId int) (*Ownership, error) {
 	// Get stats of specific motorcycle
 
-	row := s.db.QueryRow("SELECT * FROM DetailedOwnership WHERE Username=? AND MotorcycleId=?", username, MotorcycleId)
+	row := s.db.QueryRowContext(ctx, "SELECT * FROM DetailedOwnership WHERE Username=? AND MotorcycleId=?", username, MotorcycleId)
 	var owned Ownership
-	err := row.Scan(&owned.Username, &owned.MotorcycleId, &owned.Level,
-		&owned.Name, &owned.PriceToBuy, &owned.PriceToUpgrade, &owned.MaxLevel, &owned.Engine, &owned.EngineIncrement,
-		&owned.Agility, &owned.AgilityIncrement, &owned.Brakes, &owned.BrakesIncrement, &owned.Aerodynamics, &owned.AerodynamicsIncrement)
+	err := row.Scan(owned.fields()...)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "GetUserMotorcycleStats failed", "error", err)
+		return nil, err
+	}
+
+	if err := owned.setPrices(ctx, s.db); err != nil {
 		return nil, err
 	}
 
 	return &owned, nil
 }
 
-func (s *SQL_DB) GetRemainingMotorcycles(username string) ([]*Motorcycle, error) {
+func (s *SQL_DB) GetRemainingMotorcycles(ctx context.Context, username string) ([]*Motorcycle, error) {
 	// Retrieve motorcycle not owned
 
-	rows, err := s.db.Query("SELECT * FROM Motorcycles M WHERE M.Id NOT IN (SELECT O.MotorcycleId FROM Owners O WHERE O.Username=?)", username)
+	rows, err := s.db.QueryContext(ctx, "SELECT * FROM DetailedMotorcycles M WHERE M.Id NOT IN (SELECT O.MotorcycleId FROM Owners O WHERE O.Username=?)", username)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "GetRemainingMotorcycles failed", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -123,25 +320,24 @@ func (s *SQL_DB) GetRemainingMotorcycles(username string) ([]*Motorcycle, error)
 
 	for rows.Next() {
 		var row Motorcycle
-		err := rows.Scan(&row.Id, &row.Name, &row.PriceToBuy, &row.PriceToUpgrade, &row.MaxLevel, &row.Engine, &row.EngineIncrement,
-			&row.Agility, &row.AgilityIncrement, &row.Brakes, &row.BrakesIncrement, &row.Aerodynamics, &row.AerodynamicsIncrement)
+		err := rows.Scan(row.fields()...)
 		if err != nil {
-			log.Println(err)
+			slog.ErrorContext(ctx, "GetRemainingMotorcycles failed", "error", err)
 			return nil, err
 		}
 		not_owned = append(not_owned, &row)
 	}
 
 	if err := rows.Err(); err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "GetRemainingMotorcycles failed", "error", err)
 		return nil, err
 	}
 
 	return not_owned, nil
 }
 
-func (s *SQL_DB) GetUserMoney(username string) (int, error) {
-	row := s.db.QueryRow("SELECT Money FROM Users WHERE Username=?", username)
+func (s *SQL_DB) GetUserMoney(ctx context.Context, username string) (int, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT Money FROM Users WHERE Username=?", username)
 
 	money := 0
 	row.Scan(&money)
@@ -149,43 +345,62 @@ func (s *SQL_DB) GetUserMoney(username string) (int, error) {
 	return money, row.Err()
 }
 
-func (s *SQL_DB) IncreaseUserMoney(username string, value int) error {
+func (s *SQL_DB) IncreaseUserMoney(ctx context.Context, username string, value int) error {
 	// Increase money, used also for registration setting value=0
 
 	if value < 0 {
 		return errors.New("increase value can not be negative")
 	}
 
-	_, err := s.db.Exec("INSERT INTO Users VALUES (?, ?) ON DUPLICATE KEY UPDATE Money = Money + VALUES(Money)", username, value)
+	_, err := s.db.ExecContext(ctx, "INSERT INTO Users VALUES (?, ?) ON DUPLICATE KEY UPDATE Money = Money + VALUES(Money)", username, value)
 
 	return err
 }
 
-func (s *SQL_DB) BuyMotorcycle(username string, MotorcycleId int) error {
+func (s *SQL_DB) DecreaseUserMoney(ctx context.Context, username string, value int) error {
+	// Decrease money, used for payments made to other services
+
+	if value <= 0 {
+		return errors.New("decrease value must be positive")
+	}
+
+	res, err := s.db.ExecContext(ctx, "UPDATE Users SET Money = Money - ? WHERE Username=? AND Money >= ?", value, username, value)
+	if err != nil {
+		slog.ErrorContext(ctx, "DecreaseUserMoney failed", "error", err)
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errors.New("not enough money")
+	}
+
+	return nil
+}
+
+func (s *SQL_DB) BuyMotorcycle(ctx context.Context, username string, MotorcycleId int) error {
 	// Buy motorcycle
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.BuyMotorcycle"))
 	defer cancel()
 
 	// Begin transaction, if errors happen during execution the transaction is rolled back
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "BuyMotorcycle failed", "error", err)
 		return err
 	}
 	defer tx.Rollback()
 
 	var price int
-	err = tx.QueryRow("SELECT PriceToBuy FROM Motorcycles WHERE Id=?", MotorcycleId).Scan(&price)
+	err = tx.QueryRowContext(ctx, "SELECT PriceToBuy FROM Motorcycles WHERE Id=?", MotorcycleId).Scan(&price)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "BuyMotorcycle failed", "error", err)
 		return err
 	}
 
 	var money int
-	err = tx.QueryRow("SELECT Money FROM Users WHERE Username=?", username).Scan(&money)
+	err = tx.QueryRowContext(ctx, "SELECT Money FROM Users WHERE Username=?", username).Scan(&money)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "BuyMotorcycle failed", "error", err)
 		return err
 	}
 
@@ -194,46 +409,217 @@ func (s *SQL_DB) BuyMotorcycle(username string, MotorcycleId int) error {
 		return errors.New("not enough money to perform payment")
 	}
 
-	_, err = tx.Exec("INSERT INTO Owners (Username, MotorcycleId) VALUES (?, ?)", username, MotorcycleId)
+	_, err = tx.ExecContext(ctx, "INSERT INTO Owners (Username, MotorcycleId) VALUES (?, ?)", username, MotorcycleId)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "BuyMotorcycle failed", "error", err)
 		return err
 	}
 
 	// Subtract price from money of user
-	_, err = tx.Exec("UPDATE Users SET Money=Money-? WHERE Username=?", price, username)
+	_, err = tx.ExecContext(ctx, "UPDATE Users SET Money=Money-? WHERE Username=?", price, username)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "BuyMotorcycle failed", "error", err)
 		return err
 	}
 
 	return tx.Commit()
 }
 
-func (s *SQL_DB) UpgradeMotorcycle(username string, MotorcycleId int) error {
-	// Upgrade motorcycle (similar behaviour of buying)
+func (s *SQL_DB) UpgradeMotorcycle(ctx context.Context, username string, MotorcycleId int, stat string) error {
+	// Upgrade a single stat of the motorcycle (similar behaviour of buying)
+
+	column, ok := upgradableStats[stat]
+	if !ok {
+		return errors.New("unknown stat to upgrade")
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.UpgradeMotorcycle"))
 	defer cancel()
 
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "UpgradeMotorcycle failed", "error", err)
 		return err
 	}
 	defer tx.Rollback()
 
-	var price int
-	err = tx.QueryRow("SELECT PriceToUpgrade FROM Motorcycles WHERE Id=?", MotorcycleId).Scan(&price)
+	// Price is evaluated on the curve at the current level of the stat
+	var base, factor, level int
+	var curve string
+	err = tx.QueryRowContext(ctx, fmt.Sprintf("SELECT M.%[1]sPriceToUpgrade, M.PriceCurve, M.PriceCurveFactor, O.%[1]sLevel FROM Owners O INNER JOIN Motorcycles M ON O.MotorcycleId=M.Id WHERE O.Username=? AND O.MotorcycleId=? FOR UPDATE", column), username, MotorcycleId).Scan(&base, &curve, &factor, &level)
+	if err == sql.ErrNoRows {
+		return errors.New("motorcycle not owned")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "UpgradeMotorcycle failed", "error", err)
+		return err
+	}
+
+	var table priceTable
+	if curve == TableCurve {
+		if table, err = getPriceTable(ctx, tx, MotorcycleId); err != nil {
+			return err
+		}
+	}
+	price := upgradePrice(curve, factor, base, level, table[column])
+
+	var money int
+	err = tx.QueryRowContext(ctx, "SELECT Money FROM Users WHERE Username=?", username).Scan(&money)
+	if err != nil {
+		slog.ErrorContext(ctx, "UpgradeMotorcycle failed", "error", err)
+		return err
+	}
+
+	if money < price {
+		return errors.New("not enough money to perform payment")
+	}
+
+	// Level cap of each stat is enforced by the trigger on Owners
+	res, err := tx.ExecContext(ctx, fmt.Sprintf("UPDATE Owners SET %[1]sLevel=%[1]sLevel+1 WHERE Username=? AND MotorcycleId=?", column), username, MotorcycleId)
+	if err != nil {
+		slog.ErrorContext(ctx, "UpgradeMotorcycle failed", "error", err)
+		return err
+	}
+
+	if rows_affected, err := res.RowsAffected(); err != nil || rows_affected == 0 {
+		return errors.New("motorcycle not owned")
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Users SET Money=Money-? WHERE Username=?", price, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "UpgradeMotorcycle failed", "error", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) GetListings(ctx context.Context) ([]*Listing, error) {
+	// Retrieve listings not yet expired, with the stats of the motorcycle on sale
+
+	rows, err := s.db.QueryContext(ctx, "SELECT L.Id, L.Price, L.ExpiresAt, D.* FROM Listings L INNER JOIN DetailedOwnership D ON L.Seller=D.Username AND L.MotorcycleId=D.MotorcycleId WHERE L.ExpiresAt > CURRENT_TIMESTAMP ORDER BY L.ExpiresAt ASC")
+	if err != nil {
+		slog.ErrorContext(ctx, "GetListings failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var listings []*Listing
+
+	for rows.Next() {
+		var row Listing
+		err := rows.Scan(append([]any{&row.Id, &row.Price, &row.ExpiresAt}, row.Ownership.fields()...)...)
+		if err != nil {
+			slog.ErrorContext(ctx, "GetListings failed", "error", err)
+			return nil, err
+		}
+		listings = append(listings, &row)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "GetListings failed", "error", err)
+		return nil, err
+	}
+
+	return listings, nil
+}
+
+func (s *SQL_DB) CreateListing(ctx context.Context, username string, MotorcycleId int, price int, duration time.Duration) error {
+	// Put an owned motorcycle on sale at a fixed price until duration has elapsed
+
+	if price <= 0 {
+		return errors.New("price must be positive")
+	}
+
+	if duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+
+	// An expired listing of the same motorcycle would collide with the new one
+	_, err := s.db.ExecContext(ctx, "DELETE FROM Listings WHERE Seller=? AND MotorcycleId=? AND ExpiresAt <= CURRENT_TIMESTAMP", username, MotorcycleId)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateListing failed", "error", err)
+		return err
+	}
+
+	var auctions int
+	err = s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM Auctions WHERE Seller=? AND MotorcycleId=? AND Settled=FALSE", username, MotorcycleId).Scan(&auctions)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateListing failed", "error", err)
+		return err
+	}
+
+	if auctions != 0 {
+		return errors.New("motorcycle already on auction")
+	}
+
+	// Fails on the foreign key if the motorcycle is not owned and on the unique key if already listed
+	_, err = s.db.ExecContext(ctx, "INSERT INTO Listings (Seller, MotorcycleId, Price, ExpiresAt) VALUES (?, ?, ?, CURRENT_TIMESTAMP + INTERVAL ? SECOND)", username, MotorcycleId, price, int(duration.Seconds()))
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateListing failed", "error", err)
+	}
+
+	return err
+}
+
+func (s *SQL_DB) CancelListing(ctx context.Context, username string, ListingId int) error {
+	// Remove a listing, only the seller is allowed to do it
+
+	res, err := s.db.ExecContext(ctx, "DELETE FROM Listings WHERE Id=? AND Seller=?", ListingId, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "CancelListing failed", "error", err)
+		return err
+	}
+
+	rows_affected, err := res.RowsAffected()
+	if err != nil {
+		slog.ErrorContext(ctx, "CancelListing failed", "error", err)
+		return err
+	}
+
+	if rows_affected == 0 {
+		return errors.New("listing not found")
+	}
+
+	return nil
+}
+
+func (s *SQL_DB) BuyListing(ctx context.Context, username string, ListingId int) error {
+	// Buy a listed motorcycle, transferring ownership (keeping the level) and money between the two users
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.BuyListing"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "BuyListing failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the listing row so that concurrent buyers are serialized, the loser will find no listing
+	var seller string
+	var motorcycle_id, price int
+	err = tx.QueryRowContext(ctx, "SELECT Seller, MotorcycleId, Price FROM Listings WHERE Id=? AND ExpiresAt > CURRENT_TIMESTAMP FOR UPDATE", ListingId).Scan(&seller, &motorcycle_id, &price)
+	if err == sql.ErrNoRows {
+		return errors.New("listing not available")
+	}
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "BuyListing failed", "error", err)
+		return err
+	}
+
+	if seller == username {
+		return errors.New("unable to buy own listing")
+	}
+
+	if err := s.lockForTransfer(ctx, tx, seller, motorcycle_id); err != nil {
 		return err
 	}
 
 	var money int
-	err = tx.QueryRow("SELECT Money FROM Users WHERE Username=?", username).Scan(&money)
+	err = tx.QueryRowContext(ctx, "SELECT Money FROM Users WHERE Username=? FOR UPDATE", username).Scan(&money)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "BuyListing failed", "error", err)
 		return err
 	}
 
@@ -241,17 +627,909 @@ func (s *SQL_DB) UpgradeMotorcycle(username string, MotorcycleId int) error {
 		return errors.New("not enough money to perform payment")
 	}
 
-	_, err = tx.Exec("UPDATE Owners SET Level=Level+1 WHERE Username=? AND MotorcycleId=?", username, MotorcycleId)
+	res, err := tx.ExecContext(ctx, "DELETE FROM Listings WHERE Id=?", ListingId)
+	if err != nil {
+		slog.ErrorContext(ctx, "BuyListing failed", "error", err)
+		return err
+	}
+
+	if rows_affected, err := res.RowsAffected(); err != nil || rows_affected == 0 {
+		return errors.New("listing not available")
+	}
+
+	// Fails on the primary key if the buyer already owns the same motorcycle, the paint is sold together with it
+	res, err = tx.ExecContext(ctx, "UPDATE Owners SET Username=?, RaceNumber=NULL, RiderName=NULL WHERE Username=? AND MotorcycleId=?", username, seller, motorcycle_id)
+	if err != nil {
+		slog.ErrorContext(ctx, "BuyListing failed", "error", err)
+		return err
+	}
+
+	if rows_affected, err := res.RowsAffected(); err != nil || rows_affected == 0 {
+		return errors.New("motorcycle no longer owned by seller")
+	}
+
+	// Parts stay in the inventory of the seller
+	_, err = tx.ExecContext(ctx, "UPDATE Inventory SET MotorcycleId=NULL WHERE Username=? AND MotorcycleId=?", seller, motorcycle_id)
+	if err != nil {
+		slog.ErrorContext(ctx, "BuyListing failed", "error", err)
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Users SET Money=Money-? WHERE Username=?", price, username)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "BuyListing failed", "error", err)
 		return err
 	}
 
-	_, err = tx.Exec("UPDATE Users SET Money=Money-? WHERE Username=?", price, username)
+	_, err = tx.ExecContext(ctx, "UPDATE Users SET Money=Money+? WHERE Username=?", price, seller)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "BuyListing failed", "error", err)
 		return err
 	}
 
 	return tx.Commit()
 }
+
+func (s *SQL_DB) RemoveExpiredListings(ctx context.Context) error {
+	// Clean up listings whose expiration time has passed
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM Listings WHERE ExpiresAt <= CURRENT_TIMESTAMP")
+
+	return err
+}
+
+func (s *SQL_DB) GetAuctions(ctx context.Context) ([]*Auction, error) {
+	// Retrieve auctions not yet settled
+
+	rows, err := s.db.QueryContext(ctx, "SELECT * FROM DetailedAuctions WHERE Settled=FALSE ORDER BY EndsAt ASC")
+	if err != nil {
+		slog.ErrorContext(ctx, "GetAuctions failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var auctions []*Auction
+
+	for rows.Next() {
+		var row Auction
+		err := rows.Scan(&row.Id, &row.Seller, &row.MotorcycleId, &row.MotorcycleName, &row.Level, &row.ReservePrice, &row.EndsAt,
+			&row.HighestBidder, &row.HighestBid, &row.Settled)
+		if err != nil {
+			slog.ErrorContext(ctx, "GetAuctions failed", "error", err)
+			return nil, err
+		}
+		auctions = append(auctions, &row)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "GetAuctions failed", "error", err)
+		return nil, err
+	}
+
+	return auctions, nil
+}
+
+func (s *SQL_DB) GetAuction(ctx context.Context, AuctionId int) (*Auction, error) {
+	row := s.db.QueryRowContext(ctx, "SELECT * FROM DetailedAuctions WHERE Id=?", AuctionId)
+
+	var auction Auction
+	err := row.Scan(&auction.Id, &auction.Seller, &auction.MotorcycleId, &auction.MotorcycleName, &auction.Level, &auction.ReservePrice, &auction.EndsAt,
+		&auction.HighestBidder, &auction.HighestBid, &auction.Settled)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetAuction failed", "error", err)
+		return nil, err
+	}
+
+	return &auction, nil
+}
+
+func (s *SQL_DB) GetBids(ctx context.Context, AuctionId int, AfterBidId int) ([]*Bid, error) {
+	// Retrieve bids of an auction placed after a given bid, in the order they were placed
+
+	rows, err := s.db.QueryContext(ctx, "SELECT Id, AuctionId, Username, Amount, Time FROM Bids WHERE AuctionId=? AND Id>? ORDER BY Id ASC", AuctionId, AfterBidId)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetBids failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var bids []*Bid
+
+	for rows.Next() {
+		var row Bid
+		if err := rows.Scan(&row.Id, &row.AuctionId, &row.Username, &row.Amount, &row.Time); err != nil {
+			slog.ErrorContext(ctx, "GetBids failed", "error", err)
+			return nil, err
+		}
+		bids = append(bids, &row)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "GetBids failed", "error", err)
+		return nil, err
+	}
+
+	return bids, nil
+}
+
+func (s *SQL_DB) CreateAuction(ctx context.Context, username string, MotorcycleId int, level int, reserve int, duration time.Duration) error {
+	// Put a motorcycle up for auction, an empty username means that the house is selling a brand new motorcycle of the given level
+
+	if reserve <= 0 {
+		return errors.New("reserve price must be positive")
+	}
+
+	if duration <= 0 {
+		return errors.New("duration must be positive")
+	}
+
+	if username == "" {
+		var max_level int
+		err := s.db.QueryRowContext(ctx, "SELECT MaxLevel FROM DetailedMotorcycles WHERE Id=?", MotorcycleId).Scan(&max_level)
+		if err != nil {
+			slog.ErrorContext(ctx, "CreateAuction failed", "error", err)
+			return err
+		}
+
+		if level < 1 || level > max_level {
+			return errors.New("invalid level for motorcycle")
+		}
+
+		_, err = s.db.ExecContext(ctx, "INSERT INTO Auctions (Seller, MotorcycleId, Level, ReservePrice, EndsAt) VALUES (NULL, ?, ?, ?, CURRENT_TIMESTAMP + INTERVAL ? SECOND)", MotorcycleId, level, reserve, int(duration.Seconds()))
+		if err != nil {
+			slog.ErrorContext(ctx, "CreateAuction failed", "error", err)
+		}
+
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.CreateAuction"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateAuction failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	err = tx.QueryRowContext(ctx, "SELECT (EngineLevel+AgilityLevel+BrakesLevel+AerodynamicsLevel) DIV 4 FROM Owners WHERE Username=? AND MotorcycleId=? FOR UPDATE", username, MotorcycleId).Scan(&level)
+	if err == sql.ErrNoRows {
+		return errors.New("motorcycle not owned")
+	}
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateAuction failed", "error", err)
+		return err
+	}
+
+	// The same motorcycle can not be on sale twice at the same time
+	var listings, auctions int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Listings WHERE Seller=? AND MotorcycleId=? AND ExpiresAt > CURRENT_TIMESTAMP", username, MotorcycleId).Scan(&listings)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateAuction failed", "error", err)
+		return err
+	}
+
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Auctions WHERE Seller=? AND MotorcycleId=? AND Settled=FALSE", username, MotorcycleId).Scan(&auctions)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateAuction failed", "error", err)
+		return err
+	}
+
+	if listings != 0 || auctions != 0 {
+		return errors.New("motorcycle already on sale")
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO Auctions (Seller, MotorcycleId, Level, ReservePrice, EndsAt) VALUES (?, ?, ?, ?, CURRENT_TIMESTAMP + INTERVAL ? SECOND)", username, MotorcycleId, level, reserve, int(duration.Seconds()))
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateAuction failed", "error", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) PlaceBid(ctx context.Context, username string, AuctionId int, amount int) (outbid string, e error) {
+	// Place a bid escrowing its amount from the wallet of the bidder, the escrow of the outbid user is released
+	// and the outbid user is returned, read under the lock of the auction so concurrent bids report the right one
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.PlaceBid"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "PlaceBid failed", "error", err)
+		return "", err
+	}
+	defer tx.Rollback()
+
+	// Lock the auction row so that concurrent bids are serialized
+	var seller sql.NullString
+	var reserve int
+	var open bool
+	err = tx.QueryRowContext(ctx, "SELECT Seller, ReservePrice, (Settled=FALSE AND EndsAt > CURRENT_TIMESTAMP) FROM Auctions WHERE Id=? FOR UPDATE", AuctionId).Scan(&seller, &reserve, &open)
+	if err != nil {
+		slog.ErrorContext(ctx, "PlaceBid failed", "error", err)
+		return "", err
+	}
+
+	if !open {
+		return "", errors.New("auction ended")
+	}
+
+	if seller.Valid && seller.String == username {
+		return "", errors.New("unable to bid on own auction")
+	}
+
+	min_amount := reserve
+
+	var highest_bidder string
+	var highest_bid int
+	err = tx.QueryRowContext(ctx, "SELECT Username, Amount FROM Bids WHERE AuctionId=? ORDER BY Id DESC LIMIT 1", AuctionId).Scan(&highest_bidder, &highest_bid)
+	if err != nil && err != sql.ErrNoRows {
+		slog.ErrorContext(ctx, "PlaceBid failed", "error", err)
+		return "", err
+	}
+
+	if err == nil {
+		min_amount = highest_bid + 1
+
+		// Release escrow of the outbid user (the bidder themselves when raising their own bid)
+		_, err = tx.ExecContext(ctx, "UPDATE Users SET Money=Money+? WHERE Username=?", highest_bid, highest_bidder)
+		if err != nil {
+			slog.ErrorContext(ctx, "PlaceBid failed", "error", err)
+			return "", err
+		}
+	}
+
+	if amount < min_amount {
+		return "", errors.New("bid too low")
+	}
+
+	var money int
+	err = tx.QueryRowContext(ctx, "SELECT Money FROM Users WHERE Username=? FOR UPDATE", username).Scan(&money)
+	if err != nil {
+		slog.ErrorContext(ctx, "PlaceBid failed", "error", err)
+		return "", err
+	}
+
+	if money < amount {
+		return "", errors.New("not enough money to perform payment")
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Users SET Money=Money-? WHERE Username=?", amount, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "PlaceBid failed", "error", err)
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO Bids (AuctionId, Username, Amount) VALUES (?, ?, ?)", AuctionId, username, amount)
+	if err != nil {
+		slog.ErrorContext(ctx, "PlaceBid failed", "error", err)
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Auctions SET EndsAt=GREATEST(EndsAt, CURRENT_TIMESTAMP + INTERVAL ? SECOND) WHERE Id=?", int(auctionExtension.Seconds()), AuctionId)
+	if err != nil {
+		slog.ErrorContext(ctx, "PlaceBid failed", "error", err)
+		return "", err
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.ErrorContext(ctx, "PlaceBid failed", "error", err)
+		return "", err
+	}
+
+	if highest_bidder == username {
+		return "", nil
+	}
+	return highest_bidder, nil
+}
+
+func (s *SQL_DB) SettleAuctions(ctx context.Context) error {
+	// Settle every auction whose end time has passed
+
+	rows, err := s.db.QueryContext(ctx, "SELECT Id FROM Auctions WHERE Settled=FALSE AND EndsAt <= CURRENT_TIMESTAMP")
+	if err != nil {
+		slog.ErrorContext(ctx, "SettleAuctions failed", "error", err)
+		return err
+	}
+	defer rows.Close()
+
+	var ended []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			slog.ErrorContext(ctx, "SettleAuctions failed", "error", err)
+			return err
+		}
+		ended = append(ended, id)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "SettleAuctions failed", "error", err)
+		return err
+	}
+
+	rows.Close()
+
+	// A failure on one auction does not prevent the others from being settled
+	for _, id := range ended {
+		if e := s.settleAuction(ctx, id); e != nil {
+			slog.ErrorContext(ctx, "SettleAuctions failed", "error", e)
+			err = e
+		}
+	}
+
+	return err
+}
+
+func (s *SQL_DB) settleAuction(ctx context.Context, AuctionId int) error {
+	// Give the motorcycle to the highest bidder and the escrowed money to the seller
+	// if the winner already owns the same motorcycle the escrow is released and nothing is sold
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.settleAuction"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "settleAuction failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock the auction row, another replica could be settling the same auction
+	var seller sql.NullString
+	var motorcycle_id, level int
+	var settled bool
+	err = tx.QueryRowContext(ctx, "SELECT Seller, MotorcycleId, Level, Settled FROM Auctions WHERE Id=? FOR UPDATE", AuctionId).Scan(&seller, &motorcycle_id, &level, &settled)
+	if err != nil {
+		slog.ErrorContext(ctx, "settleAuction failed", "error", err)
+		return err
+	}
+
+	if settled {
+		return nil
+	}
+
+	var winner string
+	var amount int
+	err = tx.QueryRowContext(ctx, "SELECT Username, Amount FROM Bids WHERE AuctionId=? ORDER BY Id DESC LIMIT 1", AuctionId).Scan(&winner, &amount)
+	if err != nil && err != sql.ErrNoRows {
+		slog.ErrorContext(ctx, "settleAuction failed", "error", err)
+		return err
+	}
+
+	if err == nil {
+		var owned int
+		err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Owners WHERE Username=? AND MotorcycleId=?", winner, motorcycle_id).Scan(&owned)
+		if err != nil {
+			slog.ErrorContext(ctx, "settleAuction failed", "error", err)
+			return err
+		}
+
+		// The escrow goes back to the winner if the seller no longer owns the motorcycle,
+		// the auction is settled at a later round if the motorcycle is racing
+		release := owned != 0
+		if !release && seller.Valid {
+			err = s.lockForTransfer(ctx, tx, seller.String, motorcycle_id)
+			if err == errMotorcycleNotOwned {
+				release, err = true, nil
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		if release {
+			_, err = tx.ExecContext(ctx, "UPDATE Users SET Money=Money+? WHERE Username=?", amount, winner)
+		} else if seller.Valid {
+			var res sql.Result
+			res, err = tx.ExecContext(ctx, "UPDATE Owners SET Username=?, RaceNumber=NULL, RiderName=NULL WHERE Username=? AND MotorcycleId=?", winner, seller.String, motorcycle_id)
+			if err == nil {
+				if rows_affected, e := res.RowsAffected(); e != nil || rows_affected == 0 {
+					return errors.New("motorcycle no longer owned by seller")
+				}
+				// Parts stay in the inventory of the seller
+				_, err = tx.ExecContext(ctx, "UPDATE Inventory SET MotorcycleId=NULL WHERE Username=? AND MotorcycleId=?", seller.String, motorcycle_id)
+			}
+			if err == nil {
+				_, err = tx.ExecContext(ctx, "UPDATE Users SET Money=Money+? WHERE Username=?", amount, seller.String)
+			}
+		} else {
+			// Every stat of the new motorcycle gets the level of the auction, within its own cap
+			_, err = tx.ExecContext(ctx, "INSERT INTO Owners (Username, MotorcycleId, EngineLevel, AgilityLevel, BrakesLevel, AerodynamicsLevel) SELECT ?, Id, LEAST(?, EngineMaxLevel), LEAST(?, AgilityMaxLevel), LEAST(?, BrakesMaxLevel), LEAST(?, AerodynamicsMaxLevel) FROM Motorcycles WHERE Id=?",
+				winner, level, level, level, level, motorcycle_id)
+		}
+
+		if err != nil {
+			slog.ErrorContext(ctx, "settleAuction failed", "error", err)
+			return err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Auctions SET Settled=TRUE WHERE Id=?", AuctionId)
+	if err != nil {
+		slog.ErrorContext(ctx, "settleAuction failed", "error", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) GetParts(ctx context.Context) ([]*Part, error) {
+	// Retrieve parts that can be bought
+
+	rows, err := s.db.QueryContext(ctx, "SELECT Id, Name, Type, Price, Engine, Agility, Brakes, Aerodynamics FROM Parts ORDER BY Type, Price")
+	if err != nil {
+		slog.ErrorContext(ctx, "GetParts failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var parts []*Part
+
+	for rows.Next() {
+		var row Part
+		err := rows.Scan(&row.Id, &row.Name, &row.Type, &row.Price, &row.Engine, &row.Agility, &row.Brakes, &row.Aerodynamics)
+		if err != nil {
+			slog.ErrorContext(ctx, "GetParts failed", "error", err)
+			return nil, err
+		}
+		parts = append(parts, &row)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "GetParts failed", "error", err)
+		return nil, err
+	}
+
+	return parts, nil
+}
+
+func (s *SQL_DB) GetInventory(ctx context.Context, username string) ([]*Item, error) {
+	// Retrieve parts owned by user, equipped or not
+
+	rows, err := s.db.QueryContext(ctx, "SELECT I.Id, COALESCE(I.MotorcycleId, 0), P.Id, P.Name, P.Type, P.Price, P.Engine, P.Agility, P.Brakes, P.Aerodynamics FROM Inventory I INNER JOIN Parts P ON I.PartId=P.Id WHERE I.Username=? ORDER BY I.Id", username)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetInventory failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var items []*Item
+
+	for rows.Next() {
+		var row Item
+		err := rows.Scan(&row.Id, &row.MotorcycleId, &row.Part.Id, &row.Name, &row.Type, &row.Price, &row.Engine, &row.Agility, &row.Brakes, &row.Aerodynamics)
+		if err != nil {
+			slog.ErrorContext(ctx, "GetInventory failed", "error", err)
+			return nil, err
+		}
+		items = append(items, &row)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "GetInventory failed", "error", err)
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (s *SQL_DB) BuyPart(ctx context.Context, username string, PartId int) error {
+	// Buy a part, added to the inventory without being equipped
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.BuyPart"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "BuyPart failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var price int
+	err = tx.QueryRowContext(ctx, "SELECT Price FROM Parts WHERE Id=?", PartId).Scan(&price)
+	if err != nil {
+		slog.ErrorContext(ctx, "BuyPart failed", "error", err)
+		return err
+	}
+
+	var money int
+	err = tx.QueryRowContext(ctx, "SELECT Money FROM Users WHERE Username=? FOR UPDATE", username).Scan(&money)
+	if err != nil {
+		slog.ErrorContext(ctx, "BuyPart failed", "error", err)
+		return err
+	}
+
+	if money < price {
+		return errors.New("not enough money to perform payment")
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO Inventory (Username, PartId) VALUES (?, ?)", username, PartId)
+	if err != nil {
+		slog.ErrorContext(ctx, "BuyPart failed", "error", err)
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Users SET Money=Money-? WHERE Username=?", price, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "BuyPart failed", "error", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) AwardPart(ctx context.Context, username string) (*Part, error) {
+	// Give a random part for free, used as prize
+
+	var part Part
+	err := s.db.QueryRowContext(ctx, "SELECT Id, Name, Type, Price, Engine, Agility, Brakes, Aerodynamics FROM Parts ORDER BY RAND() LIMIT 1").Scan(&part.Id, &part.Name, &part.Type, &part.Price, &part.Engine, &part.Agility, &part.Brakes, &part.Aerodynamics)
+	if err != nil {
+		slog.ErrorContext(ctx, "AwardPart failed", "error", err)
+		return nil, err
+	}
+
+	_, err = s.db.ExecContext(ctx, "INSERT INTO Inventory (Username, PartId) VALUES (?, ?)", username, part.Id)
+	if err != nil {
+		slog.ErrorContext(ctx, "AwardPart failed", "error", err)
+		return nil, err
+	}
+
+	return &part, nil
+}
+
+func (s *SQL_DB) EquipPart(ctx context.Context, username string, ItemId int, MotorcycleId int) error {
+	// Equip a part of the inventory to an owned motorcycle, replacing the part of the same type
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.EquipPart"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "EquipPart failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var part_type string
+	err = tx.QueryRowContext(ctx, "SELECT P.Type FROM Inventory I INNER JOIN Parts P ON I.PartId=P.Id WHERE I.Id=? AND I.Username=? FOR UPDATE", ItemId, username).Scan(&part_type)
+	if err == sql.ErrNoRows {
+		return errors.New("part not in inventory")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "EquipPart failed", "error", err)
+		return err
+	}
+
+	var owned int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Owners WHERE Username=? AND MotorcycleId=? FOR UPDATE", username, MotorcycleId).Scan(&owned)
+	if err != nil {
+		slog.ErrorContext(ctx, "EquipPart failed", "error", err)
+		return err
+	}
+
+	if owned == 0 {
+		return errors.New("motorcycle not owned")
+	}
+
+	// Only one part of each type can be equipped on the same motorcycle
+	_, err = tx.ExecContext(ctx, "UPDATE Inventory I INNER JOIN Parts P ON I.PartId=P.Id SET I.MotorcycleId=NULL WHERE I.Username=? AND I.MotorcycleId=? AND P.Type=?", username, MotorcycleId, part_type)
+	if err != nil {
+		slog.ErrorContext(ctx, "EquipPart failed", "error", err)
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Inventory SET MotorcycleId=? WHERE Id=?", MotorcycleId, ItemId)
+	if err != nil {
+		slog.ErrorContext(ctx, "EquipPart failed", "error", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) UnequipPart(ctx context.Context, username string, ItemId int) error {
+	res, err := s.db.ExecContext(ctx, "UPDATE Inventory SET MotorcycleId=NULL WHERE Id=? AND Username=?", ItemId, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "UnequipPart failed", "error", err)
+		return err
+	}
+
+	if rows_affected, err := res.RowsAffected(); err != nil || rows_affected == 0 {
+		return errors.New("part not in inventory")
+	}
+
+	return nil
+}
+
+func (s *SQL_DB) ApplyWear(ctx context.Context, username string, MotorcycleId int, wear int) error {
+	// Wear a motorcycle after a race, up to being completely worn
+
+	if wear < 0 {
+		return errors.New("wear can not be negative")
+	}
+
+	var owned int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM Owners WHERE Username=? AND MotorcycleId=?", username, MotorcycleId).Scan(&owned)
+	if err != nil {
+		slog.ErrorContext(ctx, "ApplyWear failed", "error", err)
+		return err
+	}
+
+	if owned == 0 {
+		return errors.New("motorcycle not owned")
+	}
+
+	_, err = s.db.ExecContext(ctx, "UPDATE Owners SET Wear=LEAST(Wear+?, 100) WHERE Username=? AND MotorcycleId=?", wear, username, MotorcycleId)
+	if err != nil {
+		slog.ErrorContext(ctx, "ApplyWear failed", "error", err)
+	}
+
+	return err
+}
+
+func (s *SQL_DB) RepairMotorcycle(ctx context.Context, username string, MotorcycleId int) error {
+	// Pay to remove all the wear of a motorcycle
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.RepairMotorcycle"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "RepairMotorcycle failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var price_to_buy, wear int
+	err = tx.QueryRowContext(ctx, "SELECT M.PriceToBuy, O.Wear FROM Owners O INNER JOIN Motorcycles M ON O.MotorcycleId=M.Id WHERE O.Username=? AND O.MotorcycleId=? FOR UPDATE", username, MotorcycleId).Scan(&price_to_buy, &wear)
+	if err == sql.ErrNoRows {
+		return errors.New("motorcycle not owned")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "RepairMotorcycle failed", "error", err)
+		return err
+	}
+
+	if wear == 0 {
+		return errors.New("motorcycle not worn")
+	}
+
+	price := repairPrice(price_to_buy, wear)
+
+	var money int
+	err = tx.QueryRowContext(ctx, "SELECT Money FROM Users WHERE Username=? FOR UPDATE", username).Scan(&money)
+	if err != nil {
+		slog.ErrorContext(ctx, "RepairMotorcycle failed", "error", err)
+		return err
+	}
+
+	if money < price {
+		return errors.New("not enough money to perform payment")
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Owners SET Wear=0 WHERE Username=? AND MotorcycleId=?", username, MotorcycleId)
+	if err != nil {
+		slog.ErrorContext(ctx, "RepairMotorcycle failed", "error", err)
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Users SET Money=Money-? WHERE Username=?", price, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "RepairMotorcycle failed", "error", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) GetPaints(ctx context.Context) ([]*Paint, error) {
+	rows, err := s.db.QueryContext(ctx, "SELECT Id, Name, Color, Price FROM Paints ORDER BY Price, Name")
+	if err != nil {
+		slog.ErrorContext(ctx, "GetPaints failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var paints []*Paint
+
+	for rows.Next() {
+		var row Paint
+		err := rows.Scan(&row.Id, &row.Name, &row.Color, &row.Price)
+		if err != nil {
+			slog.ErrorContext(ctx, "GetPaints failed", "error", err)
+			return nil, err
+		}
+		paints = append(paints, &row)
+	}
+
+	if err := rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "GetPaints failed", "error", err)
+		return nil, err
+	}
+
+	return paints, nil
+}
+
+func (s *SQL_DB) CustomizeMotorcycle(ctx context.Context, username string, MotorcycleId int, PaintId int, RaceNumber int, RiderName string) error {
+	// Change paint, race number and rider name of an owned motorcycle, zero values are left unchanged
+
+	if RaceNumber < 0 || RaceNumber > 99 {
+		return errors.New("race number must be between 1 and 99")
+	}
+
+	if utf8.RuneCountInString(RiderName) > 32 {
+		return errors.New("rider name too long")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.CustomizeMotorcycle"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "CustomizeMotorcycle failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var owned int
+	err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Owners WHERE Username=? AND MotorcycleId=? FOR UPDATE", username, MotorcycleId).Scan(&owned)
+	if err != nil {
+		slog.ErrorContext(ctx, "CustomizeMotorcycle failed", "error", err)
+		return err
+	}
+
+	if owned == 0 {
+		return errors.New("motorcycle not owned")
+	}
+
+	price := 0
+	if PaintId != 0 {
+		var paint_price int
+		err = tx.QueryRowContext(ctx, "SELECT Price FROM Paints WHERE Id=?", PaintId).Scan(&paint_price)
+		if err == sql.ErrNoRows {
+			return errors.New("unknown paint")
+		} else if err != nil {
+			slog.ErrorContext(ctx, "CustomizeMotorcycle failed", "error", err)
+			return err
+		}
+		price += paint_price
+	}
+	if RaceNumber != 0 {
+		price += raceNumberPrice
+	}
+	if RiderName != "" {
+		price += riderNamePrice
+	}
+
+	if price == 0 {
+		return errors.New("nothing to customize")
+	}
+
+	var money int
+	err = tx.QueryRowContext(ctx, "SELECT Money FROM Users WHERE Username=? FOR UPDATE", username).Scan(&money)
+	if err != nil {
+		slog.ErrorContext(ctx, "CustomizeMotorcycle failed", "error", err)
+		return err
+	}
+
+	if money < price {
+		return errors.New("not enough money to perform payment")
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Owners SET PaintId=COALESCE(NULLIF(?, 0), PaintId), RaceNumber=COALESCE(NULLIF(?, 0), RaceNumber), RiderName=COALESCE(NULLIF(?, ''), RiderName) WHERE Username=? AND MotorcycleId=?",
+		PaintId, RaceNumber, RiderName, username, MotorcycleId)
+	if err != nil {
+		slog.ErrorContext(ctx, "CustomizeMotorcycle failed", "error", err)
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Users SET Money=Money-? WHERE Username=?", price, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "CustomizeMotorcycle failed", "error", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) ClaimDailyReward(ctx context.Context, username string, reward int, bonus int, max_streak int) (*DailyReward, error) {
+	// Give the daily reward once per day, the claim row is locked so concurrent claims of the same day pay only once
+
+	if reward < 0 || bonus < 0 || max_streak < 1 {
+		return nil, errors.New("invalid daily reward")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.ClaimDailyReward"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimDailyReward failed", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, "INSERT IGNORE INTO DailyRewards (Username) VALUES (?)", username)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimDailyReward failed", "error", err)
+		return nil, err
+	}
+
+	var streak int
+	var days_since sql.NullInt64
+	err = tx.QueryRowContext(ctx, "SELECT Streak, DATEDIFF(CURRENT_DATE, LastClaim) FROM DailyRewards WHERE Username=? FOR UPDATE", username).Scan(&streak, &days_since)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("user not found")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "ClaimDailyReward failed", "error", err)
+		return nil, err
+	}
+
+	if days_since.Valid && days_since.Int64 == 0 {
+		return &DailyReward{Claimed: false, Streak: streak}, nil
+	}
+
+	// A missed day resets the streak
+	if days_since.Valid && days_since.Int64 == 1 {
+		streak++
+	} else {
+		streak = 1
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE DailyRewards SET LastClaim=CURRENT_DATE, Streak=? WHERE Username=?", streak, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimDailyReward failed", "error", err)
+		return nil, err
+	}
+
+	money := dailyReward(reward, bonus, max_streak, streak)
+	_, err = tx.ExecContext(ctx, "UPDATE Users SET Money=Money+? WHERE Username=?", money, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimDailyReward failed", "error", err)
+		return nil, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimDailyReward failed", "error", err)
+		return nil, err
+	}
+
+	return &DailyReward{Claimed: true, Streak: streak, Money: money}, nil
+}
+
+func (s *SQL_DB) ClaimPayout(ctx context.Context, key string) (bool, error) {
+	// Claim the reward of a key, only the first claim inserts the row so it is paid once even if notified again
+
+	if key == "" {
+		return false, errors.New("payout key required")
+	}
+
+	res, err := s.db.ExecContext(ctx, "INSERT IGNORE INTO Payouts (PayoutKey) VALUES (?)", key)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimPayout failed", "error", err)
+		return false, err
+	}
+
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimPayout failed", "error", err)
+		return false, err
+	}
+
+	return claimed == 1, nil
+}
+
+func (s *SQL_DB) ReleasePayout(ctx context.Context, key string) error {
+	// Release the claim of a key whose reward could not be paid, so the next notification pays it
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM Payouts WHERE PayoutKey=?", key)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReleasePayout failed", "error", err)
+	}
+
+	return err
+}
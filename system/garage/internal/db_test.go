@@ -1,7 +1,11 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
+	"strings"
+	"sync"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 
@@ -9,7 +13,7 @@ import (
 )
 
 func NewSQLConnection(t *testing.T) *sql.DB {
-	db, err := sql.Open("mysql", "root:admin@tcp(test_garage_db:3306)/Garage")
+	db, err := sql.Open("mysql", "root:admin@tcp(test_garage_db:3306)/Garage?parseTime=true")
 	if err != nil {
 		t.Errorf("failed to connect to db: %s", err)
 	}
@@ -20,12 +24,25 @@ func NewSQLConnection(t *testing.T) *sql.DB {
 	return db
 }
 
+// Racing status of the motorcycles without the Racing service
+type notRacing struct{}
+
+func (notRacing) IsRacing(ctx context.Context, username string, MotorcycleId int) (bool, error) {
+	return false, nil
+}
+
+type alwaysRacing struct{}
+
+func (alwaysRacing) IsRacing(ctx context.Context, username string, MotorcycleId int) (bool, error) {
+	return true, nil
+}
+
 func TestDBBuyMotorcycleCorrect(t *testing.T) {
 	conn := NewSQLConnection(t)
 	defer conn.Close()
 
-	db := NewSQL_DB(conn)
-	err := db.BuyMotorcycle("user", 2)
+	db := NewSQL_DB(conn, notRacing{})
+	err := db.BuyMotorcycle(context.Background(), "user", 2)
 
 	if err == nil {
 		return
@@ -38,8 +55,8 @@ func TestDBBuyMotorcycleAlreadyOwned(t *testing.T) {
 	conn := NewSQLConnection(t)
 	defer conn.Close()
 
-	db := NewSQL_DB(conn)
-	err := db.BuyMotorcycle("user", 1)
+	db := NewSQL_DB(conn, notRacing{})
+	err := db.BuyMotorcycle(context.Background(), "user", 1)
 
 	if err != nil {
 		return
@@ -52,8 +69,8 @@ func TestDBBuyMotorcycleNotEnoughMoney(t *testing.T) {
 	conn := NewSQLConnection(t)
 	defer conn.Close()
 
-	db := NewSQL_DB(conn)
-	err := db.BuyMotorcycle("foo", 1)
+	db := NewSQL_DB(conn, notRacing{})
+	err := db.BuyMotorcycle(context.Background(), "foo", 1)
 
 	if err != nil {
 		return
@@ -66,8 +83,8 @@ func TestDBUpgradeMotorcycleCorrect(t *testing.T) {
 	conn := NewSQLConnection(t)
 	defer conn.Close()
 
-	db := NewSQL_DB(conn)
-	err := db.UpgradeMotorcycle("user", 1)
+	db := NewSQL_DB(conn, notRacing{})
+	err := db.UpgradeMotorcycle(context.Background(), "user", 1, "engine")
 
 	if err == nil {
 		return
@@ -80,8 +97,8 @@ func TestDBUpgradeMotorcycleNotEnoughMoney(t *testing.T) {
 	conn := NewSQLConnection(t)
 	defer conn.Close()
 
-	db := NewSQL_DB(conn)
-	err := db.UpgradeMotorcycle("test", 1)
+	db := NewSQL_DB(conn, notRacing{})
+	err := db.UpgradeMotorcycle(context.Background(), "test", 1, "engine")
 
 	if err != nil {
 		return
@@ -94,15 +111,15 @@ func TestDBUpgradeMotorcycleMaxLevelReached(t *testing.T) {
 	conn := NewSQLConnection(t)
 	defer conn.Close()
 
-	db := NewSQL_DB(conn)
-	err := db.UpgradeMotorcycle("foo", 1)
+	db := NewSQL_DB(conn, notRacing{})
+	err := db.UpgradeMotorcycle(context.Background(), "foo", 2, "engine")
 
 	if err != nil {
 		t.Errorf("Upgrade not performed, but should be")
 		return
 	}
 
-	err = db.UpgradeMotorcycle("foo", 1)
+	err = db.UpgradeMotorcycle(context.Background(), "foo", 2, "engine")
 
 	if err != nil {
 		return
@@ -111,25 +128,127 @@ func TestDBUpgradeMotorcycleMaxLevelReached(t *testing.T) {
 	t.Errorf("Upgrade performed, but should not be (max level reached)")
 }
 
+func TestDBUpgradeMotorcycleSingleStat(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+	before, err := db.GetUserMotorcycleStats(context.Background(), "user", 1)
+	if err != nil {
+		t.Errorf("Unable to get motorcycle stats")
+		return
+	}
+
+	if err = db.UpgradeMotorcycle(context.Background(), "user", 1, "brakes"); err != nil {
+		t.Errorf("Upgrade not performed, but should be")
+		return
+	}
+
+	after, err := db.GetUserMotorcycleStats(context.Background(), "user", 1)
+	if err != nil {
+		t.Errorf("Unable to get motorcycle stats")
+		return
+	}
+
+	if after.BrakesLevel != before.BrakesLevel+1 || after.Brakes != before.Brakes+before.BrakesIncrement {
+		t.Errorf("Upgraded stat not increased")
+	}
+
+	if after.EngineLevel != before.EngineLevel || after.AgilityLevel != before.AgilityLevel || after.AerodynamicsLevel != before.AerodynamicsLevel {
+		t.Errorf("Stats not upgraded changed")
+	}
+}
+
+func TestDBUpgradeMotorcycleUnknownStat(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+	err := db.UpgradeMotorcycle(context.Background(), "user", 1, "Level")
+
+	if err != nil {
+		return
+	}
+
+	t.Errorf("Upgrade performed, but should not be (unknown stat)")
+}
+
+func TestDBUpgradeMotorcycleExponentialPrice(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+	stats, err := db.GetUserMotorcycleStats(context.Background(), "tuner", 1)
+	if err != nil {
+		t.Errorf("Unable to get motorcycle stats")
+		return
+	}
+
+	// Base price of 25 increased by 10% twice, rounding down each time
+	if stats.EngineNextUpgradePrice != 29 {
+		t.Errorf("Wrong next upgrade price: %d", stats.EngineNextUpgradePrice)
+		return
+	}
+
+	before, _ := db.GetUserMoney(context.Background(), "tuner")
+	if err = db.UpgradeMotorcycle(context.Background(), "tuner", 1, "engine"); err != nil {
+		t.Errorf("Upgrade not performed, but should be")
+		return
+	}
+
+	after, _ := db.GetUserMoney(context.Background(), "tuner")
+	if before-after != stats.EngineNextUpgradePrice {
+		t.Errorf("Charged %d instead of displayed price %d", before-after, stats.EngineNextUpgradePrice)
+	}
+}
+
+func TestDBUpgradeMotorcycleTablePrice(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+	stats, err := db.GetUserMotorcycleStats(context.Background(), "tuner", 3)
+	if err != nil {
+		t.Errorf("Unable to get motorcycle stats")
+		return
+	}
+
+	// Engine prices are listed in the table, brakes fall back to the base price
+	if stats.EngineNextUpgradePrice != 40 || stats.BrakesNextUpgradePrice != stats.BrakesPriceToUpgrade {
+		t.Errorf("Wrong next upgrade prices: %d, %d", stats.EngineNextUpgradePrice, stats.BrakesNextUpgradePrice)
+		return
+	}
+
+	if err = db.UpgradeMotorcycle(context.Background(), "tuner", 3, "engine"); err != nil {
+		t.Errorf("Upgrade not performed, but should be")
+		return
+	}
+
+	stats, err = db.GetUserMotorcycleStats(context.Background(), "tuner", 3)
+	if err != nil || stats.EngineNextUpgradePrice != 60 {
+		t.Errorf("Next upgrade price not taken from the table")
+	}
+}
+
 func TestDBIncreaseUserMoney(t *testing.T) {
 	conn := NewSQLConnection(t)
 	defer conn.Close()
 
-	db := NewSQL_DB(conn)
-	money, err := db.GetUserMoney("test")
+	db := NewSQL_DB(conn, notRacing{})
+	money, err := db.GetUserMoney(context.Background(), "test")
 
 	if money != 0 || err != nil {
 		t.Errorf("Wrong value of money or error raised")
 		return
 	}
 
-	err = db.IncreaseUserMoney("test", 5)
+	err = db.IncreaseUserMoney(context.Background(), "test", 5)
 
 	if err != nil {
 		t.Errorf("Increase not performed but should be")
 	}
 
-	money, err = db.GetUserMoney("test")
+	money, err = db.GetUserMoney(context.Background(), "test")
 
 	if money == 5 && err == nil {
 		return
@@ -137,3 +256,595 @@ func TestDBIncreaseUserMoney(t *testing.T) {
 
 	t.Errorf("Wrong value of money or error raised")
 }
+
+func TestDBBuyListingRacing(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, alwaysRacing{})
+	err := db.BuyListing(context.Background(), "buyer1", 1)
+
+	if err != nil {
+		return
+	}
+
+	t.Errorf("Able to buy listing but should not be (motorcycle racing)")
+}
+
+func TestDBBuyListingConcurrentBuyers(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	// Two buyers race for the same listing, only one of them must win
+	buyers := []string{"buyer1", "buyer2"}
+	errs := make([]error, len(buyers))
+
+	var wg sync.WaitGroup
+	for i, buyer := range buyers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = db.BuyListing(context.Background(), buyer, 1)
+		}()
+	}
+	wg.Wait()
+
+	winner := ""
+	for i, err := range errs {
+		if err == nil {
+			if winner != "" {
+				t.Errorf("Both buyers bought the same listing")
+				return
+			}
+			winner = buyers[i]
+		}
+	}
+
+	if winner == "" {
+		t.Errorf("No buyer was able to buy the listing but one should be")
+		return
+	}
+
+	// Ownership is transferred keeping the level of the motorcycle
+	owned, err := db.GetUserMotorcycleStats(context.Background(), winner, 2)
+	if err != nil || owned.Level != 7 {
+		t.Errorf("Motorcycle not transferred to buyer with its level")
+	}
+
+	money, err := db.GetUserMoney(context.Background(), winner)
+	if err != nil || money != 400 {
+		t.Errorf("Wrong value of buyer money after purchase")
+	}
+
+	money, err = db.GetUserMoney(context.Background(), "seller")
+	if err != nil || money != 100 {
+		t.Errorf("Wrong value of seller money after purchase")
+	}
+}
+
+func TestDBBuyListingExpired(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+	err := db.BuyListing(context.Background(), "user", 2)
+
+	if err != nil {
+		return
+	}
+
+	t.Errorf("Able to buy listing but should not be (listing expired)")
+}
+
+func TestDBCreateAndCancelListing(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	// Replaces the expired listing of the same motorcycle
+	err := db.CreateListing(context.Background(), "seller", 1, 80, time.Hour)
+	if err != nil {
+		t.Errorf("Listing not created but should be")
+		return
+	}
+
+	err = db.CreateListing(context.Background(), "seller", 1, 80, time.Hour)
+	if err == nil {
+		t.Errorf("Listing created but should not be (motorcycle already listed)")
+	}
+
+	err = db.CreateListing(context.Background(), "buyer1", 1, 80, time.Hour)
+	if err == nil {
+		t.Errorf("Listing created but should not be (motorcycle not owned)")
+	}
+
+	listings, err := db.GetListings(context.Background())
+	if err != nil {
+		t.Errorf("Unable to retrieve listings")
+		return
+	}
+
+	id := -1
+	for _, v := range listings {
+		if v.Username == "seller" && v.MotorcycleId == 1 {
+			id = v.Id
+		}
+	}
+
+	if id == -1 {
+		t.Errorf("Created listing not found")
+		return
+	}
+
+	if err = db.CancelListing(context.Background(), "buyer1", id); err == nil {
+		t.Errorf("Listing cancelled but should not be (not the seller)")
+	}
+
+	if err = db.CancelListing(context.Background(), "seller", id); err != nil {
+		t.Errorf("Listing not cancelled but should be")
+	}
+}
+
+func TestDBPlaceBidBelowReserve(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+	_, err := db.PlaceBid(context.Background(), "bidder1", 1, 50)
+
+	if err != nil {
+		return
+	}
+
+	t.Errorf("Bid accepted but should not be (below reserve price)")
+}
+
+func TestDBPlaceBidEscrow(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	outbid, err := db.PlaceBid(context.Background(), "bidder1", 1, 120)
+	if err != nil || outbid != "" {
+		t.Errorf("Bid not accepted but should be")
+		return
+	}
+
+	money, err := db.GetUserMoney(context.Background(), "bidder1")
+	if err != nil || money != 380 {
+		t.Errorf("Bid amount not escrowed from bidder money")
+	}
+
+	outbid, err = db.PlaceBid(context.Background(), "bidder2", 1, 130)
+	if err != nil {
+		t.Errorf("Bid not accepted but should be")
+		return
+	}
+	if outbid != "bidder1" {
+		t.Errorf("Wrong outbid user: %s", outbid)
+	}
+
+	// Escrow of the outbid user is released
+	money, err = db.GetUserMoney(context.Background(), "bidder1")
+	if err != nil || money != 500 {
+		t.Errorf("Escrow not released after being outbid")
+	}
+
+	money, err = db.GetUserMoney(context.Background(), "bidder2")
+	if err != nil || money != 220 {
+		t.Errorf("Bid amount not escrowed from bidder money")
+	}
+
+	if _, err := db.PlaceBid(context.Background(), "bidder1", 1, 125); err == nil {
+		t.Errorf("Bid accepted but should not be (lower than highest bid)")
+	}
+
+	auction, err := db.GetAuction(context.Background(), 1)
+	if err != nil || auction.HighestBidder != "bidder2" || auction.HighestBid != 130 {
+		t.Errorf("Wrong highest bid of auction")
+	}
+}
+
+func TestDBPlaceBidExtendsAuction(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	if _, err := db.PlaceBid(context.Background(), "bidder1", 3, 10); err != nil {
+		t.Errorf("Bid not accepted but should be")
+		return
+	}
+
+	auction, err := db.GetAuction(context.Background(), 3)
+	if err != nil || auction.EndsAt.Before(time.Now().Add(time.Minute)) {
+		t.Errorf("Auction not extended after a bid close to its end")
+	}
+}
+
+func TestDBSettleAuctionsRacing(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, alwaysRacing{})
+
+	// The auction is left to a later round while the motorcycle is racing
+	if err := db.SettleAuctions(context.Background()); err == nil {
+		t.Errorf("Able to settle auction but should not be (motorcycle racing)")
+		return
+	}
+
+	auction, err := db.GetAuction(context.Background(), 2)
+	if err != nil || auction.Settled {
+		t.Errorf("Auction settled while the motorcycle is racing")
+	}
+}
+
+func TestDBSettleAuctions(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	if err := db.SettleAuctions(context.Background()); err != nil {
+		t.Errorf("Unable to settle auctions")
+		return
+	}
+
+	auction, err := db.GetAuction(context.Background(), 2)
+	if err != nil || !auction.Settled {
+		t.Errorf("Ended auction not settled")
+	}
+
+	auction, err = db.GetAuction(context.Background(), 1)
+	if err != nil || auction.Settled {
+		t.Errorf("Auction settled before its end")
+	}
+
+	// Motorcycle goes to the winner keeping its level, escrowed money to the seller
+	owned, err := db.GetUserMotorcycleStats(context.Background(), "bidder2", 2)
+	if err != nil || owned.Level != 4 {
+		t.Errorf("Motorcycle not transferred to auction winner")
+	}
+
+	money, err := db.GetUserMoney(context.Background(), "auctioneer")
+	if err != nil || money != 150 {
+		t.Errorf("Wrong value of seller money after auction")
+	}
+}
+
+func TestDBEquipPartModifiesStats(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+	before, err := db.GetUserMotorcycleStats(context.Background(), "mechanic", 2)
+	if err != nil {
+		t.Errorf("Unable to get motorcycle stats")
+		return
+	}
+
+	// Titanium Exhaust: +4 engine, +1 aerodynamics
+	if err = db.EquipPart(context.Background(), "mechanic", 3, 2); err != nil {
+		t.Errorf("Part not equipped, but should be")
+		return
+	}
+
+	after, err := db.GetUserMotorcycleStats(context.Background(), "mechanic", 2)
+	if err != nil {
+		t.Errorf("Unable to get motorcycle stats")
+		return
+	}
+
+	if after.Engine != before.Engine+4 || after.Aerodynamics != before.Aerodynamics+1 || after.Agility != before.Agility {
+		t.Errorf("Equipped part not included in stats")
+		return
+	}
+
+	if err = db.UnequipPart(context.Background(), "mechanic", 3); err != nil {
+		t.Errorf("Part not unequipped, but should be")
+		return
+	}
+
+	after, err = db.GetUserMotorcycleStats(context.Background(), "mechanic", 2)
+	if err != nil || after.Engine != before.Engine {
+		t.Errorf("Unequipped part still included in stats")
+	}
+}
+
+func TestDBEquipPartReplacesSameType(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	// Rain Tires replace the Racing Slicks equipped to the Ducati
+	if err := db.EquipPart(context.Background(), "mechanic", 2, 1); err != nil {
+		t.Errorf("Part not equipped, but should be")
+		return
+	}
+
+	items, err := db.GetInventory(context.Background(), "mechanic")
+	if err != nil {
+		t.Errorf("Unable to get inventory")
+		return
+	}
+
+	for _, item := range items {
+		if item.Id == 1 && item.MotorcycleId != 0 {
+			t.Errorf("Replaced part still equipped")
+		}
+		if item.Id == 2 && item.MotorcycleId != 1 {
+			t.Errorf("Part not equipped to the motorcycle")
+		}
+	}
+}
+
+func TestDBEquipPartNotOwned(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	if err := db.EquipPart(context.Background(), "mechanic", 3, 3); err == nil {
+		t.Errorf("Part equipped to a motorcycle not owned")
+	}
+
+	if err := db.EquipPart(context.Background(), "user", 3, 1); err == nil {
+		t.Errorf("Part of another user equipped")
+	}
+}
+
+func TestDBBuyPart(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	// Race ECU Map costs 80
+	if err := db.BuyPart(context.Background(), "foo", 4); err == nil {
+		t.Errorf("Part bought, but should not be (not enough money)")
+		return
+	}
+
+	if err := db.BuyPart(context.Background(), "buyer1", 4); err != nil {
+		t.Errorf("Part not bought, but should be")
+		return
+	}
+
+	items, err := db.GetInventory(context.Background(), "buyer1")
+	if err != nil || len(items) != 1 || items[0].Part.Id != 4 || items[0].MotorcycleId != 0 {
+		t.Errorf("Bought part not in inventory")
+	}
+}
+
+func TestDBApplyWearDegradesStats(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+	before, err := db.GetUserMotorcycleStats(context.Background(), "tuner", 3)
+	if err != nil {
+		t.Errorf("Unable to get motorcycle stats")
+		return
+	}
+
+	// Wear is capped at 100, halving the stats
+	if err = db.ApplyWear(context.Background(), "tuner", 3, 150); err != nil {
+		t.Errorf("Wear not applied, but should be")
+		return
+	}
+
+	after, err := db.GetUserMotorcycleStats(context.Background(), "tuner", 3)
+	if err != nil {
+		t.Errorf("Unable to get motorcycle stats")
+		return
+	}
+
+	if after.Wear != 100 || after.Engine != before.Engine/2 || after.RepairPrice != after.PriceToBuy/2 {
+		t.Errorf("Wrong stats of worn motorcycle: wear %d, engine %d, repair price %d", after.Wear, after.Engine, after.RepairPrice)
+	}
+
+	if err = db.ApplyWear(context.Background(), "test", 2, 10); err == nil {
+		t.Errorf("Wear applied to a motorcycle not owned")
+	}
+}
+
+func TestDBRepairMotorcycle(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	// Repairing the Ducati costs 30, more than the money of the user
+	if err := db.RepairMotorcycle(context.Background(), "racer", 1); err == nil {
+		t.Errorf("Repair performed, but should not be (not enough money)")
+		return
+	}
+
+	if err := db.RepairMotorcycle(context.Background(), "racer", 2); err != nil {
+		t.Errorf("Repair not performed, but should be")
+		return
+	}
+
+	stats, err := db.GetUserMotorcycleStats(context.Background(), "racer", 2)
+	if err != nil || stats.Wear != 0 || stats.RepairPrice != 0 {
+		t.Errorf("Motorcycle still worn after repair")
+		return
+	}
+
+	money, err := db.GetUserMoney(context.Background(), "racer")
+	if err != nil || money != 14 {
+		t.Errorf("Wrong price of repair, money left %d", money)
+	}
+
+	if err := db.RepairMotorcycle(context.Background(), "racer", 2); err == nil {
+		t.Errorf("Repair performed on a motorcycle not worn")
+	}
+}
+
+func TestDBCustomizeMotorcycle(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	// Factory Orange costs 30, the rider name 10, race number is unchanged
+	if err := db.CustomizeMotorcycle(context.Background(), "stylist", 2, 2, 0, "Pecco"); err != nil {
+		t.Errorf("Customization not performed, but should be")
+		return
+	}
+
+	stats, err := db.GetUserMotorcycleStats(context.Background(), "stylist", 2)
+	if err != nil {
+		t.Errorf("Unable to get motorcycle stats")
+		return
+	}
+
+	if stats.Paint != "Factory Orange" || stats.RaceNumber != 46 || stats.RiderName != "Pecco" {
+		t.Errorf("Wrong livery after customization: %s, %d, %s", stats.Paint, stats.RaceNumber, stats.RiderName)
+	}
+
+	money, err := db.GetUserMoney(context.Background(), "stylist")
+	if err != nil || money != 60 {
+		t.Errorf("Wrong price of customization, money left %d", money)
+	}
+
+	// The length of the rider name is counted in characters, not bytes
+	if err := db.CustomizeMotorcycle(context.Background(), "stylist", 2, 0, 0, strings.Repeat("è", 32)); err != nil {
+		t.Errorf("Rider name of 32 characters refused: %v", err)
+	}
+}
+
+func TestDBCustomizeMotorcycleInvalid(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	if err := db.CustomizeMotorcycle(context.Background(), "stylist", 2, 0, 100, ""); err == nil {
+		t.Errorf("Race number out of range accepted")
+	}
+
+	if err := db.CustomizeMotorcycle(context.Background(), "stylist", 2, 99, 0, ""); err == nil {
+		t.Errorf("Unknown paint accepted")
+	}
+
+	if err := db.CustomizeMotorcycle(context.Background(), "stylist", 1, 0, 7, ""); err == nil {
+		t.Errorf("Motorcycle not owned customized")
+	}
+
+	if err := db.CustomizeMotorcycle(context.Background(), "stylist", 2, 0, 0, ""); err == nil {
+		t.Errorf("Empty customization accepted")
+	}
+}
+
+func TestDBClaimDailyReward(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	// Consecutive day: streak 2 becomes 3, reward 20+10*2
+	reward, err := db.ClaimDailyReward(context.Background(), "loyal", 20, 10, 7)
+	if err != nil {
+		t.Errorf("Unable to claim daily reward: %s", err)
+		return
+	}
+	if !reward.Claimed || reward.Streak != 3 || reward.Money != 40 {
+		t.Errorf("Wrong daily reward: %+v", reward)
+	}
+
+	// Second claim of the same day gives nothing
+	reward, err = db.ClaimDailyReward(context.Background(), "loyal", 20, 10, 7)
+	if err != nil || reward.Claimed || reward.Streak != 3 {
+		t.Errorf("Daily reward claimed twice: %+v", reward)
+	}
+
+	money, err := db.GetUserMoney(context.Background(), "loyal")
+	if err != nil || money != 40 {
+		t.Errorf("Wrong money after daily reward: %d", money)
+	}
+
+	// Missed days reset the streak
+	reward, err = db.ClaimDailyReward(context.Background(), "returning", 20, 10, 7)
+	if err != nil || !reward.Claimed || reward.Streak != 1 || reward.Money != 20 {
+		t.Errorf("Streak not reset: %+v", reward)
+	}
+
+	// Already claimed today
+	reward, err = db.ClaimDailyReward(context.Background(), "claimed", 20, 10, 7)
+	if err != nil || reward.Claimed {
+		t.Errorf("Daily reward claimed twice: %+v", reward)
+	}
+
+	// First claim ever starts a new streak
+	reward, err = db.ClaimDailyReward(context.Background(), "test", 20, 10, 7)
+	if err != nil || !reward.Claimed || reward.Streak != 1 || reward.Money != 20 {
+		t.Errorf("Wrong first daily reward: %+v", reward)
+	}
+
+	if _, err = db.ClaimDailyReward(context.Background(), "nobody", 20, 10, 7); err == nil {
+		t.Errorf("Daily reward claimed by unknown user")
+	}
+}
+
+func TestDBDecreaseUserMoney(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	if err := db.DecreaseUserMoney(context.Background(), "donor", 30); err != nil {
+		t.Errorf("Unable to decrease money: %v", err)
+	}
+
+	if err := db.DecreaseUserMoney(context.Background(), "donor", 30); err == nil {
+		t.Errorf("Money decreased below zero")
+	}
+
+	if money, err := db.GetUserMoney(context.Background(), "donor"); err != nil || money != 20 {
+		t.Errorf("Wrong money after decrease: %d", money)
+	}
+}
+
+func TestDBClaimPayout(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn, notRacing{})
+
+	claimed, err := db.ClaimPayout(context.Background(), "championship-1-round-1")
+	if err != nil || !claimed {
+		t.Errorf("Unable to claim payout: %s", err)
+	}
+
+	// A key notified again is not paid twice
+	claimed, err = db.ClaimPayout(context.Background(), "championship-1-round-1")
+	if err != nil || claimed {
+		t.Errorf("Payout claimed twice")
+	}
+
+	claimed, err = db.ClaimPayout(context.Background(), "championship-1-round-2")
+	if err != nil || !claimed {
+		t.Errorf("Unable to claim payout of another round: %s", err)
+	}
+
+	if _, err = db.ClaimPayout(context.Background(), ""); err == nil {
+		t.Errorf("Payout claimed without a key")
+	}
+
+	// A released key is claimed again by the next caller
+	if err := db.ReleasePayout(context.Background(), "championship-1-round-1"); err != nil {
+		t.Errorf("Unable to release payout: %s", err)
+	}
+
+	claimed, err = db.ClaimPayout(context.Background(), "championship-1-round-1")
+	if err != nil || !claimed {
+		t.Errorf("Released payout not claimed again: %s", err)
+	}
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+)
+
+// Shapes of the curve followed by the upgrade prices of a motorcycle
+const (
+	LinearCurve      = "linear"      // base price increased by PriceCurveFactor for each level
+	ExponentialCurve = "exponential" // base price increased by PriceCurveFactor percent for each level
+	TableCurve       = "table"       // prices listed in UpgradePrices, base price for missing levels
+)
+
+// Prices listed for a table-driven curve, by stat column prefix and level
+type priceTable map[string]map[int]int
+
+// Common subset of sql.DB and sql.Tx used to read prices
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// Price to upgrade a stat from level to level+1
+func upgradePrice(curve string, factor int, base int, level int, prices map[int]int) int {
+	switch curve {
+	case ExponentialCurve:
+		price := base
+		for i := 1; i < level; i++ {
+			price = price * (100 + factor) / 100
+		}
+		return price
+	case TableCurve:
+		if price, ok := prices[level]; ok {
+			return price
+		}
+		return base
+	default:
+		return base + factor*(level-1)
+	}
+}
+
+func getPriceTable(ctx context.Context, q queryer, MotorcycleId int) (priceTable, error) {
+	rows, err := q.QueryContext(ctx, "SELECT Stat, Level, Price FROM UpgradePrices WHERE MotorcycleId=?", MotorcycleId)
+	if err != nil {
+		slog.ErrorContext(ctx, "getPriceTable failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	table := priceTable{}
+	for rows.Next() {
+		var stat string
+		var level, price int
+		if err := rows.Scan(&stat, &level, &price); err != nil {
+			slog.ErrorContext(ctx, "getPriceTable failed", "error", err)
+			return nil, err
+		}
+		if table[stat] == nil {
+			table[stat] = map[int]int{}
+		}
+		table[stat][level] = price
+	}
+
+	return table, rows.Err()
+}
+
+// Price to fully repair a motorcycle, a completely worn one costs half of its price
+func repairPrice(PriceToBuy int, wear int) int {
+	return PriceToBuy * wear / 200
+}
+
+// Daily reward for a login streak, each consecutive day adds bonus up to max_streak days
+func dailyReward(reward int, bonus int, max_streak int, streak int) int {
+	if streak > max_streak {
+		streak = max_streak
+	}
+	return reward + bonus*(streak-1)
+}
+
+// Fill the prices of the next upgrade of each stat and of the repair
+func (o *Ownership) setPrices(ctx context.Context, q queryer) error {
+	var table priceTable
+	if o.PriceCurve == TableCurve {
+		var err error
+		if table, err = getPriceTable(ctx, q, o.MotorcycleId); err != nil {
+			return err
+		}
+	}
+
+	o.EngineNextUpgradePrice = upgradePrice(o.PriceCurve, o.PriceCurveFactor, o.EnginePriceToUpgrade, o.EngineLevel, table["Engine"])
+	o.AgilityNextUpgradePrice = upgradePrice(o.PriceCurve, o.PriceCurveFactor, o.AgilityPriceToUpgrade, o.AgilityLevel, table["Agility"])
+	o.BrakesNextUpgradePrice = upgradePrice(o.PriceCurve, o.PriceCurveFactor, o.BrakesPriceToUpgrade, o.BrakesLevel, table["Brakes"])
+	o.AerodynamicsNextUpgradePrice = upgradePrice(o.PriceCurve, o.PriceCurveFactor, o.AerodynamicsPriceToUpgrade, o.AerodynamicsLevel, table["Aerodynamics"])
+	o.RepairPrice = repairPrice(o.PriceToBuy, o.Wear)
+	return nil
+}
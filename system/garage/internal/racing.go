@@ -0,0 +1,37 @@
+package internal
+
+import (
+	"context"
+
+	"garage/telemetry"
+
+	pb "garage/proto"
+
+	"google.golang.org/grpc"
+)
+
+// Racing status of the motorcycles, a motorcycle entered in a race can not change owner
+type RacingStatus interface {
+	IsRacing(ctx context.Context, username string, MotorcycleId int) (bool, error)
+}
+
+// Implementation asking the Racing service
+type RacingClient struct {
+	conn *grpc.ClientConn
+}
+
+func NewRacingClient(conn *grpc.ClientConn) *RacingClient {
+	return &RacingClient{conn: conn}
+}
+
+func (r *RacingClient) IsRacing(ctx context.Context, username string, MotorcycleId int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.CheckIsRacing"))
+	defer cancel()
+
+	status, err := pb.NewRacingClient(r.conn).CheckIsRacing(ctx, &pb.PlayerMotorcycle{Username: username, MotorcycleId: int32(MotorcycleId)})
+	if err != nil {
+		return false, err
+	}
+
+	return status.IsRacing, nil
+}
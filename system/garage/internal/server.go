@@ -2,151 +2,530 @@ package internal
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	pb "garage/proto"
 
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+// Interval between checks for new bids when watching an auction
+const watchInterval = 500 * time.Millisecond
+
 type Server struct {
 	pb.UnimplementedGarageServer
 	pb.UnimplementedStillAliveServer
-	db GarageDB
+	db    GarageDB
+	daily *DailyRewardConfig
+}
+
+func NewServer(conn GarageDB, daily *DailyRewardConfig) *Server {
+	return &Server{db: conn, daily: daily}
+}
+
+// Money given by the daily reward
+type DailyRewardConfig struct {
+	Reward      int // money of the first day of a streak
+	StreakBonus int // money added for each consecutive day
+	MaxStreak   int // days after which the reward stops growing
+}
+
+func DailyRewardConfigFromEnv() (*DailyRewardConfig, error) {
+	// Read from DAILY_REWARD, DAILY_STREAK_BONUS and DAILY_MAX_STREAK, the service does not start with invalid amounts
+
+	values := map[string]int{}
+	for _, name := range []string{"DAILY_REWARD", "DAILY_STREAK_BONUS", "DAILY_MAX_STREAK"} {
+		value, err := strconv.Atoi(os.Getenv(name))
+		if err != nil || value < 0 {
+			return nil, fmt.Errorf("invalid %s: %q", name, os.Getenv(name))
+		}
+		values[name] = value
+	}
+
+	if values["DAILY_MAX_STREAK"] == 0 {
+		return nil, errors.New("invalid DAILY_MAX_STREAK: a streak lasts at least one day")
+	}
+
+	return &DailyRewardConfig{Reward: values["DAILY_REWARD"], StreakBonus: values["DAILY_STREAK_BONUS"], MaxStreak: values["DAILY_MAX_STREAK"]}, nil
+}
+
+func motorcycleToInfo(m *Motorcycle) *pb.MotorcycleInfo {
+	return &pb.MotorcycleInfo{
+		Id:                         int32(m.Id),
+		Name:                       m.Name,
+		PriceToBuy:                 int32(m.PriceToBuy),
+		MaxLevel:                   int32(m.MaxLevel),
+		Engine:                     int32(m.Engine),
+		EngineIncrement:            int32(m.EngineIncrement),
+		EngineMaxLevel:             int32(m.EngineMaxLevel),
+		EnginePriceToUpgrade:       int32(m.EnginePriceToUpgrade),
+		Agility:                    int32(m.Agility),
+		AgilityIncrement:           int32(m.AgilityIncrement),
+		AgilityMaxLevel:            int32(m.AgilityMaxLevel),
+		AgilityPriceToUpgrade:      int32(m.AgilityPriceToUpgrade),
+		Brakes:                     int32(m.Brakes),
+		BrakesIncrement:            int32(m.BrakesIncrement),
+		BrakesMaxLevel:             int32(m.BrakesMaxLevel),
+		BrakesPriceToUpgrade:       int32(m.BrakesPriceToUpgrade),
+		Aerodynamics:               int32(m.Aerodynamics),
+		AerodynamicsIncrement:      int32(m.AerodynamicsIncrement),
+		AerodynamicsMaxLevel:       int32(m.AerodynamicsMaxLevel),
+		AerodynamicsPriceToUpgrade: int32(m.AerodynamicsPriceToUpgrade),
+		PriceCurve:                 m.PriceCurve,
+		PriceCurveFactor:           int32(m.PriceCurveFactor),
+	}
 }
 
-func NewServer(conn GarageDB) *Server {
-	return &Server{db: conn}
+func ownershipToInfo(o *Ownership) *pb.OwnershipInfo {
+	return &pb.OwnershipInfo{
+		Username:          o.Username,
+		MotorcycleId:      int32(o.MotorcycleId),
+		Level:             int32(o.Level),
+		EngineLevel:       int32(o.EngineLevel),
+		AgilityLevel:      int32(o.AgilityLevel),
+		BrakesLevel:       int32(o.BrakesLevel),
+		AerodynamicsLevel: int32(o.AerodynamicsLevel),
+
+		EngineNextUpgradePrice:       int32(o.EngineNextUpgradePrice),
+		AgilityNextUpgradePrice:      int32(o.AgilityNextUpgradePrice),
+		BrakesNextUpgradePrice:       int32(o.BrakesNextUpgradePrice),
+		AerodynamicsNextUpgradePrice: int32(o.AerodynamicsNextUpgradePrice),
+
+		Wear:        int32(o.Wear),
+		RepairPrice: int32(o.RepairPrice),
+		Livery: &pb.Livery{
+			Paint:      o.Paint,
+			PaintColor: o.PaintColor,
+			RaceNumber: int32(o.RaceNumber),
+			RiderName:  o.RiderName,
+		},
+
+		MotorcycleInfo: motorcycleToInfo(&Motorcycle{
+			Id:                         o.MotorcycleId,
+			Name:                       o.Name,
+			PriceToBuy:                 o.PriceToBuy,
+			MaxLevel:                   o.MaxLevel,
+			Engine:                     o.Engine,
+			EngineIncrement:            o.EngineIncrement,
+			EngineMaxLevel:             o.EngineMaxLevel,
+			EnginePriceToUpgrade:       o.EnginePriceToUpgrade,
+			Agility:                    o.Agility,
+			AgilityIncrement:           o.AgilityIncrement,
+			AgilityMaxLevel:            o.AgilityMaxLevel,
+			AgilityPriceToUpgrade:      o.AgilityPriceToUpgrade,
+			Brakes:                     o.Brakes,
+			BrakesIncrement:            o.BrakesIncrement,
+			BrakesMaxLevel:             o.BrakesMaxLevel,
+			BrakesPriceToUpgrade:       o.BrakesPriceToUpgrade,
+			Aerodynamics:               o.Aerodynamics,
+			AerodynamicsIncrement:      o.AerodynamicsIncrement,
+			AerodynamicsMaxLevel:       o.AerodynamicsMaxLevel,
+			AerodynamicsPriceToUpgrade: o.AerodynamicsPriceToUpgrade,
+			PriceCurve:                 o.PriceCurve,
+			PriceCurveFactor:           o.PriceCurveFactor,
+		}),
+	}
+}
+
+func partToInfo(p *Part) *pb.PartInfo {
+	return &pb.PartInfo{
+		Id:           int32(p.Id),
+		Name:         p.Name,
+		Type:         p.Type,
+		Price:        int32(p.Price),
+		Engine:       int32(p.Engine),
+		Agility:      int32(p.Agility),
+		Brakes:       int32(p.Brakes),
+		Aerodynamics: int32(p.Aerodynamics),
+	}
 }
 
 func (s *Server) GetRemainingMotorcycles(in *pb.PlayerUsername, stream pb.Garage_GetRemainingMotorcyclesServer) error {
-	motorcycles, err := s.db.GetRemainingMotorcycles(in.Username)
+	motorcycles, err := s.db.GetRemainingMotorcycles(stream.Context(), in.Username)
 
 	if len(motorcycles) == 0 || err != nil {
-		log.Println(err)
+		slog.ErrorContext(stream.Context(), "GetRemainingMotorcycles failed", "error", err)
 		return err
 	}
 
-	log.Printf("Retrieving motorcycles not owned (%s)", in.Username)
+	slog.DebugContext(stream.Context(), "Retrieving motorcycles not owned", "username", in.Username)
 
 	for _, v := range motorcycles {
-		stream.Send(&pb.MotorcycleInfo{
-			Id:                    int32(v.Id),
-			Name:                  v.Name,
-			PriceToBuy:            int32(v.PriceToBuy),
-			PriceToUpgrade:        int32(v.PriceToUpgrade),
-			MaxLevel:              int32(v.MaxLevel),
-			Engine:                int32(v.Engine),
-			EngineIncrement:       int32(v.EngineIncrement),
-			Agility:               int32(v.Agility),
-			AgilityIncrement:      int32(v.AgilityIncrement),
-			Brakes:                int32(v.Brakes),
-			BrakesIncrement:       int32(v.BrakesIncrement),
-			Aerodynamics:          int32(v.Aerodynamics),
-			AerodynamicsIncrement: int32(v.AerodynamicsIncrement),
-		})
+		stream.Send(motorcycleToInfo(v))
 	}
 
 	return nil
 }
 
 func (s *Server) GetUserMotorcycles(in *pb.PlayerUsername, stream pb.Garage_GetUserMotorcyclesServer) error {
-	ownerships, err := s.db.GetUserMotorcycles(in.Username)
+	ownerships, err := s.db.GetUserMotorcycles(stream.Context(), in.Username)
 
 	if len(ownerships) == 0 || err != nil {
-		log.Println(err)
+		slog.ErrorContext(stream.Context(), "GetUserMotorcycles failed", "error", err)
 		return err
 	}
 
-	log.Printf("Retrieving motorcycles owned (%s)", in.Username)
+	slog.DebugContext(stream.Context(), "Retrieving motorcycles owned", "username", in.Username)
 
 	for _, v := range ownerships {
-		stream.Send(&pb.OwnershipInfo{
-			Username:     v.Username,
-			MotorcycleId: int32(v.MotorcycleId),
-			Level:        int32(v.Level),
-			MotorcycleInfo: &pb.MotorcycleInfo{
-				Id:                    int32(v.MotorcycleId),
-				Name:                  v.Name,
-				PriceToBuy:            int32(v.PriceToBuy),
-				PriceToUpgrade:        int32(v.PriceToUpgrade),
-				MaxLevel:              int32(v.MaxLevel),
-				Engine:                int32(v.Engine),
-				EngineIncrement:       int32(v.EngineIncrement),
-				Agility:               int32(v.Agility),
-				AgilityIncrement:      int32(v.AgilityIncrement),
-				Brakes:                int32(v.Brakes),
-				BrakesIncrement:       int32(v.BrakesIncrement),
-				Aerodynamics:          int32(v.Aerodynamics),
-				AerodynamicsIncrement: int32(v.AerodynamicsIncrement),
-			},
-		})
+		stream.Send(ownershipToInfo(v))
 	}
 
 	return nil
 }
 
 func (s *Server) GetUserMotorcycleStats(ctx context.Context, in *pb.PlayerMotorcycle) (*pb.OwnershipInfo, error) {
-	ownership, err := s.db.GetUserMotorcycleStats(in.Username, int(in.MotorcycleId))
+	ownership, err := s.db.GetUserMotorcycleStats(ctx, in.Username, int(in.MotorcycleId))
 
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "GetUserMotorcycleStats failed", "error", err)
 		return nil, err
 	}
 
-	log.Printf("Motorcyce stats (%s:%d)", in.Username, in.MotorcycleId)
+	slog.DebugContext(ctx, "Retrieving motorcycle stats", "username", in.Username, "motorcycle_id", in.MotorcycleId)
 
-	info := &pb.OwnershipInfo{
-		Username:     ownership.Username,
-		MotorcycleId: int32(ownership.MotorcycleId),
-		Level:        int32(ownership.Level),
-		MotorcycleInfo: &pb.MotorcycleInfo{
-			Id:                    int32(ownership.MotorcycleId),
-			Name:                  ownership.Name,
-			PriceToBuy:            int32(ownership.PriceToBuy),
-			PriceToUpgrade:        int32(ownership.PriceToUpgrade),
-			MaxLevel:              int32(ownership.MaxLevel),
-			Engine:                int32(ownership.Engine),
-			EngineIncrement:       int32(ownership.EngineIncrement),
-			Agility:               int32(ownership.Agility),
-			AgilityIncrement:      int32(ownership.AgilityIncrement),
-			Brakes:                int32(ownership.Brakes),
-			BrakesIncrement:       int32(ownership.BrakesIncrement),
-			Aerodynamics:          int32(ownership.Aerodynamics),
-			AerodynamicsIncrement: int32(ownership.AerodynamicsIncrement),
-		}}
-
-	return info, nil
+	return ownershipToInfo(ownership), nil
 }
 
 func (s *Server) GetUserMoney(ctx context.Context, in *pb.PlayerUsername) (*pb.UserMoney, error) {
-	money, err := s.db.GetUserMoney(in.Username)
+	money, err := s.db.GetUserMoney(ctx, in.Username)
 
-	log.Printf("Retrieving money (%s) with value %d", in.Username, money)
+	slog.DebugContext(ctx, "Retrieving money", "username", in.Username, "money", money)
 
 	return &pb.UserMoney{Money: int32(money)}, err
 }
 
 func (s *Server) IncreaseUserMoney(ctx context.Context, in *pb.MoneyIncrease) (*emptypb.Empty, error) {
-	err := s.db.IncreaseUserMoney(in.Username, int(in.Money))
+	err := s.db.IncreaseUserMoney(ctx, in.Username, int(in.Money))
 
-	log.Printf("Increasing money (%s) with value %d", in.Username, in.Money)
+	slog.InfoContext(ctx, "Increasing money", "username", in.Username, "money", in.Money)
+
+	return nil, err
+}
+
+func (s *Server) DecreaseUserMoney(ctx context.Context, in *pb.MoneyIncrease) (*emptypb.Empty, error) {
+	err := s.db.DecreaseUserMoney(ctx, in.Username, int(in.Money))
+
+	slog.InfoContext(ctx, "Decreasing money", "username", in.Username, "money", in.Money)
 
 	return nil, err
 }
 
 func (s *Server) BuyMotorcycle(ctx context.Context, in *pb.PlayerMotorcycle) (*emptypb.Empty, error) {
-	log.Printf("Buying motorcycle (%s:%d)", in.Username, in.MotorcycleId)
+	slog.InfoContext(ctx, "Buying motorcycle", "username", in.Username, "motorcycle_id", in.MotorcycleId)
+
+	return nil, s.db.BuyMotorcycle(ctx, in.Username, int(in.MotorcycleId))
+}
+
+func (s *Server) UpgradeMotorcycle(ctx context.Context, in *pb.UpgradeRequest) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Upgrading motorcycle", "username", in.Username, "motorcycle_id", in.MotorcycleId, "stat", in.Stat)
+
+	if in.Stat == pb.MotorcycleStat_STAT_UNSPECIFIED {
+		return nil, errors.New("unknown stat to upgrade")
+	}
+
+	return nil, s.db.UpgradeMotorcycle(ctx, in.Username, int(in.MotorcycleId), strings.ToLower(in.Stat.String()))
+}
+
+func (s *Server) GetListings(_ *emptypb.Empty, stream pb.Garage_GetListingsServer) error {
+	listings, err := s.db.GetListings(stream.Context())
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetListings failed", "error", err)
+		return err
+	}
+
+	slog.DebugContext(stream.Context(), "Retrieving listings")
+
+	for _, v := range listings {
+		stream.Send(&pb.ListingInfo{
+			Id:        int32(v.Id),
+			Price:     int32(v.Price),
+			ExpiresAt: timestamppb.New(v.ExpiresAt),
+			Ownership: ownershipToInfo(&v.Ownership),
+		})
+	}
+
+	return nil
+}
+
+func (s *Server) CreateListing(ctx context.Context, in *pb.ListingRequest) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Creating listing", "username", in.Username, "motorcycle_id", in.MotorcycleId, "price", in.Price, "duration_hours", in.DurationHours)
+
+	return nil, s.db.CreateListing(ctx, in.Username, int(in.MotorcycleId), int(in.Price), time.Duration(in.DurationHours)*time.Hour)
+}
+
+func (s *Server) CancelListing(ctx context.Context, in *pb.ListingAction) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Cancelling listing", "username", in.Username, "listing_id", in.ListingId)
+
+	return nil, s.db.CancelListing(ctx, in.Username, int(in.ListingId))
+}
+
+func (s *Server) BuyListing(ctx context.Context, in *pb.ListingAction) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Buying listing", "username", in.Username, "listing_id", in.ListingId)
+
+	return nil, s.db.BuyListing(ctx, in.Username, int(in.ListingId))
+}
+
+func auctionToInfo(a *Auction) *pb.AuctionInfo {
+	return &pb.AuctionInfo{
+		Id:             int32(a.Id),
+		Seller:         a.Seller,
+		MotorcycleId:   int32(a.MotorcycleId),
+		MotorcycleName: a.MotorcycleName,
+		Level:          int32(a.Level),
+		ReservePrice:   int32(a.ReservePrice),
+		EndsAt:         timestamppb.New(a.EndsAt),
+		HighestBidder:  a.HighestBidder,
+		HighestBid:     int32(a.HighestBid),
+		Settled:        a.Settled,
+	}
+}
+
+func (s *Server) GetAuctions(_ *emptypb.Empty, stream pb.Garage_GetAuctionsServer) error {
+	auctions, err := s.db.GetAuctions(stream.Context())
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetAuctions failed", "error", err)
+		return err
+	}
+
+	slog.DebugContext(stream.Context(), "Retrieving auctions")
+
+	for _, v := range auctions {
+		stream.Send(auctionToInfo(v))
+	}
+
+	return nil
+}
+
+func (s *Server) GetAuction(ctx context.Context, in *pb.AuctionReference) (*pb.AuctionInfo, error) {
+	auction, err := s.db.GetAuction(ctx, int(in.AuctionId))
+
+	if err != nil {
+		slog.ErrorContext(ctx, "GetAuction failed", "error", err)
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "Retrieving auction", "auction_id", in.AuctionId)
+
+	return auctionToInfo(auction), nil
+}
+
+func (s *Server) CreateAuction(ctx context.Context, in *pb.AuctionRequest) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Creating auction", "username", in.Username, "motorcycle_id", in.MotorcycleId, "reserve_price", in.ReservePrice, "duration_minutes", in.DurationMinutes)
+
+	return nil, s.db.CreateAuction(ctx, in.Username, int(in.MotorcycleId), int(in.Level), int(in.ReservePrice), time.Duration(in.DurationMinutes)*time.Minute)
+}
+
+func (s *Server) PlaceBid(ctx context.Context, in *pb.BidRequest) (*pb.BidReceipt, error) {
+	slog.InfoContext(ctx, "Placing bid", "username", in.Username, "auction_id", in.AuctionId, "amount", in.Amount)
+
+	outbid, err := s.db.PlaceBid(ctx, in.Username, int(in.AuctionId), int(in.Amount))
+	if err != nil {
+		return nil, err
+	}
 
-	return nil, s.db.BuyMotorcycle(in.Username, int(in.MotorcycleId))
+	return &pb.BidReceipt{Outbid: outbid}, nil
 }
 
-func (s *Server) UpgradeMotorcycle(ctx context.Context, in *pb.PlayerMotorcycle) (*emptypb.Empty, error) {
-	log.Printf("Upgrading motorcycle (%s:%d)", in.Username, in.MotorcycleId)
+func (s *Server) WatchAuction(in *pb.AuctionReference, stream pb.Garage_WatchAuctionServer) error {
+	// Stream bids as they are placed until the auction is settled or the watcher leaves
+	// bids are read from the database since they could be placed through any replica
+
+	slog.DebugContext(stream.Context(), "Watching auction", "auction_id", in.AuctionId)
+
+	last := 0
+	for {
+		auction, err := s.db.GetAuction(stream.Context(), int(in.AuctionId))
+		if err != nil {
+			slog.ErrorContext(stream.Context(), "WatchAuction failed", "error", err)
+			return err
+		}
+
+		bids, err := s.db.GetBids(stream.Context(), int(in.AuctionId), last)
+		if err != nil {
+			slog.ErrorContext(stream.Context(), "WatchAuction failed", "error", err)
+			return err
+		}
+
+		for _, v := range bids {
+			err = stream.Send(&pb.BidInfo{
+				Id:        int32(v.Id),
+				AuctionId: int32(v.AuctionId),
+				Username:  v.Username,
+				Amount:    int32(v.Amount),
+				Time:      timestamppb.New(v.Time),
+				EndsAt:    timestamppb.New(auction.EndsAt),
+			})
+			if err != nil {
+				slog.ErrorContext(stream.Context(), "WatchAuction failed", "error", err)
+				return err
+			}
+			last = v.Id
+		}
+
+		if auction.Settled {
+			return nil
+		}
 
-	return nil, s.db.UpgradeMotorcycle(in.Username, int(in.MotorcycleId))
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-time.After(watchInterval):
+		}
+	}
 }
 
 func (s *Server) StillAlive(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
 	return nil, nil
 }
+
+func (s *Server) GetParts(_ *emptypb.Empty, stream pb.Garage_GetPartsServer) error {
+	parts, err := s.db.GetParts(stream.Context())
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetParts failed", "error", err)
+		return err
+	}
+
+	for _, v := range parts {
+		stream.Send(partToInfo(v))
+	}
+
+	return nil
+}
+
+func (s *Server) GetInventory(in *pb.PlayerUsername, stream pb.Garage_GetInventoryServer) error {
+	items, err := s.db.GetInventory(stream.Context(), in.Username)
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetInventory failed", "error", err)
+		return err
+	}
+
+	slog.DebugContext(stream.Context(), "Retrieving inventory", "username", in.Username)
+
+	for _, v := range items {
+		stream.Send(&pb.InventoryItem{
+			Id:           int32(v.Id),
+			Part:         partToInfo(&v.Part),
+			MotorcycleId: int32(v.MotorcycleId),
+		})
+	}
+
+	return nil
+}
+
+func (s *Server) BuyPart(ctx context.Context, in *pb.PartRequest) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Buying part", "username", in.Username, "part_id", in.PartId)
+
+	return nil, s.db.BuyPart(ctx, in.Username, int(in.PartId))
+}
+
+func (s *Server) AwardPart(ctx context.Context, in *pb.PlayerUsername) (*pb.PartInfo, error) {
+	part, err := s.db.AwardPart(ctx, in.Username)
+	if err != nil {
+		slog.ErrorContext(ctx, "AwardPart failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Awarded part", "part", part.Name, "username", in.Username)
+
+	return partToInfo(part), nil
+}
+
+func (s *Server) EquipPart(ctx context.Context, in *pb.EquipRequest) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Equipping part", "username", in.Username, "item_id", in.ItemId, "motorcycle_id", in.MotorcycleId)
+
+	return nil, s.db.EquipPart(ctx, in.Username, int(in.ItemId), int(in.MotorcycleId))
+}
+
+func (s *Server) UnequipPart(ctx context.Context, in *pb.EquipRequest) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Unequipping part", "username", in.Username, "item_id", in.ItemId)
+
+	return nil, s.db.UnequipPart(ctx, in.Username, int(in.ItemId))
+}
+
+func (s *Server) ApplyWear(ctx context.Context, in *pb.WearIncrease) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Applying wear", "username", in.Username, "motorcycle_id", in.MotorcycleId, "wear", in.Wear)
+
+	return nil, s.db.ApplyWear(ctx, in.Username, int(in.MotorcycleId), int(in.Wear))
+}
+
+func (s *Server) RepairMotorcycle(ctx context.Context, in *pb.PlayerMotorcycle) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Repairing motorcycle", "username", in.Username, "motorcycle_id", in.MotorcycleId)
+
+	return nil, s.db.RepairMotorcycle(ctx, in.Username, int(in.MotorcycleId))
+}
+
+func (s *Server) GetPaints(_ *emptypb.Empty, stream pb.Garage_GetPaintsServer) error {
+	paints, err := s.db.GetPaints(stream.Context())
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetPaints failed", "error", err)
+		return err
+	}
+
+	for _, v := range paints {
+		stream.Send(&pb.PaintInfo{
+			Id:    int32(v.Id),
+			Name:  v.Name,
+			Color: v.Color,
+			Price: int32(v.Price),
+		})
+	}
+
+	return nil
+}
+
+func (s *Server) CustomizeMotorcycle(ctx context.Context, in *pb.CustomizationRequest) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Customizing motorcycle", "username", in.Username, "motorcycle_id", in.MotorcycleId)
+
+	return nil, s.db.CustomizeMotorcycle(ctx, in.Username, int(in.MotorcycleId), int(in.PaintId), int(in.RaceNumber), in.RiderName)
+}
+
+func (s *Server) ClaimDailyReward(ctx context.Context, in *pb.PlayerUsername) (*pb.DailyReward, error) {
+	reward, err := s.db.ClaimDailyReward(ctx, in.Username, s.daily.Reward, s.daily.StreakBonus, s.daily.MaxStreak)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimDailyReward failed", "error", err)
+		return nil, err
+	}
+
+	if reward.Claimed {
+		slog.InfoContext(ctx, "Daily reward", "username", in.Username, "money", reward.Money, "streak", reward.Streak)
+	}
+
+	return &pb.DailyReward{Claimed: reward.Claimed, Streak: int32(reward.Streak), Money: int32(reward.Money)}, nil
+}
+
+func (s *Server) ClaimPayout(ctx context.Context, in *pb.PayoutKey) (*pb.PayoutClaim, error) {
+	claimed, err := s.db.ClaimPayout(ctx, in.Key)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimPayout failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Claiming payout", "key", in.Key, "claimed", claimed)
+
+	return &pb.PayoutClaim{Claimed: claimed}, nil
+}
+
+func (s *Server) ReleasePayout(ctx context.Context, in *pb.PayoutKey) (*emptypb.Empty, error) {
+	err := s.db.ReleasePayout(ctx, in.Key)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReleasePayout failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Releasing payout", "key", in.Key)
+
+	return &emptypb.Empty{}, nil
+}
@@ -2,17 +2,18 @@ package main
 
 import (
 	"context"
-	"database/sql"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"os"
 	"time"
 
 	"garage/internal"
+	"garage/telemetry"
 
 	pb "garage/proto"
 
@@ -21,15 +22,25 @@ import (
 )
 
 func main() {
+	// Structured log of the service, level set by LOG_LEVEL
+	telemetry.InitLogging("garage")
+
 	// Listener for Service
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", os.Getenv("SERVICE_PORT")))
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	log.Printf("server listening at %v", lis.Addr())
+	slog.Info("server listening", "address", lis.Addr().String())
+
+	// Metrics of the service for Prometheus
+	go telemetry.ServeMetrics(os.Getenv("METRICS_PORT"))
+
+	// Traces of the service, exported as set by TRACING_EXPORTER
+	shutdown := telemetry.InitTracing("garage")
+	defer shutdown(context.Background())
 
 	// Database connection for Garage DB
-	db, err := sql.Open("mysql", "root:admin@tcp(garage_db:3306)/Garage")
+	db, err := telemetry.OpenDB(mysql.MySQLDriver{}, "root:admin@tcp(garage_db:3306)/Garage?parseTime=true")
 	if err != nil {
 		log.Fatalf("failed to connect to db: %s", err)
 	}
@@ -38,15 +49,35 @@ func main() {
 		log.Fatalf("error pinging database: %v", err)
 	}
 
+	// Amounts of the daily reward, checked before serving any claim
+	daily, err := internal.DailyRewardConfigFromEnv()
+	if err != nil {
+		log.Fatalf("failed to configure daily reward: %v", err)
+	}
+
+	// Racing status of the motorcycles changing owner on the marketplace
+	racing, err := grpc.NewClient(fmt.Sprintf("racing:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()), telemetry.DialOption())
+	if err != nil {
+		log.Fatalf("failed to connect to racing: %v", err)
+	}
+	defer racing.Close()
+
 	// Creating gRPC server
-	s := grpc.NewServer()
-	server := internal.NewServer(internal.NewSQL_DB(db))
+	s := grpc.NewServer(telemetry.ServerOption())
+	garage_db := internal.NewSQL_DB(db, internal.NewRacingClient(racing))
+	server := internal.NewServer(garage_db, daily)
 	pb.RegisterGarageServer(s, server)
 	pb.RegisterStillAliveServer(s, server)
 
 	// Call to parallel registration of the service to Orchestrator
 	go registerToOrchestrator()
 
+	// Periodic cleanup of expired marketplace listings
+	go removeExpiredListings(garage_db)
+
+	// Periodic settlement of ended auctions
+	go settleAuctions(garage_db)
+
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
@@ -54,27 +85,48 @@ func main() {
 }
 
 func registerToOrchestrator() {
-	log.Printf("Trying to connect to Orchestrator")
-	conn, err := grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	slog.Info("Trying to connect to Orchestrator")
+	conn, err := grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()), telemetry.DialOption())
 	for err != nil {
 		// Wait if unable to connect to orchestrator
-		log.Print(err.Error())
+		slog.Warn("unable to connect to Orchestrator", "error", err)
 		time.Sleep(500 * time.Millisecond)
-		conn, err = grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		conn, err = grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()), telemetry.DialOption())
 	}
 	defer conn.Close()
 
 	c := pb.NewOrchestratorClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
 
-	// Call to registration of the service
-	_, err = c.RegisterGarage(ctx, nil)
-	for err != nil {
+	// Call to registration of the service, every attempt with its own deadline
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), telemetry.Deadline("Orchestrator.RegisterGarage"))
+		_, err := c.RegisterGarage(ctx, nil)
+		cancel()
+		if err == nil {
+			break
+		}
+
 		// Wait if errors during registration
-		log.Print(err.Error())
+		slog.Warn("registration to Orchestrator failed", "error", err)
 		time.Sleep(500 * time.Millisecond)
-		_, err = c.RegisterGarage(ctx, nil)
 	}
-	log.Printf("Registered to Orchestrator")
+	slog.Info("Registered to Orchestrator")
+}
+
+func removeExpiredListings(db internal.GarageDB) {
+	for {
+		if err := db.RemoveExpiredListings(context.Background()); err != nil {
+			slog.Error("RemoveExpiredListings failed", "error", err)
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+func settleAuctions(db internal.GarageDB) {
+	for {
+		if err := db.SettleAuctions(context.Background()); err != nil {
+			slog.Error("SettleAuctions failed", "error", err)
+		}
+		time.Sleep(5 * time.Second)
+	}
 }
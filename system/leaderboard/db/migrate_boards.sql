@@ -0,0 +1,17 @@
+-- Migration of an existing Leaderboard database to track and motorcycle boards.
+-- Boards start empty and fill up with the races completed after the migration.
+USE Leaderboard;
+
+CREATE TABLE IF NOT EXISTS BoardUsers (
+  Board enum('track', 'motorcycle') NOT NULL,
+  Name varchar(32) NOT NULL, -- track or motorcycle model
+  Username varchar(32) NOT NULL,
+  Points int NOT NULL DEFAULT 0, -- all time, boards are not reset by seasons
+  Races int NOT NULL DEFAULT 0,
+  Wins int NOT NULL DEFAULT 0,
+  PRIMARY KEY (Board, Name, Username)
+) ENGINE=InnoDB;
+
+CREATE OR REPLACE VIEW RankedBoards AS
+SELECT Board, Name, Username, Points, RANK() OVER (PARTITION BY Board, Name ORDER BY Points DESC) AS Position, Races, Wins
+FROM BoardUsers;
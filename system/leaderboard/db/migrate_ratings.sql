@@ -0,0 +1,10 @@
+-- Migration of an existing Leaderboard database to Elo ratings.
+-- Apply after migrate_seasons.sql, every player starts from the initial rating.
+USE Leaderboard;
+
+ALTER TABLE Users
+  ADD COLUMN IF NOT EXISTS Rating int NOT NULL DEFAULT 1500;
+
+CREATE OR REPLACE VIEW RankedUsers AS
+SELECT Username, Points, RANK() OVER (ORDER BY Points DESC) AS Position, Rating
+FROM Users;
@@ -0,0 +1,28 @@
+-- Migration of an existing Leaderboard database to seasons.
+-- Points collected so far become the points of the first season.
+USE Leaderboard;
+
+CREATE TABLE IF NOT EXISTS Seasons (
+  Id int NOT NULL AUTO_INCREMENT,
+  Name varchar(32) NOT NULL,
+  StartsAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  EndsAt timestamp NOT NULL,
+  Archived boolean NOT NULL DEFAULT FALSE, -- final standings saved, only the current season is not archived
+  PRIMARY KEY (Id),
+  CHECK (EndsAt > StartsAt)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS Standings (
+  SeasonId int NOT NULL,
+  Username varchar(32) NOT NULL,
+  Points int NOT NULL,
+  Position int NOT NULL,
+  PRIMARY KEY (SeasonId, Username),
+  FOREIGN KEY (SeasonId) REFERENCES Seasons(Id)
+) ENGINE=InnoDB;
+
+INSERT INTO Seasons (Name, EndsAt) SELECT "Season 1", CURRENT_TIMESTAMP + INTERVAL 30 DAY FROM DUAL WHERE NOT EXISTS (SELECT * FROM Seasons);
+
+-- Standings archived before the paid flag got their reward already
+ALTER TABLE Standings ADD COLUMN IF NOT EXISTS Paid boolean NOT NULL DEFAULT TRUE;
+ALTER TABLE Standings ALTER COLUMN Paid SET DEFAULT FALSE;
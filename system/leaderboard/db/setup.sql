@@ -5,12 +5,51 @@ USE Leaderboard;
 DROP TABLE IF EXISTS Users;
 CREATE TABLE IF NOT EXISTS Users (
   Username varchar(32) NOT NULL,
-  Points int NOT NULL,
+  Points int NOT NULL, -- points of the current season
+  Rating int NOT NULL DEFAULT 1500, -- Elo rating, kept across seasons
   PRIMARY KEY (Username)
 ) ENGINE=InnoDB;
 
+DROP TABLE IF EXISTS Seasons;
+CREATE TABLE IF NOT EXISTS Seasons (
+  Id int NOT NULL AUTO_INCREMENT,
+  Name varchar(32) NOT NULL,
+  StartsAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  EndsAt timestamp NOT NULL,
+  Archived boolean NOT NULL DEFAULT FALSE, -- final standings saved, only the current season is not archived
+  PRIMARY KEY (Id),
+  CHECK (EndsAt > StartsAt)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Standings;
+CREATE TABLE IF NOT EXISTS Standings (
+  SeasonId int NOT NULL,
+  Username varchar(32) NOT NULL,
+  Points int NOT NULL,
+  Position int NOT NULL,
+  Paid boolean NOT NULL DEFAULT FALSE, -- season reward paid, or no reward for the position
+  PRIMARY KEY (SeasonId, Username),
+  FOREIGN KEY (SeasonId) REFERENCES Seasons(Id)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS BoardUsers;
+CREATE TABLE IF NOT EXISTS BoardUsers (
+  Board enum('track', 'motorcycle') NOT NULL,
+  Name varchar(32) NOT NULL, -- track or motorcycle model
+  Username varchar(32) NOT NULL,
+  Points int NOT NULL DEFAULT 0, -- all time, boards are not reset by seasons
+  Races int NOT NULL DEFAULT 0,
+  Wins int NOT NULL DEFAULT 0,
+  PRIMARY KEY (Board, Name, Username)
+) ENGINE=InnoDB;
+
 CREATE VIEW RankedUsers AS
-SELECT Username, Points, RANK() OVER (ORDER BY Points DESC) AS Position
+SELECT Username, Points, RANK() OVER (ORDER BY Points DESC) AS Position, Rating
 FROM Users;
 
-INSERT INTO Users VALUES ("Lorenzo", 10), ("Matteo", 20);
\ No newline at end of file
+CREATE VIEW RankedBoards AS
+SELECT Board, Name, Username, Points, RANK() OVER (PARTITION BY Board, Name ORDER BY Points DESC) AS Position, Races, Wins
+FROM BoardUsers;
+
+INSERT INTO Users (Username, Points) VALUES ("Lorenzo", 10), ("Matteo", 20);
+INSERT INTO Seasons (Name, EndsAt) VALUES ("Season 1", CURRENT_TIMESTAMP + INTERVAL 30 DAY);
\ No newline at end of file
@@ -5,12 +5,53 @@ USE Leaderboard;
 DROP TABLE IF EXISTS Users;
 CREATE TABLE IF NOT EXISTS Users (
   Username varchar(32) NOT NULL,
-  Points int NOT NULL,
+  Points int NOT NULL, -- points of the current season
+  Rating int NOT NULL DEFAULT 1500, -- Elo rating, kept across seasons
   PRIMARY KEY (Username)
 ) ENGINE=InnoDB;
 
+DROP TABLE IF EXISTS Seasons;
+CREATE TABLE IF NOT EXISTS Seasons (
+  Id int NOT NULL AUTO_INCREMENT,
+  Name varchar(32) NOT NULL,
+  StartsAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  EndsAt timestamp NOT NULL,
+  Archived boolean NOT NULL DEFAULT FALSE, -- final standings saved, only the current season is not archived
+  PRIMARY KEY (Id),
+  CHECK (EndsAt > StartsAt)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Standings;
+CREATE TABLE IF NOT EXISTS Standings (
+  SeasonId int NOT NULL,
+  Username varchar(32) NOT NULL,
+  Points int NOT NULL,
+  Position int NOT NULL,
+  Paid boolean NOT NULL DEFAULT FALSE, -- season reward paid, or no reward for the position
+  PRIMARY KEY (SeasonId, Username),
+  FOREIGN KEY (SeasonId) REFERENCES Seasons(Id)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS BoardUsers;
+CREATE TABLE IF NOT EXISTS BoardUsers (
+  Board enum('track', 'motorcycle') NOT NULL,
+  Name varchar(32) NOT NULL, -- track or motorcycle model
+  Username varchar(32) NOT NULL,
+  Points int NOT NULL DEFAULT 0, -- all time, boards are not reset by seasons
+  Races int NOT NULL DEFAULT 0,
+  Wins int NOT NULL DEFAULT 0,
+  PRIMARY KEY (Board, Name, Username)
+) ENGINE=InnoDB;
+
 CREATE VIEW RankedUsers AS
-SELECT Username, Points, RANK() OVER (ORDER BY Points DESC) AS Position
+SELECT Username, Points, RANK() OVER (ORDER BY Points DESC) AS Position, Rating
 FROM Users;
 
-INSERT INTO Users VALUES ("user", 10);
\ No newline at end of file
+CREATE VIEW RankedBoards AS
+SELECT Board, Name, Username, Points, RANK() OVER (PARTITION BY Board, Name ORDER BY Points DESC) AS Position, Races, Wins
+FROM BoardUsers;
+
+INSERT INTO Users VALUES ("user", 10, 1500), ("strong", 0, 1700), ("weak", 0, 1300);
+INSERT INTO Seasons VALUES (1, "Season 1", CURRENT_TIMESTAMP - INTERVAL 60 DAY, CURRENT_TIMESTAMP - INTERVAL 30 DAY, TRUE), (2, "Season 2", CURRENT_TIMESTAMP - INTERVAL 30 DAY, CURRENT_TIMESTAMP - INTERVAL 1 MINUTE, FALSE);
+INSERT INTO Standings VALUES (1, "veteran", 50, 1, TRUE), (1, "user", 5, 2, TRUE);
+INSERT INTO BoardUsers VALUES ("track", "Mugello", "user", 10, 2, 1), ("track", "Mugello", "strong", 15, 3, 1), ("track", "Franciacorta", "user", 5, 1, 0), ("motorcycle", "Ducati Panigale V4", "user", 15, 3, 1);
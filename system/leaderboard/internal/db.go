@@ -1,20 +1,73 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"leaderboard/telemetry"
 )
 
 type LeaderboardInfo struct {
 	username string
 	points   int32
 	position int32
+	rating   int32
+	races    int32 // track and motorcycle boards only
+	wins     int32 // track and motorcycle boards only
+}
+
+// Kinds of board ranking the riders of a single track or motorcycle model
+const (
+	TrackBoard      = "track"
+	MotorcycleBoard = "motorcycle"
+)
+
+// Largest number of users returned by a single leaderboard query
+const maxPageSize = 100
+
+type RaceFinisher struct {
+	username string
+	position int
+}
+
+type Season struct {
+	id        int32
+	name      string
+	starts_at time.Time
+	ends_at   time.Time
+}
+
+// Position in the final standings of a season whose reward is not paid yet
+type UnpaidStanding struct {
+	season_id int32
+	username  string
+	position  int32
 }
 
 type LeaderboardDB interface {
-	GetLeaderboard() []LeaderboardInfo
-	GetUserInfo(username string) (*LeaderboardInfo, error)
-	IncrementPoints(username string, points int) error
+	GetLeaderboard(ctx context.Context) ([]LeaderboardInfo, error)
+	GetLeaderboardPage(ctx context.Context, offset int, limit int) ([]LeaderboardInfo, int, error)
+	GetLeaderboardAround(ctx context.Context, username string, radius int) ([]LeaderboardInfo, int, error)
+	GetUserInfo(ctx context.Context, username string) (*LeaderboardInfo, error)
+	GetUsersInfo(ctx context.Context, usernames []string) ([]LeaderboardInfo, error)
+	IncrementPoints(ctx context.Context, username string, points int) error
+	GetCurrentSeason(ctx context.Context) (*Season, error)
+	GetPastSeasons(ctx context.Context) ([]Season, error)
+	GetSeasonStandings(ctx context.Context, SeasonId int) ([]LeaderboardInfo, error)
+	RolloverSeason(ctx context.Context, duration time.Duration) (*Season, []LeaderboardInfo, error)
+	GetUnpaidStandings(ctx context.Context) ([]UnpaidStanding, error)
+	ClaimStanding(ctx context.Context, SeasonId int, username string) (bool, error)
+	ReleaseStanding(ctx context.Context, SeasonId int, username string) error
+	UpdateRatings(ctx context.Context, finishers []RaceFinisher) error
+	AddRacePoints(ctx context.Context, username string, track string, motorcycle string, points int, position int) error
+	GetBoard(ctx context.Context, board string, name string, offset int, limit int) ([]LeaderboardInfo, int, error)
+	GetBoardNames(ctx context.Context, board string) ([]string, error)
 }
 
 // Implementation for an SQL Database
@@ -26,59 +79,444 @@ func NewSQL_DB(conn *sql.DB) *SQL_DB {
 	return &SQL_DB{db: conn}
 }
 
-func (s *SQL_DB) GetLeaderboard() []LeaderboardInfo {
+func (s *SQL_DB) GetLeaderboard(ctx context.Context) ([]LeaderboardInfo, error) {
 	// Retrieve full leaderboard
 
-	rows, err := s.db.Query("SELECT * FROM RankedUsers ORDER BY Position ASC")
+	return s.queryLeaderboard(ctx, "SELECT Username, Points, Position, Rating FROM RankedUsers ORDER BY Position ASC, Username ASC")
+}
+
+func (s *SQL_DB) queryLeaderboard(ctx context.Context, query string, args ...any) ([]LeaderboardInfo, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Println(err)
-		return nil
+		slog.ErrorContext(ctx, "queryLeaderboard failed", "error", err)
+		return nil, err
 	}
 	defer rows.Close()
 
-	var info []LeaderboardInfo
+	info := make([]LeaderboardInfo, 0)
 	for rows.Next() {
 		var row LeaderboardInfo
-		if err := rows.Scan(&row.username, &row.points, &row.position); err != nil {
-			log.Println(err)
-			return nil
+		if err := rows.Scan(&row.username, &row.points, &row.position, &row.rating); err != nil {
+			slog.ErrorContext(ctx, "queryLeaderboard failed", "error", err)
+			return nil, err
 		}
 		info = append(info, row)
 	}
 
-	if err := rows.Err(); err != nil {
-		log.Println(err)
-		return nil
+	return info, rows.Err()
+}
+
+func (s *SQL_DB) GetLeaderboardPage(ctx context.Context, offset int, limit int) ([]LeaderboardInfo, int, error) {
+	// Retrieve a page of the leaderboard together with the number of ranked users
+
+	if offset < 0 || limit <= 0 || limit > maxPageSize {
+		return nil, 0, errors.New("invalid leaderboard page")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM Users").Scan(&total); err != nil {
+		slog.ErrorContext(ctx, "GetLeaderboardPage failed", "error", err)
+		return nil, 0, err
 	}
 
-	return info
+	page, err := s.queryLeaderboard(ctx, "SELECT Username, Points, Position, Rating FROM RankedUsers ORDER BY Position ASC, Username ASC LIMIT ? OFFSET ?", limit, offset)
+	return page, total, err
 }
 
-func (s *SQL_DB) GetUserInfo(username string) (*LeaderboardInfo, error) {
-	stmt, err := s.db.Prepare("SELECT Username, Points, Position FROM RankedUsers WHERE Username=?")
+func (s *SQL_DB) GetLeaderboardAround(ctx context.Context, username string, radius int) ([]LeaderboardInfo, int, error) {
+	// Retrieve the users ranked up to radius rows above and below username, together with the number of ranked users
+
+	if radius < 0 || 2*radius+1 > maxPageSize {
+		return nil, 0, errors.New("invalid leaderboard radius")
+	}
+
+	var total, found int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*), COUNT(CASE WHEN Username=? THEN 1 END) FROM Users", username).Scan(&total, &found); err != nil {
+		slog.ErrorContext(ctx, "GetLeaderboardAround failed", "error", err)
+		return nil, 0, err
+	}
+	if found == 0 {
+		return nil, 0, errors.New("user not in leaderboard")
+	}
+
+	// Row of the user in the same order used by pages, ties in position are broken by username
+	var row int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM RankedUsers R INNER JOIN RankedUsers U ON U.Username=? WHERE R.Position < U.Position OR (R.Position = U.Position AND R.Username < U.Username)", username).Scan(&row)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetLeaderboardAround failed", "error", err)
+		return nil, 0, err
+	}
+
+	offset := max(row-radius, 0)
+	around, err := s.queryLeaderboard(ctx, "SELECT Username, Points, Position, Rating FROM RankedUsers ORDER BY Position ASC, Username ASC LIMIT ? OFFSET ?", row-offset+radius+1, offset)
+	return around, total, err
+}
+
+func (s *SQL_DB) GetUsersInfo(ctx context.Context, usernames []string) ([]LeaderboardInfo, error) {
+	// Retrieve the overall positions of a group of users, like the friends of a player
+
+	if len(usernames) == 0 {
+		return make([]LeaderboardInfo, 0), nil
+	}
+	if len(usernames) > maxPageSize {
+		return nil, errors.New("too many users")
+	}
+
+	args := make([]any, 0, len(usernames))
+	for _, u := range usernames {
+		args = append(args, u)
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(usernames)), ", ")
+
+	return s.queryLeaderboard(ctx, "SELECT Username, Points, Position, Rating FROM RankedUsers WHERE Username IN ("+placeholders+") ORDER BY Position ASC, Username ASC", args...)
+}
+
+func (s *SQL_DB) GetUserInfo(ctx context.Context, username string) (*LeaderboardInfo, error) {
+	stmt, err := s.db.PrepareContext(ctx, "SELECT Username, Points, Position, Rating FROM RankedUsers WHERE Username=?")
 
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "GetUserInfo failed", "error", err)
 		return nil, err
 	}
 
 	var info LeaderboardInfo
-	err = stmt.QueryRow(username).Scan(&info.username, &info.points, &info.position)
+	err = stmt.QueryRowContext(ctx, username).Scan(&info.username, &info.points, &info.position, &info.rating)
 
 	return &info, err
 }
 
-func (s *SQL_DB) IncrementPoints(username string, points int) error {
+func (s *SQL_DB) IncrementPoints(ctx context.Context, username string, points int) error {
 	// Increment user points, used also during registration setting points=0
 
-	stmt, err := s.db.Prepare("INSERT INTO Users VALUES (?, ?) ON DUPLICATE KEY UPDATE Points = Points + VALUES(Points)")
+	stmt, err := s.db.PrepareContext(ctx, "INSERT INTO Users (Username, Points) VALUES (?, ?) ON DUPLICATE KEY UPDATE Points = Points + VALUES(Points)")
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "IncrementPoints failed", "error", err)
 		return err
 	}
 	defer stmt.Close()
 
-	_, err = stmt.Exec(username, points)
+	_, err = stmt.ExecContext(ctx, username, points)
 
 	return err
 }
+
+func (s *SQL_DB) GetCurrentSeason(ctx context.Context) (*Season, error) {
+	var season Season
+	err := s.db.QueryRowContext(ctx, "SELECT Id, Name, StartsAt, EndsAt FROM Seasons WHERE NOT Archived ORDER BY Id DESC LIMIT 1").Scan(&season.id, &season.name, &season.starts_at, &season.ends_at)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("no current season")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "GetCurrentSeason failed", "error", err)
+		return nil, err
+	}
+
+	return &season, nil
+}
+
+func (s *SQL_DB) GetPastSeasons(ctx context.Context) ([]Season, error) {
+	// Retrieve archived seasons, most recent first
+
+	rows, err := s.db.QueryContext(ctx, "SELECT Id, Name, StartsAt, EndsAt FROM Seasons WHERE Archived ORDER BY Id DESC")
+	if err != nil {
+		slog.ErrorContext(ctx, "GetPastSeasons failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var seasons []Season
+	for rows.Next() {
+		var season Season
+		if err := rows.Scan(&season.id, &season.name, &season.starts_at, &season.ends_at); err != nil {
+			slog.ErrorContext(ctx, "GetPastSeasons failed", "error", err)
+			return nil, err
+		}
+		seasons = append(seasons, season)
+	}
+
+	return seasons, rows.Err()
+}
+
+func (s *SQL_DB) GetSeasonStandings(ctx context.Context, SeasonId int) ([]LeaderboardInfo, error) {
+	// Retrieve final standings of an archived season
+
+	rows, err := s.db.QueryContext(ctx, "SELECT Username, Points, Position FROM Standings WHERE SeasonId=? ORDER BY Position ASC, Username ASC", SeasonId)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetSeasonStandings failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var info []LeaderboardInfo
+	for rows.Next() {
+		var row LeaderboardInfo
+		if err := rows.Scan(&row.username, &row.points, &row.position); err != nil {
+			slog.ErrorContext(ctx, "GetSeasonStandings failed", "error", err)
+			return nil, err
+		}
+		info = append(info, row)
+	}
+
+	return info, rows.Err()
+}
+
+func (s *SQL_DB) RolloverSeason(ctx context.Context, duration time.Duration) (*Season, []LeaderboardInfo, error) {
+	// If the current season has ended archive its standings, reset points and start the next season.
+	// The season row is locked, so only one of concurrent callers gets the archived standings
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.RolloverSeason"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "RolloverSeason failed", "error", err)
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var season Season
+	var ended bool
+	err = tx.QueryRowContext(ctx, "SELECT Id, Name, StartsAt, EndsAt, EndsAt <= CURRENT_TIMESTAMP FROM Seasons WHERE NOT Archived ORDER BY Id DESC LIMIT 1 FOR UPDATE").Scan(&season.id, &season.name, &season.starts_at, &season.ends_at, &ended)
+	if err == sql.ErrNoRows {
+		return nil, nil, errors.New("no current season")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "RolloverSeason failed", "error", err)
+		return nil, nil, err
+	}
+
+	if !ended {
+		return nil, nil, nil
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO Standings (SeasonId, Username, Points, Position) SELECT ?, Username, Points, Position FROM RankedUsers", season.id)
+	if err != nil {
+		slog.ErrorContext(ctx, "RolloverSeason failed", "error", err)
+		return nil, nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Users SET Points=0")
+	if err != nil {
+		slog.ErrorContext(ctx, "RolloverSeason failed", "error", err)
+		return nil, nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE Seasons SET Archived=TRUE WHERE Id=?", season.id)
+	if err != nil {
+		slog.ErrorContext(ctx, "RolloverSeason failed", "error", err)
+		return nil, nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO Seasons (Name, StartsAt, EndsAt) VALUES (?, CURRENT_TIMESTAMP, CURRENT_TIMESTAMP + INTERVAL ? SECOND)", fmt.Sprintf("Season %d", season.id+1), int(duration.Seconds()))
+	if err != nil {
+		slog.ErrorContext(ctx, "RolloverSeason failed", "error", err)
+		return nil, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		slog.ErrorContext(ctx, "RolloverSeason failed", "error", err)
+		return nil, nil, err
+	}
+
+	standings, err := s.GetSeasonStandings(ctx, int(season.id))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &season, standings, nil
+}
+
+func (s *SQL_DB) GetUnpaidStandings(ctx context.Context) ([]UnpaidStanding, error) {
+	// Retrieve the positions of the archived seasons whose reward has not been marked as paid
+
+	rows, err := s.db.QueryContext(ctx, "SELECT SeasonId, Username, Position FROM Standings WHERE NOT Paid ORDER BY SeasonId ASC, Position ASC, Username ASC")
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUnpaidStandings failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var standings []UnpaidStanding
+	for rows.Next() {
+		var row UnpaidStanding
+		if err := rows.Scan(&row.season_id, &row.username, &row.position); err != nil {
+			slog.ErrorContext(ctx, "GetUnpaidStandings failed", "error", err)
+			return nil, err
+		}
+		standings = append(standings, row)
+	}
+
+	return standings, rows.Err()
+}
+
+func (s *SQL_DB) ClaimStanding(ctx context.Context, SeasonId int, username string) (bool, error) {
+	// Mark the season reward of a position as paid before paying it, only the caller changing the row pays it
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.ClaimStanding"))
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, "UPDATE Standings SET Paid=TRUE WHERE SeasonId=? AND Username=? AND NOT Paid", SeasonId, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimStanding failed", "error", err)
+		return false, err
+	}
+
+	claimed, err := res.RowsAffected()
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimStanding failed", "error", err)
+		return false, err
+	}
+
+	return claimed == 1, nil
+}
+
+func (s *SQL_DB) ReleaseStanding(ctx context.Context, SeasonId int, username string) error {
+	// Give back a claimed position whose reward could not be paid
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.ReleaseStanding"))
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, "UPDATE Standings SET Paid=FALSE WHERE SeasonId=? AND Username=?", SeasonId, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "ReleaseStanding failed", "error", err)
+	}
+
+	return err
+}
+
+func (s *SQL_DB) UpdateRatings(ctx context.Context, finishers []RaceFinisher) error {
+	// Update the rating of every rider of a race from the complete finishing order
+
+	if len(finishers) < 2 {
+		return errors.New("a race needs at least two riders")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.UpdateRatings"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "UpdateRatings failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	// Lock riders always in the same order, so concurrent races sharing riders can not deadlock
+	finishers = append([]RaceFinisher(nil), finishers...)
+	sort.Slice(finishers, func(i, j int) bool { return finishers[i].username < finishers[j].username })
+
+	ratings := make([]int, len(finishers))
+	positions := make([]int, len(finishers))
+	for i, f := range finishers {
+		// Riders unknown to the leaderboard start from the initial rating
+		_, err = tx.ExecContext(ctx, "INSERT IGNORE INTO Users (Username, Points, Rating) VALUES (?, 0, ?)", f.username, initialRating)
+		if err != nil {
+			slog.ErrorContext(ctx, "UpdateRatings failed", "error", err)
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, "SELECT Rating FROM Users WHERE Username=? FOR UPDATE", f.username).Scan(&ratings[i])
+		if err != nil {
+			slog.ErrorContext(ctx, "UpdateRatings failed", "error", err)
+			return err
+		}
+		positions[i] = f.position
+	}
+
+	for i, rating := range updateRatings(ratings, positions) {
+		_, err = tx.ExecContext(ctx, "UPDATE Users SET Rating=? WHERE Username=?", rating, finishers[i].username)
+		if err != nil {
+			slog.ErrorContext(ctx, "UpdateRatings failed", "error", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) AddRacePoints(ctx context.Context, username string, track string, motorcycle string, points int, position int) error {
+	// Add the points of a race to the board of its track and to the board of the motorcycle model
+
+	win := 0
+	if position == 1 {
+		win = 1
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.AddRacePoints"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "AddRacePoints failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, board := range [][2]string{{TrackBoard, track}, {MotorcycleBoard, motorcycle}} {
+		_, err = tx.ExecContext(ctx, "INSERT INTO BoardUsers (Board, Name, Username, Points, Races, Wins) VALUES (?, ?, ?, ?, 1, ?) ON DUPLICATE KEY UPDATE Points = Points + VALUES(Points), Races = Races + 1, Wins = Wins + VALUES(Wins)",
+			board[0], board[1], username, points, win)
+		if err != nil {
+			slog.ErrorContext(ctx, "AddRacePoints failed", "error", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) GetBoard(ctx context.Context, board string, name string, offset int, limit int) ([]LeaderboardInfo, int, error) {
+	// Retrieve a page of the board of a track or motorcycle model together with the number of ranked users
+
+	if board != TrackBoard && board != MotorcycleBoard {
+		return nil, 0, errors.New("unknown board")
+	}
+	if offset < 0 || limit <= 0 || limit > maxPageSize {
+		return nil, 0, errors.New("invalid leaderboard page")
+	}
+
+	var total int
+	if err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM BoardUsers WHERE Board=? AND Name=?", board, name).Scan(&total); err != nil {
+		slog.ErrorContext(ctx, "GetBoard failed", "error", err)
+		return nil, 0, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT Username, Points, Position, Races, Wins FROM RankedBoards WHERE Board=? AND Name=? ORDER BY Position ASC, Username ASC LIMIT ? OFFSET ?", board, name, limit, offset)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetBoard failed", "error", err)
+		return nil, 0, err
+	}
+	defer rows.Close()
+
+	info := make([]LeaderboardInfo, 0)
+	for rows.Next() {
+		var row LeaderboardInfo
+		if err := rows.Scan(&row.username, &row.points, &row.position, &row.races, &row.wins); err != nil {
+			slog.ErrorContext(ctx, "GetBoard failed", "error", err)
+			return nil, 0, err
+		}
+		info = append(info, row)
+	}
+
+	return info, total, rows.Err()
+}
+
+func (s *SQL_DB) GetBoardNames(ctx context.Context, board string) ([]string, error) {
+	// Retrieve the tracks or motorcycle models that have a board
+
+	rows, err := s.db.QueryContext(ctx, "SELECT DISTINCT Name FROM BoardUsers WHERE Board=? ORDER BY Name", board)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetBoardNames failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := make([]string, 0)
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			slog.ErrorContext(ctx, "GetBoardNames failed", "error", err)
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
@@ -1,15 +1,17 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
 
 	_ "github.com/go-sql-driver/mysql"
 
 	"testing"
+	"time"
 )
 
 func NewSQLConnection(t *testing.T) *sql.DB {
-	db, err := sql.Open("mysql", "root:admin@tcp(test_leaderboard_db:3306)/Leaderboard")
+	db, err := sql.Open("mysql", "root:admin@tcp(test_leaderboard_db:3306)/Leaderboard?parseTime=true")
 	if err != nil {
 		t.Errorf("failed to connect to db: %s", err)
 	}
@@ -25,22 +27,268 @@ func TestDBIncrementPoints(t *testing.T) {
 	defer conn.Close()
 
 	db := NewSQL_DB(conn)
-	info, err := db.GetUserInfo("user")
+	info, err := db.GetUserInfo(context.Background(), "user")
 
 	if err != nil || info.points != 10 {
 		t.Errorf("Unable to get correct leaderboard info")
 	}
 
-	err = db.IncrementPoints("user", 10)
+	err = db.IncrementPoints(context.Background(), "user", 10)
 
 	if err != nil {
 		t.Errorf("Unable to increment user points")
 	}
 
-	info, err = db.GetUserInfo("user")
+	info, err = db.GetUserInfo(context.Background(), "user")
 
 	if err != nil || info.points != 20 {
 		t.Errorf("Unable to get correct leaderboard info")
 	}
 
 }
+
+func TestDBGetUsersInfo(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// Positions are the overall ones, unranked users are skipped
+	info, err := db.GetUsersInfo(context.Background(), []string{"weak", "user", "nobody"})
+	if err != nil || len(info) != 2 || info[0].username != "user" || info[1].username != "weak" || info[1].position != 2 {
+		t.Errorf("Unable to get users info")
+	}
+
+	info, err = db.GetUsersInfo(context.Background(), nil)
+	if err != nil || len(info) != 0 {
+		t.Errorf("Users info of nobody should be empty")
+	}
+}
+
+func TestDBGetLeaderboardPage(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	page, total, err := db.GetLeaderboardPage(context.Background(), 1, 1)
+	if err != nil || total != 3 || len(page) != 1 || page[0].username != "strong" || page[0].position != 2 {
+		t.Errorf("Unable to get leaderboard page")
+	}
+
+	page, total, err = db.GetLeaderboardPage(context.Background(), 10, 5)
+	if err != nil || total != 3 || len(page) != 0 {
+		t.Errorf("Page past the end should be empty")
+	}
+
+	if _, _, err = db.GetLeaderboardPage(context.Background(), 0, 0); err == nil {
+		t.Errorf("Empty page size accepted")
+	}
+}
+
+func TestDBGetLeaderboardAround(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// Last user, so only the rows above are returned
+	around, total, err := db.GetLeaderboardAround(context.Background(), "weak", 1)
+	if err != nil || total != 3 || len(around) != 2 || around[0].username != "strong" || around[1].username != "weak" {
+		t.Errorf("Unable to get leaderboard around user")
+	}
+
+	around, _, err = db.GetLeaderboardAround(context.Background(), "user", 0)
+	if err != nil || len(around) != 1 || around[0].username != "user" {
+		t.Errorf("Unable to get leaderboard around user")
+	}
+
+	if _, _, err = db.GetLeaderboardAround(context.Background(), "nobody", 1); err == nil {
+		t.Errorf("Leaderboard around unknown user")
+	}
+}
+
+func TestDBGetSeasons(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	past, err := db.GetPastSeasons(context.Background())
+	if err != nil || len(past) != 1 || past[0].name != "Season 1" {
+		t.Errorf("Unable to get past seasons")
+	}
+
+	standings, err := db.GetSeasonStandings(context.Background(), 1)
+	if err != nil || len(standings) != 2 || standings[0].username != "veteran" || standings[0].position != 1 {
+		t.Errorf("Unable to get standings of past season")
+	}
+}
+
+func TestDBRolloverSeason(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	current, err := db.GetCurrentSeason(context.Background())
+	if err != nil || current.name != "Season 2" {
+		t.Errorf("Unable to get current season")
+		return
+	}
+	info, err := db.GetUserInfo(context.Background(), "user")
+	if err != nil {
+		t.Errorf("Unable to get correct leaderboard info")
+		return
+	}
+
+	// Season 2 is over: its standings are archived and points start again from zero
+	ended, standings, err := db.RolloverSeason(context.Background(), 30*24*time.Hour)
+	if err != nil || ended == nil || ended.id != current.id {
+		t.Errorf("Season not rolled over: %v", err)
+		return
+	}
+	if len(standings) != 3 || standings[0].username != "user" || standings[0].points != info.points {
+		t.Errorf("Wrong archived standings")
+	}
+
+	info, err = db.GetUserInfo(context.Background(), "user")
+	if err != nil || info.points != 0 {
+		t.Errorf("Points not reset at rollover")
+	}
+
+	next, err := db.GetCurrentSeason(context.Background())
+	if err != nil || next.name != "Season 3" || !next.ends_at.After(time.Now()) {
+		t.Errorf("Next season not started")
+	}
+
+	// The new season has not ended yet
+	ended, standings, err = db.RolloverSeason(context.Background(), 30*24*time.Hour)
+	if err != nil || ended != nil || standings != nil {
+		t.Errorf("Season rolled over twice")
+	}
+}
+
+func TestDBUnpaidStandings(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// Standings archived by the rollover wait for their reward
+	unpaid, err := db.GetUnpaidStandings(context.Background())
+	if err != nil || len(unpaid) != 3 || unpaid[0].season_id != 2 || unpaid[0].position != 1 {
+		t.Errorf("Wrong unpaid standings")
+		return
+	}
+
+	claimed, err := db.ClaimStanding(context.Background(), int(unpaid[0].season_id), unpaid[0].username)
+	if err != nil || !claimed {
+		t.Errorf("Unable to claim standing: %s", err)
+		return
+	}
+
+	// Another replica can not pay it again
+	claimed, err = db.ClaimStanding(context.Background(), int(unpaid[0].season_id), unpaid[0].username)
+	if err != nil || claimed {
+		t.Errorf("Standing claimed twice")
+	}
+
+	remaining, err := db.GetUnpaidStandings(context.Background())
+	if err != nil || len(remaining) != 2 {
+		t.Errorf("Standing still unpaid after being claimed")
+	}
+
+	// A released standing is paid again
+	if err := db.ReleaseStanding(context.Background(), int(unpaid[0].season_id), unpaid[0].username); err != nil {
+		t.Errorf("Unable to release standing: %s", err)
+	}
+
+	remaining, err = db.GetUnpaidStandings(context.Background())
+	if err != nil || len(remaining) != 3 {
+		t.Errorf("Standing not unpaid after being released")
+	}
+}
+
+func TestDBUpdateRatings(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// The underdog wins against a much stronger rider
+	err := db.UpdateRatings(context.Background(), []RaceFinisher{{username: "strong", position: 2}, {username: "weak", position: 1}})
+	if err != nil {
+		t.Errorf("Unable to update ratings: %s", err)
+		return
+	}
+
+	weak, err := db.GetUserInfo(context.Background(), "weak")
+	if err != nil || weak.rating != 1329 {
+		t.Errorf("Wrong rating of the winner: %d", weak.rating)
+	}
+	strong, err := db.GetUserInfo(context.Background(), "strong")
+	if err != nil || strong.rating != 1671 {
+		t.Errorf("Wrong rating of the loser: %d", strong.rating)
+	}
+
+	// Unknown riders start from the initial rating
+	err = db.UpdateRatings(context.Background(), []RaceFinisher{{username: "rookie", position: 1}, {username: "user", position: 1}})
+	if err != nil {
+		t.Errorf("Unable to update ratings: %s", err)
+		return
+	}
+	rookie, err := db.GetUserInfo(context.Background(), "rookie")
+	if err != nil || rookie.rating != initialRating {
+		t.Errorf("Wrong rating of a new rider after a draw: %d", rookie.rating)
+	}
+
+	if err = db.UpdateRatings(context.Background(), []RaceFinisher{{username: "user", position: 1}}); err == nil {
+		t.Errorf("Ratings updated from a race with one rider")
+	}
+}
+
+func TestDBGetBoard(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	board, total, err := db.GetBoard(context.Background(), TrackBoard, "Mugello", 0, 10)
+	if err != nil || total != 2 || len(board) != 2 || board[0].username != "strong" || board[1].username != "user" || board[1].races != 2 {
+		t.Errorf("Unable to get Mugello board")
+	}
+
+	names, err := db.GetBoardNames(context.Background(), TrackBoard)
+	if err != nil || len(names) != 2 || names[0] != "Franciacorta" {
+		t.Errorf("Unable to get track boards")
+	}
+
+	if _, _, err = db.GetBoard(context.Background(), "rider", "Mugello", 0, 10); err == nil {
+		t.Errorf("Unknown board accepted")
+	}
+}
+
+func TestDBAddRacePoints(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// A win moves user ahead of strong on Mugello and opens the KTM board
+	err := db.AddRacePoints(context.Background(), "user", "Mugello", "KTM SuperDuke 1290 RR", 10, 1)
+	if err != nil {
+		t.Errorf("Unable to add race points: %s", err)
+		return
+	}
+
+	board, _, err := db.GetBoard(context.Background(), TrackBoard, "Mugello", 0, 10)
+	if err != nil || len(board) != 2 || board[0].username != "user" || board[0].points != 20 || board[0].races != 3 || board[0].wins != 2 {
+		t.Errorf("Race points not added to the track board")
+	}
+
+	board, total, err := db.GetBoard(context.Background(), MotorcycleBoard, "KTM SuperDuke 1290 RR", 0, 10)
+	if err != nil || total != 1 || board[0].username != "user" || board[0].position != 1 {
+		t.Errorf("Race points not added to the motorcycle board")
+	}
+}
@@ -0,0 +1,41 @@
+package internal
+
+import "math"
+
+// Elo rating given to new players
+const initialRating = 1500
+
+// Maximum rating change of a race, shared among the opponents
+const ratingK = 32
+
+// Multiplayer Elo: every race is scored as a set of head-to-head duels between each pair of riders,
+// the better finishing position wins the duel and equal positions draw
+func updateRatings(ratings []int, positions []int) []int {
+	n := len(ratings)
+	updated := make([]int, n)
+	copy(updated, ratings)
+	if n < 2 {
+		return updated
+	}
+
+	for i := 0; i < n; i++ {
+		delta := 0.0
+		for j := 0; j < n; j++ {
+			if i == j {
+				continue
+			}
+
+			expected := 1 / (1 + math.Pow(10, float64(ratings[j]-ratings[i])/400))
+			score := 0.5
+			if positions[i] < positions[j] {
+				score = 1
+			} else if positions[i] > positions[j] {
+				score = 0
+			}
+			delta += score - expected
+		}
+		updated[i] = ratings[i] + int(math.Round(ratingK*delta/float64(n-1)))
+	}
+
+	return updated
+}
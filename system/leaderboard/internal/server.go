@@ -3,11 +3,13 @@ package internal
 import (
 	"context"
 	"errors"
-	"log"
+	"log/slog"
+	"time"
 
 	pb "leaderboard/proto"
 
 	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type Server struct {
@@ -21,42 +23,234 @@ func NewServer(conn LeaderboardDB) *Server {
 }
 
 func (s *Server) GetFullLeaderboard(_ *emptypb.Empty, stream pb.Leaderboard_GetFullLeaderboardServer) error {
-	leaderboard := s.db.GetLeaderboard()
+	leaderboard, err := s.db.GetLeaderboard(stream.Context())
 
-	if len(leaderboard) == 0 || leaderboard == nil {
-		return errors.New("no user in leaderboard")
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetFullLeaderboard failed", "error", err)
+		return err
 	}
 
-	log.Printf("Retrieving leaderboard")
+	slog.DebugContext(stream.Context(), "Retrieving leaderboard")
 
 	for i := 0; i < len(leaderboard); i++ {
 		stream.Send(&pb.LeaderboardPosition{
 			Username: leaderboard[i].username,
 			Position: leaderboard[i].position,
 			Points:   leaderboard[i].points,
+			Rating:   leaderboard[i].rating,
 		})
 	}
 
 	return nil
 }
 
+func leaderboardToPb(leaderboard []LeaderboardInfo) []*pb.LeaderboardPosition {
+	positions := make([]*pb.LeaderboardPosition, 0, len(leaderboard))
+	for _, v := range leaderboard {
+		positions = append(positions, &pb.LeaderboardPosition{Username: v.username, Position: v.position, Points: v.points, Rating: v.rating, Races: v.races, Wins: v.wins})
+	}
+	return positions
+}
+
+func (s *Server) GetLeaderboardPage(ctx context.Context, in *pb.PageRequest) (*pb.LeaderboardPage, error) {
+	page, total, err := s.db.GetLeaderboardPage(ctx, int(in.Offset), int(in.Limit))
+	if err != nil {
+		slog.ErrorContext(ctx, "GetLeaderboardPage failed", "error", err)
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "Retrieving leaderboard page", "offset", in.Offset, "limit", in.Limit)
+
+	return &pb.LeaderboardPage{Positions: leaderboardToPb(page), Total: int32(total)}, nil
+}
+
+func (s *Server) GetLeaderboardAround(ctx context.Context, in *pb.AroundRequest) (*pb.LeaderboardPage, error) {
+	around, total, err := s.db.GetLeaderboardAround(ctx, in.Username, int(in.Radius))
+	if err != nil {
+		slog.ErrorContext(ctx, "GetLeaderboardAround failed", "error", err)
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "Retrieving leaderboard around", "username", in.Username, "radius", in.Radius)
+
+	return &pb.LeaderboardPage{Positions: leaderboardToPb(around), Total: int32(total)}, nil
+}
+
+func (s *Server) GetPlayers(ctx context.Context, in *pb.PlayerList) (*pb.LeaderboardPage, error) {
+	users, err := s.db.GetUsersInfo(ctx, in.Usernames)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetPlayers failed", "error", err)
+		return nil, err
+	}
+
+	return &pb.LeaderboardPage{Positions: leaderboardToPb(users), Total: int32(len(users))}, nil
+}
+
 func (s *Server) GetPlayer(ctx context.Context, in *pb.PlayerUsername) (*pb.LeaderboardPosition, error) {
-	user, err := s.db.GetUserInfo(in.Username)
+	user, err := s.db.GetUserInfo(ctx, in.Username)
 
 	if err != nil || user == nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "GetPlayer failed", "error", err)
 		return nil, err
 	}
 
-	log.Printf("Retrieving position (%s)", in.Username)
+	slog.DebugContext(ctx, "Retrieving position", "username", in.Username)
 
-	return &pb.LeaderboardPosition{Username: user.username, Position: user.position, Points: user.points}, nil
+	return &pb.LeaderboardPosition{Username: user.username, Position: user.position, Points: user.points, Rating: user.rating}, nil
 }
 
 func (s *Server) AddPoints(ctx context.Context, in *pb.PointIncrement) (*emptypb.Empty, error) {
-	log.Printf("Adding points (%s) with value %d", in.Username, in.Points)
+	slog.InfoContext(ctx, "Adding points", "username", in.Username, "points", in.Points)
+
+	return nil, s.db.IncrementPoints(ctx, in.Username, int(in.Points))
+}
+
+func seasonToPb(season *Season) *pb.SeasonInfo {
+	return &pb.SeasonInfo{
+		Id:       season.id,
+		Name:     season.name,
+		StartsAt: timestamppb.New(season.starts_at),
+		EndsAt:   timestamppb.New(season.ends_at),
+	}
+}
+
+func (s *Server) GetCurrentSeason(ctx context.Context, _ *emptypb.Empty) (*pb.SeasonInfo, error) {
+	season, err := s.db.GetCurrentSeason(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetCurrentSeason failed", "error", err)
+		return nil, err
+	}
+
+	return seasonToPb(season), nil
+}
+
+func (s *Server) GetPastSeasons(_ *emptypb.Empty, stream pb.Leaderboard_GetPastSeasonsServer) error {
+	seasons, err := s.db.GetPastSeasons(stream.Context())
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetPastSeasons failed", "error", err)
+		return err
+	}
+
+	for i := range seasons {
+		stream.Send(seasonToPb(&seasons[i]))
+	}
+
+	return nil
+}
+
+func (s *Server) GetSeasonStandings(in *pb.SeasonReference, stream pb.Leaderboard_GetSeasonStandingsServer) error {
+	standings, err := s.db.GetSeasonStandings(stream.Context(), int(in.SeasonId))
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetSeasonStandings failed", "error", err)
+		return err
+	}
+
+	slog.DebugContext(stream.Context(), "Retrieving season standings", "season_id", in.SeasonId)
+
+	for _, v := range standings {
+		stream.Send(&pb.LeaderboardPosition{Username: v.username, Position: v.position, Points: v.points})
+	}
+
+	return nil
+}
+
+func (s *Server) RolloverSeason(in *pb.SeasonDuration, stream pb.Leaderboard_RolloverSeasonServer) error {
+	if in.Days <= 0 {
+		return errors.New("season duration must be positive")
+	}
+
+	season, standings, err := s.db.RolloverSeason(stream.Context(), time.Duration(in.Days)*24*time.Hour)
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "RolloverSeason failed", "error", err)
+		return err
+	}
+
+	if season == nil {
+		return nil
+	}
+	slog.InfoContext(stream.Context(), "Season ended", "season", season.name, "standings", len(standings))
+
+	for _, v := range standings {
+		stream.Send(&pb.LeaderboardPosition{Username: v.username, Position: v.position, Points: v.points})
+	}
+
+	return nil
+}
+
+func (s *Server) GetUnpaidStandings(_ *emptypb.Empty, stream pb.Leaderboard_GetUnpaidStandingsServer) error {
+	standings, err := s.db.GetUnpaidStandings(stream.Context())
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetUnpaidStandings failed", "error", err)
+		return err
+	}
+
+	for _, v := range standings {
+		stream.Send(&pb.SeasonStanding{SeasonId: v.season_id, Username: v.username, Position: v.position})
+	}
+
+	return nil
+}
+
+func (s *Server) ClaimStanding(ctx context.Context, in *pb.SeasonStanding) (*pb.StandingClaim, error) {
+	claimed, err := s.db.ClaimStanding(ctx, int(in.SeasonId), in.Username)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimStanding failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Claiming season reward", "season_id", in.SeasonId, "username", in.Username, "claimed", claimed)
+
+	return &pb.StandingClaim{Claimed: claimed}, nil
+}
+
+func (s *Server) ReleaseStanding(ctx context.Context, in *pb.SeasonStanding) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Releasing season reward", "season_id", in.SeasonId, "username", in.Username)
+
+	return nil, s.db.ReleaseStanding(ctx, int(in.SeasonId), in.Username)
+}
+
+func (s *Server) UpdateRatings(ctx context.Context, in *pb.RaceStandings) (*emptypb.Empty, error) {
+	finishers := make([]RaceFinisher, 0, len(in.Finishers))
+	for _, f := range in.Finishers {
+		finishers = append(finishers, RaceFinisher{username: f.Username, position: int(f.Position)})
+	}
+
+	slog.InfoContext(ctx, "Updating ratings", "riders", len(finishers))
+
+	return nil, s.db.UpdateRatings(ctx, finishers)
+}
+
+var boardTypes = map[pb.BoardType]string{
+	pb.BoardType_TRACK:      TrackBoard,
+	pb.BoardType_MOTORCYCLE: MotorcycleBoard,
+}
+
+func (s *Server) AddRacePoints(ctx context.Context, in *pb.RacePoints) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Adding race points", "username", in.Username, "track", in.TrackName, "motorcycle", in.MotorcycleName, "points", in.Points)
+
+	return nil, s.db.AddRacePoints(ctx, in.Username, in.TrackName, in.MotorcycleName, int(in.Points), int(in.Position))
+}
+
+func (s *Server) GetBoard(ctx context.Context, in *pb.BoardRequest) (*pb.LeaderboardPage, error) {
+	board, total, err := s.db.GetBoard(ctx, boardTypes[in.Board], in.Name, int(in.Offset), int(in.Limit))
+	if err != nil {
+		slog.ErrorContext(ctx, "GetBoard failed", "error", err)
+		return nil, err
+	}
+
+	slog.DebugContext(ctx, "Retrieving board", "board", boardTypes[in.Board], "name", in.Name)
+
+	return &pb.LeaderboardPage{Positions: leaderboardToPb(board), Total: int32(total)}, nil
+}
+
+func (s *Server) GetBoardNames(ctx context.Context, in *pb.BoardReference) (*pb.BoardNames, error) {
+	names, err := s.db.GetBoardNames(ctx, boardTypes[in.Board])
+	if err != nil {
+		slog.ErrorContext(ctx, "GetBoardNames failed", "error", err)
+		return nil, err
+	}
 
-	return nil, s.db.IncrementPoints(in.Username, int(in.Points))
+	return &pb.BoardNames{Names: names}, nil
 }
 
 func (s *Server) StillAlive(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
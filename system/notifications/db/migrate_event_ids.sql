@@ -0,0 +1,5 @@
+-- Migration of an existing Notifications database to notifications caused by events of the bus.
+USE Notifications;
+
+ALTER TABLE Notifications ADD COLUMN IF NOT EXISTS EventId int AFTER CreatedAt;
+ALTER TABLE Notifications ADD UNIQUE IF NOT EXISTS (EventId);
@@ -0,0 +1,20 @@
+DROP DATABASE IF EXISTS Notifications;
+CREATE DATABASE IF NOT EXISTS Notifications;
+USE Notifications;
+
+DROP TABLE IF EXISTS Notifications;
+CREATE TABLE IF NOT EXISTS Notifications (
+  Id int NOT NULL AUTO_INCREMENT,
+  Username varchar(32) NOT NULL,
+  Kind varchar(16) NOT NULL,
+  Message varchar(256) NOT NULL,
+  Link varchar(128) NOT NULL DEFAULT '', -- page of the orchestrator, empty for none
+  IsRead boolean NOT NULL DEFAULT FALSE,
+  CreatedAt timestamp DEFAULT CURRENT_TIMESTAMP,
+  EventId int, -- event of the bus that caused it, delivered at least once
+  PRIMARY KEY (Id),
+  UNIQUE (EventId),
+  INDEX (Username, IsRead)
+) ENGINE=InnoDB;
+
+INSERT INTO Notifications (Username, Kind, Message, Link) VALUES ("Lorenzo", "season", "Welcome to the new season!", "/leaderboard");
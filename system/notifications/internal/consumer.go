@@ -0,0 +1,76 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"notifications/telemetry"
+
+	pb "notifications/proto"
+
+	"google.golang.org/grpc"
+)
+
+const subscriptionName = "notifications"
+
+func eventNotification(e *pb.DomainEvent) (kind string, message string, link string) {
+	switch e.Type {
+	case pb.DomainEventType_RACE_COMPLETED:
+		return "race", fmt.Sprintf("Race at %s finished in position %d of %d", e.TrackName, e.Position, e.TotalMotorcycles), "/private/history"
+	case pb.DomainEventType_MOTORCYCLE_BOUGHT:
+		return "garage", fmt.Sprintf("%s added to your garage for %d", e.MotorcycleName, e.Price), "/private/garage"
+	case pb.DomainEventType_PLAYER_REGISTERED:
+		return "account", fmt.Sprintf("Welcome %s, buy your first motorcycle and start racing!", e.Username), "/private/garage"
+	}
+	return "", "", ""
+}
+
+func (s *Server) ConsumeEvents(events *grpc.ClientConn) {
+	// Turn the events of the bus into notifications, forever.
+	// An event is acknowledged once stored, so events are delivered again after any failure
+
+	c := pb.NewEventsClient(events)
+	for {
+		if err := s.consume(c); err != nil {
+			slog.Error("ConsumeEvents failed", "error", err)
+		}
+		time.Sleep(time.Second)
+	}
+}
+
+func (s *Server) consume(c pb.EventsClient) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	stream, err := c.Subscribe(ctx, &pb.EventSubscription{
+		Name:  subscriptionName,
+		Types: []pb.DomainEventType{pb.DomainEventType_RACE_COMPLETED, pb.DomainEventType_MOTORCYCLE_BOUGHT, pb.DomainEventType_PLAYER_REGISTERED},
+	})
+	if err != nil {
+		return err
+	}
+	slog.Info("Subscribed to the event bus")
+
+	for {
+		event, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		// Events without a notification are only acknowledged
+		if kind, message, link := eventNotification(event); kind != "" {
+			if err := s.db.PublishEvent(ctx, int(event.Id), event.Username, kind, message, link); err != nil {
+				return err
+			}
+		}
+
+		ack_ctx, ack_cancel := context.WithTimeout(ctx, telemetry.Deadline("Events.AckEvent"))
+		_, err = c.AckEvent(ack_ctx, &pb.EventAck{Name: subscriptionName, EventId: event.Id})
+		ack_cancel()
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"time"
+	"unicode/utf8"
+
+	"notifications/telemetry"
+)
+
+const (
+	inboxLimit       = 50  // notifications shown in the inbox
+	maxMessageLength = 256 // characters, as the column of the messages
+)
+
+type Notification struct {
+	Id        int
+	Kind      string
+	Message   string
+	Link      string
+	Read      bool
+	CreatedAt time.Time
+}
+
+type NotificationsDB interface {
+	Publish(ctx context.Context, usernames []string, kind string, message string, link string) error
+	PublishEvent(ctx context.Context, event_id int, username string, kind string, message string, link string) error
+	GetNotifications(ctx context.Context, username string) ([]*Notification, error)
+	GetNotificationsAfter(ctx context.Context, username string, last_id int) ([]*Notification, error)
+	GetLastNotificationId(ctx context.Context, username string) (int, error)
+	GetUnreadCount(ctx context.Context, username string) (int, error)
+	MarkRead(ctx context.Context, username string, notification_id int) error
+	MarkAllRead(ctx context.Context, username string) error
+}
+
+// Implementation for an SQL Database
+type SQL_DB struct {
+	db *sql.DB
+}
+
+func NewSQL_DB(conn *sql.DB) *SQL_DB {
+	return &SQL_DB{db: conn}
+}
+
+func truncateMessage(message string) string {
+	// Cut a message longer than maxMessageLength on a character boundary, so multibyte characters are never split
+
+	if utf8.RuneCountInString(message) <= maxMessageLength {
+		return message
+	}
+
+	return string([]rune(message)[:maxMessageLength])
+}
+
+func (s *SQL_DB) Publish(ctx context.Context, usernames []string, kind string, message string, link string) error {
+	// Store a copy of the notification in the inbox of every recipient, all or none
+
+	if kind == "" || message == "" {
+		return errors.New("empty notification")
+	}
+	message = truncateMessage(message)
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Notifications.Publish"))
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "Publish failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, username := range usernames {
+		_, err = tx.ExecContext(ctx, "INSERT INTO Notifications (Username, Kind, Message, Link) VALUES (?, ?, ?, ?)", username, kind, message, link)
+		if err != nil {
+			slog.ErrorContext(ctx, "Publish failed", "error", err)
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQL_DB) PublishEvent(ctx context.Context, event_id int, username string, kind string, message string, link string) error {
+	// Store the notification caused by an event of the bus, an event delivered again is ignored
+
+	message = truncateMessage(message)
+
+	_, err := s.db.ExecContext(ctx, "INSERT IGNORE INTO Notifications (Username, Kind, Message, Link, EventId) VALUES (?, ?, ?, ?, ?)", username, kind, message, link, event_id)
+	if err != nil {
+		slog.ErrorContext(ctx, "PublishEvent failed", "error", err)
+	}
+	return err
+}
+
+func (s *SQL_DB) queryNotifications(ctx context.Context, query string, args ...any) ([]*Notification, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "queryNotifications failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var notifications []*Notification
+	for rows.Next() {
+		var n Notification
+		if err := rows.Scan(&n.Id, &n.Kind, &n.Message, &n.Link, &n.Read, &n.CreatedAt); err != nil {
+			slog.ErrorContext(ctx, "queryNotifications failed", "error", err)
+			return nil, err
+		}
+		notifications = append(notifications, &n)
+	}
+
+	return notifications, rows.Err()
+}
+
+func (s *SQL_DB) GetNotifications(ctx context.Context, username string) ([]*Notification, error) {
+	// Latest notifications of the inbox, newest first
+	return s.queryNotifications(ctx, "SELECT Id, Kind, Message, Link, IsRead, CreatedAt FROM Notifications WHERE Username=? ORDER BY Id DESC LIMIT ?", username, inboxLimit)
+}
+
+func (s *SQL_DB) GetNotificationsAfter(ctx context.Context, username string, last_id int) ([]*Notification, error) {
+	// Notifications published after the given one, oldest first, used to push new notifications
+	return s.queryNotifications(ctx, "SELECT Id, Kind, Message, Link, IsRead, CreatedAt FROM Notifications WHERE Username=? AND Id>? ORDER BY Id", username, last_id)
+}
+
+func (s *SQL_DB) GetLastNotificationId(ctx context.Context, username string) (int, error) {
+	var id int
+	err := s.db.QueryRowContext(ctx, "SELECT COALESCE(MAX(Id), 0) FROM Notifications WHERE Username=?", username).Scan(&id)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetLastNotificationId failed", "error", err)
+		return 0, err
+	}
+
+	return id, nil
+}
+
+func (s *SQL_DB) GetUnreadCount(ctx context.Context, username string) (int, error) {
+	var count int
+	err := s.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM Notifications WHERE Username=? AND NOT IsRead", username).Scan(&count)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUnreadCount failed", "error", err)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func (s *SQL_DB) MarkRead(ctx context.Context, username string, notification_id int) error {
+	// Only the owner of the inbox can read its notifications
+
+	var exists bool
+	err := s.db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM Notifications WHERE Id=? AND Username=?)", notification_id, username).Scan(&exists)
+	if err != nil {
+		slog.ErrorContext(ctx, "MarkRead failed", "error", err)
+		return err
+	}
+	if !exists {
+		return errors.New("notification not found")
+	}
+
+	_, err = s.db.ExecContext(ctx, "UPDATE Notifications SET IsRead=TRUE WHERE Id=? AND Username=?", notification_id, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "MarkRead failed", "error", err)
+	}
+	return err
+}
+
+func (s *SQL_DB) MarkAllRead(ctx context.Context, username string) error {
+	_, err := s.db.ExecContext(ctx, "UPDATE Notifications SET IsRead=TRUE WHERE Username=? AND NOT IsRead", username)
+	if err != nil {
+		slog.ErrorContext(ctx, "MarkAllRead failed", "error", err)
+	}
+	return err
+}
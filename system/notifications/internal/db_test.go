@@ -0,0 +1,133 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+
+	_ "github.com/go-sql-driver/mysql"
+
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func NewSQLConnection(t *testing.T) *sql.DB {
+	db, err := sql.Open("mysql", "root:admin@tcp(test_notifications_db:3306)/Notifications?parseTime=true")
+	if err != nil {
+		t.Errorf("failed to connect to db: %s", err)
+	}
+	if err := db.Ping(); err != nil {
+		t.Errorf("error pinging database: %v", err)
+	}
+
+	return db
+}
+
+func TestDBGetNotifications(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	notifications, err := db.GetNotifications(context.Background(), "user")
+	if err != nil || len(notifications) != 3 {
+		t.Errorf("Unable to get notifications")
+		return
+	}
+
+	// Newest first
+	if notifications[0].Id != 3 || notifications[2].Id != 1 || !notifications[2].Read || notifications[0].Read {
+		t.Errorf("Wrong notifications order or read state")
+	}
+
+	if count, err := db.GetUnreadCount(context.Background(), "user"); err != nil || count != 2 {
+		t.Errorf("Wrong unread count: %d", count)
+	}
+}
+
+func TestDBPublish(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	last, err := db.GetLastNotificationId(context.Background(), "reader")
+	if err != nil || last != 0 {
+		t.Errorf("Wrong last notification of an empty inbox")
+	}
+
+	if err := db.Publish(context.Background(), []string{"reader", "watcher"}, "clan", "You were invited to a clan", "/private/clans"); err != nil {
+		t.Errorf("Unable to publish: %v", err)
+	}
+	if err := db.Publish(context.Background(), []string{"reader"}, "clan", "", ""); err == nil {
+		t.Errorf("Empty notification published")
+	}
+
+	// Both recipients get their own copy
+	for _, username := range []string{"reader", "watcher"} {
+		notifications, err := db.GetNotificationsAfter(context.Background(), username, 0)
+		if err != nil || len(notifications) != 1 || notifications[0].Kind != "clan" || notifications[0].Link != "/private/clans" {
+			t.Errorf("Wrong notifications of %s", username)
+		}
+	}
+
+	notifications, err := db.GetNotificationsAfter(context.Background(), "reader", last)
+	if err != nil || len(notifications) != 1 {
+		t.Errorf("Wrong notifications after the last one")
+	}
+}
+
+func TestDBMarkRead(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// Notifications of another inbox cannot be read
+	if err := db.MarkRead(context.Background(), "other", 2); err == nil {
+		t.Errorf("Read the notification of another user")
+	}
+	if err := db.MarkRead(context.Background(), "user", 2); err != nil {
+		t.Errorf("Unable to mark as read: %v", err)
+	}
+	if count, _ := db.GetUnreadCount(context.Background(), "user"); count != 1 {
+		t.Errorf("Wrong unread count after reading one: %d", count)
+	}
+
+	if err := db.MarkAllRead(context.Background(), "other"); err != nil {
+		t.Errorf("Unable to mark all as read: %v", err)
+	}
+	if count, _ := db.GetUnreadCount(context.Background(), "other"); count != 0 {
+		t.Errorf("Wrong unread count after reading all: %d", count)
+	}
+}
+
+func TestDBPublishEvent(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// An event delivered twice gives a single notification
+	for i := 0; i < 2; i++ {
+		if err := db.PublishEvent(context.Background(), 10, "racer", "race", "Race at Mugello finished in position 1 of 3", "/private/history"); err != nil {
+			t.Errorf("Unable to publish event: %v", err)
+		}
+	}
+
+	if count, err := db.GetUnreadCount(context.Background(), "racer"); err != nil || count != 1 {
+		t.Errorf("Wrong notifications of a delivered event: %d", count)
+	}
+}
+
+func TestTruncateMessage(t *testing.T) {
+	if message := truncateMessage("Badge unlocked"); message != "Badge unlocked" {
+		t.Errorf("Short message truncated: %s", message)
+	}
+
+	// Multibyte characters are kept whole at the end of the message
+	message := truncateMessage(strings.Repeat("è", maxMessageLength+1))
+	if !utf8.ValidString(message) || utf8.RuneCountInString(message) != maxMessageLength {
+		t.Errorf("Wrong truncated message of %d characters", utf8.RuneCountInString(message))
+	}
+}
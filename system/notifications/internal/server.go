@@ -0,0 +1,111 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	pb "notifications/proto"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const watchInterval = time.Second
+
+type Server struct {
+	pb.UnimplementedNotificationsServer
+	pb.UnimplementedStillAliveServer
+	db NotificationsDB
+}
+
+func NewServer(conn NotificationsDB) *Server {
+	return &Server{db: conn}
+}
+
+func notificationToPb(n *Notification) *pb.Notification {
+	return &pb.Notification{
+		Id:        int32(n.Id),
+		Kind:      n.Kind,
+		Message:   n.Message,
+		Link:      n.Link,
+		Read:      n.Read,
+		CreatedAt: timestamppb.New(n.CreatedAt),
+	}
+}
+
+func (s *Server) PublishNotification(ctx context.Context, in *pb.NotificationRequest) (*emptypb.Empty, error) {
+	slog.InfoContext(ctx, "Publishing notification", "kind", in.Kind, "players", len(in.Usernames))
+
+	return nil, s.db.Publish(ctx, in.Usernames, in.Kind, in.Message, in.Link)
+}
+
+func (s *Server) GetNotifications(in *pb.PlayerUsername, stream pb.Notifications_GetNotificationsServer) error {
+	notifications, err := s.db.GetNotifications(stream.Context(), in.Username)
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetNotifications failed", "error", err)
+		return err
+	}
+
+	for _, v := range notifications {
+		stream.Send(notificationToPb(v))
+	}
+
+	return nil
+}
+
+func (s *Server) GetUnreadCount(ctx context.Context, in *pb.PlayerUsername) (*pb.UnreadCount, error) {
+	count, err := s.db.GetUnreadCount(ctx, in.Username)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUnreadCount failed", "error", err)
+		return nil, err
+	}
+
+	return &pb.UnreadCount{Count: int32(count)}, nil
+}
+
+func (s *Server) MarkNotificationRead(ctx context.Context, in *pb.NotificationReference) (*emptypb.Empty, error) {
+	return nil, s.db.MarkRead(ctx, in.Username, int(in.NotificationId))
+}
+
+func (s *Server) MarkAllNotificationsRead(ctx context.Context, in *pb.PlayerUsername) (*emptypb.Empty, error) {
+	return nil, s.db.MarkAllRead(ctx, in.Username)
+}
+
+func (s *Server) WatchNotifications(in *pb.PlayerUsername, stream pb.Notifications_WatchNotificationsServer) error {
+	// Stream notifications as they are published until the watcher leaves
+	// notifications are read from the database since they could be published through any replica
+
+	last, err := s.db.GetLastNotificationId(stream.Context(), in.Username)
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "WatchNotifications failed", "error", err)
+		return err
+	}
+
+	for {
+		notifications, err := s.db.GetNotificationsAfter(stream.Context(), in.Username, last)
+		if err != nil {
+			slog.ErrorContext(stream.Context(), "WatchNotifications failed", "error", err)
+			return err
+		}
+
+		for _, v := range notifications {
+			if err := stream.Send(notificationToPb(v)); err != nil {
+				slog.ErrorContext(stream.Context(), "WatchNotifications failed", "error", err)
+				return err
+			}
+			last = v.Id
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case <-time.After(watchInterval):
+		}
+	}
+}
+
+func (s *Server) StillAlive(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	return nil, nil
+}
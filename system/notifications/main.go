@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+
+	"github.com/go-sql-driver/mysql"
+
+	"fmt"
+	"log"
+	"log/slog"
+	"net"
+	"os"
+	"time"
+
+	"notifications/internal"
+	"notifications/telemetry"
+
+	pb "notifications/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func main() {
+	// Structured log of the service, level set by LOG_LEVEL
+	telemetry.InitLogging("notifications")
+
+	// Listener for Service
+	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", os.Getenv("SERVICE_PORT")))
+	if err != nil {
+		log.Fatalf("failed to listen: %v", err)
+	}
+	slog.Info("server listening", "address", lis.Addr().String())
+
+	// Metrics of the service for Prometheus
+	go telemetry.ServeMetrics(os.Getenv("METRICS_PORT"))
+
+	// Traces of the service, exported as set by TRACING_EXPORTER
+	shutdown := telemetry.InitTracing("notifications")
+	defer shutdown(context.Background())
+
+	// Database connection for Notifications DB
+	db, err := telemetry.OpenDB(mysql.MySQLDriver{}, "root:admin@tcp(notifications_db:3306)/Notifications?parseTime=true")
+	if err != nil {
+		log.Fatalf("failed to connect to db: %s", err)
+	}
+	defer db.Close()
+	if err := db.Ping(); err != nil {
+		log.Fatalf("error pinging database: %v", err)
+	}
+
+	// Creating gRPC server
+	s := grpc.NewServer(telemetry.ServerOption())
+	server := internal.NewServer(internal.NewSQL_DB(db))
+	pb.RegisterNotificationsServer(s, server)
+	pb.RegisterStillAliveServer(s, server)
+
+	// Call to parallel registration of the service to Orchestrator
+	go registerToOrchestrator()
+
+	// Notifications caused by the events of the bus
+	events, err := grpc.NewClient(fmt.Sprintf("events:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()), telemetry.DialOption())
+	if err != nil {
+		log.Fatalf("failed to connect to event bus: %v", err)
+	}
+	defer events.Close()
+	go server.ConsumeEvents(events)
+
+	if err := s.Serve(lis); err != nil {
+		log.Fatalf("failed to serve: %v", err)
+	}
+
+}
+
+func registerToOrchestrator() {
+	slog.Info("Trying to connect to Orchestrator")
+	conn, err := grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()), telemetry.DialOption())
+	for err != nil {
+		// Wait if unable to connect to orchestrator
+		slog.Warn("unable to connect to Orchestrator", "error", err)
+		time.Sleep(500 * time.Millisecond)
+		conn, err = grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()), telemetry.DialOption())
+	}
+	defer conn.Close()
+
+	c := pb.NewOrchestratorClient(conn)
+
+	// Call to registration of the service, every attempt with its own deadline
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), telemetry.Deadline("Orchestrator.RegisterNotifications"))
+		_, err := c.RegisterNotifications(ctx, nil)
+		cancel()
+		if err == nil {
+			break
+		}
+
+		// Wait if errors during registration
+		slog.Warn("registration to Orchestrator failed", "error", err)
+		time.Sleep(500 * time.Millisecond)
+	}
+	slog.Info("Registered to Orchestrator")
+}
@@ -1,6 +1,7 @@
 package internal
 
 import (
+	"context"
 	"orchestrator/internal/services"
 	"sync"
 
@@ -9,16 +10,28 @@ import (
 
 type LoadBalancer interface {
 	RegisterAuth(services.Auth)
-	GetAuth() services.Auth
+	GetAuth(ctx context.Context) services.Auth
 
 	RegisterLeaderboard(services.Leaderboard)
-	GetLeaderboard() services.Leaderboard
+	GetLeaderboard(ctx context.Context) services.Leaderboard
 
 	RegisterGarage(services.Garage)
-	GetGarage() services.Garage
+	GetGarage(ctx context.Context) services.Garage
 
 	RegisterRacing(services.Racing)
-	GetRacing() services.Racing
+	GetRacing(ctx context.Context) services.Racing
+
+	RegisterAchievements(services.Achievements)
+	GetAchievements(ctx context.Context) services.Achievements
+
+	RegisterClans(services.Clans)
+	GetClans(ctx context.Context) services.Clans
+
+	RegisterNotifications(services.Notifications)
+	GetNotifications(ctx context.Context) services.Notifications
+
+	RegisterEvents(services.Events)
+	GetEvents(ctx context.Context) services.Events
 }
 
 // Implementation of LoadBalancer with random selection
@@ -34,6 +47,18 @@ type RandomLoadBalancer struct {
 
 	mu_racing sync.Mutex
 	racing    []services.Racing
+
+	mu_achievements sync.Mutex
+	achievements    []services.Achievements
+
+	mu_clans sync.Mutex
+	clans    []services.Clans
+
+	mu_notifications sync.Mutex
+	notifications    []services.Notifications
+
+	mu_events sync.Mutex
+	events    []services.Events
 }
 
 func NewRandomLoadBalancer() *RandomLoadBalancer {
@@ -45,9 +70,10 @@ func (lb *RandomLoadBalancer) RegisterAuth(s services.Auth) {
 	defer lb.mu_auth.Unlock()
 
 	lb.auth = append(lb.auth, s)
+	replicasAvailable.WithLabelValues("auth").Set(float64(len(lb.auth)))
 }
 
-func (lb *RandomLoadBalancer) GetAuth() (s services.Auth) {
+func (lb *RandomLoadBalancer) GetAuth(ctx context.Context) (s services.Auth) {
 	lb.mu_auth.Lock()
 	defer lb.mu_auth.Unlock()
 
@@ -56,13 +82,15 @@ func (lb *RandomLoadBalancer) GetAuth() (s services.Auth) {
 		temp := lb.auth[index]
 
 		// test connection using StillAlive service
-		if temp.StillAlive() {
+		if temp.StillAlive(ctx) {
 			s = temp
 			break
 		} else {
 			// Selected replica not alive, removing and retrying
 			lb.auth = append(lb.auth[:index], lb.auth[index+1:]...)
 			temp.Close()
+			replicasAvailable.WithLabelValues("auth").Set(float64(len(lb.auth)))
+			replicasRemovedTotal.WithLabelValues("auth").Inc()
 		}
 	}
 
@@ -74,9 +102,10 @@ func (lb *RandomLoadBalancer) RegisterLeaderboard(s services.Leaderboard) {
 	defer lb.mu_leaderboard.Unlock()
 
 	lb.leaderboard = append(lb.leaderboard, s)
+	replicasAvailable.WithLabelValues("leaderboard").Set(float64(len(lb.leaderboard)))
 }
 
-func (lb *RandomLoadBalancer) GetLeaderboard() (s services.Leaderboard) {
+func (lb *RandomLoadBalancer) GetLeaderboard(ctx context.Context) (s services.Leaderboard) {
 	lb.mu_leaderboard.Lock()
 	defer lb.mu_leaderboard.Unlock()
 
@@ -85,13 +114,15 @@ func (lb *RandomLoadBalancer) GetLeaderboard() (s services.Leaderboard) {
 		temp := lb.leaderboard[index]
 
 		// test connection using StillAlive service
-		if temp.StillAlive() {
+		if temp.StillAlive(ctx) {
 			s = temp
 			break
 		} else {
 			// Selected replica not alive, removing and retrying
 			lb.leaderboard = append(lb.leaderboard[:index], lb.leaderboard[index+1:]...)
 			temp.Close()
+			replicasAvailable.WithLabelValues("leaderboard").Set(float64(len(lb.leaderboard)))
+			replicasRemovedTotal.WithLabelValues("leaderboard").Inc()
 		}
 	}
 
@@ -103,9 +134,10 @@ func (lb *RandomLoadBalancer) RegisterRacing(s services.Racing) {
 	defer lb.mu_racing.Unlock()
 
 	lb.racing = append(lb.racing, s)
+	replicasAvailable.WithLabelValues("racing").Set(float64(len(lb.racing)))
 }
 
-func (lb *RandomLoadBalancer) GetRacing() (s services.Racing) {
+func (lb *RandomLoadBalancer) GetRacing(ctx context.Context) (s services.Racing) {
 	lb.mu_racing.Lock()
 	defer lb.mu_racing.Unlock()
 
@@ -114,13 +146,15 @@ func (lb *RandomLoadBalancer) GetRacing() (s services.Racing) {
 		temp := lb.racing[index]
 
 		// test connection using StillAlive service
-		if temp.StillAlive() {
+		if temp.StillAlive(ctx) {
 			s = temp
 			break
 		} else {
 			// Selected replica not alive, removing and retrying
 			lb.racing = append(lb.racing[:index], lb.racing[index+1:]...)
 			temp.Close()
+			replicasAvailable.WithLabelValues("racing").Set(float64(len(lb.racing)))
+			replicasRemovedTotal.WithLabelValues("racing").Inc()
 		}
 	}
 
@@ -132,9 +166,10 @@ func (lb *RandomLoadBalancer) RegisterGarage(s services.Garage) {
 	defer lb.mu_garage.Unlock()
 
 	lb.garage = append(lb.garage, s)
+	replicasAvailable.WithLabelValues("garage").Set(float64(len(lb.garage)))
 }
 
-func (lb *RandomLoadBalancer) GetGarage() (s services.Garage) {
+func (lb *RandomLoadBalancer) GetGarage(ctx context.Context) (s services.Garage) {
 	lb.mu_garage.Lock()
 	defer lb.mu_garage.Unlock()
 
@@ -143,13 +178,143 @@ func (lb *RandomLoadBalancer) GetGarage() (s services.Garage) {
 		temp := lb.garage[index]
 
 		// test connection using StillAlive service
-		if temp.StillAlive() {
+		if temp.StillAlive(ctx) {
 			s = temp
 			break
 		} else {
 			// Selected replica not alive, removing and retrying
 			lb.garage = append(lb.garage[:index], lb.garage[index+1:]...)
 			temp.Close()
+			replicasAvailable.WithLabelValues("garage").Set(float64(len(lb.garage)))
+			replicasRemovedTotal.WithLabelValues("garage").Inc()
+		}
+	}
+
+	return s
+}
+
+func (lb *RandomLoadBalancer) RegisterAchievements(s services.Achievements) {
+	lb.mu_achievements.Lock()
+	defer lb.mu_achievements.Unlock()
+
+	lb.achievements = append(lb.achievements, s)
+	replicasAvailable.WithLabelValues("achievements").Set(float64(len(lb.achievements)))
+}
+
+func (lb *RandomLoadBalancer) GetAchievements(ctx context.Context) (s services.Achievements) {
+	lb.mu_achievements.Lock()
+	defer lb.mu_achievements.Unlock()
+
+	for i := len(lb.achievements); i > 0; i-- {
+		index := rand.IntN(len(lb.achievements))
+		temp := lb.achievements[index]
+
+		// test connection using StillAlive service
+		if temp.StillAlive(ctx) {
+			s = temp
+			break
+		} else {
+			// Selected replica not alive, removing and retrying
+			lb.achievements = append(lb.achievements[:index], lb.achievements[index+1:]...)
+			temp.Close()
+			replicasAvailable.WithLabelValues("achievements").Set(float64(len(lb.achievements)))
+			replicasRemovedTotal.WithLabelValues("achievements").Inc()
+		}
+	}
+
+	return s
+}
+
+func (lb *RandomLoadBalancer) RegisterClans(s services.Clans) {
+	lb.mu_clans.Lock()
+	defer lb.mu_clans.Unlock()
+
+	lb.clans = append(lb.clans, s)
+	replicasAvailable.WithLabelValues("clans").Set(float64(len(lb.clans)))
+}
+
+func (lb *RandomLoadBalancer) GetClans(ctx context.Context) (s services.Clans) {
+	lb.mu_clans.Lock()
+	defer lb.mu_clans.Unlock()
+
+	for i := len(lb.clans); i > 0; i-- {
+		index := rand.IntN(len(lb.clans))
+		temp := lb.clans[index]
+
+		// test connection using StillAlive service
+		if temp.StillAlive(ctx) {
+			s = temp
+			break
+		} else {
+			// Selected replica not alive, removing and retrying
+			lb.clans = append(lb.clans[:index], lb.clans[index+1:]...)
+			temp.Close()
+			replicasAvailable.WithLabelValues("clans").Set(float64(len(lb.clans)))
+			replicasRemovedTotal.WithLabelValues("clans").Inc()
+		}
+	}
+
+	return s
+}
+
+func (lb *RandomLoadBalancer) RegisterNotifications(s services.Notifications) {
+	lb.mu_notifications.Lock()
+	defer lb.mu_notifications.Unlock()
+
+	lb.notifications = append(lb.notifications, s)
+	replicasAvailable.WithLabelValues("notifications").Set(float64(len(lb.notifications)))
+}
+
+func (lb *RandomLoadBalancer) GetNotifications(ctx context.Context) (s services.Notifications) {
+	lb.mu_notifications.Lock()
+	defer lb.mu_notifications.Unlock()
+
+	for i := len(lb.notifications); i > 0; i-- {
+		index := rand.IntN(len(lb.notifications))
+		temp := lb.notifications[index]
+
+		// test connection using StillAlive service
+		if temp.StillAlive(ctx) {
+			s = temp
+			break
+		} else {
+			// Selected replica not alive, removing and retrying
+			lb.notifications = append(lb.notifications[:index], lb.notifications[index+1:]...)
+			temp.Close()
+			replicasAvailable.WithLabelValues("notifications").Set(float64(len(lb.notifications)))
+			replicasRemovedTotal.WithLabelValues("notifications").Inc()
+		}
+	}
+
+	return s
+}
+
+func (lb *RandomLoadBalancer) RegisterEvents(s services.Events) {
+	lb.mu_events.Lock()
+	defer lb.mu_events.Unlock()
+
+	lb.events = append(lb.events, s)
+	replicasAvailable.WithLabelValues("events").Set(float64(len(lb.events)))
+}
+
+func (lb *RandomLoadBalancer) GetEvents(ctx context.Context) (s services.Events) {
+	lb.mu_events.Lock()
+	defer lb.mu_events.Unlock()
+
+	for i := len(lb.events); i > 0; i-- {
+		index := rand.IntN(len(lb.events))
+		temp := lb.events[index]
+
+		// test connection using StillAlive service
+		if temp.StillAlive(ctx) {
+			s = temp
+			break
+		} else {
+			// Selected replica not alive, removing and retrying
+			lb.events = append(lb.events[:index], lb.events[index+1:]...)
+			temp.Close()
+			replicasAvailable.WithLabelValues("events").Set(float64(len(lb.events)))
+			replicasRemovedTotal.WithLabelValues("events").Inc()
 		}
 	}
 
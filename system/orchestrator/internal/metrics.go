@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics of the game and of the load balancer, the gRPC calls are measured by the telemetry package
+var (
+	registrationsTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orchestrator_registrations_total",
+		Help: "Players registered.",
+	})
+
+	loginsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orchestrator_logins_total",
+		Help: "Login attempts, by result: success, failure or error.",
+	}, []string{"result"})
+
+	racesCompletedTotal = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "orchestrator_races_completed_total",
+		Help: "Races whose results were notified by the Racing service, friendly ones included.",
+	})
+
+	moneyMintedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orchestrator_money_minted_total",
+		Help: "Money created and given to players, by source. Trades between players are not counted.",
+	}, []string{"source"})
+
+	replicasAvailable = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "orchestrator_replicas_available",
+		Help: "Replicas of a service known to the load balancer.",
+	}, []string{"service"})
+
+	replicasRemovedTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "orchestrator_replicas_removed_total",
+		Help: "Replicas of a service removed by the load balancer after failing the StillAlive check.",
+	}, []string{"service"})
+)
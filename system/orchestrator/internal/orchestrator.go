@@ -5,12 +5,14 @@ import (
 	"errors"
 	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	"orchestrator/internal/services"
 	pb "orchestrator/proto"
+	"orchestrator/telemetry"
 	"os"
 	"strconv"
 	"strings"
+	"time"
 
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
@@ -34,6 +36,188 @@ func (o *Orchestrator) computeAfterRace(position int, total int, first int, last
 	return int(m*(position-1) + first)
 }
 
+func (o *Orchestrator) updateRatings(ctx context.Context, finishers []*services.LeaderboardPosition) error {
+	// Utility function that sends the complete finishing order of a race to the Leaderboard service
+
+	if len(finishers) < 2 {
+		return nil
+	}
+
+	leaderboard := o.balancer.GetLeaderboard(ctx)
+	if leaderboard == nil {
+		slog.ErrorContext(ctx, "unable to connect to Leaderboard Service")
+		return errors.New("unable to connect to Leaderboard Service")
+	}
+
+	if err := leaderboard.UpdateRatings(ctx, finishers); err != nil {
+		slog.ErrorContext(ctx, "updateRatings failed", "error", err)
+		return err
+	}
+	slog.InfoContext(ctx, "Race ended: updated ratings", "riders", len(finishers))
+
+	return nil
+}
+
+func (o *Orchestrator) publishEvent(ctx context.Context, event *services.DomainEvent) {
+	// Utility function that publishes a domain event on the bus for the services that react to it
+
+	ctx = context.WithoutCancel(ctx)
+
+	events := o.balancer.GetEvents(ctx)
+	if events == nil {
+		slog.ErrorContext(ctx, "unable to connect to Events Service")
+		return
+	}
+
+	if err := events.Publish(ctx, event); err != nil {
+		slog.ErrorContext(ctx, "publishEvent failed", "error", err)
+	}
+}
+
+func (o *Orchestrator) notify(ctx context.Context, usernames []string, kind string, message string, link string) {
+	// Utility function that publishes a notification to the inbox of the players
+
+	ctx = context.WithoutCancel(ctx)
+
+	notifications := o.balancer.GetNotifications(ctx)
+	if notifications == nil {
+		slog.ErrorContext(ctx, "unable to connect to Notifications Service")
+		return
+	}
+
+	if err := notifications.Publish(ctx, usernames, kind, message, link); err != nil {
+		slog.ErrorContext(ctx, "notify failed", "error", err)
+	}
+}
+
+func (o *Orchestrator) recordClanResults(ctx context.Context, results []*services.ClanRaceResult) error {
+	// Utility function that adds the points of a race to the clans of the riders
+
+	if len(results) == 0 {
+		return nil
+	}
+
+	clans := o.balancer.GetClans(ctx)
+	if clans == nil {
+		slog.ErrorContext(ctx, "unable to connect to Clans Service")
+		return errors.New("unable to connect to Clans Service")
+	}
+
+	if err := clans.RecordRaceResults(ctx, results); err != nil {
+		slog.ErrorContext(ctx, "recordClanResults failed", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) recordAchievementEvent(ctx context.Context, username string, event *services.AchievementEvent) error {
+	// Utility function that reports an event to the Achievements service and pays the rewards of the unlocked badges.
+	// Only a failure to record the event is returned, unpaid rewards are paid again by PayPendingRewards
+
+	ctx = context.WithoutCancel(ctx)
+
+	achievements := o.balancer.GetAchievements(ctx)
+	if achievements == nil {
+		slog.ErrorContext(ctx, "unable to connect to Achievements Service")
+		return errors.New("unable to connect to Achievements Service")
+	}
+
+	unlocked, err := achievements.RecordEvent(ctx, username, event)
+	if err != nil {
+		slog.ErrorContext(ctx, "recordAchievementEvent failed", "error", err)
+		return err
+	}
+
+	for _, badge := range unlocked {
+		slog.InfoContext(ctx, "Badge unlocked", "badge", badge.Name, "username", username)
+		o.notify(ctx, []string{username}, "achievement", fmt.Sprintf("Badge %s unlocked", badge.Name), "/private")
+		if badge.Reward == 0 {
+			continue
+		}
+
+		if _, err := o.payBadgeReward(ctx, achievements, &services.PendingReward{Username: username, BadgeId: badge.Id, Name: badge.Name, Reward: badge.Reward}); err != nil {
+			slog.ErrorContext(ctx, "recordAchievementEvent failed", "error", err)
+		}
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) payOnce(ctx context.Context, garage services.Garage, key string, pay func() error) error {
+	// Utility function that claims the key of a reward before paying it and releases the claim if the payment fails,
+	// so results notified again pay only the rewards still missing. Without a key the reward is paid directly
+
+	if key == "" {
+		return pay()
+	}
+
+	claimed, err := garage.ClaimPayout(ctx, key)
+	if err != nil || !claimed {
+		return err
+	}
+
+	if err := pay(); err != nil {
+		if err := garage.ReleasePayout(ctx, key); err != nil {
+			slog.ErrorContext(ctx, "payOnce failed", "error", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) payBadgeReward(ctx context.Context, achievements services.Achievements, reward *services.PendingReward) (bool, error) {
+	// Utility function that claims the reward of a badge and then pays it, false if another caller already claimed it.
+	// A failed payment releases the claim, so the reward is paid again by PayPendingRewards
+
+	garage := o.balancer.GetGarage(ctx)
+	if garage == nil {
+		return false, errors.New("unable to connect to Garage Service")
+	}
+
+	claimed, err := achievements.ClaimReward(ctx, reward.Username, reward.BadgeId)
+	if err != nil || !claimed {
+		return false, err
+	}
+
+	if err := garage.IncreaseUserMoney(ctx, reward.Username, reward.Reward); err != nil {
+		if err := achievements.ReleaseReward(ctx, reward.Username, reward.BadgeId); err != nil {
+			slog.ErrorContext(ctx, "payBadgeReward failed", "error", err)
+		}
+		return false, err
+	}
+	moneyMintedTotal.WithLabelValues("achievement").Add(float64(reward.Reward))
+
+	return true, nil
+}
+
+func (o *Orchestrator) PayPendingRewards(ctx context.Context) error {
+	// Pays the rewards of the badges left unpaid by a failure of Garage or of the Achievements service
+
+	achievements := o.balancer.GetAchievements(ctx)
+	if achievements == nil {
+		return errors.New("unable to connect to Achievements Service")
+	}
+
+	rewards, err := achievements.GetPendingRewards(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, reward := range rewards {
+		paid, err := o.payBadgeReward(ctx, achievements, reward)
+		if err != nil {
+			return err
+		}
+		if paid {
+			slog.InfoContext(ctx, "Pending badge reward paid", "badge", reward.Name, "username", reward.Username, "money", reward.Reward)
+		}
+	}
+
+	return nil
+}
+
 func getGrpcClientFromContext(ctx context.Context) (*grpc.ClientConn, error) {
 	// Utility function to obtain connection from context
 
@@ -43,7 +227,7 @@ func getGrpcClientFromContext(ctx context.Context) (*grpc.ClientConn, error) {
 	}
 
 	address := strings.Split(peerInfo.Addr.String(), ":")[0]
-	return grpc.NewClient(fmt.Sprintf("%s:%s", address, os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	return grpc.NewClient(fmt.Sprintf("%s:%s", address, os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()), telemetry.DialOption())
 }
 
 ////////////////////// gRPC Server section
@@ -52,7 +236,7 @@ func (o *Orchestrator) RegisterAuth(ctx context.Context, _ *emptypb.Empty) (*emp
 	client, err := getGrpcClientFromContext(ctx)
 	if err != nil {
 		client.Close()
-		log.Println(err)
+		slog.ErrorContext(ctx, "RegisterAuth failed", "error", err)
 		return nil, err
 	}
 
@@ -65,7 +249,7 @@ func (o *Orchestrator) RegisterGarage(ctx context.Context, _ *emptypb.Empty) (*e
 	client, err := getGrpcClientFromContext(ctx)
 	if err != nil {
 		client.Close()
-		log.Println(err)
+		slog.ErrorContext(ctx, "RegisterGarage failed", "error", err)
 		return nil, err
 	}
 
@@ -78,7 +262,7 @@ func (o *Orchestrator) RegisterLeaderboard(ctx context.Context, _ *emptypb.Empty
 	client, err := getGrpcClientFromContext(ctx)
 	if err != nil {
 		client.Close()
-		log.Println(err)
+		slog.ErrorContext(ctx, "RegisterLeaderboard failed", "error", err)
 		return nil, err
 	}
 
@@ -91,7 +275,7 @@ func (o *Orchestrator) RegisterRacing(ctx context.Context, _ *emptypb.Empty) (*e
 	client, err := getGrpcClientFromContext(ctx)
 	if err != nil {
 		client.Close()
-		log.Println(err)
+		slog.ErrorContext(ctx, "RegisterRacing failed", "error", err)
 		return nil, err
 	}
 
@@ -100,18 +284,124 @@ func (o *Orchestrator) RegisterRacing(ctx context.Context, _ *emptypb.Empty) (*e
 	return nil, nil
 }
 
+func (o *Orchestrator) RegisterAchievements(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	client, err := getGrpcClientFromContext(ctx)
+	if err != nil {
+		client.Close()
+		slog.ErrorContext(ctx, "RegisterAchievements failed", "error", err)
+		return nil, err
+	}
+
+	o.balancer.RegisterAchievements(services.NewAchievementsService(client))
+
+	return nil, nil
+}
+
+func (o *Orchestrator) RegisterClans(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	client, err := getGrpcClientFromContext(ctx)
+	if err != nil {
+		client.Close()
+		slog.ErrorContext(ctx, "RegisterClans failed", "error", err)
+		return nil, err
+	}
+
+	o.balancer.RegisterClans(services.NewClansService(client))
+
+	return nil, nil
+}
+
+func (o *Orchestrator) RegisterNotifications(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	client, err := getGrpcClientFromContext(ctx)
+	if err != nil {
+		client.Close()
+		slog.ErrorContext(ctx, "RegisterNotifications failed", "error", err)
+		return nil, err
+	}
+
+	o.balancer.RegisterNotifications(services.NewNotificationsService(client))
+
+	return nil, nil
+}
+
+func (o *Orchestrator) RegisterEvents(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
+	client, err := getGrpcClientFromContext(ctx)
+	if err != nil {
+		client.Close()
+		slog.ErrorContext(ctx, "RegisterEvents failed", "error", err)
+		return nil, err
+	}
+
+	o.balancer.RegisterEvents(services.NewEventsService(client))
+
+	return nil, nil
+}
+
 func (o *Orchestrator) NotifyEndRace(stream pb.Orchestrator_NotifyEndRaceServer) error {
-	// Used by Racing service to notify the end of a race by streaming results
+	// Used by Racing service to notify the end of a race by streaming results.
+	// Results are final, so they are processed even if Racing stops waiting
+	ctx := context.WithoutCancel(stream.Context())
 
+	var results []*pb.RaceResult
 	for {
 		race_result, err := stream.Recv()
 		if err == io.EOF {
-			return stream.SendAndClose(nil)
+			break
 		}
 		if err != nil {
-			log.Println(err)
+			slog.ErrorContext(ctx, "NotifyEndRace failed", "error", err)
 			return err
 		}
+		results = append(results, race_result)
+	}
+
+	if len(results) == 0 {
+		return stream.SendAndClose(nil)
+	}
+
+	garage := o.balancer.GetGarage(ctx)
+	if garage == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	leaderboard := o.balancer.GetLeaderboard(ctx)
+	if leaderboard == nil {
+		return errors.New("unable to connect to Leaderboard Service")
+	}
+
+	// Championship rounds are notified again until received, each reward has its own key so a retry pays only the ones that failed.
+	// Other races are notified once and their failed rewards are only logged
+	id, round := results[0].ChampionshipId, results[0].Round
+	key := func(reward string) string {
+		if id == 0 {
+			return ""
+		}
+		return fmt.Sprintf("championship-%d-round-%d-%s", id, round, reward)
+	}
+	failed := false
+
+	if err := o.payOnce(ctx, garage, key("completed"), func() error { racesCompletedTotal.Inc(); return nil }); err != nil {
+		failed = true
+		slog.ErrorContext(ctx, "NotifyEndRace failed", "error", err)
+	}
+
+	var finishers []*services.LeaderboardPosition
+	var clan_results []*services.ClanRaceResult
+	for _, race_result := range results {
+		// Wear the motorcycle as reported by the Racing service, every step of the rewards is tried even if another one fails
+		err := o.payOnce(ctx, garage, key("wear-"+race_result.Username), func() error {
+			return garage.ApplyWear(ctx, race_result.Username, int(race_result.MotorcycleId), int(race_result.Wear))
+		})
+		if err != nil {
+			failed = true
+			slog.ErrorContext(ctx, "NotifyEndRace failed", "error", err)
+		} else {
+			slog.InfoContext(ctx, "Race ended: wear motorcycle", "username", race_result.Username, "motorcycle_id", race_result.MotorcycleId, "wear", race_result.Wear)
+		}
+
+		// Friendly races of private lobbies give no rewards
+		if race_result.Friendly {
+			continue
+		}
 
 		// Garage: increase money
 		money_win, _ := strconv.Atoi(os.Getenv("MONEY_WIN"))
@@ -119,224 +409,1513 @@ func (o *Orchestrator) NotifyEndRace(stream pb.Orchestrator_NotifyEndRaceServer)
 		increase := o.computeAfterRace(int(race_result.PositionInRace), int(race_result.TotalMotorcycles), money_win, money_last)
 
 		// Give money to user based on position in race
-		garage := o.balancer.GetGarage()
-		if garage == nil {
-			return errors.New("unable to connect to Garage Service")
-		}
-		err = garage.IncreaseUserMoney(race_result.Username, increase)
+		err = o.payOnce(ctx, garage, key("money-"+race_result.Username), func() error {
+			if err := garage.IncreaseUserMoney(ctx, race_result.Username, increase); err != nil {
+				return err
+			}
+			moneyMintedTotal.WithLabelValues("race").Add(float64(increase))
+			slog.InfoContext(ctx, "Race ended: increase money", "username", race_result.Username, "money", increase)
+			return nil
+		})
 		if err != nil {
-			log.Println(err)
-			return err
+			failed = true
+			slog.ErrorContext(ctx, "NotifyEndRace failed", "error", err)
 		}
-		log.Printf("Race ended: increase money %s by %d", race_result.Username, increase)
 
-		// Leaderboard: increase points
-		leaderboard := o.balancer.GetLeaderboard()
-		if leaderboard == nil {
-			return errors.New("unable to connect to Leaderboard Service")
+		// Winner also gets a random part, the race result is not lost if this fails
+		if race_result.PositionInRace == 1 {
+			err = o.payOnce(ctx, garage, key("part-"+race_result.Username), func() error {
+				part, err := garage.AwardPart(ctx, race_result.Username)
+				if err != nil {
+					return err
+				}
+				slog.InfoContext(ctx, "Race ended: awarded part", "part", part.Name, "username", race_result.Username)
+				return nil
+			})
+			if err != nil {
+				failed = true
+				slog.ErrorContext(ctx, "NotifyEndRace failed", "error", err)
+			}
 		}
 
+		// Leaderboard: increase points
 		points_win, _ := strconv.Atoi(os.Getenv("POINTS_WIN"))
 		points_last, _ := strconv.Atoi(os.Getenv("POINTS_LAST"))
-		increase = o.computeAfterRace(int(race_result.PositionInRace), int(race_result.TotalMotorcycles), points_win, points_last)
+		points := o.computeAfterRace(int(race_result.PositionInRace), int(race_result.TotalMotorcycles), points_win, points_last)
 
 		// Give points to user based on position in race
-		err = leaderboard.AddPoints(race_result.Username, increase)
+		err = o.payOnce(ctx, garage, key("points-"+race_result.Username), func() error {
+			if err := leaderboard.AddPoints(ctx, race_result.Username, points); err != nil {
+				return err
+			}
+			slog.InfoContext(ctx, "Race ended: increase points", "username", race_result.Username, "points", points)
+			return nil
+		})
 		if err != nil {
-			log.Println(err)
-			return err
+			failed = true
+			slog.ErrorContext(ctx, "NotifyEndRace failed", "error", err)
+		}
+
+		// Same points on the boards of the track and of the motorcycle model, global points are already given if this fails
+		err = o.payOnce(ctx, garage, key("boards-"+race_result.Username), func() error {
+			return leaderboard.AddRacePoints(ctx, race_result.Username, race_result.TrackName, race_result.MotorcycleName, points, int(race_result.PositionInRace))
+		})
+		if err != nil {
+			failed = true
+			slog.ErrorContext(ctx, "NotifyEndRace failed", "error", err)
+		}
+		finishers = append(finishers, &services.LeaderboardPosition{Username: race_result.Username, Position: int(race_result.PositionInRace)})
+		clan_results = append(clan_results, &services.ClanRaceResult{Username: race_result.Username, Points: points, Won: race_result.PositionInRace == 1})
+
+		err = o.payOnce(ctx, garage, key("achievement-"+race_result.Username), func() error {
+			return o.recordAchievementEvent(ctx, race_result.Username, &services.AchievementEvent{Type: "race", TrackName: race_result.TrackName, Position: int(race_result.PositionInRace)})
+		})
+		if err != nil {
+			failed = true
+		}
+	}
+
+	if err := o.payOnce(ctx, garage, key("ratings"), func() error { return o.updateRatings(ctx, finishers) }); err != nil {
+		failed = true
+	}
+	if err := o.payOnce(ctx, garage, key("clans"), func() error { return o.recordClanResults(ctx, clan_results) }); err != nil {
+		failed = true
+	}
+
+	// Racing notifies the round again, paying only the rewards that failed this time
+	if failed && id != 0 {
+		return fmt.Errorf("rewards of round %d of championship %d not paid", round, id)
+	}
+	return stream.SendAndClose(nil)
+}
+
+func (o *Orchestrator) NotifyEndChampionship(ctx context.Context, in *pb.ChampionshipResult) (*emptypb.Empty, error) {
+	// Used by Racing service to notify the final standings of a championship, the rounds are already rewarded like any other race
+	ctx = context.WithoutCancel(ctx)
+
+	garage := o.balancer.GetGarage(ctx)
+	if garage == nil {
+		return nil, errors.New("unable to connect to Garage Service")
+	}
+
+	leaderboard := o.balancer.GetLeaderboard(ctx)
+	if leaderboard == nil {
+		return nil, errors.New("unable to connect to Leaderboard Service")
+	}
+
+	// Standings are notified again until received, each reward has its own key so a retry pays only the ones that failed
+	failed := false
+	for _, standing := range in.Standings {
+		position := int(standing.Position)
+		key := func(reward string) string {
+			return fmt.Sprintf("championship-%d-%s-%s", in.ChampionshipId, reward, standing.Username)
+		}
+
+		err := o.payOnce(ctx, garage, key("notify"), func() error {
+			o.notify(ctx, []string{standing.Username}, "championship", fmt.Sprintf("Championship %s ended in position %d", in.Name, position), "/private/championships")
+			return nil
+		})
+		if err != nil {
+			slog.ErrorContext(ctx, "NotifyEndChampionship failed", "error", err)
+		}
+
+		// Garage: prize money for the top positions
+		if reward := o.finalReward("CHAMPIONSHIP_REWARDS", position); reward != 0 {
+			err = o.payOnce(ctx, garage, key("money"), func() error {
+				if err := garage.IncreaseUserMoney(ctx, standing.Username, reward); err != nil {
+					return err
+				}
+				moneyMintedTotal.WithLabelValues("championship").Add(float64(reward))
+				slog.InfoContext(ctx, "Championship ended: increase money", "championship", in.Name, "username", standing.Username, "position", position, "money", reward)
+				return nil
+			})
+			if err != nil {
+				failed = true
+				slog.ErrorContext(ctx, "NotifyEndChampionship failed", "error", err)
+			}
+		}
+
+		// Leaderboard: bonus points for the top positions
+		if points := o.finalReward("CHAMPIONSHIP_POINTS", position); points != 0 {
+			err = o.payOnce(ctx, garage, key("points"), func() error {
+				if err := leaderboard.AddPoints(ctx, standing.Username, points); err != nil {
+					return err
+				}
+				slog.InfoContext(ctx, "Championship ended: increase points", "championship", in.Name, "username", standing.Username, "points", points)
+				return nil
+			})
+			if err != nil {
+				failed = true
+				slog.ErrorContext(ctx, "NotifyEndChampionship failed", "error", err)
+			}
 		}
-		log.Printf("Race ended: increase points %s by %d", race_result.Username, increase)
 	}
+
+	// Racing notifies the standings again, paying only the rewards that failed this time
+	if failed {
+		return nil, fmt.Errorf("rewards of championship %d not paid", in.ChampionshipId)
+	}
+	return &emptypb.Empty{}, nil
 }
 
 ////////////////////// Orchestrator section
 
-func (o *Orchestrator) Login(username string, password string) (res bool, e error) {
-	conn := o.balancer.GetAuth()
+func (o *Orchestrator) Login(ctx context.Context, username string, password string) (res bool, e error) {
+	conn := o.balancer.GetAuth(ctx)
 
 	if conn == nil {
 		return false, errors.New("unable to connect to Auth Service")
 	}
 
 	// Proxy
-	result, err := conn.Login(username, password)
+	result, err := conn.Login(ctx, username, password)
+
+	switch {
+	case err != nil:
+		loginsTotal.WithLabelValues("error").Inc()
+	case result:
+		loginsTotal.WithLabelValues("success").Inc()
+	default:
+		loginsTotal.WithLabelValues("failure").Inc()
+	}
 
-	log.Printf("Login of %s result %t", username, result)
+	slog.InfoContext(ctx, "Login", "username", username, "result", result)
 	return result, err
 }
 
-func (o *Orchestrator) Register(username string, password string, email string, phone string) (res bool, e error) {
+func (o *Orchestrator) Register(ctx context.Context, username string, password string, email string, phone string) (res bool, e error) {
 	// Register in Auth Service
-	auth := o.balancer.GetAuth()
+	auth := o.balancer.GetAuth(ctx)
 
 	if auth == nil {
 		return false, errors.New("unable to connect to Auth Service")
 	}
 
-	register_result, err := auth.Register(username, password, email, phone)
+	register_result, err := auth.Register(ctx, username, password, email, phone)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Register failed", "error", err)
 		return false, err
 	}
-	log.Printf("Auth Register of %s result %t", username, register_result)
+	slog.InfoContext(ctx, "Auth Register", "username", username, "result", register_result)
 
 	// Register in Leaderboard Service (giving startin points)
-	leaderboard := o.balancer.GetLeaderboard()
+	leaderboard := o.balancer.GetLeaderboard(ctx)
 
 	if leaderboard == nil {
 		return false, errors.New("unable to connect to Leaderboard Service")
 	}
 
-	err = leaderboard.AddPoints(username, 0)
+	err = leaderboard.AddPoints(ctx, username, 0)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Register failed", "error", err)
 		return false, err
 	}
 
 	// Register in Garage Service (giving starting money)
-	garage := o.balancer.GetGarage()
+	garage := o.balancer.GetGarage(ctx)
 
 	if garage == nil {
 		return false, errors.New("unable to connect to Garage Service")
 	}
 
 	start_money, _ := strconv.Atoi(os.Getenv("START_MONEY"))
-	err = garage.IncreaseUserMoney(username, start_money)
+	err = garage.IncreaseUserMoney(ctx, username, start_money)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "Register failed", "error", err)
 		return false, err
 	}
+	moneyMintedTotal.WithLabelValues("registration").Add(float64(start_money))
+	registrationsTotal.Inc()
+
+	o.publishEvent(ctx, &services.DomainEvent{Type: services.PlayerRegisteredEvent, Username: username})
 
 	return register_result, nil
 }
 
-func (o *Orchestrator) GetLeaderboardInfo(username string) (*services.LeaderboardPosition, error) {
-	conn := o.balancer.GetLeaderboard()
+func (o *Orchestrator) SendFriendRequest(ctx context.Context, username string, friend string) error {
+	conn := o.balancer.GetAuth(ctx)
 
 	if conn == nil {
-		return nil, errors.New("unable to connect to Leaderboard Service")
+		return errors.New("unable to connect to Auth Service")
 	}
 
-	// Proxy
-	return conn.GetPlayer(username)
-}
-
-func (o *Orchestrator) GetFullLeaderboard() ([]*services.LeaderboardPosition, error) {
-	conn := o.balancer.GetLeaderboard()
-
-	if conn == nil {
-		return nil, errors.New("unable to connect to Leaderboard Service")
+	err := conn.SendFriendRequest(ctx, username, friend)
+	if err != nil {
+		slog.ErrorContext(ctx, "SendFriendRequest failed", "error", err)
+		return err
 	}
+	slog.InfoContext(ctx, "Friend request sent", "username", username, "friend", friend)
 
-	// Proxy
-	return conn.GetFullLeaderboard()
+	o.notify(ctx, []string{friend}, "friend", fmt.Sprintf("%s sent you a friend request", username), "/private/friends")
+	return nil
 }
 
-func (o *Orchestrator) GetRemainingMotorcycles(username string) ([]*services.Motorcycle, error) {
-	garage_conn := o.balancer.GetGarage()
+func (o *Orchestrator) AcceptFriendRequest(ctx context.Context, username string, friend string) error {
+	conn := o.balancer.GetAuth(ctx)
 
-	if garage_conn == nil {
-		return nil, errors.New("unable to connect to Garage Service")
+	if conn == nil {
+		return errors.New("unable to connect to Auth Service")
 	}
 
 	// Proxy
-	return garage_conn.GetRemainingMotorcycles(username)
+	err := conn.AcceptFriendRequest(ctx, username, friend)
+	if err != nil {
+		slog.ErrorContext(ctx, "AcceptFriendRequest failed", "error", err)
+		return err
+	}
+	slog.InfoContext(ctx, "Friend request accepted", "username", username, "friend", friend)
+	return nil
 }
 
-func (o *Orchestrator) GetUserMotorcycles(username string) ([]*services.Ownership, error) {
-	garage_conn := o.balancer.GetGarage()
+func (o *Orchestrator) DeclineFriendRequest(ctx context.Context, username string, friend string) error {
+	conn := o.balancer.GetAuth(ctx)
 
-	if garage_conn == nil {
-		return nil, errors.New("unable to connect to Garage Service")
+	if conn == nil {
+		return errors.New("unable to connect to Auth Service")
 	}
 
-	owned, err := garage_conn.GetUserMotorcycles(username)
+	// Proxy
+	err := conn.DeclineFriendRequest(ctx, username, friend)
 	if err != nil {
-		log.Println(err)
-		return nil, err
+		slog.ErrorContext(ctx, "DeclineFriendRequest failed", "error", err)
+		return err
 	}
+	slog.InfoContext(ctx, "Friend request declined", "username", username, "friend", friend)
+	return nil
+}
 
-	racing_conn := o.balancer.GetRacing()
+func (o *Orchestrator) RemoveFriend(ctx context.Context, username string, friend string) error {
+	conn := o.balancer.GetAuth(ctx)
 
-	if racing_conn == nil {
-		return nil, errors.New("unable to connect to Racing Service")
+	if conn == nil {
+		return errors.New("unable to connect to Auth Service")
 	}
 
-	for _, v := range owned {
-		// Add information regarding racing to each motorcycle
-		if status, err := racing_conn.CheckIsRacing(username, v.Motorcycle.Id); err == nil {
-			v.RacingStatus = status
-		}
+	// Proxy
+	err := conn.RemoveFriend(ctx, username, friend)
+	if err != nil {
+		slog.ErrorContext(ctx, "RemoveFriend failed", "error", err)
+		return err
 	}
-
-	return owned, nil
+	slog.InfoContext(ctx, "Friend removed", "username", username, "friend", friend)
+	return nil
 }
 
-func (o *Orchestrator) GetUserMoney(username string) (int, error) {
-	garage_conn := o.balancer.GetGarage()
+func (o *Orchestrator) GetFriends(ctx context.Context, username string) (*services.Friends, error) {
+	conn := o.balancer.GetAuth(ctx)
 
-	if garage_conn == nil {
-		return 0, errors.New("unable to connect to Garage Service")
+	if conn == nil {
+		return nil, errors.New("unable to connect to Auth Service")
 	}
 
 	// Proxy
-	return garage_conn.GetUserMoney(username)
+	return conn.GetFriends(ctx, username)
 }
 
-func (o *Orchestrator) BuyMotorcycle(username string, MotorcycleId int) error {
-	garage_conn := o.balancer.GetGarage()
+func (o *Orchestrator) GetFriendsLeaderboard(ctx context.Context, username string) ([]*services.LeaderboardPosition, error) {
+	// Friends are known by the Auth Service, their positions by the Leaderboard Service
+	auth := o.balancer.GetAuth(ctx)
 
-	if garage_conn == nil {
-		return errors.New("unable to connect to Garage Service")
+	if auth == nil {
+		return nil, errors.New("unable to connect to Auth Service")
 	}
 
-	// Proxy
-	return garage_conn.BuyMotorcycle(username, MotorcycleId)
-}
+	friends, err := auth.GetFriends(ctx, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetFriendsLeaderboard failed", "error", err)
+		return nil, err
+	}
 
-func (o *Orchestrator) UpgradeMotorcycle(username string, MotorcycleId int) error {
-	garage_conn := o.balancer.GetGarage()
+	leaderboard := o.balancer.GetLeaderboard(ctx)
 
-	if garage_conn == nil {
-		return errors.New("unable to connect to Garage Service")
+	if leaderboard == nil {
+		return nil, errors.New("unable to connect to Leaderboard Service")
 	}
 
-	// Proxy
-	return garage_conn.UpgradeMotorcycle(username, MotorcycleId)
+	// The player is ranked together with friends
+	return leaderboard.GetPlayers(ctx, append(friends.Friends, username))
 }
 
-func (o *Orchestrator) StartMatchmaking(username string, MotorcycleId int) error {
-	garage_conn := o.balancer.GetGarage()
+func (o *Orchestrator) GetFriendsRecentRaces(ctx context.Context, username string) ([]*services.RaceResult, error) {
+	auth := o.balancer.GetAuth(ctx)
 
-	if garage_conn == nil {
-		return errors.New("unable to connect to Garage Service")
+	if auth == nil {
+		return nil, errors.New("unable to connect to Auth Service")
 	}
 
-	// Get stats from garage
-	stats, err := garage_conn.GetUserMotorcycleStats(username, MotorcycleId)
+	friends, err := auth.GetFriends(ctx, username)
 	if err != nil {
-		log.Println(err)
-		return err
+		slog.ErrorContext(ctx, "GetFriendsRecentRaces failed", "error", err)
+		return nil, err
+	}
+	if len(friends.Friends) == 0 {
+		return nil, nil
 	}
 
-	racing_conn := o.balancer.GetRacing()
+	racing := o.balancer.GetRacing(ctx)
 
-	if racing_conn == nil {
-		return errors.New("unable to connect to Racing Service")
+	if racing == nil {
+		return nil, errors.New("unable to connect to Racing Service")
 	}
 
-	// Start matchmaking sending stats of motorcycle
-	return racing_conn.StartMatchmaking(username, stats)
+	return racing.GetPlayersRecentRaces(ctx, friends.Friends)
 }
 
-func (o *Orchestrator) GetHistory(username string) ([]*services.RaceResult, error) {
-	conn := o.balancer.GetRacing()
+func (o *Orchestrator) GetLeaderboardInfo(ctx context.Context, username string) (*services.LeaderboardPosition, error) {
+	conn := o.balancer.GetLeaderboard(ctx)
 
 	if conn == nil {
-		return nil, errors.New("unable to connect to Racing Service")
+		return nil, errors.New("unable to connect to Leaderboard Service")
+	}
+
+	// Proxy
+	return conn.GetPlayer(ctx, username)
+}
+
+func (o *Orchestrator) GetFullLeaderboard(ctx context.Context) ([]*services.LeaderboardPosition, error) {
+	conn := o.balancer.GetLeaderboard(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Leaderboard Service")
+	}
+
+	// Proxy
+	return conn.GetFullLeaderboard(ctx)
+}
+
+func (o *Orchestrator) GetLeaderboardPage(ctx context.Context, offset int, limit int) ([]*services.LeaderboardPosition, int, error) {
+	conn := o.balancer.GetLeaderboard(ctx)
+
+	if conn == nil {
+		return nil, 0, errors.New("unable to connect to Leaderboard Service")
+	}
+
+	// Proxy
+	return conn.GetLeaderboardPage(ctx, offset, limit)
+}
+
+func (o *Orchestrator) GetLeaderboardAround(ctx context.Context, username string, radius int) ([]*services.LeaderboardPosition, int, error) {
+	conn := o.balancer.GetLeaderboard(ctx)
+
+	if conn == nil {
+		return nil, 0, errors.New("unable to connect to Leaderboard Service")
+	}
+
+	// Proxy
+	return conn.GetLeaderboardAround(ctx, username, radius)
+}
+
+func (o *Orchestrator) GetBoard(ctx context.Context, board string, name string, offset int, limit int) ([]*services.LeaderboardPosition, int, error) {
+	conn := o.balancer.GetLeaderboard(ctx)
+
+	if conn == nil {
+		return nil, 0, errors.New("unable to connect to Leaderboard Service")
+	}
+
+	// Proxy
+	return conn.GetBoard(ctx, board, name, offset, limit)
+}
+
+func (o *Orchestrator) GetBoardNames(ctx context.Context, board string) ([]string, error) {
+	conn := o.balancer.GetLeaderboard(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Leaderboard Service")
+	}
+
+	// Proxy
+	return conn.GetBoardNames(ctx, board)
+}
+
+func (o *Orchestrator) GetCurrentSeason(ctx context.Context) (*services.Season, error) {
+	conn := o.balancer.GetLeaderboard(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Leaderboard Service")
+	}
+
+	// Proxy
+	return conn.GetCurrentSeason(ctx)
+}
+
+func (o *Orchestrator) GetPastSeasons(ctx context.Context) ([]*services.Season, error) {
+	conn := o.balancer.GetLeaderboard(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Leaderboard Service")
+	}
+
+	// Proxy
+	return conn.GetPastSeasons(ctx)
+}
+
+func (o *Orchestrator) GetSeasonStandings(ctx context.Context, SeasonId int) ([]*services.LeaderboardPosition, error) {
+	conn := o.balancer.GetLeaderboard(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Leaderboard Service")
+	}
+
+	// Proxy
+	return conn.GetSeasonStandings(ctx, SeasonId)
+}
+
+func (o *Orchestrator) finalReward(variable string, position int) int {
+	// Utility function that reads the reward of a final position from an env variable, a comma separated list starting from the winner
+
+	rewards := strings.Split(os.Getenv(variable), ",")
+	if position < 1 || position > len(rewards) {
+		return 0
+	}
+
+	reward, _ := strconv.Atoi(strings.TrimSpace(rewards[position-1]))
+	return reward
+}
+
+func (o *Orchestrator) RolloverSeason(ctx context.Context) error {
+	// End the current season if its time is over and pay the season rewards.
+	// Every replica calls it, but only one receives the final standings from the Leaderboard service.
+	// Each archived standing is claimed before its reward is paid, and released if the payment fails so the next call tries again
+
+	leaderboard := o.balancer.GetLeaderboard(ctx)
+	if leaderboard == nil {
+		return errors.New("unable to connect to Leaderboard Service")
+	}
+
+	days, _ := strconv.Atoi(os.Getenv("SEASON_DAYS"))
+	standings, err := leaderboard.RolloverSeason(ctx, days)
+	if err != nil {
+		slog.ErrorContext(ctx, "RolloverSeason failed", "error", err)
+		return err
+	}
+
+	for _, standing := range standings {
+		reward := o.finalReward("SEASON_REWARDS", standing.Position)
+		o.notify(ctx, []string{standing.Username}, "season", fmt.Sprintf("Season ended in position %d, reward: %d", standing.Position, reward), "/leaderboard")
+	}
+
+	unpaid, err := leaderboard.GetUnpaidStandings(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "RolloverSeason failed", "error", err)
+		return err
+	}
+	if len(unpaid) == 0 {
+		return nil
+	}
+
+	garage := o.balancer.GetGarage(ctx)
+	if garage == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	for _, standing := range unpaid {
+		claimed, err := leaderboard.ClaimStanding(ctx, standing.SeasonId, standing.Username)
+		if err != nil {
+			slog.ErrorContext(ctx, "RolloverSeason failed", "error", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		reward := o.finalReward("SEASON_REWARDS", standing.Position)
+		if reward == 0 {
+			continue
+		}
+
+		if err = garage.IncreaseUserMoney(ctx, standing.Username, reward); err != nil {
+			slog.ErrorContext(ctx, "RolloverSeason failed", "error", err)
+			if err = leaderboard.ReleaseStanding(ctx, standing.SeasonId, standing.Username); err != nil {
+				slog.ErrorContext(ctx, "RolloverSeason failed", "error", err)
+			}
+			continue
+		}
+		moneyMintedTotal.WithLabelValues("season").Add(float64(reward))
+		slog.InfoContext(ctx, "Season ended: increase money", "username", standing.Username, "position", standing.Position, "money", reward)
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) GetRemainingMotorcycles(ctx context.Context, username string) ([]*services.Motorcycle, error) {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return nil, errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.GetRemainingMotorcycles(ctx, username)
+}
+
+func (o *Orchestrator) GetUserMotorcycles(ctx context.Context, username string) ([]*services.Ownership, error) {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return nil, errors.New("unable to connect to Garage Service")
+	}
+
+	owned, err := garage_conn.GetUserMotorcycles(ctx, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUserMotorcycles failed", "error", err)
+		return nil, err
+	}
+
+	racing_conn := o.balancer.GetRacing(ctx)
+
+	if racing_conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	for _, v := range owned {
+		// Add information regarding racing to each motorcycle
+		if status, err := racing_conn.CheckIsRacing(ctx, username, v.Motorcycle.Id); err == nil {
+			v.RacingStatus = status
+		}
+	}
+
+	return owned, nil
+}
+
+func (o *Orchestrator) GetUserMoney(ctx context.Context, username string) (int, error) {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return 0, errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.GetUserMoney(ctx, username)
+}
+
+func (o *Orchestrator) BuyMotorcycle(ctx context.Context, username string, MotorcycleId int) error {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	err := garage_conn.BuyMotorcycle(ctx, username, MotorcycleId)
+	if err != nil {
+		return err
+	}
+
+	o.recordAchievementEvent(ctx, username, &services.AchievementEvent{Type: "purchase"})
+
+	event := &services.DomainEvent{Type: services.MotorcycleBoughtEvent, Username: username, MotorcycleId: MotorcycleId}
+	if stats, err := garage_conn.GetUserMotorcycleStats(ctx, username, MotorcycleId); err == nil {
+		event.MotorcycleName, event.Price = stats.Motorcycle.Name, stats.Motorcycle.PriceToBuy
+	}
+	o.publishEvent(ctx, event)
+	return nil
+}
+
+func (o *Orchestrator) UpgradeMotorcycle(ctx context.Context, username string, MotorcycleId int, stat string) error {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	err := garage_conn.UpgradeMotorcycle(ctx, username, MotorcycleId, stat)
+	if err != nil {
+		return err
+	}
+
+	// Check if the upgrade brought every stat to its max level
+	fully_upgraded := false
+	if stats, err := garage_conn.GetUserMotorcycleStats(ctx, username, MotorcycleId); err != nil {
+		slog.ErrorContext(ctx, "UpgradeMotorcycle failed", "error", err)
+	} else {
+		m := stats.Motorcycle
+		fully_upgraded = stats.EngineLevel >= m.EngineMaxLevel && stats.AgilityLevel >= m.AgilityMaxLevel &&
+			stats.BrakesLevel >= m.BrakesMaxLevel && stats.AerodynamicsLevel >= m.AerodynamicsMaxLevel
+	}
+
+	o.recordAchievementEvent(ctx, username, &services.AchievementEvent{Type: "upgrade", FullyUpgraded: fully_upgraded})
+	return nil
+}
+
+// Wear above which a motorcycle cannot race, used when MAX_RACING_WEAR is missing or not a percentage
+const defaultMaxRacingWear = 80
+
+func maxRacingWear() int {
+	// Utility function that reads MAX_RACING_WEAR, an invalid value must not stop every motorcycle from racing
+
+	max_wear, err := strconv.Atoi(os.Getenv("MAX_RACING_WEAR"))
+	if err != nil || max_wear < 0 || max_wear > 100 {
+		return defaultMaxRacingWear
+	}
+
+	return max_wear
+}
+
+func (o *Orchestrator) getRacingStats(ctx context.Context, username string, MotorcycleId int) (*services.Ownership, error) {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return nil, errors.New("unable to connect to Garage Service")
+	}
+
+	// Get stats from garage
+	stats, err := garage_conn.GetUserMotorcycleStats(ctx, username, MotorcycleId)
+	if err != nil {
+		slog.ErrorContext(ctx, "getRacingStats failed", "error", err)
+		return nil, err
+	}
+
+	if stats.Wear > maxRacingWear() {
+		return nil, errors.New("motorcycle too worn to race, repair it first")
+	}
+
+	return stats, nil
+}
+
+func (o *Orchestrator) StartMatchmaking(ctx context.Context, username string, MotorcycleId int) error {
+	stats, err := o.getRacingStats(ctx, username, MotorcycleId)
+	if err != nil {
+		return err
+	}
+
+	racing_conn := o.balancer.GetRacing(ctx)
+
+	if racing_conn == nil {
+		return errors.New("unable to connect to Racing Service")
+	}
+
+	// Start matchmaking sending stats of motorcycle
+	return racing_conn.StartMatchmaking(ctx, username, stats)
+}
+
+func (o *Orchestrator) GetTracks(ctx context.Context) ([]*services.Track, error) {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	return conn.GetTracks(ctx)
+}
+
+func (o *Orchestrator) CreateLobby(ctx context.Context, username string, MotorcycleId int, TrackId int, friendly bool) (*services.Lobby, error) {
+	stats, err := o.getRacingStats(ctx, username, MotorcycleId)
+	if err != nil {
+		return nil, err
+	}
+
+	racing_conn := o.balancer.GetRacing(ctx)
+
+	if racing_conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Host joins the lobby with the chosen motorcycle
+	lobby, err := racing_conn.CreateLobby(ctx, username, stats, TrackId, friendly)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateLobby failed", "error", err)
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Lobby created", "code", lobby.Code, "username", username)
+
+	return lobby, nil
+}
+
+func (o *Orchestrator) JoinLobby(ctx context.Context, username string, code string, MotorcycleId int) error {
+	stats, err := o.getRacingStats(ctx, username, MotorcycleId)
+	if err != nil {
+		return err
+	}
+
+	racing_conn := o.balancer.GetRacing(ctx)
+
+	if racing_conn == nil {
+		return errors.New("unable to connect to Racing Service")
+	}
+
+	// The race starts if the lobby is now full
+	return racing_conn.JoinLobby(ctx, code, username, stats)
+}
+
+func (o *Orchestrator) LeaveLobby(ctx context.Context, username string, code string) error {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	return conn.LeaveLobby(ctx, code, username)
+}
+
+func (o *Orchestrator) StartLobbyRace(ctx context.Context, username string, code string) error {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	return conn.StartLobbyRace(ctx, code, username)
+}
+
+func (o *Orchestrator) GetLobby(ctx context.Context, code string) (*services.Lobby, error) {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	return conn.GetLobby(ctx, code)
+}
+
+func (o *Orchestrator) GetPlayerLobbies(ctx context.Context, username string) ([]*services.Lobby, error) {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	return conn.GetPlayerLobbies(ctx, username)
+}
+
+func (o *Orchestrator) CreateChampionship(ctx context.Context, username string, name string, tracks []int, starts_at time.Time, interval_minutes int, max_entrants int) (*services.Championship, error) {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	championship, err := conn.CreateChampionship(ctx, username, name, tracks, starts_at, interval_minutes, max_entrants)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateChampionship failed", "error", err)
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Championship created", "championship", name, "username", username)
+
+	return championship, nil
+}
+
+func (o *Orchestrator) RegisterChampionship(ctx context.Context, username string, ChampionshipId int, MotorcycleId int) error {
+	stats, err := o.getRacingStats(ctx, username, MotorcycleId)
+	if err != nil {
+		return err
+	}
+
+	racing_conn := o.balancer.GetRacing(ctx)
+
+	if racing_conn == nil {
+		return errors.New("unable to connect to Racing Service")
+	}
+
+	// Stats at registration are used for every round
+	return racing_conn.RegisterChampionship(ctx, ChampionshipId, username, stats)
+}
+
+func (o *Orchestrator) GetChampionships(ctx context.Context) ([]*services.Championship, error) {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	return conn.GetChampionships(ctx)
+}
+
+func (o *Orchestrator) GetChampionship(ctx context.Context, ChampionshipId int) (*services.Championship, error) {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	return conn.GetChampionship(ctx, ChampionshipId)
+}
+
+func (o *Orchestrator) GetChampionshipSchedule(ctx context.Context, ChampionshipId int) ([]*services.ChampionshipRound, error) {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	return conn.GetChampionshipSchedule(ctx, ChampionshipId)
+}
+
+func (o *Orchestrator) GetChampionshipStandings(ctx context.Context, ChampionshipId int) ([]*services.ChampionshipStanding, error) {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	return conn.GetChampionshipStandings(ctx, ChampionshipId)
+}
+
+func (o *Orchestrator) RunTimeTrial(ctx context.Context, username string, TrackId int, MotorcycleId int) (*services.TimeTrialResult, error) {
+	stats, err := o.getRacingStats(ctx, username, MotorcycleId)
+	if err != nil {
+		return nil, err
+	}
+
+	racing_conn := o.balancer.GetRacing(ctx)
+
+	if racing_conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Solo lap, no wear and no rewards apart from taking the track record from another player, the first record of a track is not taken from anyone
+	result, err := racing_conn.RunTimeTrial(ctx, username, stats, TrackId)
+	if err != nil {
+		slog.ErrorContext(ctx, "RunTimeTrial failed", "error", err)
+		return nil, err
+	}
+
+	reward, _ := strconv.Atoi(os.Getenv("TIME_TRIAL_RECORD_REWARD"))
+	if result.NewTrackRecord && result.RecordHolder != "" && result.RecordHolder != username && reward > 0 {
+		garage_conn := o.balancer.GetGarage(ctx)
+		if garage_conn == nil {
+			slog.ErrorContext(ctx, "unable to connect to Garage Service")
+		} else if err := garage_conn.IncreaseUserMoney(ctx, username, reward); err != nil {
+			slog.ErrorContext(ctx, "RunTimeTrial failed", "error", err)
+		} else {
+			moneyMintedTotal.WithLabelValues("time_trial").Add(float64(reward))
+			slog.InfoContext(ctx, "Track record: increase money", "track", result.TrackName, "username", username, "money", reward)
+		}
+	}
+
+	return result, nil
+}
+
+func (o *Orchestrator) GetTimeTrialLeaderboard(ctx context.Context, TrackId int) ([]*services.TimeTrialRecord, error) {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	return conn.GetTimeTrialLeaderboard(ctx, TrackId)
+}
+
+func (o *Orchestrator) GetHistory(ctx context.Context, username string) ([]*services.RaceResult, error) {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	return conn.GetHistory(ctx, username)
+}
+
+func (o *Orchestrator) GetRecentRaces(ctx context.Context) ([]*services.RaceResult, error) {
+	conn := o.balancer.GetRacing(ctx)
+
+	if conn == nil {
+		return nil, errors.New("unable to connect to Racing Service")
+	}
+
+	// Proxy
+	return conn.GetRecentRaces(ctx)
+}
+
+func (o *Orchestrator) GetListings(ctx context.Context) ([]*services.Listing, error) {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return nil, errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.GetListings(ctx)
+}
+
+func (o *Orchestrator) CreateListing(ctx context.Context, username string, MotorcycleId int, price int, duration_hours int) error {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	racing_conn := o.balancer.GetRacing(ctx)
+
+	if racing_conn == nil {
+		return errors.New("unable to connect to Racing Service")
+	}
+
+	// A motorcycle can not be put on sale while it is racing
+	status, err := racing_conn.CheckIsRacing(ctx, username, MotorcycleId)
+	if err != nil {
+		slog.ErrorContext(ctx, "CheckIsRacing failed", "error", err)
+		return err
+	}
+	if status.Status {
+		return errors.New("motorcycle is racing")
+	}
+
+	return garage_conn.CreateListing(ctx, username, MotorcycleId, price, duration_hours)
+}
+
+func (o *Orchestrator) CancelListing(ctx context.Context, username string, ListingId int) error {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.CancelListing(ctx, username, ListingId)
+}
+
+func (o *Orchestrator) BuyListing(ctx context.Context, username string, ListingId int) error {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	// The listing is gone once bought, its motorcycle is read before
+	var listing *services.Listing
+	if listings, err := garage_conn.GetListings(ctx); err == nil {
+		for _, l := range listings {
+			if l.Id == ListingId {
+				listing = l
+			}
+		}
+	}
+
+	err := garage_conn.BuyListing(ctx, username, ListingId)
+	if err != nil {
+		return err
+	}
+
+	o.recordAchievementEvent(ctx, username, &services.AchievementEvent{Type: "purchase"})
+
+	if listing != nil {
+		o.publishEvent(ctx, &services.DomainEvent{Type: services.MotorcycleBoughtEvent, Username: username, MotorcycleId: listing.Ownership.Motorcycle.Id, MotorcycleName: listing.Ownership.Motorcycle.Name, Price: listing.Price})
+	}
+	return nil
+}
+
+func (o *Orchestrator) IsAdmin(ctx context.Context, username string) bool {
+	admin := os.Getenv("ADMIN_USERNAME")
+	return admin != "" && admin == username
+}
+
+func (o *Orchestrator) GetAuctions(ctx context.Context) ([]*services.Auction, error) {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return nil, errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.GetAuctions(ctx)
+}
+
+func (o *Orchestrator) GetAuction(ctx context.Context, AuctionId int) (*services.Auction, error) {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return nil, errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.GetAuction(ctx, AuctionId)
+}
+
+func (o *Orchestrator) CreateAuction(ctx context.Context, username string, MotorcycleId int, reserve int, duration_minutes int) error {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	racing_conn := o.balancer.GetRacing(ctx)
+
+	if racing_conn == nil {
+		return errors.New("unable to connect to Racing Service")
+	}
+
+	// A motorcycle can not be put up for auction while it is racing
+	status, err := racing_conn.CheckIsRacing(ctx, username, MotorcycleId)
+	if err != nil {
+		slog.ErrorContext(ctx, "CheckIsRacing failed", "error", err)
+		return err
+	}
+	if status.Status {
+		return errors.New("motorcycle is racing")
+	}
+
+	// Level is taken from the ownership of the seller
+	return garage_conn.CreateAuction(ctx, username, MotorcycleId, 0, reserve, duration_minutes)
+}
+
+func (o *Orchestrator) CreateHouseAuction(ctx context.Context, username string, MotorcycleId int, level int, reserve int, duration_minutes int) error {
+	// Auction of a brand new motorcycle, allowed only to the admin
+
+	if !o.IsAdmin(ctx, username) {
+		return errors.New("user is not admin")
+	}
+
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	return garage_conn.CreateAuction(ctx, "", MotorcycleId, level, reserve, duration_minutes)
+}
+
+func (o *Orchestrator) PlaceBid(ctx context.Context, username string, AuctionId int, amount int) error {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	outbid, err := garage_conn.PlaceBid(ctx, username, AuctionId, amount)
+	if err != nil {
+		slog.ErrorContext(ctx, "PlaceBid failed", "error", err)
+		return err
+	}
+
+	// The bidder that lost the lead is told so
+	if outbid != "" {
+		name := "a motorcycle"
+		if auction, err := garage_conn.GetAuction(ctx, AuctionId); err == nil {
+			name = auction.Motorcycle.Name
+		}
+		o.notify(ctx, []string{outbid}, "auction", fmt.Sprintf("You were outbid on the auction of %s", name), fmt.Sprintf("/private/auctions/%d", AuctionId))
+	}
+	return nil
+}
+
+func (o *Orchestrator) WatchAuction(ctx context.Context, AuctionId int) (<-chan *services.Bid, error) {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return nil, errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.WatchAuction(ctx, AuctionId)
+}
+
+func (o *Orchestrator) GetParts(ctx context.Context) ([]*services.Part, error) {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return nil, errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.GetParts(ctx)
+}
+
+func (o *Orchestrator) GetInventory(ctx context.Context, username string) ([]*services.Item, error) {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return nil, errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.GetInventory(ctx, username)
+}
+
+func (o *Orchestrator) BuyPart(ctx context.Context, username string, PartId int) error {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	err := garage_conn.BuyPart(ctx, username, PartId)
+	if err != nil {
+		return err
+	}
+
+	o.recordAchievementEvent(ctx, username, &services.AchievementEvent{Type: "purchase"})
+	return nil
+}
+
+func (o *Orchestrator) EquipPart(ctx context.Context, username string, ItemId int, MotorcycleId int) error {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.EquipPart(ctx, username, ItemId, MotorcycleId)
+}
+
+func (o *Orchestrator) UnequipPart(ctx context.Context, username string, ItemId int) error {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.UnequipPart(ctx, username, ItemId)
+}
+
+func (o *Orchestrator) RepairMotorcycle(ctx context.Context, username string, MotorcycleId int) error {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.RepairMotorcycle(ctx, username, MotorcycleId)
+}
+
+func (o *Orchestrator) GetPaints(ctx context.Context) ([]*services.Paint, error) {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return nil, errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.GetPaints(ctx)
+}
+
+func (o *Orchestrator) CustomizeMotorcycle(ctx context.Context, username string, MotorcycleId int, PaintId int, RaceNumber int, RiderName string) error {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	// Proxy
+	return garage_conn.CustomizeMotorcycle(ctx, username, MotorcycleId, PaintId, RaceNumber, RiderName)
+}
+
+func (o *Orchestrator) ClaimDailyReward(ctx context.Context, username string) (*services.DailyReward, error) {
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return nil, errors.New("unable to connect to Garage Service")
+	}
+
+	// Garage records the claim, so reloading the page or hitting another replica pays only once a day
+	daily, err := garage_conn.ClaimDailyReward(ctx, username)
+	if err == nil && daily.Claimed {
+		moneyMintedTotal.WithLabelValues("daily_reward").Add(float64(daily.Money))
+	}
+	return daily, err
+}
+
+func (o *Orchestrator) GetAchievements(ctx context.Context, username string) ([]*services.Achievement, error) {
+	achievements_conn := o.balancer.GetAchievements(ctx)
+
+	if achievements_conn == nil {
+		return nil, errors.New("unable to connect to Achievements Service")
+	}
+
+	// Proxy
+	return achievements_conn.GetAchievements(ctx, username)
+}
+
+func (o *Orchestrator) AddClanTags(ctx context.Context, leaderboards ...[]*services.LeaderboardPosition) {
+	// Utility function that fills the clan tags of leaderboard rows, rows are still shown untagged if this fails
+
+	var usernames []string
+	for _, leaderboard := range leaderboards {
+		for _, p := range leaderboard {
+			usernames = append(usernames, p.Username)
+		}
+	}
+	if len(usernames) == 0 {
+		return
+	}
+
+	clans_conn := o.balancer.GetClans(ctx)
+	if clans_conn == nil {
+		slog.ErrorContext(ctx, "unable to connect to Clans Service")
+		return
+	}
+
+	tags, err := clans_conn.GetClanTags(ctx, usernames)
+	if err != nil {
+		slog.ErrorContext(ctx, "AddClanTags failed", "error", err)
+		return
+	}
+
+	for _, leaderboard := range leaderboards {
+		for _, p := range leaderboard {
+			p.ClanTag = tags[p.Username]
+		}
+	}
+}
+
+func (o *Orchestrator) CreateClan(ctx context.Context, username string, name string, tag string) (*services.Clan, error) {
+	clans_conn := o.balancer.GetClans(ctx)
+
+	if clans_conn == nil {
+		return nil, errors.New("unable to connect to Clans Service")
+	}
+
+	// Proxy
+	return clans_conn.CreateClan(ctx, username, name, tag)
+}
+
+func (o *Orchestrator) GetClan(ctx context.Context, ClanId int) (*services.Clan, []*services.ClanMember, []*services.ClanTransaction, error) {
+	clans_conn := o.balancer.GetClans(ctx)
+
+	if clans_conn == nil {
+		return nil, nil, nil, errors.New("unable to connect to Clans Service")
+	}
+
+	clan, err := clans_conn.GetClan(ctx, ClanId)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetClan failed", "error", err)
+		return nil, nil, nil, err
+	}
+
+	members, err := clans_conn.GetMembers(ctx, ClanId)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetClan failed", "error", err)
+		return nil, nil, nil, err
+	}
+
+	transactions, err := clans_conn.GetTransactions(ctx, ClanId)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetClan failed", "error", err)
+		return nil, nil, nil, err
+	}
+
+	return clan, members, transactions, nil
+}
+
+func (o *Orchestrator) GetPlayerClan(ctx context.Context, username string) (*services.Clan, error) {
+	clans_conn := o.balancer.GetClans(ctx)
+
+	if clans_conn == nil {
+		return nil, errors.New("unable to connect to Clans Service")
+	}
+
+	// Proxy
+	return clans_conn.GetPlayerClan(ctx, username)
+}
+
+func (o *Orchestrator) GetClanLeaderboard(ctx context.Context) ([]*services.Clan, error) {
+	clans_conn := o.balancer.GetClans(ctx)
+
+	if clans_conn == nil {
+		return nil, errors.New("unable to connect to Clans Service")
+	}
+
+	// Proxy
+	return clans_conn.GetClanLeaderboard(ctx)
+}
+
+func (o *Orchestrator) InviteToClan(ctx context.Context, username string, invited string) error {
+	clans_conn := o.balancer.GetClans(ctx)
+
+	if clans_conn == nil {
+		return errors.New("unable to connect to Clans Service")
+	}
+
+	if err := clans_conn.InviteToClan(ctx, username, invited); err != nil {
+		slog.ErrorContext(ctx, "InviteToClan failed", "error", err)
+		return err
+	}
+
+	o.notify(ctx, []string{invited}, "clan", fmt.Sprintf("%s invited you to their clan", username), "/private/clans")
+	return nil
+}
+
+func (o *Orchestrator) GetClanInvites(ctx context.Context, username string) ([]*services.Clan, error) {
+	clans_conn := o.balancer.GetClans(ctx)
+
+	if clans_conn == nil {
+		return nil, errors.New("unable to connect to Clans Service")
+	}
+
+	// Proxy
+	return clans_conn.GetInvites(ctx, username)
+}
+
+func (o *Orchestrator) AcceptClanInvite(ctx context.Context, username string, ClanId int) error {
+	clans_conn := o.balancer.GetClans(ctx)
+
+	if clans_conn == nil {
+		return errors.New("unable to connect to Clans Service")
+	}
+
+	// Proxy
+	return clans_conn.AcceptInvite(ctx, username, ClanId)
+}
+
+func (o *Orchestrator) DeclineClanInvite(ctx context.Context, username string, ClanId int) error {
+	clans_conn := o.balancer.GetClans(ctx)
+
+	if clans_conn == nil {
+		return errors.New("unable to connect to Clans Service")
+	}
+
+	// Proxy
+	return clans_conn.DeclineInvite(ctx, username, ClanId)
+}
+
+func (o *Orchestrator) LeaveClan(ctx context.Context, username string) error {
+	clans_conn := o.balancer.GetClans(ctx)
+
+	if clans_conn == nil {
+		return errors.New("unable to connect to Clans Service")
+	}
+
+	clan, err := clans_conn.GetPlayerClan(ctx, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "LeaveClan failed", "error", err)
+		return err
+	}
+
+	// The last member disbanding the clan gets the treasury back,
+	// it stays in the clan until the wallet has received it
+	if clan.Members == 1 && clan.Treasury > 0 {
+		if err = o.WithdrawFromClan(ctx, username, username, clan.Treasury); err != nil {
+			return err
+		}
+	}
+
+	if err = clans_conn.LeaveClan(ctx, username); err != nil {
+		slog.ErrorContext(ctx, "LeaveClan failed", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) DepositToClan(ctx context.Context, username string, amount int) error {
+	clans_conn := o.balancer.GetClans(ctx)
+
+	if clans_conn == nil {
+		return errors.New("unable to connect to Clans Service")
+	}
+
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	// Money leaves the wallet first, so it is never in the treasury without being paid
+	if err := garage_conn.DecreaseUserMoney(ctx, username, amount); err != nil {
+		slog.ErrorContext(ctx, "DepositToClan failed", "error", err)
+		return err
+	}
+
+	if err := clans_conn.Deposit(ctx, username, amount); err != nil {
+		slog.ErrorContext(ctx, "DepositToClan failed", "error", err)
+
+		// Give the money back to the wallet
+		if err := garage_conn.IncreaseUserMoney(ctx, username, amount); err != nil {
+			slog.ErrorContext(ctx, "DepositToClan failed", "error", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) WithdrawFromClan(ctx context.Context, username string, member string, amount int) error {
+	clans_conn := o.balancer.GetClans(ctx)
+
+	if clans_conn == nil {
+		return errors.New("unable to connect to Clans Service")
+	}
+
+	garage_conn := o.balancer.GetGarage(ctx)
+
+	if garage_conn == nil {
+		return errors.New("unable to connect to Garage Service")
+	}
+
+	// The Clans Service checks the owner and the treasury before any money reaches the wallet
+	transaction, err := clans_conn.Withdraw(ctx, username, member, amount)
+	if err != nil {
+		slog.ErrorContext(ctx, "WithdrawFromClan failed", "error", err)
+		return err
+	}
+
+	if err := garage_conn.IncreaseUserMoney(ctx, member, amount); err != nil {
+		slog.ErrorContext(ctx, "WithdrawFromClan failed", "error", err)
+
+		// Put the money back in the treasury
+		if err := clans_conn.CancelWithdrawal(ctx, transaction); err != nil {
+			slog.ErrorContext(ctx, "WithdrawFromClan failed", "error", err)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func (o *Orchestrator) GetNotifications(ctx context.Context, username string) ([]*services.Notification, error) {
+	notifications_conn := o.balancer.GetNotifications(ctx)
+
+	if notifications_conn == nil {
+		return nil, errors.New("unable to connect to Notifications Service")
+	}
+
+	// Proxy
+	return notifications_conn.GetNotifications(ctx, username)
+}
+
+func (o *Orchestrator) GetUnreadCount(ctx context.Context, username string) (int, error) {
+	notifications_conn := o.balancer.GetNotifications(ctx)
+
+	if notifications_conn == nil {
+		return 0, errors.New("unable to connect to Notifications Service")
+	}
+
+	// Proxy
+	return notifications_conn.GetUnreadCount(ctx, username)
+}
+
+func (o *Orchestrator) MarkNotificationRead(ctx context.Context, username string, NotificationId int) error {
+	notifications_conn := o.balancer.GetNotifications(ctx)
+
+	if notifications_conn == nil {
+		return errors.New("unable to connect to Notifications Service")
+	}
+
+	// Proxy
+	return notifications_conn.MarkRead(ctx, username, NotificationId)
+}
+
+func (o *Orchestrator) MarkAllNotificationsRead(ctx context.Context, username string) error {
+	notifications_conn := o.balancer.GetNotifications(ctx)
+
+	if notifications_conn == nil {
+		return errors.New("unable to connect to Notifications Service")
+	}
+
+	// Proxy
+	return notifications_conn.MarkAllRead(ctx, username)
+}
+
+func (o *Orchestrator) WatchNotifications(ctx context.Context, username string) (<-chan *services.Notification, error) {
+	notifications_conn := o.balancer.GetNotifications(ctx)
+
+	if notifications_conn == nil {
+		return nil, errors.New("unable to connect to Notifications Service")
 	}
 
 	// Proxy
-	return conn.GetHistory(username)
+	return notifications_conn.WatchNotifications(ctx, username)
 }
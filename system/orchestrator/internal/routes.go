@@ -1,14 +1,26 @@
 package internal
 
 import (
+	"fmt"
+	"io"
+	"log/slog"
 	"net/http"
 	"orchestrator/internal/services"
+	"orchestrator/telemetry"
+	"os"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
+func notificationsPush() bool {
+	// live push of new notifications is optional, the inbox works without it
+	return os.Getenv("NOTIFICATIONS_PUSH") == "true"
+}
+
 func isLoggedIn(c *gin.Context) bool {
 	// user is logged if username key is in session
 	return sessions.Default(c).Get("username") != nil
@@ -23,6 +35,23 @@ func Authorized(c *gin.Context) {
 	c.Next()
 }
 
+func Correlated(c *gin.Context) {
+	// Handle giving every request a correlation ID, the one sent by the client if valid, otherwise a new one.
+	// The ID is returned in the response and carried by the request context down to the services,
+	// so the log lines of one user action across the system share it
+
+	id := c.GetHeader(telemetry.CorrelationHeader)
+	if !telemetry.ValidCorrelationID(id) {
+		id = telemetry.NewCorrelationID()
+	}
+	c.Header(telemetry.CorrelationHeader, id)
+	c.Request = c.Request.WithContext(telemetry.WithCorrelationID(c.Request.Context(), id))
+
+	start := time.Now()
+	c.Next()
+	slog.InfoContext(c.Request.Context(), "Request", "method", c.Request.Method, "path", c.Request.URL.Path, "status", c.Writer.Status(), "duration", time.Since(start))
+}
+
 type MyRoutes struct {
 	orchestrator *Orchestrator
 }
@@ -44,7 +73,7 @@ func (r *MyRoutes) LoginRoute(c *gin.Context) {
 	session := sessions.Default(c)
 
 	username, password := c.PostForm("username"), c.PostForm("password")
-	res, err := r.orchestrator.Login(username, password)
+	res, err := r.orchestrator.Login(c.Request.Context(), username, password)
 
 	if res && err == nil {
 		// if login is successful, save username in session
@@ -61,7 +90,7 @@ func (r *MyRoutes) RegisterRoute(c *gin.Context) {
 	session := sessions.Default(c)
 
 	username, password, email, phone := c.PostForm("username"), c.PostForm("password"), c.PostForm("email"), c.PostForm("phone")
-	res, err := r.orchestrator.Register(username, password, email, phone)
+	res, err := r.orchestrator.Register(c.Request.Context(), username, password, email, phone)
 
 	if res && err == nil {
 		// if registration is successful, save username in session
@@ -86,40 +115,88 @@ func (r *MyRoutes) LogoutRoute(c *gin.Context) {
 func (r *MyRoutes) HomeRoute(c *gin.Context) {
 	username := sessions.Default(c).Get("username").(string)
 
-	info, err := r.orchestrator.GetLeaderboardInfo(username)
+	// The first load of the day claims the daily reward
+	daily, err := r.orchestrator.ClaimDailyReward(c.Request.Context(), username)
+	if err != nil {
+		slog.ErrorContext(c.Request.Context(), "ClaimDailyReward failed", "error", err)
+		daily = &services.DailyReward{}
+	}
+
+	achievements, err := r.orchestrator.GetAchievements(c.Request.Context(), username)
+	if err != nil {
+		achievements = make([]*services.Achievement, 0)
+	}
+
+	friend_races, err := r.orchestrator.GetFriendsRecentRaces(c.Request.Context(), username)
+	if err != nil {
+		friend_races = make([]*services.RaceResult, 0)
+	}
+
+	unread, _ := r.orchestrator.GetUnreadCount(c.Request.Context(), username)
+
+	info, err := r.orchestrator.GetLeaderboardInfo(c.Request.Context(), username)
 
 	points := 0
 	position := 0
+	rating := 0
 
 	if err == nil {
 		points = int(info.Points)
 		position = int(info.Position)
+		rating = int(info.Rating)
 	}
 
 	c.HTML(http.StatusOK, "home.html", gin.H{
-		"username": username,
-		"points":   points,
-		"position": position,
+		"username":     username,
+		"points":       points,
+		"position":     position,
+		"rating":       rating,
+		"daily":        daily,
+		"achievements": achievements,
+		"friendRaces":  friend_races,
+		"unread":       unread,
+		"push":         notificationsPush(),
 	})
 }
 
 func (r *MyRoutes) GarageRoute(c *gin.Context) {
 	username := sessions.Default(c).Get("username").(string)
 
-	money, _ := r.orchestrator.GetUserMoney(username)
-	owned, err := r.orchestrator.GetUserMotorcycles(username)
+	money, _ := r.orchestrator.GetUserMoney(c.Request.Context(), username)
+	owned, err := r.orchestrator.GetUserMotorcycles(c.Request.Context(), username)
 	if err != nil {
 		owned = make([]*services.Ownership, 0)
 	}
-	not_owned, err := r.orchestrator.GetRemainingMotorcycles(username)
+	not_owned, err := r.orchestrator.GetRemainingMotorcycles(c.Request.Context(), username)
 	if err != nil {
 		not_owned = make([]*services.Motorcycle, 0)
 	}
+	inventory, err := r.orchestrator.GetInventory(c.Request.Context(), username)
+	if err != nil {
+		inventory = make([]*services.Item, 0)
+	}
+	parts, err := r.orchestrator.GetParts(c.Request.Context())
+	if err != nil {
+		parts = make([]*services.Part, 0)
+	}
+	paints, err := r.orchestrator.GetPaints(c.Request.Context())
+	if err != nil {
+		paints = make([]*services.Paint, 0)
+	}
+	// Forecast to pick the motorcycle suited to the weather
+	tracks, err := r.orchestrator.GetTracks(c.Request.Context())
+	if err != nil {
+		tracks = make([]*services.Track, 0)
+	}
 
 	c.HTML(http.StatusOK, "garage.html", gin.H{
 		"money":     money,
 		"not_owned": not_owned,
 		"owned":     owned,
+		"inventory": inventory,
+		"parts":     parts,
+		"paints":    paints,
+		"tracks":    tracks,
 	})
 }
 
@@ -132,7 +209,7 @@ func (r *MyRoutes) GarageBuyRoute(c *gin.Context) {
 		return
 	}
 
-	_ = r.orchestrator.BuyMotorcycle(username, id)
+	_ = r.orchestrator.BuyMotorcycle(c.Request.Context(), username, id)
 
 	c.Redirect(http.StatusSeeOther, "/private/garage")
 }
@@ -146,7 +223,86 @@ func (r *MyRoutes) GarageUpgradeRoute(c *gin.Context) {
 		return
 	}
 
-	_ = r.orchestrator.UpgradeMotorcycle(username, id)
+	_ = r.orchestrator.UpgradeMotorcycle(c.Request.Context(), username, id, c.PostForm("stat"))
+
+	c.Redirect(http.StatusSeeOther, "/private/garage")
+}
+
+func (r *MyRoutes) GarageRepairRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.RepairMotorcycle(c.Request.Context(), username, id)
+
+	c.Redirect(http.StatusSeeOther, "/private/garage")
+}
+
+func (r *MyRoutes) GarageCustomizeRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	// Fields left empty are not changed
+	paint_id, _ := strconv.Atoi(c.PostForm("paint_id"))
+	race_number, _ := strconv.Atoi(c.PostForm("race_number"))
+
+	_ = r.orchestrator.CustomizeMotorcycle(c.Request.Context(), username, id, paint_id, race_number, c.PostForm("rider_name"))
+
+	c.Redirect(http.StatusSeeOther, "/private/garage")
+}
+
+func (r *MyRoutes) GaragePartBuyRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.BuyPart(c.Request.Context(), username, id)
+
+	c.Redirect(http.StatusSeeOther, "/private/garage")
+}
+
+func (r *MyRoutes) GaragePartEquipRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	motorcycle_id, err := strconv.Atoi(c.PostForm("motorcycle_id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.EquipPart(c.Request.Context(), username, id, motorcycle_id)
+
+	c.Redirect(http.StatusSeeOther, "/private/garage")
+}
+
+func (r *MyRoutes) GaragePartUnequipRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.UnequipPart(c.Request.Context(), username, id)
 
 	c.Redirect(http.StatusSeeOther, "/private/garage")
 }
@@ -159,7 +315,7 @@ func (r *MyRoutes) RaceStartRoute(c *gin.Context) {
 		return
 	}
 
-	err = r.orchestrator.StartMatchmaking(username, id)
+	err = r.orchestrator.StartMatchmaking(c.Request.Context(), username, id)
 	if err != nil {
 		c.Redirect(http.StatusFound, "/private")
 		return
@@ -168,24 +324,906 @@ func (r *MyRoutes) RaceStartRoute(c *gin.Context) {
 	c.Redirect(http.StatusSeeOther, "/private/garage")
 }
 
-func (r *MyRoutes) LeaderboardRoute(c *gin.Context) {
-	leaderboard, err := r.orchestrator.GetFullLeaderboard()
+func (r *MyRoutes) LobbiesRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	lobbies, err := r.orchestrator.GetPlayerLobbies(c.Request.Context(), username)
 	if err != nil {
-		leaderboard = make([]*services.LeaderboardPosition, 0)
+		lobbies = make([]*services.Lobby, 0)
+	}
+	tracks, err := r.orchestrator.GetTracks(c.Request.Context())
+	if err != nil {
+		tracks = make([]*services.Track, 0)
+	}
+	owned, err := r.orchestrator.GetUserMotorcycles(c.Request.Context(), username)
+	if err != nil {
+		owned = make([]*services.Ownership, 0)
 	}
 
-	c.HTML(http.StatusOK, "leaderboard.html", gin.H{
-		"leaderboard": leaderboard,
+	c.HTML(http.StatusOK, "lobbies.html", gin.H{
+		"username": username,
+		"lobbies":  lobbies,
+		"tracks":   tracks,
+		"owned":    owned,
 	})
 }
 
-func (r *MyRoutes) RaceHistoryRoute(c *gin.Context) {
+func (r *MyRoutes) LobbyRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+	code := strings.ToUpper(c.Param("code"))
+
+	// A lobby is gone once its race is completed
+	lobby, _ := r.orchestrator.GetLobby(c.Request.Context(), code)
+	owned, err := r.orchestrator.GetUserMotorcycles(c.Request.Context(), username)
+	if err != nil {
+		owned = make([]*services.Ownership, 0)
+	}
+
+	joined := false
+	if lobby != nil {
+		for _, e := range lobby.Entries {
+			joined = joined || e.Username == username
+		}
+	}
+
+	c.HTML(http.StatusOK, "lobby.html", gin.H{
+		"username": username,
+		"code":     code,
+		"lobby":    lobby,
+		"joined":   joined,
+		"owned":    owned,
+	})
+}
+
+func (r *MyRoutes) LobbyCreateRoute(c *gin.Context) {
 	username := sessions.Default(c).Get("username").(string)
-	results, err := r.orchestrator.GetHistory(username)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	track, err := strconv.Atoi(c.PostForm("track"))
 	if err != nil {
 		c.Redirect(http.StatusFound, "/private")
 		return
 	}
 
-	c.HTML(http.StatusOK, "race_history.html", gin.H{"results": results})
+	lobby, err := r.orchestrator.CreateLobby(c.Request.Context(), username, id, track, c.PostForm("friendly") != "")
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/private/lobbies")
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/private/lobbies/"+lobby.Code)
+}
+
+func (r *MyRoutes) LobbyJoinRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+	code := strings.ToUpper(strings.TrimSpace(c.PostForm("code")))
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.JoinLobby(c.Request.Context(), username, code, id)
+
+	c.Redirect(http.StatusSeeOther, "/private/lobbies/"+code)
+}
+
+func (r *MyRoutes) LobbyLeaveRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	_ = r.orchestrator.LeaveLobby(c.Request.Context(), username, c.PostForm("code"))
+
+	c.Redirect(http.StatusSeeOther, "/private/lobbies")
+}
+
+func (r *MyRoutes) LobbyStartRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+	code := c.PostForm("code")
+
+	err := r.orchestrator.StartLobbyRace(c.Request.Context(), username, code)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/private/lobbies/"+code)
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, "/private/history")
+}
+
+// Track choices shown in the form creating a championship
+const championshipFormRounds = 5
+
+func (r *MyRoutes) ChampionshipsRoute(c *gin.Context) {
+	championships, err := r.orchestrator.GetChampionships(c.Request.Context())
+	if err != nil {
+		championships = make([]*services.Championship, 0)
+	}
+	tracks, err := r.orchestrator.GetTracks(c.Request.Context())
+	if err != nil {
+		tracks = make([]*services.Track, 0)
+	}
+
+	rounds := make([]int, 0, championshipFormRounds)
+	for i := 1; i <= championshipFormRounds; i++ {
+		rounds = append(rounds, i)
+	}
+
+	c.HTML(http.StatusOK, "championships.html", gin.H{
+		"championships": championships,
+		"tracks":        tracks,
+		"rounds":        rounds,
+	})
+}
+
+func (r *MyRoutes) ChampionshipRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private/championships")
+		return
+	}
+
+	championship, err := r.orchestrator.GetChampionship(c.Request.Context(), id)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private/championships")
+		return
+	}
+	schedule, err := r.orchestrator.GetChampionshipSchedule(c.Request.Context(), id)
+	if err != nil {
+		schedule = make([]*services.ChampionshipRound, 0)
+	}
+	standings, err := r.orchestrator.GetChampionshipStandings(c.Request.Context(), id)
+	if err != nil {
+		standings = make([]*services.ChampionshipStanding, 0)
+	}
+	owned, err := r.orchestrator.GetUserMotorcycles(c.Request.Context(), username)
+	if err != nil {
+		owned = make([]*services.Ownership, 0)
+	}
+
+	registered := false
+	for _, s := range standings {
+		registered = registered || s.Username == username
+	}
+
+	c.HTML(http.StatusOK, "championship.html", gin.H{
+		"username":     username,
+		"championship": championship,
+		"schedule":     schedule,
+		"standings":    standings,
+		"registered":   registered,
+		"owned":        owned,
+	})
+}
+
+func (r *MyRoutes) ChampionshipCreateRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	// Rounds left empty in the form are skipped
+	var tracks []int
+	for _, t := range c.PostFormArray("track") {
+		if track, err := strconv.Atoi(t); err == nil {
+			tracks = append(tracks, track)
+		}
+	}
+	starts_in, err := strconv.Atoi(c.PostForm("starts_in"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	interval, err := strconv.Atoi(c.PostForm("interval"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	max_entrants, err := strconv.Atoi(c.PostForm("max_entrants"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	championship, err := r.orchestrator.CreateChampionship(c.Request.Context(), username, c.PostForm("name"), tracks, time.Now().Add(time.Duration(starts_in)*time.Minute), interval, max_entrants)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/private/championships")
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/private/championships/%d", championship.Id))
+}
+
+func (r *MyRoutes) ChampionshipRegisterRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	motorcycle, err := strconv.Atoi(c.PostForm("motorcycle"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.RegisterChampionship(c.Request.Context(), username, id, motorcycle)
+
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/private/championships/%d", id))
+}
+
+func (r *MyRoutes) timeTrialPage(c *gin.Context, username string, track int, result *services.TimeTrialResult) {
+	tracks, err := r.orchestrator.GetTracks(c.Request.Context())
+	if err != nil {
+		tracks = make([]*services.Track, 0)
+	}
+	owned, err := r.orchestrator.GetUserMotorcycles(c.Request.Context(), username)
+	if err != nil {
+		owned = make([]*services.Ownership, 0)
+	}
+
+	// First track if none is selected
+	if track == 0 && len(tracks) > 0 {
+		track = tracks[0].Id
+	}
+	records, err := r.orchestrator.GetTimeTrialLeaderboard(c.Request.Context(), track)
+	if err != nil {
+		records = make([]*services.TimeTrialRecord, 0)
+	}
+
+	c.HTML(http.StatusOK, "timetrial.html", gin.H{
+		"username": username,
+		"track":    track,
+		"tracks":   tracks,
+		"owned":    owned,
+		"records":  records,
+		"result":   result,
+	})
+}
+
+func (r *MyRoutes) TimeTrialRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+	track, _ := strconv.Atoi(c.Query("track"))
+
+	r.timeTrialPage(c, username, track, nil)
+}
+
+func (r *MyRoutes) TimeTrialRunRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	track, err := strconv.Atoi(c.PostForm("track"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	result, err := r.orchestrator.RunTimeTrial(c.Request.Context(), username, track, id)
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, fmt.Sprintf("/private/timetrial?track=%d", track))
+		return
+	}
+
+	// The lap is shown next to the ghost and the record it was raced against
+	r.timeTrialPage(c, username, track, result)
+}
+
+// Rows of a leaderboard page and rows shown above and below the logged user
+const (
+	leaderboardPageSize = 20
+	leaderboardRadius   = 2
+)
+
+func (r *MyRoutes) LeaderboardRoute(c *gin.Context) {
+	// Standings of a past season if selected, a page of the current season otherwise
+	season_id, _ := strconv.Atoi(c.Query("season"))
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	var leaderboard []*services.LeaderboardPosition
+	total := 0
+	if season_id > 0 {
+		leaderboard, err = r.orchestrator.GetSeasonStandings(c.Request.Context(), season_id)
+	} else {
+		leaderboard, total, err = r.orchestrator.GetLeaderboardPage(c.Request.Context(), (page-1)*leaderboardPageSize, leaderboardPageSize)
+	}
+	if err != nil {
+		leaderboard = make([]*services.LeaderboardPosition, 0)
+	}
+	pages := (total + leaderboardPageSize - 1) / leaderboardPageSize
+
+	// The logged user sees the positions close to their own
+	username := ""
+	around := make([]*services.LeaderboardPosition, 0)
+	if isLoggedIn(c) {
+		username = sessions.Default(c).Get("username").(string)
+		if season_id == 0 {
+			if around, _, err = r.orchestrator.GetLeaderboardAround(c.Request.Context(), username, leaderboardRadius); err != nil {
+				around = make([]*services.LeaderboardPosition, 0)
+			}
+		}
+	}
+
+	r.orchestrator.AddClanTags(c.Request.Context(), leaderboard, around)
+
+	current, _ := r.orchestrator.GetCurrentSeason(c.Request.Context())
+	past, err := r.orchestrator.GetPastSeasons(c.Request.Context())
+	if err != nil {
+		past = make([]*services.Season, 0)
+	}
+	recent, err := r.orchestrator.GetRecentRaces(c.Request.Context())
+	if err != nil {
+		recent = make([]*services.RaceResult, 0)
+	}
+
+	c.HTML(http.StatusOK, "leaderboard.html", gin.H{
+		"leaderboard": leaderboard,
+		"around":      around,
+		"username":    username,
+		"page":        page,
+		"pages":       pages,
+		"prev_page":   page - 1,
+		"next_page":   page + 1,
+		"recent":      recent,
+		"season_id":   season_id,
+		"current":     current,
+		"past":        past,
+	})
+}
+
+func (r *MyRoutes) BoardsRoute(c *gin.Context) {
+	// Board of a single track or motorcycle model, the first one available if not selected
+	board := c.DefaultQuery("board", "track")
+	if board != "track" && board != "motorcycle" {
+		board = "track"
+	}
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	names, err := r.orchestrator.GetBoardNames(c.Request.Context(), board)
+	if err != nil {
+		names = make([]string, 0)
+	}
+	name := c.Query("name")
+	if name == "" && len(names) > 0 {
+		name = names[0]
+	}
+
+	leaderboard := make([]*services.LeaderboardPosition, 0)
+	total := 0
+	if name != "" {
+		leaderboard, total, err = r.orchestrator.GetBoard(c.Request.Context(), board, name, (page-1)*leaderboardPageSize, leaderboardPageSize)
+		if err != nil {
+			leaderboard = make([]*services.LeaderboardPosition, 0)
+		}
+	}
+
+	username := ""
+	if isLoggedIn(c) {
+		username = sessions.Default(c).Get("username").(string)
+	}
+
+	r.orchestrator.AddClanTags(c.Request.Context(), leaderboard)
+
+	c.HTML(http.StatusOK, "boards.html", gin.H{
+		"board":       board,
+		"name":        name,
+		"names":       names,
+		"leaderboard": leaderboard,
+		"username":    username,
+		"page":        page,
+		"pages":       (total + leaderboardPageSize - 1) / leaderboardPageSize,
+		"prev_page":   page - 1,
+		"next_page":   page + 1,
+	})
+}
+
+func (r *MyRoutes) RaceHistoryRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+	results, err := r.orchestrator.GetHistory(c.Request.Context(), username)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	c.HTML(http.StatusOK, "race_history.html", gin.H{"results": results})
+}
+
+func (r *MyRoutes) FriendsRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	friends, err := r.orchestrator.GetFriends(c.Request.Context(), username)
+	if err != nil {
+		friends = &services.Friends{}
+	}
+	leaderboard, err := r.orchestrator.GetFriendsLeaderboard(c.Request.Context(), username)
+	if err != nil {
+		leaderboard = make([]*services.LeaderboardPosition, 0)
+	}
+	r.orchestrator.AddClanTags(c.Request.Context(), leaderboard)
+
+	c.HTML(http.StatusOK, "friends.html", gin.H{
+		"username":    username,
+		"friends":     friends,
+		"leaderboard": leaderboard,
+	})
+}
+
+func (r *MyRoutes) FriendRequestRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	_ = r.orchestrator.SendFriendRequest(c.Request.Context(), username, c.PostForm("friend"))
+
+	c.Redirect(http.StatusSeeOther, "/private/friends")
+}
+
+func (r *MyRoutes) FriendAcceptRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	_ = r.orchestrator.AcceptFriendRequest(c.Request.Context(), username, c.PostForm("friend"))
+
+	c.Redirect(http.StatusSeeOther, "/private/friends")
+}
+
+func (r *MyRoutes) FriendDeclineRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	_ = r.orchestrator.DeclineFriendRequest(c.Request.Context(), username, c.PostForm("friend"))
+
+	c.Redirect(http.StatusSeeOther, "/private/friends")
+}
+
+func (r *MyRoutes) FriendRemoveRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	_ = r.orchestrator.RemoveFriend(c.Request.Context(), username, c.PostForm("friend"))
+
+	c.Redirect(http.StatusSeeOther, "/private/friends")
+}
+
+func (r *MyRoutes) ClansRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	// Players without a clan see the creation form instead
+	clan, _ := r.orchestrator.GetPlayerClan(c.Request.Context(), username)
+	invites, err := r.orchestrator.GetClanInvites(c.Request.Context(), username)
+	if err != nil {
+		invites = make([]*services.Clan, 0)
+	}
+	leaderboard, err := r.orchestrator.GetClanLeaderboard(c.Request.Context())
+	if err != nil {
+		leaderboard = make([]*services.Clan, 0)
+	}
+
+	c.HTML(http.StatusOK, "clans.html", gin.H{
+		"username":    username,
+		"clan":        clan,
+		"invites":     invites,
+		"leaderboard": leaderboard,
+	})
+}
+
+func (r *MyRoutes) ClanRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private/clans")
+		return
+	}
+
+	clan, members, transactions, err := r.orchestrator.GetClan(c.Request.Context(), id)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private/clans")
+		return
+	}
+	money, _ := r.orchestrator.GetUserMoney(c.Request.Context(), username)
+
+	member, owner := false, false
+	for _, m := range members {
+		if m.Username == username {
+			member = true
+			owner = m.Role == "owner"
+		}
+	}
+
+	c.HTML(http.StatusOK, "clan.html", gin.H{
+		"username":     username,
+		"clan":         clan,
+		"members":      members,
+		"transactions": transactions,
+		"member":       member,
+		"owner":        owner,
+		"money":        money,
+	})
+}
+
+func (r *MyRoutes) ClanCreateRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	clan, err := r.orchestrator.CreateClan(c.Request.Context(), username, strings.TrimSpace(c.PostForm("name")), strings.ToUpper(strings.TrimSpace(c.PostForm("tag"))))
+	if err != nil {
+		c.Redirect(http.StatusSeeOther, "/private/clans")
+		return
+	}
+
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/private/clans/%d", clan.Id))
+}
+
+func (r *MyRoutes) ClanInviteRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.InviteToClan(c.Request.Context(), username, strings.TrimSpace(c.PostForm("player")))
+
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/private/clans/%d", id))
+}
+
+func (r *MyRoutes) ClanAcceptRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.AcceptClanInvite(c.Request.Context(), username, id)
+
+	c.Redirect(http.StatusSeeOther, "/private/clans")
+}
+
+func (r *MyRoutes) ClanDeclineRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.DeclineClanInvite(c.Request.Context(), username, id)
+
+	c.Redirect(http.StatusSeeOther, "/private/clans")
+}
+
+func (r *MyRoutes) ClanLeaveRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	_ = r.orchestrator.LeaveClan(c.Request.Context(), username)
+
+	c.Redirect(http.StatusSeeOther, "/private/clans")
+}
+
+func (r *MyRoutes) ClanDepositRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	amount, err := strconv.Atoi(c.PostForm("amount"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.DepositToClan(c.Request.Context(), username, amount)
+
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/private/clans/%d", id))
+}
+
+func (r *MyRoutes) ClanWithdrawRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	amount, err := strconv.Atoi(c.PostForm("amount"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.WithdrawFromClan(c.Request.Context(), username, c.PostForm("member"), amount)
+
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/private/clans/%d", id))
+}
+
+func (r *MyRoutes) NotificationsRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	notifications, err := r.orchestrator.GetNotifications(c.Request.Context(), username)
+	if err != nil {
+		notifications = make([]*services.Notification, 0)
+	}
+	unread, _ := r.orchestrator.GetUnreadCount(c.Request.Context(), username)
+
+	c.HTML(http.StatusOK, "notifications.html", gin.H{
+		"username":      username,
+		"notifications": notifications,
+		"unread":        unread,
+		"push":          notificationsPush(),
+	})
+}
+
+func (r *MyRoutes) NotificationReadRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.MarkNotificationRead(c.Request.Context(), username, id)
+
+	// Opening a notification leads to its page, only pages of this site are followed
+	link := c.PostForm("link")
+	if !strings.HasPrefix(link, "/") || strings.HasPrefix(link, "//") {
+		link = "/private/notifications"
+	}
+
+	c.Redirect(http.StatusSeeOther, link)
+}
+
+func (r *MyRoutes) NotificationsReadAllRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	_ = r.orchestrator.MarkAllNotificationsRead(c.Request.Context(), username)
+
+	c.Redirect(http.StatusSeeOther, "/private/notifications")
+}
+
+func (r *MyRoutes) NotificationsWatchRoute(c *gin.Context) {
+	// Server-sent events with the new notifications of the user, closed when the client leaves
+
+	if !notificationsPush() {
+		c.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	username := sessions.Default(c).Get("username").(string)
+
+	notifications, err := r.orchestrator.WatchNotifications(c.Request.Context(), username)
+	if err != nil {
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.Stream(func(w io.Writer) bool {
+		notification, ok := <-notifications
+		if !ok {
+			return false
+		}
+
+		c.SSEvent("notification", notification)
+		return true
+	})
+}
+
+func (r *MyRoutes) MarketRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	money, _ := r.orchestrator.GetUserMoney(c.Request.Context(), username)
+	listings, err := r.orchestrator.GetListings(c.Request.Context())
+	if err != nil {
+		listings = make([]*services.Listing, 0)
+	}
+	owned, err := r.orchestrator.GetUserMotorcycles(c.Request.Context(), username)
+	if err != nil {
+		owned = make([]*services.Ownership, 0)
+	}
+
+	c.HTML(http.StatusOK, "market.html", gin.H{
+		"username": username,
+		"money":    money,
+		"listings": listings,
+		"owned":    owned,
+	})
+}
+
+func (r *MyRoutes) MarketListRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	price, err := strconv.Atoi(c.PostForm("price"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	duration, err := strconv.Atoi(c.PostForm("duration"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.CreateListing(c.Request.Context(), username, id, price, duration)
+
+	c.Redirect(http.StatusSeeOther, "/private/market")
+}
+
+func (r *MyRoutes) MarketBuyRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.BuyListing(c.Request.Context(), username, id)
+
+	c.Redirect(http.StatusSeeOther, "/private/market")
+}
+
+func (r *MyRoutes) MarketCancelRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.CancelListing(c.Request.Context(), username, id)
+
+	c.Redirect(http.StatusSeeOther, "/private/market")
+}
+
+func (r *MyRoutes) AuctionsRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	money, _ := r.orchestrator.GetUserMoney(c.Request.Context(), username)
+	auctions, err := r.orchestrator.GetAuctions(c.Request.Context())
+	if err != nil {
+		auctions = make([]*services.Auction, 0)
+	}
+	owned, err := r.orchestrator.GetUserMotorcycles(c.Request.Context(), username)
+	if err != nil {
+		owned = make([]*services.Ownership, 0)
+	}
+
+	// No user has an empty username, so every motorcycle is returned
+	motorcycles := make([]*services.Motorcycle, 0)
+	admin := r.orchestrator.IsAdmin(c.Request.Context(), username)
+	if admin {
+		if all, err := r.orchestrator.GetRemainingMotorcycles(c.Request.Context(), ""); err == nil {
+			motorcycles = all
+		}
+	}
+
+	c.HTML(http.StatusOK, "auctions.html", gin.H{
+		"money":       money,
+		"auctions":    auctions,
+		"owned":       owned,
+		"admin":       admin,
+		"motorcycles": motorcycles,
+	})
+}
+
+func (r *MyRoutes) AuctionRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private/auctions")
+		return
+	}
+
+	auction, err := r.orchestrator.GetAuction(c.Request.Context(), id)
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private/auctions")
+		return
+	}
+
+	money, _ := r.orchestrator.GetUserMoney(c.Request.Context(), username)
+
+	c.HTML(http.StatusOK, "auction.html", gin.H{
+		"username": username,
+		"money":    money,
+		"auction":  auction,
+	})
+}
+
+func (r *MyRoutes) AuctionWatchRoute(c *gin.Context) {
+	// Server-sent events with the bids of the auction, closed when the auction is settled or the client leaves
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	bids, err := r.orchestrator.WatchAuction(c.Request.Context(), id)
+	if err != nil {
+		c.AbortWithStatus(http.StatusServiceUnavailable)
+		return
+	}
+
+	c.Stream(func(w io.Writer) bool {
+		bid, ok := <-bids
+		if !ok {
+			return false
+		}
+
+		c.SSEvent("bid", bid)
+		return true
+	})
+}
+
+func (r *MyRoutes) AuctionCreateRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	reserve, err := strconv.Atoi(c.PostForm("reserve"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	duration, err := strconv.Atoi(c.PostForm("duration"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	if c.PostForm("house") != "" {
+		level, err := strconv.Atoi(c.PostForm("level"))
+		if err != nil {
+			c.Redirect(http.StatusFound, "/private")
+			return
+		}
+
+		_ = r.orchestrator.CreateHouseAuction(c.Request.Context(), username, id, level, reserve, duration)
+	} else {
+		_ = r.orchestrator.CreateAuction(c.Request.Context(), username, id, reserve, duration)
+	}
+
+	c.Redirect(http.StatusSeeOther, "/private/auctions")
+}
+
+func (r *MyRoutes) AuctionBidRoute(c *gin.Context) {
+	username := sessions.Default(c).Get("username").(string)
+
+	id, err := strconv.Atoi(c.PostForm("id"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+	amount, err := strconv.Atoi(c.PostForm("amount"))
+	if err != nil {
+		c.Redirect(http.StatusFound, "/private")
+		return
+	}
+
+	_ = r.orchestrator.PlaceBid(c.Request.Context(), username, id, amount)
+
+	c.Redirect(http.StatusSeeOther, fmt.Sprintf("/private/auctions/%d", id))
 }
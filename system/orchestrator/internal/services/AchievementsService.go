@@ -0,0 +1,180 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"orchestrator/telemetry"
+
+	pb "orchestrator/proto"
+
+	"google.golang.org/grpc"
+)
+
+type Achievement struct {
+	Id          int
+	Name        string
+	Description string
+	Reward      int
+	Unlocked    bool
+	UnlockedAt  time.Time
+	Progress    int
+	Threshold   int
+}
+
+type AchievementEvent struct {
+	Type          string // race, purchase or upgrade
+	TrackName     string
+	Position      int
+	FullyUpgraded bool
+}
+
+// Reward of an unlocked badge not paid yet
+type PendingReward struct {
+	Username string
+	BadgeId  int
+	Name     string
+	Reward   int
+}
+
+type Achievements interface {
+	StillAlive
+	GetAchievements(ctx context.Context, username string) ([]*Achievement, error)
+	RecordEvent(ctx context.Context, username string, event *AchievementEvent) ([]*Achievement, error)
+	GetPendingRewards(ctx context.Context) ([]*PendingReward, error)
+	ClaimReward(ctx context.Context, username string, badge_id int) (bool, error)
+	ReleaseReward(ctx context.Context, username string, badge_id int) error
+}
+
+// gRPC implementation of Achievements interface
+type AchievementsService struct {
+	conn *grpc.ClientConn
+}
+
+func NewAchievementsService(conn *grpc.ClientConn) *AchievementsService {
+	return &AchievementsService{conn: conn}
+}
+
+func (s *AchievementsService) StillAlive(ctx context.Context) bool {
+	return StillAliveHandle(ctx, s.conn)
+}
+
+func (s *AchievementsService) Close() {
+	s.conn.Close()
+}
+
+// Server-side stream of achievements
+type achievementStream interface {
+	Recv() (*pb.Achievement, error)
+	Context() context.Context
+}
+
+func receiveAchievements(stream achievementStream) ([]*Achievement, error) {
+	var achievements []*Achievement
+	for {
+		a, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(stream.Context(), "receiveAchievements failed", "error", err)
+			return nil, err
+		}
+
+		achievements = append(achievements, &Achievement{
+			Id:          int(a.Id),
+			Name:        a.Name,
+			Description: a.Description,
+			Reward:      int(a.Reward),
+			Unlocked:    a.Unlocked,
+			UnlockedAt:  a.UnlockedAt.AsTime(),
+			Progress:    int(a.Progress),
+			Threshold:   int(a.Threshold),
+		})
+	}
+
+	return achievements, nil
+}
+
+func (s *AchievementsService) GetAchievements(ctx context.Context, username string) ([]*Achievement, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Achievements.GetAchievements"))
+	defer cancel()
+
+	r, err := pb.NewAchievementsClient(s.conn).GetAchievements(ctx, &pb.PlayerUsername{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	return receiveAchievements(r)
+}
+
+func (s *AchievementsService) RecordEvent(ctx context.Context, username string, event *AchievementEvent) ([]*Achievement, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Achievements.RecordEvent"))
+	defer cancel()
+
+	value, ok := pb.AchievementEventType_value[strings.ToUpper(event.Type)]
+	if !ok || value == int32(pb.AchievementEventType_ACHIEVEMENT_EVENT_UNSPECIFIED) {
+		return nil, errors.New("unknown achievement event")
+	}
+
+	r, err := pb.NewAchievementsClient(s.conn).RecordEvent(ctx, &pb.AchievementEvent{
+		Username:      username,
+		Type:          pb.AchievementEventType(value),
+		TrackName:     event.TrackName,
+		Position:      int32(event.Position),
+		FullyUpgraded: event.FullyUpgraded,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return receiveAchievements(r)
+}
+
+func (s *AchievementsService) GetPendingRewards(ctx context.Context) ([]*PendingReward, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Achievements.GetPendingRewards"))
+	defer cancel()
+
+	r, err := pb.NewAchievementsClient(s.conn).GetPendingRewards(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var rewards []*PendingReward
+	for {
+		reward, err := r.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetPendingRewards failed", "error", err)
+			return nil, err
+		}
+
+		rewards = append(rewards, &PendingReward{Username: reward.Username, BadgeId: int(reward.BadgeId), Name: reward.Name, Reward: int(reward.Reward)})
+	}
+
+	return rewards, nil
+}
+
+func (s *AchievementsService) ClaimReward(ctx context.Context, username string, badge_id int) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Achievements.ClaimReward"))
+	defer cancel()
+
+	r, err := pb.NewAchievementsClient(s.conn).ClaimReward(ctx, &pb.PendingReward{Username: username, BadgeId: int32(badge_id)})
+	if err != nil {
+		return false, err
+	}
+
+	return r.Claimed, nil
+}
+
+func (s *AchievementsService) ReleaseReward(ctx context.Context, username string, badge_id int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Achievements.ReleaseReward"))
+	defer cancel()
+
+	_, err := pb.NewAchievementsClient(s.conn).ReleaseReward(ctx, &pb.PendingReward{Username: username, BadgeId: int32(badge_id)})
+	return err
+}
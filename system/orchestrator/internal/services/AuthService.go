@@ -3,15 +3,26 @@ package services
 import (
 	"context"
 	pb "orchestrator/proto"
-	"time"
+	"orchestrator/telemetry"
 
 	"google.golang.org/grpc"
 )
 
 type Auth interface {
 	StillAlive
-	Login(username string, password string) (bool, error)
-	Register(username, password, email, phone string) (bool, error)
+	Login(ctx context.Context, username string, password string) (bool, error)
+	Register(ctx context.Context, username, password, email, phone string) (bool, error)
+	SendFriendRequest(ctx context.Context, username string, friend string) error
+	AcceptFriendRequest(ctx context.Context, username string, friend string) error
+	DeclineFriendRequest(ctx context.Context, username string, friend string) error
+	RemoveFriend(ctx context.Context, username string, friend string) error
+	GetFriends(ctx context.Context, username string) (*Friends, error)
+}
+
+type Friends struct {
+	Friends  []string
+	Received []string // pending requests sent by others
+	Sent     []string // pending requests sent by the user
 }
 
 // gRPC implementation of Auth interface
@@ -23,16 +34,16 @@ func NewAuthService(conn *grpc.ClientConn) *AuthService {
 	return &AuthService{conn: conn}
 }
 
-func (s *AuthService) StillAlive() bool {
-	return StillAliveHandle(s.conn)
+func (s *AuthService) StillAlive(ctx context.Context) bool {
+	return StillAliveHandle(ctx, s.conn)
 }
 
 func (s *AuthService) Close() {
 	s.conn.Close()
 }
 
-func (s *AuthService) Login(username string, password string) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *AuthService) Login(ctx context.Context, username string, password string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Authentication.Login"))
 	defer cancel()
 
 	res, err := pb.NewAuthenticationClient(s.conn).Login(ctx, &pb.PlayerCredentials{Username: username, Password: password})
@@ -43,8 +54,8 @@ func (s *AuthService) Login(username string, password string) (bool, error) {
 	return res.Result, nil
 }
 
-func (s *AuthService) Register(username, password, email, phone string) (bool, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *AuthService) Register(ctx context.Context, username, password, email, phone string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Authentication.Register"))
 	defer cancel()
 
 	res, err := pb.NewAuthenticationClient(s.conn).Register(ctx, &pb.PlayerDetails{Username: username, Password: password, Email: email, Phone: phone})
@@ -54,3 +65,47 @@ func (s *AuthService) Register(username, password, email, phone string) (bool, e
 
 	return res.Result, nil
 }
+
+func (s *AuthService) SendFriendRequest(ctx context.Context, username string, friend string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Authentication.SendFriendRequest"))
+	defer cancel()
+
+	_, err := pb.NewAuthenticationClient(s.conn).SendFriendRequest(ctx, &pb.FriendRequest{Username: username, Friend: friend})
+	return err
+}
+
+func (s *AuthService) AcceptFriendRequest(ctx context.Context, username string, friend string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Authentication.AcceptFriendRequest"))
+	defer cancel()
+
+	_, err := pb.NewAuthenticationClient(s.conn).AcceptFriendRequest(ctx, &pb.FriendRequest{Username: username, Friend: friend})
+	return err
+}
+
+func (s *AuthService) DeclineFriendRequest(ctx context.Context, username string, friend string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Authentication.DeclineFriendRequest"))
+	defer cancel()
+
+	_, err := pb.NewAuthenticationClient(s.conn).DeclineFriendRequest(ctx, &pb.FriendRequest{Username: username, Friend: friend})
+	return err
+}
+
+func (s *AuthService) RemoveFriend(ctx context.Context, username string, friend string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Authentication.RemoveFriend"))
+	defer cancel()
+
+	_, err := pb.NewAuthenticationClient(s.conn).RemoveFriend(ctx, &pb.FriendRequest{Username: username, Friend: friend})
+	return err
+}
+
+func (s *AuthService) GetFriends(ctx context.Context, username string) (*Friends, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Authentication.GetFriends"))
+	defer cancel()
+
+	res, err := pb.NewAuthenticationClient(s.conn).GetFriends(ctx, &pb.PlayerUsername{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Friends{Friends: res.Friends, Received: res.Received, Sent: res.Sent}, nil
+}
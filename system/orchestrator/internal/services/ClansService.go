@@ -0,0 +1,336 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"orchestrator/telemetry"
+
+	pb "orchestrator/proto"
+
+	"google.golang.org/grpc"
+)
+
+type Clan struct {
+	Id       int
+	Name     string
+	Tag      string
+	Treasury int
+	Points   int
+	Races    int
+	Wins     int
+	Members  int
+	Position int
+}
+
+type ClanMember struct {
+	Username string
+	Role     string // owner or member
+	Points   int
+	Races    int
+	Wins     int
+	JoinedAt time.Time
+}
+
+type ClanTransaction struct {
+	Username string
+	Amount   int // negative for withdrawals
+	Time     time.Time
+}
+
+type ClanRaceResult struct {
+	Username string
+	Points   int
+	Won      bool
+}
+
+type Clans interface {
+	StillAlive
+	CreateClan(ctx context.Context, owner string, name string, tag string) (*Clan, error)
+	GetClan(ctx context.Context, clan_id int) (*Clan, error)
+	GetPlayerClan(ctx context.Context, username string) (*Clan, error)
+	GetMembers(ctx context.Context, clan_id int) ([]*ClanMember, error)
+	GetTransactions(ctx context.Context, clan_id int) ([]*ClanTransaction, error)
+	GetClanLeaderboard(ctx context.Context) ([]*Clan, error)
+	InviteToClan(ctx context.Context, inviter string, username string) error
+	GetInvites(ctx context.Context, username string) ([]*Clan, error)
+	AcceptInvite(ctx context.Context, username string, clan_id int) error
+	DeclineInvite(ctx context.Context, username string, clan_id int) error
+	LeaveClan(ctx context.Context, username string) error
+	Deposit(ctx context.Context, username string, amount int) error
+	Withdraw(ctx context.Context, owner string, member string, amount int) (int, error)
+	CancelWithdrawal(ctx context.Context, TransactionId int) error
+	GetClanTags(ctx context.Context, usernames []string) (map[string]string, error)
+	RecordRaceResults(ctx context.Context, results []*ClanRaceResult) error
+}
+
+// gRPC implementation of Clans interface
+type ClansService struct {
+	conn *grpc.ClientConn
+}
+
+func NewClansService(conn *grpc.ClientConn) *ClansService {
+	return &ClansService{conn: conn}
+}
+
+func (s *ClansService) StillAlive(ctx context.Context) bool {
+	return StillAliveHandle(ctx, s.conn)
+}
+
+func (s *ClansService) Close() {
+	s.conn.Close()
+}
+
+func clanFromInfo(p *pb.ClanInfo) *Clan {
+	return &Clan{
+		Id:       int(p.Id),
+		Name:     p.Name,
+		Tag:      p.Tag,
+		Treasury: int(p.Treasury),
+		Points:   int(p.Points),
+		Races:    int(p.Races),
+		Wins:     int(p.Wins),
+		Members:  int(p.Members),
+		Position: int(p.Position),
+	}
+}
+
+// Server-side stream of clans
+type clanStream interface {
+	Recv() (*pb.ClanInfo, error)
+	Context() context.Context
+}
+
+func receiveClans(stream clanStream) ([]*Clan, error) {
+	var clans []*Clan
+	for {
+		c, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(stream.Context(), "receiveClans failed", "error", err)
+			return nil, err
+		}
+
+		clans = append(clans, clanFromInfo(c))
+	}
+
+	return clans, nil
+}
+
+func (s *ClansService) CreateClan(ctx context.Context, owner string, name string, tag string) (*Clan, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.CreateClan"))
+	defer cancel()
+
+	p, err := pb.NewClansClient(s.conn).CreateClan(ctx, &pb.ClanSettings{Owner: owner, Name: name, Tag: tag})
+	if err != nil {
+		return nil, err
+	}
+
+	return clanFromInfo(p), nil
+}
+
+func (s *ClansService) GetClan(ctx context.Context, clan_id int) (*Clan, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.GetClan"))
+	defer cancel()
+
+	p, err := pb.NewClansClient(s.conn).GetClan(ctx, &pb.ClanReference{ClanId: int32(clan_id)})
+	if err != nil {
+		return nil, err
+	}
+
+	return clanFromInfo(p), nil
+}
+
+func (s *ClansService) GetPlayerClan(ctx context.Context, username string) (*Clan, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.GetPlayerClan"))
+	defer cancel()
+
+	p, err := pb.NewClansClient(s.conn).GetPlayerClan(ctx, &pb.PlayerUsername{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	return clanFromInfo(p), nil
+}
+
+func (s *ClansService) GetMembers(ctx context.Context, clan_id int) ([]*ClanMember, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.GetClanMembers"))
+	defer cancel()
+
+	r, err := pb.NewClansClient(s.conn).GetClanMembers(ctx, &pb.ClanReference{ClanId: int32(clan_id)})
+	if err != nil {
+		return nil, err
+	}
+
+	var members []*ClanMember
+	for {
+		m, err := r.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetMembers failed", "error", err)
+			return nil, err
+		}
+
+		members = append(members, &ClanMember{
+			Username: m.Username,
+			Role:     m.Role,
+			Points:   int(m.Points),
+			Races:    int(m.Races),
+			Wins:     int(m.Wins),
+			JoinedAt: m.JoinedAt.AsTime(),
+		})
+	}
+
+	return members, nil
+}
+
+func (s *ClansService) GetTransactions(ctx context.Context, clan_id int) ([]*ClanTransaction, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.GetClanTransactions"))
+	defer cancel()
+
+	r, err := pb.NewClansClient(s.conn).GetClanTransactions(ctx, &pb.ClanReference{ClanId: int32(clan_id)})
+	if err != nil {
+		return nil, err
+	}
+
+	var transactions []*ClanTransaction
+	for {
+		t, err := r.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetTransactions failed", "error", err)
+			return nil, err
+		}
+
+		transactions = append(transactions, &ClanTransaction{Username: t.Username, Amount: int(t.Amount), Time: t.Time.AsTime()})
+	}
+
+	return transactions, nil
+}
+
+func (s *ClansService) GetClanLeaderboard(ctx context.Context) ([]*Clan, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.GetClanLeaderboard"))
+	defer cancel()
+
+	r, err := pb.NewClansClient(s.conn).GetClanLeaderboard(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return receiveClans(r)
+}
+
+func (s *ClansService) InviteToClan(ctx context.Context, inviter string, username string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.InviteToClan"))
+	defer cancel()
+
+	_, err := pb.NewClansClient(s.conn).InviteToClan(ctx, &pb.ClanInvite{Inviter: inviter, Username: username})
+	return err
+}
+
+func (s *ClansService) GetInvites(ctx context.Context, username string) ([]*Clan, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.GetClanInvites"))
+	defer cancel()
+
+	r, err := pb.NewClansClient(s.conn).GetClanInvites(ctx, &pb.PlayerUsername{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	return receiveClans(r)
+}
+
+func (s *ClansService) AcceptInvite(ctx context.Context, username string, clan_id int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.AcceptClanInvite"))
+	defer cancel()
+
+	_, err := pb.NewClansClient(s.conn).AcceptClanInvite(ctx, &pb.ClanInvite{Username: username, ClanId: int32(clan_id)})
+	return err
+}
+
+func (s *ClansService) DeclineInvite(ctx context.Context, username string, clan_id int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.DeclineClanInvite"))
+	defer cancel()
+
+	_, err := pb.NewClansClient(s.conn).DeclineClanInvite(ctx, &pb.ClanInvite{Username: username, ClanId: int32(clan_id)})
+	return err
+}
+
+func (s *ClansService) LeaveClan(ctx context.Context, username string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.LeaveClan"))
+	defer cancel()
+
+	_, err := pb.NewClansClient(s.conn).LeaveClan(ctx, &pb.PlayerUsername{Username: username})
+	return err
+}
+
+func (s *ClansService) Deposit(ctx context.Context, username string, amount int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.DepositToClan"))
+	defer cancel()
+
+	_, err := pb.NewClansClient(s.conn).DepositToClan(ctx, &pb.ClanTransfer{Username: username, Amount: int32(amount)})
+	return err
+}
+
+func (s *ClansService) Withdraw(ctx context.Context, owner string, member string, amount int) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.WithdrawFromClan"))
+	defer cancel()
+
+	res, err := pb.NewClansClient(s.conn).WithdrawFromClan(ctx, &pb.ClanTransfer{Username: owner, Member: member, Amount: int32(amount)})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(res.TransactionId), nil
+}
+
+func (s *ClansService) CancelWithdrawal(ctx context.Context, TransactionId int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.CancelWithdrawal"))
+	defer cancel()
+
+	_, err := pb.NewClansClient(s.conn).CancelWithdrawal(ctx, &pb.ClanTransactionReference{TransactionId: int32(TransactionId)})
+	return err
+}
+
+func (s *ClansService) GetClanTags(ctx context.Context, usernames []string) (map[string]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.GetClanTags"))
+	defer cancel()
+
+	r, err := pb.NewClansClient(s.conn).GetClanTags(ctx, &pb.PlayerList{Usernames: usernames})
+	if err != nil {
+		return nil, err
+	}
+
+	tags := make(map[string]string)
+	for {
+		t, err := r.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetClanTags failed", "error", err)
+			return nil, err
+		}
+
+		tags[t.Username] = t.Tag
+	}
+
+	return tags, nil
+}
+
+func (s *ClansService) RecordRaceResults(ctx context.Context, results []*ClanRaceResult) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Clans.RecordClanResults"))
+	defer cancel()
+
+	var pb_results []*pb.ClanRaceResult
+	for _, r := range results {
+		pb_results = append(pb_results, &pb.ClanRaceResult{Username: r.Username, Points: int32(r.Points), Won: r.Won})
+	}
+
+	_, err := pb.NewClansClient(s.conn).RecordClanResults(ctx, &pb.ClanRaceResults{Results: pb_results})
+	return err
+}
@@ -0,0 +1,61 @@
+package services
+
+import (
+	"context"
+
+	"orchestrator/telemetry"
+
+	pb "orchestrator/proto"
+
+	"google.golang.org/grpc"
+)
+
+// Kinds of domain event published on the bus
+const (
+	MotorcycleBoughtEvent = pb.DomainEventType_MOTORCYCLE_BOUGHT
+	PlayerRegisteredEvent = pb.DomainEventType_PLAYER_REGISTERED
+)
+
+type DomainEvent struct {
+	Type           pb.DomainEventType
+	Username       string
+	MotorcycleId   int    // motorcycle bought only
+	MotorcycleName string // motorcycle bought only
+	Price          int    // motorcycle bought only
+}
+
+type Events interface {
+	StillAlive
+	Publish(ctx context.Context, event *DomainEvent) error
+}
+
+// gRPC implementation of Events interface
+type EventsService struct {
+	conn *grpc.ClientConn
+}
+
+func NewEventsService(conn *grpc.ClientConn) *EventsService {
+	return &EventsService{conn: conn}
+}
+
+func (s *EventsService) StillAlive(ctx context.Context) bool {
+	return StillAliveHandle(ctx, s.conn)
+}
+
+func (s *EventsService) Close() {
+	s.conn.Close()
+}
+
+func (s *EventsService) Publish(ctx context.Context, event *DomainEvent) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Events.PublishEvent"))
+	defer cancel()
+
+	_, err := pb.NewEventsClient(s.conn).PublishEvent(ctx, &pb.DomainEvent{
+		Type:           event.Type,
+		Username:       event.Username,
+		MotorcycleId:   int32(event.MotorcycleId),
+		MotorcycleName: event.MotorcycleName,
+		Price:          int32(event.Price),
+	})
+	return err
+}
@@ -2,46 +2,168 @@ package services
 
 import (
 	"context"
+	"errors"
 	"io"
-	"log"
+	"log/slog"
+	"strings"
 	"time"
 
+	"orchestrator/telemetry"
+
 	pb "orchestrator/proto"
 
 	"google.golang.org/grpc"
 )
 
 type Motorcycle struct {
-	Id                    int
-	Name                  string
-	PriceToBuy            int
-	PriceToUpgrade        int
-	MaxLevel              int
-	Engine                int
-	EngineIncrement       int
-	Agility               int
-	AgilityIncrement      int
-	Brakes                int
-	BrakesIncrement       int
-	Aerodynamics          int
-	AerodynamicsIncrement int
+	Id                         int
+	Name                       string
+	PriceToBuy                 int
+	MaxLevel                   int
+	Engine                     int
+	EngineIncrement            int
+	EngineMaxLevel             int
+	EnginePriceToUpgrade       int
+	Agility                    int
+	AgilityIncrement           int
+	AgilityMaxLevel            int
+	AgilityPriceToUpgrade      int
+	Brakes                     int
+	BrakesIncrement            int
+	BrakesMaxLevel             int
+	BrakesPriceToUpgrade       int
+	Aerodynamics               int
+	AerodynamicsIncrement      int
+	AerodynamicsMaxLevel       int
+	AerodynamicsPriceToUpgrade int
+	PriceCurve                 string
+	PriceCurveFactor           int
+}
+
+// Cosmetics of an owned motorcycle, shared with the Racing service
+type Livery struct {
+	Paint      string
+	PaintColor string
+	RaceNumber int // 0 if not chosen
+	RiderName  string
+}
+
+func liveryFromInfo(p *pb.Livery) Livery {
+	return Livery{
+		Paint:      p.GetPaint(),
+		PaintColor: p.GetPaintColor(),
+		RaceNumber: int(p.GetRaceNumber()),
+		RiderName:  p.GetRiderName(),
+	}
 }
 
 type Ownership struct {
-	Level        int
-	Motorcycle   *Motorcycle
-	RacingStatus *RacingStatus
+	Level                        int
+	EngineLevel                  int
+	AgilityLevel                 int
+	BrakesLevel                  int
+	AerodynamicsLevel            int
+	EngineNextUpgradePrice       int
+	AgilityNextUpgradePrice      int
+	BrakesNextUpgradePrice       int
+	AerodynamicsNextUpgradePrice int
+	Wear                         int // percentage, already applied to the stats
+	RepairPrice                  int
+	Livery                       Livery
+	Motorcycle                   *Motorcycle
+	RacingStatus                 *RacingStatus
+}
+
+type Listing struct {
+	Id        int
+	Seller    string
+	Price     int
+	ExpiresAt time.Time
+	Ownership *Ownership
+}
+
+type Auction struct {
+	Id            int
+	Seller        string
+	Level         int
+	ReservePrice  int
+	EndsAt        time.Time
+	HighestBidder string
+	HighestBid    int
+	Settled       bool
+	Motorcycle    *Motorcycle
+}
+
+type Bid struct {
+	AuctionId int
+	Username  string
+	Amount    int
+	Time      time.Time
+	EndsAt    time.Time
+}
+
+type Part struct {
+	Id           int
+	Name         string
+	Type         string
+	Price        int
+	Engine       int
+	Agility      int
+	Brakes       int
+	Aerodynamics int
+}
+
+type Item struct {
+	Id           int
+	MotorcycleId int // 0 if not equipped
+	Part         *Part
+}
+
+type Paint struct {
+	Id    int
+	Name  string
+	Color string
+	Price int
+}
+
+type DailyReward struct {
+	Claimed bool
+	Streak  int
+	Money   int
 }
 
 type Garage interface {
 	StillAlive
-	GetUserMoney(username string) (int, error)
-	IncreaseUserMoney(username string, money int) error
-	GetRemainingMotorcycles(username string) ([]*Motorcycle, error)
-	GetUserMotorcycles(username string) ([]*Ownership, error)
-	BuyMotorcycle(username string, motorcycle_id int) error
-	UpgradeMotorcycle(username string, motorcycle_id int) error
-	GetUserMotorcycleStats(username string, motorcycle_id int) (*Ownership, error)
+	GetUserMoney(ctx context.Context, username string) (int, error)
+	IncreaseUserMoney(ctx context.Context, username string, money int) error
+	DecreaseUserMoney(ctx context.Context, username string, money int) error
+	GetRemainingMotorcycles(ctx context.Context, username string) ([]*Motorcycle, error)
+	GetUserMotorcycles(ctx context.Context, username string) ([]*Ownership, error)
+	BuyMotorcycle(ctx context.Context, username string, motorcycle_id int) error
+	UpgradeMotorcycle(ctx context.Context, username string, motorcycle_id int, stat string) error
+	GetUserMotorcycleStats(ctx context.Context, username string, motorcycle_id int) (*Ownership, error)
+	GetListings(ctx context.Context) ([]*Listing, error)
+	CreateListing(ctx context.Context, username string, motorcycle_id int, price int, duration_hours int) error
+	CancelListing(ctx context.Context, username string, listing_id int) error
+	BuyListing(ctx context.Context, username string, listing_id int) error
+	GetAuctions(ctx context.Context) ([]*Auction, error)
+	GetAuction(ctx context.Context, auction_id int) (*Auction, error)
+	CreateAuction(ctx context.Context, username string, motorcycle_id int, level int, reserve int, duration_minutes int) error
+	PlaceBid(ctx context.Context, username string, auction_id int, amount int) (outbid string, e error)
+	WatchAuction(ctx context.Context, auction_id int) (<-chan *Bid, error)
+	GetParts(ctx context.Context) ([]*Part, error)
+	GetInventory(ctx context.Context, username string) ([]*Item, error)
+	BuyPart(ctx context.Context, username string, part_id int) error
+	AwardPart(ctx context.Context, username string) (*Part, error)
+	EquipPart(ctx context.Context, username string, item_id int, motorcycle_id int) error
+	UnequipPart(ctx context.Context, username string, item_id int) error
+	ApplyWear(ctx context.Context, username string, motorcycle_id int, wear int) error
+	RepairMotorcycle(ctx context.Context, username string, motorcycle_id int) error
+	GetPaints(ctx context.Context) ([]*Paint, error)
+	CustomizeMotorcycle(ctx context.Context, username string, motorcycle_id int, paint_id int, race_number int, rider_name string) error
+	ClaimDailyReward(ctx context.Context, username string) (*DailyReward, error)
+	ClaimPayout(ctx context.Context, key string) (bool, error)
+	ReleasePayout(ctx context.Context, key string) error
 }
 
 // gRPC implementation of Garage interface
@@ -53,16 +175,78 @@ func NewGarageService(conn *grpc.ClientConn) *GarageService {
 	return &GarageService{conn: conn}
 }
 
-func (s *GarageService) StillAlive() bool {
-	return StillAliveHandle(s.conn)
+func motorcycleFromInfo(p *pb.MotorcycleInfo) *Motorcycle {
+	return &Motorcycle{
+		Id:                         int(p.Id),
+		Name:                       p.Name,
+		PriceToBuy:                 int(p.PriceToBuy),
+		MaxLevel:                   int(p.MaxLevel),
+		Engine:                     int(p.Engine),
+		EngineIncrement:            int(p.EngineIncrement),
+		EngineMaxLevel:             int(p.EngineMaxLevel),
+		EnginePriceToUpgrade:       int(p.EnginePriceToUpgrade),
+		Agility:                    int(p.Agility),
+		AgilityIncrement:           int(p.AgilityIncrement),
+		AgilityMaxLevel:            int(p.AgilityMaxLevel),
+		AgilityPriceToUpgrade:      int(p.AgilityPriceToUpgrade),
+		Brakes:                     int(p.Brakes),
+		BrakesIncrement:            int(p.BrakesIncrement),
+		BrakesMaxLevel:             int(p.BrakesMaxLevel),
+		BrakesPriceToUpgrade:       int(p.BrakesPriceToUpgrade),
+		Aerodynamics:               int(p.Aerodynamics),
+		AerodynamicsIncrement:      int(p.AerodynamicsIncrement),
+		AerodynamicsMaxLevel:       int(p.AerodynamicsMaxLevel),
+		AerodynamicsPriceToUpgrade: int(p.AerodynamicsPriceToUpgrade),
+		PriceCurve:                 p.PriceCurve,
+		PriceCurveFactor:           int(p.PriceCurveFactor),
+	}
+}
+
+func ownershipFromInfo(p *pb.OwnershipInfo) *Ownership {
+	return &Ownership{
+		Level:             int(p.Level),
+		EngineLevel:       int(p.EngineLevel),
+		AgilityLevel:      int(p.AgilityLevel),
+		BrakesLevel:       int(p.BrakesLevel),
+		AerodynamicsLevel: int(p.AerodynamicsLevel),
+
+		EngineNextUpgradePrice:       int(p.EngineNextUpgradePrice),
+		AgilityNextUpgradePrice:      int(p.AgilityNextUpgradePrice),
+		BrakesNextUpgradePrice:       int(p.BrakesNextUpgradePrice),
+		AerodynamicsNextUpgradePrice: int(p.AerodynamicsNextUpgradePrice),
+
+		Wear:        int(p.Wear),
+		RepairPrice: int(p.RepairPrice),
+		Livery:      liveryFromInfo(p.Livery),
+
+		Motorcycle:   motorcycleFromInfo(p.MotorcycleInfo),
+		RacingStatus: nil, // defined by racing service
+	}
+}
+
+func partFromInfo(p *pb.PartInfo) *Part {
+	return &Part{
+		Id:           int(p.Id),
+		Name:         p.Name,
+		Type:         p.Type,
+		Price:        int(p.Price),
+		Engine:       int(p.Engine),
+		Agility:      int(p.Agility),
+		Brakes:       int(p.Brakes),
+		Aerodynamics: int(p.Aerodynamics),
+	}
+}
+
+func (s *GarageService) StillAlive(ctx context.Context) bool {
+	return StillAliveHandle(ctx, s.conn)
 }
 
 func (s *GarageService) Close() {
 	s.conn.Close()
 }
 
-func (s *GarageService) GetRemainingMotorcycles(username string) ([]*Motorcycle, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *GarageService) GetRemainingMotorcycles(ctx context.Context, username string) ([]*Motorcycle, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.GetRemainingMotorcycles"))
 	defer cancel()
 
 	r, err := pb.NewGarageClient(s.conn).GetRemainingMotorcycles(ctx, &pb.PlayerUsername{Username: username})
@@ -76,33 +260,18 @@ func (s *GarageService) GetRemainingMotorcycles(username string) ([]*Motorcycle,
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			log.Println(err)
+			slog.ErrorContext(ctx, "GetRemainingMotorcycles failed", "error", err)
 			return nil, err
 		} else {
-			pos := &Motorcycle{
-				Id:                    int(p.Id),
-				Name:                  p.Name,
-				PriceToBuy:            int(p.PriceToBuy),
-				PriceToUpgrade:        int(p.PriceToUpgrade),
-				MaxLevel:              int(p.MaxLevel),
-				Engine:                int(p.Engine),
-				EngineIncrement:       int(p.EngineIncrement),
-				Agility:               int(p.Agility),
-				AgilityIncrement:      int(p.AgilityIncrement),
-				Brakes:                int(p.Brakes),
-				BrakesIncrement:       int(p.BrakesIncrement),
-				Aerodynamics:          int(p.Aerodynamics),
-				AerodynamicsIncrement: int(p.AerodynamicsIncrement),
-			}
-			motorcycles = append(motorcycles, pos)
+			motorcycles = append(motorcycles, motorcycleFromInfo(p))
 		}
 	}
 
 	return motorcycles, nil
 }
 
-func (s *GarageService) GetUserMotorcycles(username string) ([]*Ownership, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *GarageService) GetUserMotorcycles(ctx context.Context, username string) ([]*Ownership, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.GetUserMotorcycles"))
 	defer cancel()
 
 	r, err := pb.NewGarageClient(s.conn).GetUserMotorcycles(ctx, &pb.PlayerUsername{Username: username})
@@ -116,38 +285,18 @@ func (s *GarageService) GetUserMotorcycles(username string) ([]*Ownership, error
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			log.Println(err)
+			slog.ErrorContext(ctx, "GetUserMotorcycles failed", "error", err)
 			return nil, err
 		} else {
-			m := &Ownership{
-				Level: int(p.Level),
-				Motorcycle: &Motorcycle{
-					Id:                    int(p.MotorcycleInfo.Id),
-					Name:                  p.MotorcycleInfo.Name,
-					PriceToBuy:            int(p.MotorcycleInfo.PriceToBuy),
-					PriceToUpgrade:        int(p.MotorcycleInfo.PriceToUpgrade),
-					MaxLevel:              int(p.MotorcycleInfo.MaxLevel),
-					Engine:                int(p.MotorcycleInfo.Engine),
-					EngineIncrement:       int(p.MotorcycleInfo.EngineIncrement),
-					Agility:               int(p.MotorcycleInfo.Agility),
-					AgilityIncrement:      int(p.MotorcycleInfo.AgilityIncrement),
-					Brakes:                int(p.MotorcycleInfo.Brakes),
-					BrakesIncrement:       int(p.MotorcycleInfo.BrakesIncrement),
-					Aerodynamics:          int(p.MotorcycleInfo.Aerodynamics),
-					AerodynamicsIncrement: int(p.MotorcycleInfo.AerodynamicsIncrement),
-				},
-				RacingStatus: nil, // defined by racing service
-			}
-
-			motorcycles = append(motorcycles, m)
+			motorcycles = append(motorcycles, ownershipFromInfo(p))
 		}
 	}
 
 	return motorcycles, nil
 }
 
-func (s *GarageService) GetUserMotorcycleStats(username string, motorcycle_id int) (*Ownership, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *GarageService) GetUserMotorcycleStats(ctx context.Context, username string, motorcycle_id int) (*Ownership, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.GetUserMotorcycleStats"))
 	defer cancel()
 
 	p, err := pb.NewGarageClient(s.conn).GetUserMotorcycleStats(ctx, &pb.PlayerMotorcycle{Username: username, MotorcycleId: int32(motorcycle_id)})
@@ -155,30 +304,11 @@ func (s *GarageService) GetUserMotorcycleStats(username string, motorcycle_id in
 		return nil, err
 	}
 
-	stats := &Ownership{
-		Level: int(p.Level),
-		Motorcycle: &Motorcycle{
-			Id:                    int(p.MotorcycleId),
-			Name:                  p.MotorcycleInfo.Name,
-			PriceToBuy:            int(p.MotorcycleInfo.PriceToBuy),
-			PriceToUpgrade:        int(p.MotorcycleInfo.PriceToUpgrade),
-			MaxLevel:              int(p.MotorcycleInfo.MaxLevel),
-			Engine:                int(p.MotorcycleInfo.Engine),
-			EngineIncrement:       int(p.MotorcycleInfo.EngineIncrement),
-			Agility:               int(p.MotorcycleInfo.Agility),
-			AgilityIncrement:      int(p.MotorcycleInfo.AgilityIncrement),
-			Brakes:                int(p.MotorcycleInfo.Brakes),
-			BrakesIncrement:       int(p.MotorcycleInfo.BrakesIncrement),
-			Aerodynamics:          int(p.MotorcycleInfo.Aerodynamics),
-			AerodynamicsIncrement: int(p.MotorcycleInfo.AerodynamicsIncrement),
-		},
-	}
-
-	return stats, nil
+	return ownershipFromInfo(p), nil
 }
 
-func (s *GarageService) GetUserMoney(username string) (int, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *GarageService) GetUserMoney(ctx context.Context, username string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.GetUserMoney"))
 	defer cancel()
 
 	res, err := pb.NewGarageClient(s.conn).GetUserMoney(ctx, &pb.PlayerUsername{Username: username})
@@ -189,26 +319,384 @@ func (s *GarageService) GetUserMoney(username string) (int, error) {
 	return int(res.Money), nil
 }
 
-func (s *GarageService) IncreaseUserMoney(username string, money int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *GarageService) IncreaseUserMoney(ctx context.Context, username string, money int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.IncreaseUserMoney"))
 	defer cancel()
 
 	_, err := pb.NewGarageClient(s.conn).IncreaseUserMoney(ctx, &pb.MoneyIncrease{Username: username, Money: int32(money)})
 	return err
 }
 
-func (s *GarageService) BuyMotorcycle(username string, motorcycle_id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *GarageService) DecreaseUserMoney(ctx context.Context, username string, money int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.DecreaseUserMoney"))
+	defer cancel()
+
+	_, err := pb.NewGarageClient(s.conn).DecreaseUserMoney(ctx, &pb.MoneyIncrease{Username: username, Money: int32(money)})
+	return err
+}
+
+func (s *GarageService) BuyMotorcycle(ctx context.Context, username string, motorcycle_id int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.BuyMotorcycle"))
 	defer cancel()
 
 	_, err := pb.NewGarageClient(s.conn).BuyMotorcycle(ctx, &pb.PlayerMotorcycle{Username: username, MotorcycleId: int32(motorcycle_id)})
 	return err
 }
 
-func (s *GarageService) UpgradeMotorcycle(username string, motorcycle_id int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *GarageService) UpgradeMotorcycle(ctx context.Context, username string, motorcycle_id int, stat string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.UpgradeMotorcycle"))
+	defer cancel()
+
+	value, ok := pb.MotorcycleStat_value[strings.ToUpper(stat)]
+	if !ok || value == int32(pb.MotorcycleStat_STAT_UNSPECIFIED) {
+		return errors.New("unknown stat to upgrade")
+	}
+
+	_, err := pb.NewGarageClient(s.conn).UpgradeMotorcycle(ctx, &pb.UpgradeRequest{Username: username, MotorcycleId: int32(motorcycle_id), Stat: pb.MotorcycleStat(value)})
+	return err
+}
+
+func (s *GarageService) GetListings(ctx context.Context) ([]*Listing, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.GetListings"))
+	defer cancel()
+
+	r, err := pb.NewGarageClient(s.conn).GetListings(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var listings []*Listing
+	for {
+		p, err := r.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetListings failed", "error", err)
+			return nil, err
+		} else {
+			l := &Listing{
+				Id:        int(p.Id),
+				Seller:    p.Ownership.Username,
+				Price:     int(p.Price),
+				ExpiresAt: p.ExpiresAt.AsTime(),
+				Ownership: ownershipFromInfo(p.Ownership),
+			}
+
+			listings = append(listings, l)
+		}
+	}
+
+	return listings, nil
+}
+
+func (s *GarageService) CreateListing(ctx context.Context, username string, motorcycle_id int, price int, duration_hours int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.CreateListing"))
+	defer cancel()
+
+	_, err := pb.NewGarageClient(s.conn).CreateListing(ctx, &pb.ListingRequest{Username: username, MotorcycleId: int32(motorcycle_id), Price: int32(price), DurationHours: int32(duration_hours)})
+	return err
+}
+
+func (s *GarageService) CancelListing(ctx context.Context, username string, listing_id int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.CancelListing"))
+	defer cancel()
+
+	_, err := pb.NewGarageClient(s.conn).CancelListing(ctx, &pb.ListingAction{Username: username, ListingId: int32(listing_id)})
+	return err
+}
+
+func (s *GarageService) BuyListing(ctx context.Context, username string, listing_id int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.BuyListing"))
+	defer cancel()
+
+	_, err := pb.NewGarageClient(s.conn).BuyListing(ctx, &pb.ListingAction{Username: username, ListingId: int32(listing_id)})
+	return err
+}
+
+func auctionFromInfo(p *pb.AuctionInfo) *Auction {
+	return &Auction{
+		Id:            int(p.Id),
+		Seller:        p.Seller,
+		Level:         int(p.Level),
+		ReservePrice:  int(p.ReservePrice),
+		EndsAt:        p.EndsAt.AsTime(),
+		HighestBidder: p.HighestBidder,
+		HighestBid:    int(p.HighestBid),
+		Settled:       p.Settled,
+		Motorcycle:    &Motorcycle{Id: int(p.MotorcycleId), Name: p.MotorcycleName},
+	}
+}
+
+func (s *GarageService) GetAuctions(ctx context.Context) ([]*Auction, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.GetAuctions"))
+	defer cancel()
+
+	r, err := pb.NewGarageClient(s.conn).GetAuctions(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var auctions []*Auction
+	for {
+		p, err := r.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetAuctions failed", "error", err)
+			return nil, err
+		} else {
+			auctions = append(auctions, auctionFromInfo(p))
+		}
+	}
+
+	return auctions, nil
+}
+
+func (s *GarageService) GetAuction(ctx context.Context, auction_id int) (*Auction, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.GetAuction"))
+	defer cancel()
+
+	p, err := pb.NewGarageClient(s.conn).GetAuction(ctx, &pb.AuctionReference{AuctionId: int32(auction_id)})
+	if err != nil {
+		return nil, err
+	}
+
+	return auctionFromInfo(p), nil
+}
+
+func (s *GarageService) CreateAuction(ctx context.Context, username string, motorcycle_id int, level int, reserve int, duration_minutes int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.CreateAuction"))
+	defer cancel()
+
+	_, err := pb.NewGarageClient(s.conn).CreateAuction(ctx, &pb.AuctionRequest{Username: username, MotorcycleId: int32(motorcycle_id), Level: int32(level), ReservePrice: int32(reserve), DurationMinutes: int32(duration_minutes)})
+	return err
+}
+
+func (s *GarageService) PlaceBid(ctx context.Context, username string, auction_id int, amount int) (outbid string, e error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.PlaceBid"))
+	defer cancel()
+
+	r, err := pb.NewGarageClient(s.conn).PlaceBid(ctx, &pb.BidRequest{Username: username, AuctionId: int32(auction_id), Amount: int32(amount)})
+	if err != nil {
+		return "", err
+	}
+
+	return r.Outbid, nil
+}
+
+func (s *GarageService) WatchAuction(ctx context.Context, auction_id int) (<-chan *Bid, error) {
+	// Bids are delivered on the returned channel until the auction is settled or ctx is done
+
+	r, err := pb.NewGarageClient(s.conn).WatchAuction(ctx, &pb.AuctionReference{AuctionId: int32(auction_id)})
+	if err != nil {
+		return nil, err
+	}
+
+	bids := make(chan *Bid)
+	go func() {
+		defer close(bids)
+
+		for {
+			p, err := r.Recv()
+			if err != nil {
+				if err != io.EOF {
+					slog.ErrorContext(ctx, "WatchAuction failed", "error", err)
+				}
+				return
+			}
+
+			bid := &Bid{
+				AuctionId: int(p.AuctionId),
+				Username:  p.Username,
+				Amount:    int(p.Amount),
+				Time:      p.Time.AsTime(),
+				EndsAt:    p.EndsAt.AsTime(),
+			}
+
+			select {
+			case bids <- bid:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return bids, nil
+}
+
+func (s *GarageService) GetParts(ctx context.Context) ([]*Part, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.GetParts"))
+	defer cancel()
+
+	r, err := pb.NewGarageClient(s.conn).GetParts(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var parts []*Part
+	for {
+		p, err := r.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetParts failed", "error", err)
+			return nil, err
+		} else {
+			parts = append(parts, partFromInfo(p))
+		}
+	}
+
+	return parts, nil
+}
+
+func (s *GarageService) GetInventory(ctx context.Context, username string) ([]*Item, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.GetInventory"))
+	defer cancel()
+
+	r, err := pb.NewGarageClient(s.conn).GetInventory(ctx, &pb.PlayerUsername{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	var items []*Item
+	for {
+		p, err := r.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetInventory failed", "error", err)
+			return nil, err
+		} else {
+			items = append(items, &Item{
+				Id:           int(p.Id),
+				MotorcycleId: int(p.MotorcycleId),
+				Part:         partFromInfo(p.Part),
+			})
+		}
+	}
+
+	return items, nil
+}
+
+func (s *GarageService) BuyPart(ctx context.Context, username string, part_id int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.BuyPart"))
+	defer cancel()
+
+	_, err := pb.NewGarageClient(s.conn).BuyPart(ctx, &pb.PartRequest{Username: username, PartId: int32(part_id)})
+	return err
+}
+
+func (s *GarageService) AwardPart(ctx context.Context, username string) (*Part, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.AwardPart"))
+	defer cancel()
+
+	p, err := pb.NewGarageClient(s.conn).AwardPart(ctx, &pb.PlayerUsername{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	return partFromInfo(p), nil
+}
+
+func (s *GarageService) EquipPart(ctx context.Context, username string, item_id int, motorcycle_id int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.EquipPart"))
+	defer cancel()
+
+	_, err := pb.NewGarageClient(s.conn).EquipPart(ctx, &pb.EquipRequest{Username: username, ItemId: int32(item_id), MotorcycleId: int32(motorcycle_id)})
+	return err
+}
+
+func (s *GarageService) UnequipPart(ctx context.Context, username string, item_id int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.UnequipPart"))
+	defer cancel()
+
+	_, err := pb.NewGarageClient(s.conn).UnequipPart(ctx, &pb.EquipRequest{Username: username, ItemId: int32(item_id)})
+	return err
+}
+
+func (s *GarageService) ApplyWear(ctx context.Context, username string, motorcycle_id int, wear int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.ApplyWear"))
+	defer cancel()
+
+	_, err := pb.NewGarageClient(s.conn).ApplyWear(ctx, &pb.WearIncrease{Username: username, MotorcycleId: int32(motorcycle_id), Wear: int32(wear)})
+	return err
+}
+
+func (s *GarageService) RepairMotorcycle(ctx context.Context, username string, motorcycle_id int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.RepairMotorcycle"))
+	defer cancel()
+
+	_, err := pb.NewGarageClient(s.conn).RepairMotorcycle(ctx, &pb.PlayerMotorcycle{Username: username, MotorcycleId: int32(motorcycle_id)})
+	return err
+}
+
+func (s *GarageService) GetPaints(ctx context.Context) ([]*Paint, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.GetPaints"))
+	defer cancel()
+
+	r, err := pb.NewGarageClient(s.conn).GetPaints(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var paints []*Paint
+	for {
+		p, err := r.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetPaints failed", "error", err)
+			return nil, err
+		} else {
+			paints = append(paints, &Paint{Id: int(p.Id), Name: p.Name, Color: p.Color, Price: int(p.Price)})
+		}
+	}
+
+	return paints, nil
+}
+
+func (s *GarageService) CustomizeMotorcycle(ctx context.Context, username string, motorcycle_id int, paint_id int, race_number int, rider_name string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.CustomizeMotorcycle"))
+	defer cancel()
+
+	_, err := pb.NewGarageClient(s.conn).CustomizeMotorcycle(ctx, &pb.CustomizationRequest{
+		Username:     username,
+		MotorcycleId: int32(motorcycle_id),
+		PaintId:      int32(paint_id),
+		RaceNumber:   int32(race_number),
+		RiderName:    rider_name,
+	})
+	return err
+}
+
+func (s *GarageService) ClaimDailyReward(ctx context.Context, username string) (*DailyReward, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.ClaimDailyReward"))
+	defer cancel()
+
+	r, err := pb.NewGarageClient(s.conn).ClaimDailyReward(ctx, &pb.PlayerUsername{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	return &DailyReward{Claimed: r.Claimed, Streak: int(r.Streak), Money: int(r.Money)}, nil
+}
+
+func (s *GarageService) ClaimPayout(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.ClaimPayout"))
+	defer cancel()
+
+	r, err := pb.NewGarageClient(s.conn).ClaimPayout(ctx, &pb.PayoutKey{Key: key})
+	if err != nil {
+		return false, err
+	}
+
+	return r.Claimed, nil
+}
+
+func (s *GarageService) ReleasePayout(ctx context.Context, key string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Garage.ReleasePayout"))
 	defer cancel()
 
-	_, err := pb.NewGarageClient(s.conn).UpgradeMotorcycle(ctx, &pb.PlayerMotorcycle{Username: username, MotorcycleId: int32(motorcycle_id)})
+	_, err := pb.NewGarageClient(s.conn).ReleasePayout(ctx, &pb.PayoutKey{Key: key})
 	return err
 }
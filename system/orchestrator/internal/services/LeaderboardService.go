@@ -2,10 +2,15 @@ package services
 
 import (
 	"context"
+	"errors"
 	"io"
-	"log"
+	"log/slog"
+	"sort"
+	"strings"
 	"time"
 
+	"orchestrator/telemetry"
+
 	pb "orchestrator/proto"
 
 	"google.golang.org/grpc"
@@ -15,13 +20,48 @@ type LeaderboardPosition struct {
 	Username string
 	Points   int
 	Position int
+	Rating   int
+	Races    int // track and motorcycle boards only
+	Wins     int // track and motorcycle boards only
+	ClanTag  string
+}
+
+// Largest list of players accepted by a single GetPlayers request of the Leaderboard service
+const maxPlayersPerRequest = 100
+
+type Season struct {
+	Id       int
+	Name     string
+	StartsAt time.Time
+	EndsAt   time.Time
+}
+
+// Position in the final standings of a season whose reward is not paid yet
+type SeasonStanding struct {
+	SeasonId int
+	Username string
+	Position int
 }
 
 type Leaderboard interface {
 	StillAlive
-	AddPoints(username string, points int) error
-	GetPlayer(username string) (*LeaderboardPosition, error)
-	GetFullLeaderboard() ([]*LeaderboardPosition, error)
+	AddPoints(ctx context.Context, username string, points int) error
+	GetPlayer(ctx context.Context, username string) (*LeaderboardPosition, error)
+	GetPlayers(ctx context.Context, usernames []string) ([]*LeaderboardPosition, error)
+	GetFullLeaderboard(ctx context.Context) ([]*LeaderboardPosition, error)
+	GetLeaderboardPage(ctx context.Context, offset int, limit int) ([]*LeaderboardPosition, int, error)
+	GetLeaderboardAround(ctx context.Context, username string, radius int) ([]*LeaderboardPosition, int, error)
+	GetCurrentSeason(ctx context.Context) (*Season, error)
+	GetPastSeasons(ctx context.Context) ([]*Season, error)
+	GetSeasonStandings(ctx context.Context, season_id int) ([]*LeaderboardPosition, error)
+	RolloverSeason(ctx context.Context, days int) ([]*LeaderboardPosition, error)
+	GetUnpaidStandings(ctx context.Context) ([]*SeasonStanding, error)
+	ClaimStanding(ctx context.Context, season_id int, username string) (bool, error)
+	ReleaseStanding(ctx context.Context, season_id int, username string) error
+	UpdateRatings(ctx context.Context, finishers []*LeaderboardPosition) error
+	AddRacePoints(ctx context.Context, username string, track_name string, motorcycle_name string, points int, position int) error
+	GetBoard(ctx context.Context, board string, name string, offset int, limit int) ([]*LeaderboardPosition, int, error)
+	GetBoardNames(ctx context.Context, board string) ([]string, error)
 }
 
 // gRPC implementation of Leaderboard interface
@@ -33,33 +73,31 @@ func NewLeaderboardService(conn *grpc.ClientConn) *LeaderboardService {
 	return &LeaderboardService{conn: conn}
 }
 
-func (s *LeaderboardService) StillAlive() bool {
-	return StillAliveHandle(s.conn)
+func (s *LeaderboardService) StillAlive(ctx context.Context) bool {
+	return StillAliveHandle(ctx, s.conn)
 }
 
 func (s *LeaderboardService) Close() {
 	s.conn.Close()
 }
 
-func (s *LeaderboardService) GetFullLeaderboard() ([]*LeaderboardPosition, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-
-	r, err := pb.NewLeaderboardClient(s.conn).GetFullLeaderboard(ctx, nil)
-	if err != nil {
-		return nil, err
-	}
+// Server-side stream of leaderboard positions
+type positionStream interface {
+	Recv() (*pb.LeaderboardPosition, error)
+	Context() context.Context
+}
 
+func receivePositions(stream positionStream) ([]*LeaderboardPosition, error) {
 	var leaderboard []*LeaderboardPosition
 	for {
-		p, err := r.Recv()
+		p, err := stream.Recv()
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			log.Println(err)
+			slog.ErrorContext(stream.Context(), "receivePositions failed", "error", err)
 			return nil, err
 		} else {
-			pos := &LeaderboardPosition{Username: p.Username, Points: int(p.Points), Position: int(p.Position)}
+			pos := &LeaderboardPosition{Username: p.Username, Points: int(p.Points), Position: int(p.Position), Rating: int(p.Rating)}
 			leaderboard = append(leaderboard, pos)
 		}
 	}
@@ -67,21 +105,255 @@ func (s *LeaderboardService) GetFullLeaderboard() ([]*LeaderboardPosition, error
 	return leaderboard, nil
 }
 
-func (s *LeaderboardService) GetPlayer(username string) (*LeaderboardPosition, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *LeaderboardService) GetFullLeaderboard(ctx context.Context) ([]*LeaderboardPosition, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.GetFullLeaderboard"))
+	defer cancel()
+
+	r, err := pb.NewLeaderboardClient(s.conn).GetFullLeaderboard(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return receivePositions(r)
+}
+
+func positionsFromPage(page *pb.LeaderboardPage) []*LeaderboardPosition {
+	positions := make([]*LeaderboardPosition, 0, len(page.Positions))
+	for _, p := range page.Positions {
+		positions = append(positions, &LeaderboardPosition{Username: p.Username, Points: int(p.Points), Position: int(p.Position), Rating: int(p.Rating), Races: int(p.Races), Wins: int(p.Wins)})
+	}
+	return positions
+}
+
+func (s *LeaderboardService) GetLeaderboardPage(ctx context.Context, offset int, limit int) ([]*LeaderboardPosition, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.GetLeaderboardPage"))
+	defer cancel()
+
+	page, err := pb.NewLeaderboardClient(s.conn).GetLeaderboardPage(ctx, &pb.PageRequest{Offset: int32(offset), Limit: int32(limit)})
+	if err != nil {
+		return nil, 0, err
+	}
+	return positionsFromPage(page), int(page.Total), nil
+}
+
+func (s *LeaderboardService) GetLeaderboardAround(ctx context.Context, username string, radius int) ([]*LeaderboardPosition, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.GetLeaderboardAround"))
+	defer cancel()
+
+	page, err := pb.NewLeaderboardClient(s.conn).GetLeaderboardAround(ctx, &pb.AroundRequest{Username: username, Radius: int32(radius)})
+	if err != nil {
+		return nil, 0, err
+	}
+	return positionsFromPage(page), int(page.Total), nil
+}
+
+func (s *LeaderboardService) GetPlayers(ctx context.Context, usernames []string) ([]*LeaderboardPosition, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.GetPlayers"))
+	defer cancel()
+
+	// The Leaderboard service accepts a limited number of players per request, longer lists are split
+	var players []*LeaderboardPosition
+	for start := 0; start < len(usernames); start += maxPlayersPerRequest {
+		end := min(start+maxPlayersPerRequest, len(usernames))
+
+		page, err := pb.NewLeaderboardClient(s.conn).GetPlayers(ctx, &pb.PlayerList{Usernames: usernames[start:end]})
+		if err != nil {
+			return nil, err
+		}
+		players = append(players, positionsFromPage(page)...)
+	}
+
+	sort.SliceStable(players, func(i, j int) bool {
+		if players[i].Position != players[j].Position {
+			return players[i].Position < players[j].Position
+		}
+		return players[i].Username < players[j].Username
+	})
+	return players, nil
+}
+
+func (s *LeaderboardService) GetPlayer(ctx context.Context, username string) (*LeaderboardPosition, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.GetPlayer"))
 	defer cancel()
 
 	pos, err := pb.NewLeaderboardClient(s.conn).GetPlayer(ctx, &pb.PlayerUsername{Username: username})
 	if err != nil {
 		return nil, err
 	}
-	return &LeaderboardPosition{Username: pos.Username, Points: int(pos.Points), Position: int(pos.Position)}, nil
+	return &LeaderboardPosition{Username: pos.Username, Points: int(pos.Points), Position: int(pos.Position), Rating: int(pos.Rating)}, nil
 }
 
-func (s *LeaderboardService) AddPoints(username string, points int) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *LeaderboardService) AddPoints(ctx context.Context, username string, points int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.AddPoints"))
 	defer cancel()
 
 	_, err := pb.NewLeaderboardClient(s.conn).AddPoints(ctx, &pb.PointIncrement{Username: username, Points: int32(points)})
 	return err
 }
+
+func seasonFromInfo(info *pb.SeasonInfo) *Season {
+	return &Season{Id: int(info.Id), Name: info.Name, StartsAt: info.StartsAt.AsTime(), EndsAt: info.EndsAt.AsTime()}
+}
+
+func (s *LeaderboardService) GetCurrentSeason(ctx context.Context) (*Season, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.GetCurrentSeason"))
+	defer cancel()
+
+	season, err := pb.NewLeaderboardClient(s.conn).GetCurrentSeason(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	return seasonFromInfo(season), nil
+}
+
+func (s *LeaderboardService) GetPastSeasons(ctx context.Context) ([]*Season, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.GetPastSeasons"))
+	defer cancel()
+
+	r, err := pb.NewLeaderboardClient(s.conn).GetPastSeasons(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var seasons []*Season
+	for {
+		season, err := r.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetPastSeasons failed", "error", err)
+			return nil, err
+		}
+		seasons = append(seasons, seasonFromInfo(season))
+	}
+
+	return seasons, nil
+}
+
+func (s *LeaderboardService) GetSeasonStandings(ctx context.Context, season_id int) ([]*LeaderboardPosition, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.GetSeasonStandings"))
+	defer cancel()
+
+	r, err := pb.NewLeaderboardClient(s.conn).GetSeasonStandings(ctx, &pb.SeasonReference{SeasonId: int32(season_id)})
+	if err != nil {
+		return nil, err
+	}
+
+	return receivePositions(r)
+}
+
+func (s *LeaderboardService) RolloverSeason(ctx context.Context, days int) ([]*LeaderboardPosition, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.RolloverSeason"))
+	defer cancel()
+
+	r, err := pb.NewLeaderboardClient(s.conn).RolloverSeason(ctx, &pb.SeasonDuration{Days: int32(days)})
+	if err != nil {
+		return nil, err
+	}
+
+	return receivePositions(r)
+}
+
+func (s *LeaderboardService) GetUnpaidStandings(ctx context.Context) ([]*SeasonStanding, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.GetUnpaidStandings"))
+	defer cancel()
+
+	r, err := pb.NewLeaderboardClient(s.conn).GetUnpaidStandings(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var standings []*SeasonStanding
+	for {
+		standing, err := r.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetUnpaidStandings failed", "error", err)
+			return nil, err
+		}
+
+		standings = append(standings, &SeasonStanding{SeasonId: int(standing.SeasonId), Username: standing.Username, Position: int(standing.Position)})
+	}
+
+	return standings, nil
+}
+
+func (s *LeaderboardService) ClaimStanding(ctx context.Context, season_id int, username string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.ClaimStanding"))
+	defer cancel()
+
+	r, err := pb.NewLeaderboardClient(s.conn).ClaimStanding(ctx, &pb.SeasonStanding{SeasonId: int32(season_id), Username: username})
+	if err != nil {
+		return false, err
+	}
+
+	return r.Claimed, nil
+}
+
+func (s *LeaderboardService) ReleaseStanding(ctx context.Context, season_id int, username string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.ReleaseStanding"))
+	defer cancel()
+
+	_, err := pb.NewLeaderboardClient(s.conn).ReleaseStanding(ctx, &pb.SeasonStanding{SeasonId: int32(season_id), Username: username})
+	return err
+}
+
+func (s *LeaderboardService) UpdateRatings(ctx context.Context, finishers []*LeaderboardPosition) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.UpdateRatings"))
+	defer cancel()
+
+	standings := &pb.RaceStandings{}
+	for _, f := range finishers {
+		standings.Finishers = append(standings.Finishers, &pb.RaceFinisher{Username: f.Username, Position: int32(f.Position)})
+	}
+
+	_, err := pb.NewLeaderboardClient(s.conn).UpdateRatings(ctx, standings)
+	return err
+}
+
+func (s *LeaderboardService) AddRacePoints(ctx context.Context, username string, track_name string, motorcycle_name string, points int, position int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.AddRacePoints"))
+	defer cancel()
+
+	_, err := pb.NewLeaderboardClient(s.conn).AddRacePoints(ctx, &pb.RacePoints{
+		Username:       username,
+		TrackName:      track_name,
+		MotorcycleName: motorcycle_name,
+		Points:         int32(points),
+		Position:       int32(position),
+	})
+	return err
+}
+
+func (s *LeaderboardService) GetBoard(ctx context.Context, board string, name string, offset int, limit int) ([]*LeaderboardPosition, int, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.GetBoard"))
+	defer cancel()
+
+	value, ok := pb.BoardType_value[strings.ToUpper(board)]
+	if !ok {
+		return nil, 0, errors.New("unknown board")
+	}
+
+	page, err := pb.NewLeaderboardClient(s.conn).GetBoard(ctx, &pb.BoardRequest{Board: pb.BoardType(value), Name: name, Offset: int32(offset), Limit: int32(limit)})
+	if err != nil {
+		return nil, 0, err
+	}
+	return positionsFromPage(page), int(page.Total), nil
+}
+
+func (s *LeaderboardService) GetBoardNames(ctx context.Context, board string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Leaderboard.GetBoardNames"))
+	defer cancel()
+
+	value, ok := pb.BoardType_value[strings.ToUpper(board)]
+	if !ok {
+		return nil, errors.New("unknown board")
+	}
+
+	names, err := pb.NewLeaderboardClient(s.conn).GetBoardNames(ctx, &pb.BoardReference{Board: pb.BoardType(value)})
+	if err != nil {
+		return nil, err
+	}
+	return names.Names, nil
+}
@@ -0,0 +1,154 @@
+package services
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"time"
+
+	"orchestrator/telemetry"
+
+	pb "orchestrator/proto"
+
+	"google.golang.org/grpc"
+)
+
+type Notification struct {
+	Id        int
+	Kind      string // race, auction, season, championship, friend, clan, achievement, garage or account
+	Message   string
+	Link      string
+	Read      bool
+	CreatedAt time.Time
+}
+
+type Notifications interface {
+	StillAlive
+	Publish(ctx context.Context, usernames []string, kind string, message string, link string) error
+	GetNotifications(ctx context.Context, username string) ([]*Notification, error)
+	GetUnreadCount(ctx context.Context, username string) (int, error)
+	MarkRead(ctx context.Context, username string, notification_id int) error
+	MarkAllRead(ctx context.Context, username string) error
+	WatchNotifications(ctx context.Context, username string) (<-chan *Notification, error)
+}
+
+// gRPC implementation of Notifications interface
+type NotificationsService struct {
+	conn *grpc.ClientConn
+}
+
+func NewNotificationsService(conn *grpc.ClientConn) *NotificationsService {
+	return &NotificationsService{conn: conn}
+}
+
+func (s *NotificationsService) StillAlive(ctx context.Context) bool {
+	return StillAliveHandle(ctx, s.conn)
+}
+
+func (s *NotificationsService) Close() {
+	s.conn.Close()
+}
+
+func notificationFromInfo(p *pb.Notification) *Notification {
+	return &Notification{
+		Id:        int(p.Id),
+		Kind:      p.Kind,
+		Message:   p.Message,
+		Link:      p.Link,
+		Read:      p.Read,
+		CreatedAt: p.CreatedAt.AsTime(),
+	}
+}
+
+func (s *NotificationsService) Publish(ctx context.Context, usernames []string, kind string, message string, link string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Notifications.PublishNotification"))
+	defer cancel()
+
+	_, err := pb.NewNotificationsClient(s.conn).PublishNotification(ctx, &pb.NotificationRequest{Usernames: usernames, Kind: kind, Message: message, Link: link})
+	return err
+}
+
+func (s *NotificationsService) GetNotifications(ctx context.Context, username string) ([]*Notification, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Notifications.GetNotifications"))
+	defer cancel()
+
+	r, err := pb.NewNotificationsClient(s.conn).GetNotifications(ctx, &pb.PlayerUsername{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	var notifications []*Notification
+	for {
+		p, err := r.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetNotifications failed", "error", err)
+			return nil, err
+		}
+
+		notifications = append(notifications, notificationFromInfo(p))
+	}
+
+	return notifications, nil
+}
+
+func (s *NotificationsService) GetUnreadCount(ctx context.Context, username string) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Notifications.GetUnreadCount"))
+	defer cancel()
+
+	res, err := pb.NewNotificationsClient(s.conn).GetUnreadCount(ctx, &pb.PlayerUsername{Username: username})
+	if err != nil {
+		return 0, err
+	}
+
+	return int(res.Count), nil
+}
+
+func (s *NotificationsService) MarkRead(ctx context.Context, username string, notification_id int) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Notifications.MarkNotificationRead"))
+	defer cancel()
+
+	_, err := pb.NewNotificationsClient(s.conn).MarkNotificationRead(ctx, &pb.NotificationReference{Username: username, NotificationId: int32(notification_id)})
+	return err
+}
+
+func (s *NotificationsService) MarkAllRead(ctx context.Context, username string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Notifications.MarkAllNotificationsRead"))
+	defer cancel()
+
+	_, err := pb.NewNotificationsClient(s.conn).MarkAllNotificationsRead(ctx, &pb.PlayerUsername{Username: username})
+	return err
+}
+
+func (s *NotificationsService) WatchNotifications(ctx context.Context, username string) (<-chan *Notification, error) {
+	// New notifications are delivered on the returned channel until ctx is done
+
+	r, err := pb.NewNotificationsClient(s.conn).WatchNotifications(ctx, &pb.PlayerUsername{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	notifications := make(chan *Notification)
+	go func() {
+		defer close(notifications)
+
+		for {
+			p, err := r.Recv()
+			if err != nil {
+				if err != io.EOF {
+					slog.ErrorContext(ctx, "WatchNotifications failed", "error", err)
+				}
+				return
+			}
+
+			select {
+			case notifications <- notificationFromInfo(p):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return notifications, nil
+}
@@ -2,12 +2,15 @@ package services
 
 import (
 	"context"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
 	pb "orchestrator/proto"
+	"orchestrator/telemetry"
 	"time"
 
 	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
 type RacingStatus struct {
@@ -15,22 +18,128 @@ type RacingStatus struct {
 	MotorcycleId int
 	Status       bool
 	TrackName    string
+	LobbyCode    string // empty if racing in public matchmaking
+	Weather      string // forecast of the race
+	Championship int    // 0 if not entered in a championship
 }
 
 type RaceResult struct {
+	Username         string
 	MotorcycleName   string
 	MotorcycleLevel  int
 	Position         int
 	TotalMotorcycles int
 	TrackName        string
+	Weather          string
 	Time             time.Time
+	Livery           Livery
 }
 
 type Racing interface {
 	StillAlive
-	CheckIsRacing(username string, motorcycle_id int) (*RacingStatus, error)
-	StartMatchmaking(username string, stats *Ownership) error
-	GetHistory(username string) ([]*RaceResult, error)
+	CheckIsRacing(ctx context.Context, username string, motorcycle_id int) (*RacingStatus, error)
+	StartMatchmaking(ctx context.Context, username string, stats *Ownership) error
+	GetHistory(ctx context.Context, username string) ([]*RaceResult, error)
+	GetRecentRaces(ctx context.Context) ([]*RaceResult, error)
+	GetPlayersRecentRaces(ctx context.Context, usernames []string) ([]*RaceResult, error)
+	GetTracks(ctx context.Context) ([]*Track, error)
+	CreateLobby(ctx context.Context, username string, stats *Ownership, track_id int, friendly bool) (*Lobby, error)
+	JoinLobby(ctx context.Context, code string, username string, stats *Ownership) error
+	LeaveLobby(ctx context.Context, code string, username string) error
+	StartLobbyRace(ctx context.Context, code string, username string) error
+	GetLobby(ctx context.Context, code string) (*Lobby, error)
+	GetPlayerLobbies(ctx context.Context, username string) ([]*Lobby, error)
+	CreateChampionship(ctx context.Context, organizer string, name string, tracks []int, starts_at time.Time, interval_minutes int, max_entrants int) (*Championship, error)
+	RegisterChampionship(ctx context.Context, id int, username string, stats *Ownership) error
+	GetChampionships(ctx context.Context) ([]*Championship, error)
+	GetChampionship(ctx context.Context, id int) (*Championship, error)
+	GetChampionshipSchedule(ctx context.Context, id int) ([]*ChampionshipRound, error)
+	GetChampionshipStandings(ctx context.Context, id int) ([]*ChampionshipStanding, error)
+	RunTimeTrial(ctx context.Context, username string, stats *Ownership, track_id int) (*TimeTrialResult, error)
+	GetTimeTrialLeaderboard(ctx context.Context, track_id int) ([]*TimeTrialRecord, error)
+}
+
+// Series of races decided by the points of every round
+type Championship struct {
+	Id              int
+	Name            string
+	Organizer       string
+	Status          string // open, running, completed or cancelled
+	MaxEntrants     int
+	Entrants        int
+	Rounds          int
+	RoundsCompleted int
+	StartsAt        time.Time
+}
+
+type ChampionshipRound struct {
+	Round     int
+	TrackName string
+	StartsAt  time.Time
+	Completed bool
+	Winner    string
+	Weather   string
+}
+
+type ChampionshipStanding struct {
+	Username       string
+	MotorcycleName string
+	Points         int
+	Wins           int
+	Position       int
+}
+
+// Lap time in milliseconds, printed as m:ss.mmm
+type LapTime int
+
+func (l LapTime) String() string {
+	return fmt.Sprintf("%d:%02d.%03d", l/60000, l/1000%60, l%1000)
+}
+
+// Solo lap compared with the bests set before it, zero if there were none
+type TimeTrialResult struct {
+	TrackName       string
+	LapTime         LapTime
+	PersonalBest    LapTime
+	TrackRecord     LapTime
+	RecordHolder    string
+	NewPersonalBest bool
+	NewTrackRecord  bool
+}
+
+type TimeTrialRecord struct {
+	Username        string
+	Position        int
+	LapTime         LapTime
+	MotorcycleName  string
+	MotorcycleLevel int
+	Time            time.Time
+}
+
+type Track struct {
+	Id             int
+	Name           string
+	MaxMotorcycles int
+	Weather        string // forecast of the next matchmaking race
+}
+
+// Private race joined through an invite code
+type Lobby struct {
+	Code           string
+	Host           string
+	TrackName      string
+	MaxMotorcycles int
+	Friendly       bool // no rewards for the result
+	Weather        string
+	Entries        []*LobbyEntry
+}
+
+type LobbyEntry struct {
+	Username        string
+	MotorcycleId    int
+	MotorcycleName  string
+	MotorcycleLevel int
+	Livery          Livery
 }
 
 // gRPC implementation of Racing interface
@@ -42,19 +151,16 @@ func NewRacingService(conn *grpc.ClientConn) *RacingService {
 	return &RacingService{conn: conn}
 }
 
-func (s *RacingService) StillAlive() bool {
-	return StillAliveHandle(s.conn)
+func (s *RacingService) StillAlive(ctx context.Context) bool {
+	return StillAliveHandle(ctx, s.conn)
 }
 
 func (s *RacingService) Close() {
 	s.conn.Close()
 }
 
-func (s *RacingService) StartMatchmaking(username string, stats *Ownership) error {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
-
-	_, err := pb.NewRacingClient(s.conn).StartMatchmaking(ctx, &pb.RaceMotorcycle{
+func raceMotorcycle(username string, stats *Ownership) *pb.RaceMotorcycle {
+	return &pb.RaceMotorcycle{
 		Username:       username,
 		MotorcycleId:   int32(stats.Motorcycle.Id),
 		MotorcycleName: stats.Motorcycle.Name,
@@ -63,12 +169,31 @@ func (s *RacingService) StartMatchmaking(username string, stats *Ownership) erro
 		Aerodynamics:   int32(stats.Motorcycle.Aerodynamics),
 		Agility:        int32(stats.Motorcycle.Agility),
 		Brakes:         int32(stats.Motorcycle.Brakes),
-	})
+
+		EngineLevel:       int32(stats.EngineLevel),
+		AgilityLevel:      int32(stats.AgilityLevel),
+		BrakesLevel:       int32(stats.BrakesLevel),
+		AerodynamicsLevel: int32(stats.AerodynamicsLevel),
+
+		Livery: &pb.Livery{
+			Paint:      stats.Livery.Paint,
+			PaintColor: stats.Livery.PaintColor,
+			RaceNumber: int32(stats.Livery.RaceNumber),
+			RiderName:  stats.Livery.RiderName,
+		},
+	}
+}
+
+func (s *RacingService) StartMatchmaking(ctx context.Context, username string, stats *Ownership) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.StartMatchmaking"))
+	defer cancel()
+
+	_, err := pb.NewRacingClient(s.conn).StartMatchmaking(ctx, raceMotorcycle(username, stats))
 	return err
 }
 
-func (s *RacingService) CheckIsRacing(username string, motorcycle_id int) (*RacingStatus, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *RacingService) CheckIsRacing(ctx context.Context, username string, motorcycle_id int) (*RacingStatus, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.CheckIsRacing"))
 	defer cancel()
 
 	status, err := pb.NewRacingClient(s.conn).CheckIsRacing(ctx, &pb.PlayerMotorcycle{Username: username, MotorcycleId: int32(motorcycle_id)})
@@ -76,11 +201,11 @@ func (s *RacingService) CheckIsRacing(username string, motorcycle_id int) (*Raci
 		return nil, err
 	}
 
-	return &RacingStatus{Username: username, MotorcycleId: motorcycle_id, Status: status.IsRacing, TrackName: status.TrackName}, nil
+	return &RacingStatus{Username: username, MotorcycleId: motorcycle_id, Status: status.IsRacing, TrackName: status.TrackName, LobbyCode: status.LobbyCode, Weather: status.Weather, Championship: int(status.ChampionshipId)}, nil
 }
 
-func (s *RacingService) GetHistory(username string) ([]*RaceResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *RacingService) GetHistory(ctx context.Context, username string) ([]*RaceResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.GetHistory"))
 	defer cancel()
 
 	stream, err := pb.NewRacingClient(s.conn).GetHistory(ctx, &pb.PlayerUsername{Username: username})
@@ -88,22 +213,59 @@ func (s *RacingService) GetHistory(username string) ([]*RaceResult, error) {
 		return nil, err
 	}
 
+	return receiveResults(stream)
+}
+
+func (s *RacingService) GetRecentRaces(ctx context.Context) ([]*RaceResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.GetRecentRaces"))
+	defer cancel()
+
+	stream, err := pb.NewRacingClient(s.conn).GetRecentRaces(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return receiveResults(stream)
+}
+
+func (s *RacingService) GetPlayersRecentRaces(ctx context.Context, usernames []string) ([]*RaceResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.GetPlayersRecentRaces"))
+	defer cancel()
+
+	stream, err := pb.NewRacingClient(s.conn).GetPlayersRecentRaces(ctx, &pb.PlayerList{Usernames: usernames})
+	if err != nil {
+		return nil, err
+	}
+
+	return receiveResults(stream)
+}
+
+// Common part of the streams of race results
+type resultStream interface {
+	Recv() (*pb.RaceResult, error)
+	Context() context.Context
+}
+
+func receiveResults(stream resultStream) ([]*RaceResult, error) {
 	var results []*RaceResult
 	for {
 		r, err := stream.Recv()
 		if err == io.EOF {
 			break
 		} else if err != nil {
-			log.Println(err)
+			slog.ErrorContext(stream.Context(), "receiveResults failed", "error", err)
 			return nil, err
 		} else {
 			res := &RaceResult{
+				Username:         r.Username,
 				MotorcycleName:   r.MotorcycleName,
 				MotorcycleLevel:  int(r.MotorcycleLevel),
 				Position:         int(r.PositionInRace),
 				TotalMotorcycles: int(r.TotalMotorcycles),
 				TrackName:        r.TrackName,
+				Weather:          r.Weather,
 				Time:             r.Time.AsTime(),
+				Livery:           liveryFromInfo(r.Livery),
 			}
 
 			results = append(results, res)
@@ -112,3 +274,321 @@ func (s *RacingService) GetHistory(username string) ([]*RaceResult, error) {
 
 	return results, nil
 }
+
+func (s *RacingService) GetTracks(ctx context.Context) ([]*Track, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.GetTracks"))
+	defer cancel()
+
+	stream, err := pb.NewRacingClient(s.conn).GetTracks(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []*Track
+	for {
+		t, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetTracks failed", "error", err)
+			return nil, err
+		}
+
+		tracks = append(tracks, &Track{Id: int(t.Id), Name: t.Name, MaxMotorcycles: int(t.MaxMotorcycles), Weather: t.Weather})
+	}
+
+	return tracks, nil
+}
+
+func lobbyFromInfo(l *pb.LobbyInfo) *Lobby {
+	lobby := &Lobby{
+		Code:           l.Code,
+		Host:           l.Host,
+		TrackName:      l.TrackName,
+		MaxMotorcycles: int(l.MaxMotorcycles),
+		Friendly:       l.Friendly,
+		Weather:        l.Weather,
+	}
+	for _, e := range l.Entries {
+		lobby.Entries = append(lobby.Entries, &LobbyEntry{
+			Username:        e.Username,
+			MotorcycleId:    int(e.MotorcycleId),
+			MotorcycleName:  e.MotorcycleName,
+			MotorcycleLevel: int(e.MotorcycleLevel),
+			Livery:          liveryFromInfo(e.Livery),
+		})
+	}
+	return lobby
+}
+
+func (s *RacingService) CreateLobby(ctx context.Context, username string, stats *Ownership, track_id int, friendly bool) (*Lobby, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.CreateLobby"))
+	defer cancel()
+
+	lobby, err := pb.NewRacingClient(s.conn).CreateLobby(ctx, &pb.LobbySettings{TrackId: int32(track_id), Friendly: friendly, Motorcycle: raceMotorcycle(username, stats)})
+	if err != nil {
+		return nil, err
+	}
+
+	return lobbyFromInfo(lobby), nil
+}
+
+func (s *RacingService) JoinLobby(ctx context.Context, code string, username string, stats *Ownership) error {
+	// The race may start once joined, the results are notified before returning
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.JoinLobby"))
+	defer cancel()
+
+	_, err := pb.NewRacingClient(s.conn).JoinLobby(ctx, &pb.LobbyMotorcycle{Code: code, Motorcycle: raceMotorcycle(username, stats)})
+	return err
+}
+
+func (s *RacingService) LeaveLobby(ctx context.Context, code string, username string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.LeaveLobby"))
+	defer cancel()
+
+	_, err := pb.NewRacingClient(s.conn).LeaveLobby(ctx, &pb.LobbyPlayer{Code: code, Username: username})
+	return err
+}
+
+func (s *RacingService) StartLobbyRace(ctx context.Context, code string, username string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.StartLobbyRace"))
+	defer cancel()
+
+	_, err := pb.NewRacingClient(s.conn).StartLobbyRace(ctx, &pb.LobbyPlayer{Code: code, Username: username})
+	return err
+}
+
+func (s *RacingService) GetLobby(ctx context.Context, code string) (*Lobby, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.GetLobby"))
+	defer cancel()
+
+	lobby, err := pb.NewRacingClient(s.conn).GetLobby(ctx, &pb.LobbyReference{Code: code})
+	if err != nil {
+		return nil, err
+	}
+
+	return lobbyFromInfo(lobby), nil
+}
+
+func (s *RacingService) GetPlayerLobbies(ctx context.Context, username string) ([]*Lobby, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.GetPlayerLobbies"))
+	defer cancel()
+
+	stream, err := pb.NewRacingClient(s.conn).GetPlayerLobbies(ctx, &pb.PlayerUsername{Username: username})
+	if err != nil {
+		return nil, err
+	}
+
+	var lobbies []*Lobby
+	for {
+		l, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetPlayerLobbies failed", "error", err)
+			return nil, err
+		}
+
+		lobbies = append(lobbies, lobbyFromInfo(l))
+	}
+
+	return lobbies, nil
+}
+
+func championshipFromInfo(c *pb.ChampionshipInfo) *Championship {
+	return &Championship{
+		Id:              int(c.Id),
+		Name:            c.Name,
+		Organizer:       c.Organizer,
+		Status:          c.Status,
+		MaxEntrants:     int(c.MaxEntrants),
+		Entrants:        int(c.Entrants),
+		Rounds:          int(c.Rounds),
+		RoundsCompleted: int(c.RoundsCompleted),
+		StartsAt:        c.StartsAt.AsTime(),
+	}
+}
+
+func (s *RacingService) CreateChampionship(ctx context.Context, organizer string, name string, tracks []int, starts_at time.Time, interval_minutes int, max_entrants int) (*Championship, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.CreateChampionship"))
+	defer cancel()
+
+	track_ids := make([]int32, 0, len(tracks))
+	for _, t := range tracks {
+		track_ids = append(track_ids, int32(t))
+	}
+
+	c, err := pb.NewRacingClient(s.conn).CreateChampionship(ctx, &pb.ChampionshipSettings{
+		Organizer:            organizer,
+		Name:                 name,
+		TrackIds:             track_ids,
+		StartsAt:             timestamppb.New(starts_at),
+		RoundIntervalMinutes: int32(interval_minutes),
+		MaxEntrants:          int32(max_entrants),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return championshipFromInfo(c), nil
+}
+
+func (s *RacingService) RegisterChampionship(ctx context.Context, id int, username string, stats *Ownership) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.RegisterChampionship"))
+	defer cancel()
+
+	_, err := pb.NewRacingClient(s.conn).RegisterChampionship(ctx, &pb.ChampionshipEntry{ChampionshipId: int32(id), Motorcycle: raceMotorcycle(username, stats)})
+	return err
+}
+
+func (s *RacingService) GetChampionships(ctx context.Context) ([]*Championship, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.GetChampionships"))
+	defer cancel()
+
+	stream, err := pb.NewRacingClient(s.conn).GetChampionships(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var championships []*Championship
+	for {
+		c, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetChampionships failed", "error", err)
+			return nil, err
+		}
+
+		championships = append(championships, championshipFromInfo(c))
+	}
+
+	return championships, nil
+}
+
+func (s *RacingService) GetChampionship(ctx context.Context, id int) (*Championship, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.GetChampionship"))
+	defer cancel()
+
+	c, err := pb.NewRacingClient(s.conn).GetChampionship(ctx, &pb.ChampionshipReference{ChampionshipId: int32(id)})
+	if err != nil {
+		return nil, err
+	}
+
+	return championshipFromInfo(c), nil
+}
+
+func (s *RacingService) GetChampionshipSchedule(ctx context.Context, id int) ([]*ChampionshipRound, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.GetChampionshipSchedule"))
+	defer cancel()
+
+	stream, err := pb.NewRacingClient(s.conn).GetChampionshipSchedule(ctx, &pb.ChampionshipReference{ChampionshipId: int32(id)})
+	if err != nil {
+		return nil, err
+	}
+
+	var rounds []*ChampionshipRound
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetChampionshipSchedule failed", "error", err)
+			return nil, err
+		}
+
+		rounds = append(rounds, &ChampionshipRound{
+			Round:     int(r.Round),
+			TrackName: r.TrackName,
+			StartsAt:  r.StartsAt.AsTime(),
+			Completed: r.Completed,
+			Winner:    r.Winner,
+			Weather:   r.Weather,
+		})
+	}
+
+	return rounds, nil
+}
+
+func (s *RacingService) GetChampionshipStandings(ctx context.Context, id int) ([]*ChampionshipStanding, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.GetChampionshipStandings"))
+	defer cancel()
+
+	stream, err := pb.NewRacingClient(s.conn).GetChampionshipStandings(ctx, &pb.ChampionshipReference{ChampionshipId: int32(id)})
+	if err != nil {
+		return nil, err
+	}
+
+	var standings []*ChampionshipStanding
+	for {
+		st, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetChampionshipStandings failed", "error", err)
+			return nil, err
+		}
+
+		standings = append(standings, &ChampionshipStanding{
+			Username:       st.Username,
+			MotorcycleName: st.MotorcycleName,
+			Points:         int(st.Points),
+			Wins:           int(st.Wins),
+			Position:       int(st.Position),
+		})
+	}
+
+	return standings, nil
+}
+
+func (s *RacingService) RunTimeTrial(ctx context.Context, username string, stats *Ownership, track_id int) (*TimeTrialResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.RunTimeTrial"))
+	defer cancel()
+
+	r, err := pb.NewRacingClient(s.conn).RunTimeTrial(ctx, &pb.TimeTrialRequest{TrackId: int32(track_id), Motorcycle: raceMotorcycle(username, stats)})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TimeTrialResult{
+		TrackName:       r.TrackName,
+		LapTime:         LapTime(r.LapTimeMs),
+		PersonalBest:    LapTime(r.PersonalBestMs),
+		TrackRecord:     LapTime(r.TrackRecordMs),
+		RecordHolder:    r.RecordHolder,
+		NewPersonalBest: r.NewPersonalBest,
+		NewTrackRecord:  r.NewTrackRecord,
+	}, nil
+}
+
+func (s *RacingService) GetTimeTrialLeaderboard(ctx context.Context, track_id int) ([]*TimeTrialRecord, error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.GetTimeTrialLeaderboard"))
+	defer cancel()
+
+	stream, err := pb.NewRacingClient(s.conn).GetTimeTrialLeaderboard(ctx, &pb.TrackReference{TrackId: int32(track_id)})
+	if err != nil {
+		return nil, err
+	}
+
+	var records []*TimeTrialRecord
+	for {
+		r, err := stream.Recv()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			slog.ErrorContext(ctx, "GetTimeTrialLeaderboard failed", "error", err)
+			return nil, err
+		}
+
+		records = append(records, &TimeTrialRecord{
+			Username:        r.Username,
+			Position:        int(r.Position),
+			LapTime:         LapTime(r.LapTimeMs),
+			MotorcycleName:  r.MotorcycleName,
+			MotorcycleLevel: int(r.MotorcycleLevel),
+			Time:            r.Time.AsTime(),
+		})
+	}
+
+	return records, nil
+}
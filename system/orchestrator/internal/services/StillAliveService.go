@@ -3,20 +3,20 @@ package services
 import (
 	"context"
 	pb "orchestrator/proto"
-	"time"
+	"orchestrator/telemetry"
 
 	"google.golang.org/grpc"
 )
 
 type StillAlive interface {
-	StillAlive() bool
+	StillAlive(ctx context.Context) bool
 	Close()
 }
 
-func StillAliveHandle(conn *grpc.ClientConn) bool {
+func StillAliveHandle(ctx context.Context, conn *grpc.ClientConn) bool {
 	// Function used by service to check if underlying connection is still alive
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("StillAlive.StillAlive"))
 	defer cancel()
 
 	_, err := pb.NewStillAliveClient(conn).StillAlive(ctx, nil)
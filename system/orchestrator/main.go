@@ -1,25 +1,40 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"os"
+	"time"
 
 	"orchestrator/internal"
+	"orchestrator/telemetry"
 
 	pb "orchestrator/proto"
 
 	"github.com/gin-gonic/contrib/sessions"
 	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 	"google.golang.org/grpc"
 )
 
 func main() {
+	// Structured log of the Orchestrator, level set by LOG_LEVEL
+	telemetry.InitLogging("orchestrator")
+
 	// Cookie store using env variable session_key
 	store := sessions.NewCookieStore([]byte(os.Getenv("SESSION_KEY")))
 
-	r := gin.Default()
+	// Traces of the Orchestrator, exported as set by TRACING_EXPORTER
+	shutdown := telemetry.InitTracing("orchestrator")
+	defer shutdown(context.Background())
+
+	r := gin.New()
+	r.Use(gin.Recovery())
+	r.Use(otelgin.Middleware("orchestrator")) // span of every request, parent of the gRPC calls made to serve it
+	r.Use(internal.Correlated)                // correlation ID and log line of every request
 	r.Use(sessions.Sessions("session", store))
 	r.LoadHTMLGlob("./templates/*") // load templates
 
@@ -27,6 +42,15 @@ func main() {
 	orchestrator := internal.NewOrchestrator(internal.NewRandomLoadBalancer())
 	go startOrchestratorService(orchestrator)
 
+	// Metrics of the Orchestrator for Prometheus
+	go telemetry.ServeMetrics(os.Getenv("METRICS_PORT"))
+
+	// Periodic rollover of ended seasons
+	go rolloverSeasons(orchestrator)
+
+	// Periodic payment of the badge rewards left unpaid
+	go payPendingRewards(orchestrator)
+
 	// Handle routes
 	routes := internal.NewMyRoutes(orchestrator)
 
@@ -35,6 +59,7 @@ func main() {
 	r.POST("/login", routes.LoginRoute)
 	r.POST("/register", routes.RegisterRoute)
 	r.GET("/leaderboard", routes.LeaderboardRoute)
+	r.GET("/leaderboard/boards", routes.BoardsRoute)
 
 	// Group of routes that need Authorization
 	private := r.Group("/private")
@@ -46,10 +71,62 @@ func main() {
 		private.GET("/garage", routes.GarageRoute)
 		private.POST("/garage/buy", routes.GarageBuyRoute)
 		private.POST("/garage/upgrade", routes.GarageUpgradeRoute)
+		private.POST("/garage/repair", routes.GarageRepairRoute)
+		private.POST("/garage/customize", routes.GarageCustomizeRoute)
+		private.POST("/garage/parts/buy", routes.GaragePartBuyRoute)
+		private.POST("/garage/parts/equip", routes.GaragePartEquipRoute)
+		private.POST("/garage/parts/unequip", routes.GaragePartUnequipRoute)
+
+		private.GET("/market", routes.MarketRoute)
+		private.POST("/market/list", routes.MarketListRoute)
+		private.POST("/market/buy", routes.MarketBuyRoute)
+		private.POST("/market/cancel", routes.MarketCancelRoute)
+
+		private.GET("/auctions", routes.AuctionsRoute)
+		private.GET("/auctions/:id", routes.AuctionRoute)
+		private.GET("/auctions/:id/watch", routes.AuctionWatchRoute)
+		private.POST("/auctions/create", routes.AuctionCreateRoute)
+		private.POST("/auctions/bid", routes.AuctionBidRoute)
 
 		private.POST("/race/start", routes.RaceStartRoute)
 
+		private.GET("/lobbies", routes.LobbiesRoute)
+		private.GET("/lobbies/:code", routes.LobbyRoute)
+		private.POST("/lobbies/create", routes.LobbyCreateRoute)
+		private.POST("/lobbies/join", routes.LobbyJoinRoute)
+		private.POST("/lobbies/leave", routes.LobbyLeaveRoute)
+		private.POST("/lobbies/start", routes.LobbyStartRoute)
+
+		private.GET("/championships", routes.ChampionshipsRoute)
+		private.GET("/championships/:id", routes.ChampionshipRoute)
+		private.POST("/championships/create", routes.ChampionshipCreateRoute)
+		private.POST("/championships/register", routes.ChampionshipRegisterRoute)
+
+		private.GET("/timetrial", routes.TimeTrialRoute)
+		private.POST("/timetrial/run", routes.TimeTrialRunRoute)
+
 		private.GET("/history", routes.RaceHistoryRoute)
+
+		private.GET("/friends", routes.FriendsRoute)
+		private.POST("/friends/request", routes.FriendRequestRoute)
+		private.POST("/friends/accept", routes.FriendAcceptRoute)
+		private.POST("/friends/decline", routes.FriendDeclineRoute)
+		private.POST("/friends/remove", routes.FriendRemoveRoute)
+
+		private.GET("/clans", routes.ClansRoute)
+		private.GET("/clans/:id", routes.ClanRoute)
+		private.POST("/clans/create", routes.ClanCreateRoute)
+		private.POST("/clans/invite", routes.ClanInviteRoute)
+		private.POST("/clans/accept", routes.ClanAcceptRoute)
+		private.POST("/clans/decline", routes.ClanDeclineRoute)
+		private.POST("/clans/leave", routes.ClanLeaveRoute)
+		private.POST("/clans/deposit", routes.ClanDepositRoute)
+		private.POST("/clans/withdraw", routes.ClanWithdrawRoute)
+
+		private.GET("/notifications", routes.NotificationsRoute)
+		private.GET("/notifications/watch", routes.NotificationsWatchRoute)
+		private.POST("/notifications/read", routes.NotificationReadRoute)
+		private.POST("/notifications/readall", routes.NotificationsReadAllRoute)
 	}
 
 	// Run webserver on env web_port
@@ -57,17 +134,35 @@ func main() {
 }
 
 func startOrchestratorService(orchestrator *internal.Orchestrator) {
-	log.Printf("Starting Orchestrator Service")
+	slog.Info("Starting Orchestrator Service")
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", os.Getenv("SERVICE_PORT")))
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
 
 	// Creation of gRPC Server
-	s := grpc.NewServer()
+	s := grpc.NewServer(telemetry.ServerOption())
 	pb.RegisterOrchestratorServer(s, orchestrator)
-	log.Printf("server listening at %v", lis.Addr())
+	slog.Info("server listening", "address", lis.Addr().String())
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
 	}
 }
+
+func rolloverSeasons(orchestrator *internal.Orchestrator) {
+	for {
+		if err := orchestrator.RolloverSeason(context.Background()); err != nil {
+			slog.Error("RolloverSeason failed", "error", err)
+		}
+		time.Sleep(time.Minute)
+	}
+}
+
+func payPendingRewards(orchestrator *internal.Orchestrator) {
+	for {
+		if err := orchestrator.PayPendingRewards(context.Background()); err != nil {
+			slog.Error("PayPendingRewards failed", "error", err)
+		}
+		time.Sleep(time.Minute)
+	}
+}
@@ -0,0 +1,62 @@
+-- Migration of an existing Racing database to championships.
+USE Racing;
+
+CREATE TABLE IF NOT EXISTS Championships (
+  Id int NOT NULL AUTO_INCREMENT,
+  Name varchar(32) NOT NULL,
+  Organizer varchar(32) NOT NULL,
+  Status enum('open', 'running', 'completed', 'cancelled') NOT NULL DEFAULT 'open', -- registrations are accepted until the first round
+  MaxEntrants int NOT NULL,
+  CreatedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Id),
+  CHECK (MaxEntrants >= 2)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS ChampionshipRounds (
+  ChampionshipId int NOT NULL,
+  Round int NOT NULL,
+  TrackId int NOT NULL,
+  StartsAt timestamp NOT NULL,
+  Completed boolean NOT NULL DEFAULT FALSE,
+  Winner varchar(32) NULL,
+  PRIMARY KEY (ChampionshipId, Round),
+  FOREIGN KEY (ChampionshipId) REFERENCES Championships(Id),
+  FOREIGN KEY (TrackId) REFERENCES Tracks(Id)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS ChampionshipEntrants (
+  ChampionshipId int NOT NULL,
+  PlayerUsername varchar(32) NOT NULL,
+  MotorcycleId int NOT NULL,
+  MotorcycleName varchar(32) NOT NULL,
+  MotorcycleLevel int NOT NULL, -- stats at registration, used for every round
+  MotorcycleEngine int NOT NULL,
+  MotorcycleBrakes int NOT NULL,
+  MotorcycleAgility int NOT NULL,
+  MotorcycleAerodynamics int NOT NULL,
+  Paint varchar(32) NOT NULL DEFAULT '',
+  PaintColor char(7) NOT NULL DEFAULT '',
+  RaceNumber int NOT NULL DEFAULT 0,
+  RiderName varchar(32) NOT NULL DEFAULT '',
+  Points int NOT NULL DEFAULT 0,
+  Wins int NOT NULL DEFAULT 0,
+  PRIMARY KEY (ChampionshipId, PlayerUsername),
+  FOREIGN KEY (ChampionshipId) REFERENCES Championships(Id)
+) ENGINE=InnoDB;
+
+CREATE OR REPLACE VIEW DetailedChampionships AS
+SELECT E.ChampionshipId, Round, PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, Paint, PaintColor, RaceNumber, RiderName, T.Name as TrackName, COUNT(*) OVER (PARTITION BY E.ChampionshipId, Round) as Entrants, RANK() OVER (PARTITION BY E.ChampionshipId, Round ORDER BY (MotorcycleEngine * EngineValue + MotorcycleAgility * AgilityValue + MotorcycleBrakes * BrakesValue + MotorcycleAerodynamics * AerodynamicsValue) DESC) as Position
+FROM ChampionshipEntrants E
+INNER JOIN ChampionshipRounds R ON E.ChampionshipId=R.ChampionshipId
+INNER JOIN Tracks T ON R.TrackId=T.Id;
+
+-- Results of the championships raced before the notified flags were delivered already
+ALTER TABLE Championships
+  ADD COLUMN IF NOT EXISTS Notified boolean NOT NULL DEFAULT TRUE,
+  ADD COLUMN IF NOT EXISTS ClaimedAt timestamp NULL;
+ALTER TABLE Championships ALTER COLUMN Notified SET DEFAULT FALSE;
+UPDATE Championships SET Notified=FALSE WHERE Status IN ('open', 'running');
+
+ALTER TABLE ChampionshipRounds ADD COLUMN IF NOT EXISTS Notified boolean NOT NULL DEFAULT TRUE;
+ALTER TABLE ChampionshipRounds ALTER COLUMN Notified SET DEFAULT FALSE;
+UPDATE ChampionshipRounds SET Notified=FALSE WHERE NOT Completed;
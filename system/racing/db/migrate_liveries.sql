@@ -0,0 +1,19 @@
+-- Migration of an existing Racing database to motorcycle liveries.
+USE Racing;
+
+ALTER TABLE Matchmaking
+  ADD COLUMN IF NOT EXISTS Paint varchar(32) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS PaintColor char(7) NOT NULL DEFAULT '',
+  ADD COLUMN IF NOT EXISTS RaceNumber int NOT NULL DEFAULT 0,
+  ADD COLUMN IF NOT EXISTS RiderName varchar(32) NOT NULL DEFAULT '';
+
+ALTER TABLE History
+  ADD COLUMN IF NOT EXISTS Paint varchar(32) NOT NULL DEFAULT '' AFTER MotorcycleLevel,
+  ADD COLUMN IF NOT EXISTS PaintColor char(7) NOT NULL DEFAULT '' AFTER Paint,
+  ADD COLUMN IF NOT EXISTS RaceNumber int NOT NULL DEFAULT 0 AFTER PaintColor,
+  ADD COLUMN IF NOT EXISTS RiderName varchar(32) NOT NULL DEFAULT '' AFTER RaceNumber;
+
+CREATE OR REPLACE VIEW DetailedMatchmaking AS
+SELECT PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, Paint, PaintColor, RaceNumber, RiderName, TrackId, T.Name as Trackname, MaxMotorcycles - COUNT(*) OVER (PARTITION BY TrackId) as FreeSlots, MaxMotorcycles, (MotorcycleEngine * EngineValue + MotorcycleAgility * AgilityValue + MotorcycleBrakes * BrakesValue + MotorcycleAerodynamics * AerodynamicsValue) as Power, RANK() OVER (PARTITION BY TrackId ORDER BY (MotorcycleEngine * EngineValue + MotorcycleAgility * AgilityValue + MotorcycleBrakes * BrakesValue + MotorcycleAerodynamics * AerodynamicsValue) DESC) as Position
+FROM Matchmaking M
+INNER JOIN Tracks T ON M.TrackId=T.Id;
@@ -0,0 +1,37 @@
+-- Migration of an existing Racing database to private lobbies.
+USE Racing;
+
+CREATE TABLE IF NOT EXISTS Lobbies (
+  Code char(6) NOT NULL, -- invite code shared by the host
+  Host varchar(32) NOT NULL,
+  TrackId int NOT NULL,
+  Friendly boolean NOT NULL DEFAULT FALSE, -- no money, points or rating for the result
+  CreatedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Code),
+  FOREIGN KEY (TrackId) REFERENCES Tracks(Id)
+) ENGINE=InnoDB;
+
+CREATE TABLE IF NOT EXISTS LobbyEntries (
+  Code char(6) NOT NULL,
+  PlayerUsername varchar(32) NOT NULL,
+  MotorcycleId int NOT NULL,
+  MotorcycleName varchar(32) NOT NULL,
+  MotorcycleLevel int NOT NULL,
+  MotorcycleEngine int NOT NULL,
+  MotorcycleBrakes int NOT NULL,
+  MotorcycleAgility int NOT NULL,
+  MotorcycleAerodynamics int NOT NULL,
+  Paint varchar(32) NOT NULL DEFAULT '',
+  PaintColor char(7) NOT NULL DEFAULT '',
+  RaceNumber int NOT NULL DEFAULT 0,
+  RiderName varchar(32) NOT NULL DEFAULT '',
+  JoinedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Code, PlayerUsername), -- one motorcycle for each player
+  FOREIGN KEY (Code) REFERENCES Lobbies(Code)
+) ENGINE=InnoDB;
+
+CREATE OR REPLACE VIEW DetailedLobbies AS
+SELECT PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, Paint, PaintColor, RaceNumber, RiderName, E.Code, Host, Friendly, TrackId, T.Name as TrackName, MaxMotorcycles - COUNT(*) OVER (PARTITION BY E.Code) as FreeSlots, MaxMotorcycles, RANK() OVER (PARTITION BY E.Code ORDER BY (MotorcycleEngine * EngineValue + MotorcycleAgility * AgilityValue + MotorcycleBrakes * BrakesValue + MotorcycleAerodynamics * AerodynamicsValue) DESC) as Position, JoinedAt
+FROM LobbyEntries E
+INNER JOIN Lobbies L ON E.Code=L.Code
+INNER JOIN Tracks T ON L.TrackId=T.Id;
@@ -0,0 +1,12 @@
+-- Migration of an existing Racing database to the matchmaking wait time.
+-- Apply after migrate_weather.sql.
+USE Racing;
+
+ALTER TABLE Matchmaking
+  ADD COLUMN IF NOT EXISTS JoinedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP AFTER RiderName;
+
+CREATE OR REPLACE VIEW DetailedMatchmaking AS
+SELECT PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, Paint, PaintColor, RaceNumber, RiderName, TrackId, T.Name as Trackname, T.Weather, MaxMotorcycles - COUNT(*) OVER (PARTITION BY TrackId) as FreeSlots, MaxMotorcycles, (MotorcycleEngine * EngineValue * EngineModifier + MotorcycleAgility * AgilityValue * AgilityModifier + MotorcycleBrakes * BrakesValue * BrakesModifier + MotorcycleAerodynamics * AerodynamicsValue * AerodynamicsModifier) as Power, RANK() OVER (PARTITION BY TrackId ORDER BY (MotorcycleEngine * EngineValue * EngineModifier + MotorcycleAgility * AgilityValue * AgilityModifier + MotorcycleBrakes * BrakesValue * BrakesModifier + MotorcycleAerodynamics * AerodynamicsValue * AerodynamicsModifier) DESC) as Position, JoinedAt
+FROM Matchmaking M
+INNER JOIN Tracks T ON M.TrackId=T.Id
+INNER JOIN Weathers W ON T.Weather=W.Name;
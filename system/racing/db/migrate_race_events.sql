@@ -0,0 +1,15 @@
+-- Migration of an existing Racing database to the race results published from the database.
+-- Apply after migrate_matchmaking_wait.sql.
+USE Racing;
+
+CREATE TABLE IF NOT EXISTS RaceEvents ( -- results to publish on the event bus, written with the History
+  Id int AUTO_INCREMENT NOT NULL,
+  PlayerUsername varchar(32) NOT NULL,
+  TrackName varchar(32) NOT NULL,
+  Position int NOT NULL,
+  TotalMotorcycles int NOT NULL,
+  MotorcycleId int NOT NULL,
+  MotorcycleName varchar(32) NOT NULL,
+  ClaimedAt timestamp NULL DEFAULT NULL, -- replica publishing the event, another one tries again when it is old
+  PRIMARY KEY (Id)
+) ENGINE=InnoDB;
@@ -0,0 +1,25 @@
+-- Migration of an existing Racing database to time trials.
+USE Racing;
+
+CREATE TABLE IF NOT EXISTS TimeTrials (
+  Id int NOT NULL AUTO_INCREMENT,
+  PlayerUsername varchar(32) NOT NULL,
+  TrackId int NOT NULL,
+  MotorcycleId int NOT NULL,
+  MotorcycleName varchar(32) NOT NULL,
+  MotorcycleLevel int NOT NULL,
+  LapTime int NOT NULL, -- milliseconds
+  Time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Id),
+  INDEX (TrackId, LapTime),
+  INDEX PlayerUsername (PlayerUsername, Time),
+  FOREIGN KEY (TrackId) REFERENCES Tracks(Id)
+) ENGINE=InnoDB;
+
+CREATE OR REPLACE VIEW TimeTrialBests AS
+SELECT TrackId, PlayerUsername, MotorcycleName, MotorcycleLevel, LapTime, Time, RANK() OVER (PARTITION BY TrackId ORDER BY LapTime) AS Position
+FROM (SELECT TrackId, PlayerUsername, MotorcycleName, MotorcycleLevel, LapTime, Time, ROW_NUMBER() OVER (PARTITION BY TrackId, PlayerUsername ORDER BY LapTime, Id) AS PersonalRank FROM TimeTrials) T
+WHERE PersonalRank = 1;
+
+-- Laps of a player are counted to limit their rate
+ALTER TABLE TimeTrials ADD INDEX IF NOT EXISTS PlayerUsername (PlayerUsername, Time);
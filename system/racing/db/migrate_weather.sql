@@ -0,0 +1,50 @@
+-- Migration of an existing Racing database to weather conditions.
+-- Apply after migrate_time_trials.sql.
+USE Racing;
+
+CREATE TABLE IF NOT EXISTS Weathers (
+  Name varchar(16) NOT NULL,
+  EngineModifier int NOT NULL, -- percentage of the track value, 100 leaves it unchanged
+  BrakesModifier int NOT NULL,
+  AgilityModifier int NOT NULL,
+  AerodynamicsModifier int NOT NULL,
+  PRIMARY KEY (Name)
+) ENGINE=InnoDB;
+
+-- Weathers shipped with setup.sql
+INSERT IGNORE INTO Weathers VALUES ("dry", 100, 100, 100, 100), ("wet", 60, 150, 130, 80), ("windy", 90, 100, 120, 160);
+
+ALTER TABLE Tracks
+  ADD COLUMN IF NOT EXISTS Weather varchar(16) NOT NULL DEFAULT 'dry',
+  ADD CONSTRAINT TracksWeather FOREIGN KEY IF NOT EXISTS (Weather) REFERENCES Weathers(Name);
+
+ALTER TABLE Lobbies
+  ADD COLUMN IF NOT EXISTS Weather varchar(16) NOT NULL DEFAULT 'dry',
+  ADD CONSTRAINT LobbiesWeather FOREIGN KEY IF NOT EXISTS (Weather) REFERENCES Weathers(Name);
+
+ALTER TABLE ChampionshipRounds
+  ADD COLUMN IF NOT EXISTS Weather varchar(16) NOT NULL DEFAULT 'dry',
+  ADD CONSTRAINT ChampionshipRoundsWeather FOREIGN KEY IF NOT EXISTS (Weather) REFERENCES Weathers(Name);
+
+ALTER TABLE History
+  ADD COLUMN IF NOT EXISTS Weather varchar(16) NOT NULL DEFAULT 'dry' AFTER RiderName;
+
+CREATE OR REPLACE VIEW DetailedMatchmaking AS
+SELECT PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, Paint, PaintColor, RaceNumber, RiderName, TrackId, T.Name as Trackname, T.Weather, MaxMotorcycles - COUNT(*) OVER (PARTITION BY TrackId) as FreeSlots, MaxMotorcycles, (MotorcycleEngine * EngineValue * EngineModifier + MotorcycleAgility * AgilityValue * AgilityModifier + MotorcycleBrakes * BrakesValue * BrakesModifier + MotorcycleAerodynamics * AerodynamicsValue * AerodynamicsModifier) as Power, RANK() OVER (PARTITION BY TrackId ORDER BY (MotorcycleEngine * EngineValue * EngineModifier + MotorcycleAgility * AgilityValue * AgilityModifier + MotorcycleBrakes * BrakesValue * BrakesModifier + MotorcycleAerodynamics * AerodynamicsValue * AerodynamicsModifier) DESC) as Position
+FROM Matchmaking M
+INNER JOIN Tracks T ON M.TrackId=T.Id
+INNER JOIN Weathers W ON T.Weather=W.Name;
+
+CREATE OR REPLACE VIEW DetailedLobbies AS
+SELECT PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, Paint, PaintColor, RaceNumber, RiderName, E.Code, Host, Friendly, TrackId, T.Name as TrackName, L.Weather, MaxMotorcycles - COUNT(*) OVER (PARTITION BY E.Code) as FreeSlots, MaxMotorcycles, RANK() OVER (PARTITION BY E.Code ORDER BY (MotorcycleEngine * EngineValue * EngineModifier + MotorcycleAgility * AgilityValue * AgilityModifier + MotorcycleBrakes * BrakesValue * BrakesModifier + MotorcycleAerodynamics * AerodynamicsValue * AerodynamicsModifier) DESC) as Position, JoinedAt
+FROM LobbyEntries E
+INNER JOIN Lobbies L ON E.Code=L.Code
+INNER JOIN Tracks T ON L.TrackId=T.Id
+INNER JOIN Weathers W ON L.Weather=W.Name;
+
+CREATE OR REPLACE VIEW DetailedChampionships AS
+SELECT E.ChampionshipId, Round, PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, Paint, PaintColor, RaceNumber, RiderName, T.Name as TrackName, R.Weather, COUNT(*) OVER (PARTITION BY E.ChampionshipId, Round) as Entrants, RANK() OVER (PARTITION BY E.ChampionshipId, Round ORDER BY (MotorcycleEngine * EngineValue * EngineModifier + MotorcycleAgility * AgilityValue * AgilityModifier + MotorcycleBrakes * BrakesValue * BrakesModifier + MotorcycleAerodynamics * AerodynamicsValue * AerodynamicsModifier) DESC) as Position
+FROM ChampionshipEntrants E
+INNER JOIN ChampionshipRounds R ON E.ChampionshipId=R.ChampionshipId
+INNER JOIN Tracks T ON R.TrackId=T.Id
+INNER JOIN Weathers W ON R.Weather=W.Name;
@@ -2,6 +2,16 @@ DROP DATABASE IF EXISTS Racing;
 CREATE DATABASE IF NOT EXISTS Racing;
 USE Racing;
 
+DROP TABLE IF EXISTS Weathers;
+CREATE TABLE IF NOT EXISTS Weathers (
+  Name varchar(16) NOT NULL,
+  EngineModifier int NOT NULL, -- percentage of the track value, 100 leaves it unchanged
+  BrakesModifier int NOT NULL,
+  AgilityModifier int NOT NULL,
+  AerodynamicsModifier int NOT NULL,
+  PRIMARY KEY (Name)
+) ENGINE=InnoDB;
+
 DROP TABLE IF EXISTS Tracks;
 CREATE TABLE IF NOT EXISTS Tracks (
   Id int NOT NULL,
@@ -11,7 +21,9 @@ CREATE TABLE IF NOT EXISTS Tracks (
   BrakesValue int NOT NULL,
   AgilityValue int NOT NULL,
   AerodynamicsValue int NOT NULL,
-  PRIMARY KEY (Id)
+  Weather varchar(16) NOT NULL DEFAULT 'dry', -- forecast of the next matchmaking race, changed after every race
+  PRIMARY KEY (Id),
+  FOREIGN KEY (Weather) REFERENCES Weathers(Name)
 ) ENGINE=InnoDB;
 
 DROP TABLE IF EXISTS Matchmaking;
@@ -25,14 +37,20 @@ CREATE TABLE IF NOT EXISTS Matchmaking (
   MotorcycleBrakes int NOT NULL,
   MotorcycleAgility int NOT NULL,
   MotorcycleAerodynamics int NOT NULL,
+  Paint varchar(32) NOT NULL DEFAULT '',
+  PaintColor char(7) NOT NULL DEFAULT '',
+  RaceNumber int NOT NULL DEFAULT 0,
+  RiderName varchar(32) NOT NULL DEFAULT '',
+  JoinedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
   PRIMARY KEY (PlayerUsername, MotorcycleId, TrackId),
   FOREIGN KEY (TrackId) REFERENCES Tracks(Id)
 ) ENGINE=InnoDB;
 
 CREATE VIEW DetailedMatchmaking AS
-SELECT PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, TrackId, T.Name as Trackname, MaxMotorcycles - COUNT(*) OVER (PARTITION BY TrackId) as FreeSlots, MaxMotorcycles, (MotorcycleEngine * EngineValue + MotorcycleAgility * AgilityValue + MotorcycleBrakes * BrakesValue + MotorcycleAerodynamics * AerodynamicsValue) as Power, RANK() OVER (PARTITION BY TrackId ORDER BY (MotorcycleEngine * EngineValue + MotorcycleAgility * AgilityValue + MotorcycleBrakes * BrakesValue + MotorcycleAerodynamics * AerodynamicsValue) DESC) as Position
+SELECT PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, Paint, PaintColor, RaceNumber, RiderName, TrackId, T.Name as Trackname, T.Weather, MaxMotorcycles - COUNT(*) OVER (PARTITION BY TrackId) as FreeSlots, MaxMotorcycles, (MotorcycleEngine * EngineValue * EngineModifier + MotorcycleAgility * AgilityValue * AgilityModifier + MotorcycleBrakes * BrakesValue * BrakesModifier + MotorcycleAerodynamics * AerodynamicsValue * AerodynamicsModifier) as Power, RANK() OVER (PARTITION BY TrackId ORDER BY (MotorcycleEngine * EngineValue * EngineModifier + MotorcycleAgility * AgilityValue * AgilityModifier + MotorcycleBrakes * BrakesValue * BrakesModifier + MotorcycleAerodynamics * AerodynamicsValue * AerodynamicsModifier) DESC) as Position, JoinedAt
 FROM Matchmaking M
-INNER JOIN Tracks T ON M.TrackId=T.Id;
+INNER JOIN Tracks T ON M.TrackId=T.Id
+INNER JOIN Weathers W ON T.Weather=W.Name;
 
 DELIMITER $$
 
@@ -69,8 +87,146 @@ CREATE TABLE IF NOT EXISTS History (
   TrackName varchar(32) NOT NULL,
   MotorcycleName varchar(32) NOT NULL,
   MotorcycleLevel int NOT NULL,
+  Paint varchar(32) NOT NULL DEFAULT '',
+  PaintColor char(7) NOT NULL DEFAULT '',
+  RaceNumber int NOT NULL DEFAULT 0,
+  RiderName varchar(32) NOT NULL DEFAULT '',
+  Weather varchar(16) NOT NULL DEFAULT 'dry',
   Time timestamp DEFAULT CURRENT_TIMESTAMP,
   PRIMARY KEY (RaceId)
 ) ENGINE=InnoDB;
 
-INSERT INTO Tracks VALUES (1, "Mugello", 3, 5, 5, 5, 5), (2, "Franciacorta", 2, 2, 4, 1, 1);
+DROP TABLE IF EXISTS RaceEvents;
+CREATE TABLE IF NOT EXISTS RaceEvents ( -- results to publish on the event bus, written with the History
+  Id int AUTO_INCREMENT NOT NULL,
+  PlayerUsername varchar(32) NOT NULL,
+  TrackName varchar(32) NOT NULL,
+  Position int NOT NULL,
+  TotalMotorcycles int NOT NULL,
+  MotorcycleId int NOT NULL,
+  MotorcycleName varchar(32) NOT NULL,
+  ClaimedAt timestamp NULL DEFAULT NULL, -- replica publishing the event, another one tries again when it is old
+  PRIMARY KEY (Id)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS Lobbies;
+CREATE TABLE IF NOT EXISTS Lobbies (
+  Code char(6) NOT NULL, -- invite code shared by the host
+  Host varchar(32) NOT NULL,
+  TrackId int NOT NULL,
+  Friendly boolean NOT NULL DEFAULT FALSE, -- no money, points or rating for the result
+  Weather varchar(16) NOT NULL DEFAULT 'dry', -- generated when the lobby is created
+  CreatedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Code),
+  FOREIGN KEY (TrackId) REFERENCES Tracks(Id),
+  FOREIGN KEY (Weather) REFERENCES Weathers(Name)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS LobbyEntries;
+CREATE TABLE IF NOT EXISTS LobbyEntries (
+  Code char(6) NOT NULL,
+  PlayerUsername varchar(32) NOT NULL,
+  MotorcycleId int NOT NULL,
+  MotorcycleName varchar(32) NOT NULL,
+  MotorcycleLevel int NOT NULL,
+  MotorcycleEngine int NOT NULL,
+  MotorcycleBrakes int NOT NULL,
+  MotorcycleAgility int NOT NULL,
+  MotorcycleAerodynamics int NOT NULL,
+  Paint varchar(32) NOT NULL DEFAULT '',
+  PaintColor char(7) NOT NULL DEFAULT '',
+  RaceNumber int NOT NULL DEFAULT 0,
+  RiderName varchar(32) NOT NULL DEFAULT '',
+  JoinedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Code, PlayerUsername), -- one motorcycle for each player
+  FOREIGN KEY (Code) REFERENCES Lobbies(Code)
+) ENGINE=InnoDB;
+
+CREATE VIEW DetailedLobbies AS
+SELECT PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, Paint, PaintColor, RaceNumber, RiderName, E.Code, Host, Friendly, TrackId, T.Name as TrackName, L.Weather, MaxMotorcycles - COUNT(*) OVER (PARTITION BY E.Code) as FreeSlots, MaxMotorcycles, RANK() OVER (PARTITION BY E.Code ORDER BY (MotorcycleEngine * EngineValue * EngineModifier + MotorcycleAgility * AgilityValue * AgilityModifier + MotorcycleBrakes * BrakesValue * BrakesModifier + MotorcycleAerodynamics * AerodynamicsValue * AerodynamicsModifier) DESC) as Position, JoinedAt
+FROM LobbyEntries E
+INNER JOIN Lobbies L ON E.Code=L.Code
+INNER JOIN Tracks T ON L.TrackId=T.Id
+INNER JOIN Weathers W ON L.Weather=W.Name;
+
+DROP TABLE IF EXISTS Championships;
+CREATE TABLE IF NOT EXISTS Championships (
+  Id int NOT NULL AUTO_INCREMENT,
+  Name varchar(32) NOT NULL,
+  Organizer varchar(32) NOT NULL,
+  Status enum('open', 'running', 'completed', 'cancelled') NOT NULL DEFAULT 'open', -- registrations are accepted until the first round
+  MaxEntrants int NOT NULL,
+  CreatedAt timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  Notified boolean NOT NULL DEFAULT FALSE, -- final standings received by the Orchestrator
+  ClaimedAt timestamp NULL, -- last time a replica started notifying the results
+  PRIMARY KEY (Id),
+  CHECK (MaxEntrants >= 2)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS ChampionshipRounds;
+CREATE TABLE IF NOT EXISTS ChampionshipRounds (
+  ChampionshipId int NOT NULL,
+  Round int NOT NULL,
+  TrackId int NOT NULL,
+  StartsAt timestamp NOT NULL,
+  Completed boolean NOT NULL DEFAULT FALSE,
+  Winner varchar(32) NULL,
+  Weather varchar(16) NOT NULL DEFAULT 'dry', -- forecast generated when the championship is created
+  Notified boolean NOT NULL DEFAULT FALSE, -- results received by the Orchestrator
+  PRIMARY KEY (ChampionshipId, Round),
+  FOREIGN KEY (ChampionshipId) REFERENCES Championships(Id),
+  FOREIGN KEY (TrackId) REFERENCES Tracks(Id),
+  FOREIGN KEY (Weather) REFERENCES Weathers(Name)
+) ENGINE=InnoDB;
+
+DROP TABLE IF EXISTS ChampionshipEntrants;
+CREATE TABLE IF NOT EXISTS ChampionshipEntrants (
+  ChampionshipId int NOT NULL,
+  PlayerUsername varchar(32) NOT NULL,
+  MotorcycleId int NOT NULL,
+  MotorcycleName varchar(32) NOT NULL,
+  MotorcycleLevel int NOT NULL, -- stats at registration, used for every round
+  MotorcycleEngine int NOT NULL,
+  MotorcycleBrakes int NOT NULL,
+  MotorcycleAgility int NOT NULL,
+  MotorcycleAerodynamics int NOT NULL,
+  Paint varchar(32) NOT NULL DEFAULT '',
+  PaintColor char(7) NOT NULL DEFAULT '',
+  RaceNumber int NOT NULL DEFAULT 0,
+  RiderName varchar(32) NOT NULL DEFAULT '',
+  Points int NOT NULL DEFAULT 0,
+  Wins int NOT NULL DEFAULT 0,
+  PRIMARY KEY (ChampionshipId, PlayerUsername),
+  FOREIGN KEY (ChampionshipId) REFERENCES Championships(Id)
+) ENGINE=InnoDB;
+
+CREATE VIEW DetailedChampionships AS
+SELECT E.ChampionshipId, Round, PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, Paint, PaintColor, RaceNumber, RiderName, T.Name as TrackName, R.Weather, COUNT(*) OVER (PARTITION BY E.ChampionshipId, Round) as Entrants, RANK() OVER (PARTITION BY E.ChampionshipId, Round ORDER BY (MotorcycleEngine * EngineValue * EngineModifier + MotorcycleAgility * AgilityValue * AgilityModifier + MotorcycleBrakes * BrakesValue * BrakesModifier + MotorcycleAerodynamics * AerodynamicsValue * AerodynamicsModifier) DESC) as Position
+FROM ChampionshipEntrants E
+INNER JOIN ChampionshipRounds R ON E.ChampionshipId=R.ChampionshipId
+INNER JOIN Tracks T ON R.TrackId=T.Id
+INNER JOIN Weathers W ON R.Weather=W.Name;
+
+DROP TABLE IF EXISTS TimeTrials;
+CREATE TABLE IF NOT EXISTS TimeTrials (
+  Id int NOT NULL AUTO_INCREMENT,
+  PlayerUsername varchar(32) NOT NULL,
+  TrackId int NOT NULL,
+  MotorcycleId int NOT NULL,
+  MotorcycleName varchar(32) NOT NULL,
+  MotorcycleLevel int NOT NULL,
+  LapTime int NOT NULL, -- milliseconds
+  Time timestamp NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY (Id),
+  INDEX (TrackId, LapTime),
+  INDEX PlayerUsername (PlayerUsername, Time),
+  FOREIGN KEY (TrackId) REFERENCES Tracks(Id)
+) ENGINE=InnoDB;
+
+CREATE VIEW TimeTrialBests AS
+SELECT TrackId, PlayerUsername, MotorcycleName, MotorcycleLevel, LapTime, Time, RANK() OVER (PARTITION BY TrackId ORDER BY LapTime) AS Position
+FROM (SELECT TrackId, PlayerUsername, MotorcycleName, MotorcycleLevel, LapTime, Time, ROW_NUMBER() OVER (PARTITION BY TrackId, PlayerUsername ORDER BY LapTime, Id) AS PersonalRank FROM TimeTrials) T
+WHERE PersonalRank = 1;
+
+INSERT INTO Weathers VALUES ("dry", 100, 100, 100, 100), ("wet", 60, 150, 130, 80), ("windy", 90, 100, 120, 160);
+INSERT INTO Tracks VALUES (1, "Mugello", 3, 5, 5, 5, 5, "dry"), (2, "Franciacorta", 2, 2, 4, 1, 1, "dry");
@@ -3,10 +3,23 @@ package internal
 import (
 	"context"
 	"database/sql"
-	"log"
+	"errors"
+	"log/slog"
+	"math/rand"
+	"strings"
 	"time"
+
+	"racing/telemetry"
 )
 
+// Cosmetics chosen in the Garage service, shown to other players
+type Livery struct {
+	Paint      string
+	PaintColor string
+	RaceNumber int
+	RiderName  string
+}
+
 type MotorcycleStats struct {
 	Id           int
 	Name         string
@@ -15,6 +28,7 @@ type MotorcycleStats struct {
 	Brakes       int
 	Agility      int
 	Aerodynamics int
+	Livery
 }
 
 type RaceResult struct {
@@ -26,15 +40,195 @@ type RaceResult struct {
 	TotalMotorcycles int
 	TrackName        string
 	Time             time.Time
+	Wear             int  // applied to the motorcycle by the Garage service
+	Friendly         bool // private race without rewards
+	Weather          string
+	Waited           time.Duration // in matchmaking before the race, zero for the other races
+	Livery
+}
+
+// Result of a race waiting to be published on the event bus
+type RaceEvent struct {
+	Id               int
+	Username         string
+	TrackName        string
+	Position         int
+	TotalMotorcycles int
+	MotorcycleId     int
+	MotorcycleName   string
+}
+
+type Track struct {
+	Id             int
+	Name           string
+	MaxMotorcycles int
+	Weather        string // forecast of the next matchmaking race
+}
+
+// Private race on a track chosen by the host, joined through its invite code
+type Lobby struct {
+	Code           string
+	Host           string
+	TrackName      string
+	MaxMotorcycles int
+	Friendly       bool
+	Weather        string
+	Entries        []LobbyEntry
+}
+
+type LobbyEntry struct {
+	Username string
+	MotorcycleStats
+}
+
+// Series of races on tracks chosen by the organizer, decided by the points of every round
+type Championship struct {
+	Id              int
+	Name            string
+	Organizer       string
+	Status          string // open, running, completed or cancelled
+	MaxEntrants     int
+	Entrants        int
+	Rounds          int
+	RoundsCompleted int
+	StartsAt        time.Time // first round
+	Notified        bool      // final standings received by the Orchestrator
+}
+
+type ChampionshipRound struct {
+	ChampionshipId int
+	Round          int
+	TrackName      string
+	StartsAt       time.Time
+	Completed      bool
+	Winner         string
+	Weather        string
+}
+
+type ChampionshipStanding struct {
+	Username       string
+	MotorcycleName string
+	Points         int
+	Wins           int
+	Position       int
+}
+
+// Points of every round by finishing position, riders further back get nothing
+var championshipPoints = []int{25, 20, 16, 13, 11, 10, 9, 8, 7, 6, 5, 4, 3, 2, 1}
+
+func roundPoints(position int) int {
+	if position < 1 || position > len(championshipPoints) {
+		return 0
+	}
+	return championshipPoints[position-1]
+}
+
+const (
+	maxChampionshipRounds   = 10
+	maxChampionshipEntrants = 20
+)
+
+// Seconds a player waits between two time trial laps
+const timeTrialCooldown = 10
+
+// Lap of a time trial compared with the bests set before it, zero if there were none
+type TimeTrialResult struct {
+	TrackName       string
+	LapTime         int // milliseconds
+	PersonalBest    int // ghost of the player
+	TrackRecord     int
+	RecordHolder    string
+	NewPersonalBest bool
+	NewTrackRecord  bool
+}
+
+// Best lap of a player on a track
+type TimeTrialRecord struct {
+	Username        string
+	Position        int
+	LapTime         int
+	MotorcycleName  string
+	MotorcycleLevel int
+	Time            time.Time
+}
+
+// Best laps shown for each track
+const timeTrialBoardLimit = 20
+
+// Conditions of a new race, each one weights the stats of the motorcycles differently
+func randomWeather(ctx context.Context, tx *sql.Tx) (weather string, e error) {
+	e = tx.QueryRowContext(ctx, "SELECT Name FROM Weathers ORDER BY RAND() LIMIT 1").Scan(&weather)
+	if e != nil {
+		slog.ErrorContext(ctx, "randomWeather failed", "error", e)
+	}
+	return weather, e
+}
+
+// Invite codes avoid characters easily confused with each other
+const (
+	lobbyCodeChars   = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+	lobbyCodeLength  = 6
+	lobbyCodeRetries = 5
+)
+
+func lobbyCode() string {
+	code := make([]byte, lobbyCodeLength)
+	for i := range code {
+		code[i] = lobbyCodeChars[rand.Intn(len(lobbyCodeChars))]
+	}
+	return string(code)
+}
+
+// Wear suffered in a race, motorcycles pushed harder to catch up wear more
+const (
+	raceWearBase        = 5
+	raceWearPerPosition = 3
+)
+
+func raceWear(position int) int {
+	return raceWearBase + raceWearPerPosition*(position-1)
 }
 
 type RacingDB interface {
-	StartMatchmaking(username string, stats *MotorcycleStats) (track int, left int, e error)
-	CompleteRace(track int) ([]RaceResult, error)
-	CheckIsRacing(username string, MotorcycleId int) (track string, e error)
-	GetHistory(username string) ([]RaceResult, error)
+	StartMatchmaking(ctx context.Context, username string, stats *MotorcycleStats) (track int, left int, e error)
+	CompleteRace(ctx context.Context, track int) ([]RaceResult, error)
+	CheckIsRacing(ctx context.Context, username string, MotorcycleId int) (track string, weather string, e error)
+	GetHistory(ctx context.Context, username string) ([]RaceResult, error)
+	GetRecentRaces(ctx context.Context) ([]RaceResult, error)
+	GetPlayersRecentRaces(ctx context.Context, usernames []string) ([]RaceResult, error)
+	GetTracks(ctx context.Context) ([]Track, error)
+	GetMatchmakingQueues(ctx context.Context) (map[string]int, error)
+	CreateLobby(ctx context.Context, username string, stats *MotorcycleStats, track int, friendly bool) (code string, e error)
+	JoinLobby(ctx context.Context, code string, username string, stats *MotorcycleStats) (left int, e error)
+	LeaveLobby(ctx context.Context, code string, username string) error
+	GetLobby(ctx context.Context, code string) (*Lobby, error)
+	GetPlayerLobbies(ctx context.Context, username string) ([]Lobby, error)
+	CheckIsInLobby(ctx context.Context, username string, MotorcycleId int) (code string, track string, weather string, e error)
+	CheckIsInChampionship(ctx context.Context, username string, MotorcycleId int) (ChampionshipId int, e error)
+	CompleteLobbyRace(ctx context.Context, code string) ([]RaceResult, error)
+	CreateChampionship(ctx context.Context, organizer string, name string, tracks []int, starts_at time.Time, interval time.Duration, max_entrants int) (id int, e error)
+	RegisterChampionship(ctx context.Context, id int, username string, stats *MotorcycleStats) error
+	GetChampionships(ctx context.Context) ([]Championship, error)
+	GetChampionship(ctx context.Context, id int) (*Championship, error)
+	GetChampionshipSchedule(ctx context.Context, id int) ([]ChampionshipRound, error)
+	GetChampionshipStandings(ctx context.Context, id int) ([]ChampionshipStanding, error)
+	GetDueRounds(ctx context.Context) ([]ChampionshipRound, error)
+	CompleteChampionshipRound(ctx context.Context, id int, round int) (results []RaceResult, standings []ChampionshipStanding, e error)
+	GetUnnotifiedChampionships(ctx context.Context) ([]int, error)
+	ClaimChampionship(ctx context.Context, id int) (bool, error)
+	GetUnnotifiedRounds(ctx context.Context, id int) ([]ChampionshipRound, error)
+	GetRoundResults(ctx context.Context, id int, round int) ([]RaceResult, error)
+	MarkRoundNotified(ctx context.Context, id int, round int) error
+	MarkChampionshipNotified(ctx context.Context, id int) error
+	ClaimRaceEvents(ctx context.Context) ([]RaceEvent, error)
+	DeleteRaceEvent(ctx context.Context, id int) error
+	RunTimeTrial(ctx context.Context, username string, stats *MotorcycleStats, track int) (*TimeTrialResult, error)
+	GetTimeTrialLeaderboard(ctx context.Context, track int) ([]TimeTrialRecord, error)
 }
 
+// Races shown to every player
+const recentRacesLimit = 20
+
 // Implementation for an SQL Database
 type SQL_DB struct {
 	db *sql.DB
@@ -44,48 +238,57 @@ func NewSQL_DB(conn *sql.DB) *SQL_DB {
 	return &SQL_DB{db: conn}
 }
 
-func (s *SQL_DB) StartMatchmaking(username string, stats *MotorcycleStats) (track int, left int, e error) {
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+func (s *SQL_DB) StartMatchmaking(ctx context.Context, username string, stats *MotorcycleStats) (track int, left int, e error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.StartMatchmaking"))
 	defer cancel()
 
 	// Begin transaction to start matchmaking
-	// steps: select random track, insert motorcycle and compute free slots remained
+	// steps: check the motorcycle is free, select random track, insert motorcycle and compute free slots remained
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "StartMatchmaking failed", "error", err)
 		return -1, -1, err
 	}
 	defer tx.Rollback()
 
-	row := tx.QueryRow("SELECT Id FROM Tracks ORDER BY RAND() LIMIT 1")
+	if err = checkMotorcycleFree(ctx, tx, username, stats.Id); err != nil {
+		return -1, -1, err
+	}
+
+	row := tx.QueryRowContext(ctx, "SELECT Id FROM Tracks ORDER BY RAND() LIMIT 1")
 	row.Scan(&track)
 
-	_, e = tx.Exec("INSERT INTO Matchmaking VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)", username, stats.Id, track, stats.Name, stats.Level, stats.Engine, stats.Brakes, stats.Agility, stats.Aerodynamics)
+	_, e = tx.ExecContext(ctx, "INSERT INTO Matchmaking VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, CURRENT_TIMESTAMP)", username, stats.Id, track, stats.Name, stats.Level, stats.Engine, stats.Brakes, stats.Agility, stats.Aerodynamics,
+		stats.Paint, stats.PaintColor, stats.RaceNumber, stats.RiderName)
+	if e != nil {
+		slog.ErrorContext(ctx, "StartMatchmaking failed", "error", e)
+		return -1, -1, e
+	}
 
-	row = tx.QueryRow("SELECT FreeSlots FROM DetailedMatchmaking WHERE TrackId=? LIMIT 1", track)
+	row = tx.QueryRowContext(ctx, "SELECT FreeSlots FROM DetailedMatchmaking WHERE TrackId=? LIMIT 1", track)
 	row.Scan(&left)
 
 	return track, left, tx.Commit()
 }
 
-func (s *SQL_DB) CompleteRace(track int) ([]RaceResult, error) {
+func (s *SQL_DB) CompleteRace(ctx context.Context, track int) ([]RaceResult, error) {
 	// Complete race on specific track
 
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.CompleteRace"))
 	defer cancel()
 
 	// Begin transaction
-	// steps: select results computing power for each participant, insert results into history, clean matchmaking from that track
+	// steps: select results computing power for each participant with the weather of the track, insert results into history and the events to publish, clean matchmaking from that track, forecast the next race
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "CompleteRace failed", "error", err)
 		return nil, err
 	}
 	defer tx.Rollback()
 
-	rows, err := tx.Query("SELECT PlayerUsername, MotorcycleId, Position, MaxMotorcycles, MotorcycleLevel, MotorcycleName, Trackname, CURRENT_TIMESTAMP AS Time FROM DetailedMatchmaking WHERE TrackId=?", track)
+	rows, err := tx.QueryContext(ctx, "SELECT PlayerUsername, MotorcycleId, Position, MaxMotorcycles, MotorcycleLevel, MotorcycleName, Trackname, CURRENT_TIMESTAMP AS Time, Weather, Paint, PaintColor, RaceNumber, RiderName, TIMESTAMPDIFF(SECOND, JoinedAt, CURRENT_TIMESTAMP) FROM DetailedMatchmaking WHERE TrackId=?", track)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "CompleteRace failed", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -93,53 +296,125 @@ func (s *SQL_DB) CompleteRace(track int) ([]RaceResult, error) {
 	var res []RaceResult
 	for rows.Next() {
 		var result RaceResult
-		err = rows.Scan(&result.Username, &result.MotorcycleId, &result.Position, &result.TotalMotorcycles, &result.MotorcycleLevel, &result.MotorcycleName, &result.TrackName, &result.Time)
+		var waited int
+		err = rows.Scan(&result.Username, &result.MotorcycleId, &result.Position, &result.TotalMotorcycles, &result.MotorcycleLevel, &result.MotorcycleName, &result.TrackName, &result.Time, &result.Weather,
+			&result.Paint, &result.PaintColor, &result.RaceNumber, &result.RiderName, &waited)
 		if err != nil {
-			log.Println(err)
+			slog.ErrorContext(ctx, "CompleteRace failed", "error", err)
 			return nil, err
 		}
+		result.Wear = raceWear(result.Position)
+		result.Waited = time.Duration(waited) * time.Second
 
 		res = append(res, result)
 	}
 
 	if err = rows.Err(); err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "CompleteRace failed", "error", err)
 		return nil, err
 	}
 
 	rows.Close()
 
-	_, err = tx.Exec("INSERT INTO History (Position, TotalMotorcycles, PlayerUsername, TrackName, MotorcycleName, MotorcycleLevel) SELECT Position, MaxMotorcycles, PlayerUsername, TrackName, MotorcycleName, MotorcycleLevel FROM DetailedMatchmaking WHERE TrackId=?", track)
+	_, err = tx.ExecContext(ctx, "INSERT INTO History (Position, TotalMotorcycles, PlayerUsername, TrackName, MotorcycleName, MotorcycleLevel, Weather, Paint, PaintColor, RaceNumber, RiderName) SELECT Position, MaxMotorcycles, PlayerUsername, TrackName, MotorcycleName, MotorcycleLevel, Weather, Paint, PaintColor, RaceNumber, RiderName FROM DetailedMatchmaking WHERE TrackId=?", track)
+	if err != nil {
+		slog.ErrorContext(ctx, "CompleteRace failed", "error", err)
+		return nil, err
+	}
+
+	if err = insertRaceEvents(ctx, tx, res); err != nil {
+		slog.ErrorContext(ctx, "CompleteRace failed", "error", err)
+		return nil, err
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM Matchmaking WHERE TrackId=?", track)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "CompleteRace failed", "error", err)
 		return nil, err
 	}
 
-	_, err = tx.Exec("DELETE FROM Matchmaking WHERE TrackId=?", track)
+	weather, err := randomWeather(ctx, tx)
 	if err != nil {
-		log.Println(err)
+		return nil, err
+	}
+	if _, err = tx.ExecContext(ctx, "UPDATE Tracks SET Weather=? WHERE Id=?", weather, track); err != nil {
+		slog.ErrorContext(ctx, "CompleteRace failed", "error", err)
 		return nil, err
 	}
 
 	return res, tx.Commit()
 }
 
-func (s *SQL_DB) CheckIsRacing(username string, MotorcycleId int) (track string, e error) {
-	// Check if motorcycle of user is racing and get trackname
+func (s *SQL_DB) GetMatchmakingQueues(ctx context.Context) (map[string]int, error) {
+	// Motorcycles waiting in matchmaking on each track, empty tracks included
+
+	rows, err := s.db.QueryContext(ctx, "SELECT T.Name, COUNT(M.TrackId) FROM Tracks T LEFT JOIN Matchmaking M ON M.TrackId=T.Id GROUP BY T.Id, T.Name")
+	if err != nil {
+		slog.ErrorContext(ctx, "GetMatchmakingQueues failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	queues := make(map[string]int)
+	for rows.Next() {
+		var track string
+		var waiting int
+		if err := rows.Scan(&track, &waiting); err != nil {
+			slog.ErrorContext(ctx, "GetMatchmakingQueues failed", "error", err)
+			return nil, err
+		}
+		queues[track] = waiting
+	}
+
+	return queues, rows.Err()
+}
+
+func (s *SQL_DB) CheckIsRacing(ctx context.Context, username string, MotorcycleId int) (track string, weather string, e error) {
+	// Check if motorcycle of user is racing and get trackname and the weather forecast
 
 	track = ""
-	row := s.db.QueryRow("SELECT TrackName FROM DetailedMatchmaking WHERE PlayerUsername=? AND MotorcycleId=?", username, MotorcycleId)
-	e = row.Scan(&track)
+	row := s.db.QueryRowContext(ctx, "SELECT TrackName, Weather FROM DetailedMatchmaking WHERE PlayerUsername=? AND MotorcycleId=?", username, MotorcycleId)
+	e = row.Scan(&track, &weather)
+	if e == sql.ErrNoRows {
+		return "", "", nil
+	}
 
-	return track, e
+	return track, weather, e
 }
 
-func (s *SQL_DB) GetHistory(username string) ([]RaceResult, error) {
+func (s *SQL_DB) GetHistory(ctx context.Context, username string) ([]RaceResult, error) {
 	// Retrieve history of selected user
 
-	rows, err := s.db.Query("SELECT Position, TotalMotorcycles, PlayerUsername, TrackName, MotorcycleName, MotorcycleLevel, Time FROM History WHERE PlayerUsername=? ORDER BY RaceId DESC", username)
+	return s.queryHistory(ctx, "SELECT Position, TotalMotorcycles, PlayerUsername, TrackName, MotorcycleName, MotorcycleLevel, Time, Weather, Paint, PaintColor, RaceNumber, RiderName FROM History WHERE PlayerUsername=? ORDER BY RaceId DESC", username)
+}
+
+func (s *SQL_DB) GetRecentRaces(ctx context.Context) ([]RaceResult, error) {
+	// Retrieve the latest race of every player, most recent first
+
+	return s.queryHistory(ctx, "SELECT Position, TotalMotorcycles, PlayerUsername, TrackName, MotorcycleName, MotorcycleLevel, Time, Weather, Paint, PaintColor, RaceNumber, RiderName FROM History WHERE RaceId IN (SELECT MAX(RaceId) FROM History GROUP BY PlayerUsername) ORDER BY RaceId DESC LIMIT ?", recentRacesLimit)
+}
+
+func (s *SQL_DB) GetPlayersRecentRaces(ctx context.Context, usernames []string) ([]RaceResult, error) {
+	// Retrieve the latest race of a group of players, most recent first
+
+	if len(usernames) == 0 {
+		return nil, nil
+	}
+
+	args := make([]any, 0, len(usernames)+1)
+	for _, u := range usernames {
+		args = append(args, u)
+	}
+	args = append(args, recentRacesLimit)
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(usernames)), ", ")
+
+	return s.queryHistory(ctx, "SELECT Position, TotalMotorcycles, PlayerUsername, TrackName, MotorcycleName, MotorcycleLevel, Time, Weather, Paint, PaintColor, RaceNumber, RiderName FROM History WHERE RaceId IN (SELECT MAX(RaceId) FROM History WHERE PlayerUsername IN ("+placeholders+") GROUP BY PlayerUsername) ORDER BY RaceId DESC LIMIT ?", args...)
+}
+
+func (s *SQL_DB) queryHistory(ctx context.Context, query string, args ...any) ([]RaceResult, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "queryHistory failed", "error", err)
 		return nil, err
 	}
 	defer rows.Close()
@@ -147,9 +422,10 @@ func (s *SQL_DB) GetHistory(username string) ([]RaceResult, error) {
 	var history []RaceResult
 	for rows.Next() {
 		var result RaceResult
-		err = rows.Scan(&result.Position, &result.TotalMotorcycles, &result.Username, &result.TrackName, &result.MotorcycleName, &result.MotorcycleLevel, &result.Time)
+		err = rows.Scan(&result.Position, &result.TotalMotorcycles, &result.Username, &result.TrackName, &result.MotorcycleName, &result.MotorcycleLevel, &result.Time, &result.Weather,
+			&result.Paint, &result.PaintColor, &result.RaceNumber, &result.RiderName)
 		if err != nil {
-			log.Println(err)
+			slog.ErrorContext(ctx, "queryHistory failed", "error", err)
 			return nil, err
 		}
 
@@ -157,9 +433,956 @@ func (s *SQL_DB) GetHistory(username string) ([]RaceResult, error) {
 	}
 
 	if err = rows.Err(); err != nil {
-		log.Println(err)
+		slog.ErrorContext(ctx, "queryHistory failed", "error", err)
 		return nil, err
 	}
 
 	return history, err
 }
+
+func (s *SQL_DB) GetTracks(ctx context.Context) ([]Track, error) {
+	// Retrieve tracks that can be chosen for a private race
+
+	rows, err := s.db.QueryContext(ctx, "SELECT Id, Name, MaxMotorcycles, Weather FROM Tracks ORDER BY Name")
+	if err != nil {
+		slog.ErrorContext(ctx, "GetTracks failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tracks []Track
+	for rows.Next() {
+		var track Track
+		if err := rows.Scan(&track.Id, &track.Name, &track.MaxMotorcycles, &track.Weather); err != nil {
+			slog.ErrorContext(ctx, "GetTracks failed", "error", err)
+			return nil, err
+		}
+		tracks = append(tracks, track)
+	}
+
+	return tracks, rows.Err()
+}
+
+// Motorcycles already waiting for a race cannot join another one
+func checkMotorcycleFree(ctx context.Context, tx *sql.Tx, username string, MotorcycleId int) error {
+	var busy int
+	err := tx.QueryRowContext(ctx, "SELECT (SELECT COUNT(*) FROM Matchmaking WHERE PlayerUsername=? AND MotorcycleId=?) + (SELECT COUNT(*) FROM LobbyEntries WHERE PlayerUsername=? AND MotorcycleId=?) + (SELECT COUNT(*) FROM ChampionshipEntrants E INNER JOIN Championships C ON E.ChampionshipId=C.Id WHERE E.PlayerUsername=? AND E.MotorcycleId=? AND C.Status IN ('open', 'running'))",
+		username, MotorcycleId, username, MotorcycleId, username, MotorcycleId).Scan(&busy)
+	if err != nil {
+		slog.ErrorContext(ctx, "checkMotorcycleFree failed", "error", err)
+		return err
+	}
+	if busy != 0 {
+		return errors.New("motorcycle already racing")
+	}
+
+	return nil
+}
+
+func insertLobbyEntry(ctx context.Context, tx *sql.Tx, code string, username string, stats *MotorcycleStats) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO LobbyEntries (Code, PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, MotorcycleEngine, MotorcycleBrakes, MotorcycleAgility, MotorcycleAerodynamics, Paint, PaintColor, RaceNumber, RiderName) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		code, username, stats.Id, stats.Name, stats.Level, stats.Engine, stats.Brakes, stats.Agility, stats.Aerodynamics, stats.Paint, stats.PaintColor, stats.RaceNumber, stats.RiderName)
+	return err
+}
+
+func (s *SQL_DB) CreateLobby(ctx context.Context, username string, stats *MotorcycleStats, track int, friendly bool) (code string, e error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.CreateLobby"))
+	defer cancel()
+
+	// Begin transaction to create the lobby
+	// steps: check the motorcycle is free, insert lobby with a new invite code and its weather, insert motorcycle of the host
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateLobby failed", "error", err)
+		return "", err
+	}
+	defer tx.Rollback()
+
+	if err = checkMotorcycleFree(ctx, tx, username, stats.Id); err != nil {
+		return "", err
+	}
+
+	var found int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Tracks WHERE Id=?", track).Scan(&found); err != nil {
+		slog.ErrorContext(ctx, "CreateLobby failed", "error", err)
+		return "", err
+	}
+	if found == 0 {
+		return "", errors.New("track not found")
+	}
+
+	// Codes are random, try again in the unlikely case of a code already in use
+	for i := 0; i < lobbyCodeRetries; i++ {
+		code = lobbyCode()
+		if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM Lobbies WHERE Code=?", code).Scan(&found); err != nil {
+			slog.ErrorContext(ctx, "CreateLobby failed", "error", err)
+			return "", err
+		}
+		if found == 0 {
+			break
+		}
+	}
+	if found != 0 {
+		return "", errors.New("unable to generate an invite code")
+	}
+
+	weather, err := randomWeather(ctx, tx)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO Lobbies (Code, Host, TrackId, Friendly, Weather) VALUES (?, ?, ?, ?, ?)", code, username, track, friendly, weather)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateLobby failed", "error", err)
+		return "", err
+	}
+
+	if err = insertLobbyEntry(ctx, tx, code, username, stats); err != nil {
+		slog.ErrorContext(ctx, "CreateLobby failed", "error", err)
+		return "", err
+	}
+
+	return code, tx.Commit()
+}
+
+func (s *SQL_DB) JoinLobby(ctx context.Context, code string, username string, stats *MotorcycleStats) (left int, e error) {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.JoinLobby"))
+	defer cancel()
+
+	// Begin transaction to join the lobby
+	// steps: lock lobby, check the motorcycle is free and a slot is available, insert motorcycle and compute free slots remained
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "JoinLobby failed", "error", err)
+		return -1, err
+	}
+	defer tx.Rollback()
+
+	var max_motorcycles int
+	err = tx.QueryRowContext(ctx, "SELECT MaxMotorcycles FROM Lobbies L INNER JOIN Tracks T ON L.TrackId=T.Id WHERE Code=? FOR UPDATE", code).Scan(&max_motorcycles)
+	if err == sql.ErrNoRows {
+		return -1, errors.New("lobby not found")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "JoinLobby failed", "error", err)
+		return -1, err
+	}
+
+	if err = checkMotorcycleFree(ctx, tx, username, stats.Id); err != nil {
+		return -1, err
+	}
+
+	var entries int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM LobbyEntries WHERE Code=?", code).Scan(&entries); err != nil {
+		slog.ErrorContext(ctx, "JoinLobby failed", "error", err)
+		return -1, err
+	}
+	if entries >= max_motorcycles {
+		return -1, errors.New("lobby is full")
+	}
+
+	if err = insertLobbyEntry(ctx, tx, code, username, stats); err != nil {
+		slog.ErrorContext(ctx, "JoinLobby failed", "error", err)
+		return -1, err
+	}
+
+	return max_motorcycles - entries - 1, tx.Commit()
+}
+
+func (s *SQL_DB) LeaveLobby(ctx context.Context, code string, username string) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.LeaveLobby"))
+	defer cancel()
+
+	// Begin transaction to leave the lobby
+	// steps: lock lobby, remove motorcycle of the player, close the lobby if the player is the host
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "LeaveLobby failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var host string
+	err = tx.QueryRowContext(ctx, "SELECT Host FROM Lobbies WHERE Code=? FOR UPDATE", code).Scan(&host)
+	if err == sql.ErrNoRows {
+		return errors.New("lobby not found")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "LeaveLobby failed", "error", err)
+		return err
+	}
+
+	if host == username {
+		if err = deleteLobby(ctx, tx, code); err != nil {
+			return err
+		}
+		return tx.Commit()
+	}
+
+	res, err := tx.ExecContext(ctx, "DELETE FROM LobbyEntries WHERE Code=? AND PlayerUsername=?", code, username)
+	if err != nil {
+		slog.ErrorContext(ctx, "LeaveLobby failed", "error", err)
+		return err
+	}
+	if rows_affected, err := res.RowsAffected(); err != nil || rows_affected == 0 {
+		return errors.New("player not in lobby")
+	}
+
+	return tx.Commit()
+}
+
+func deleteLobby(ctx context.Context, tx *sql.Tx, code string) error {
+	if _, err := tx.ExecContext(ctx, "DELETE FROM LobbyEntries WHERE Code=?", code); err != nil {
+		slog.ErrorContext(ctx, "deleteLobby failed", "error", err)
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM Lobbies WHERE Code=?", code); err != nil {
+		slog.ErrorContext(ctx, "deleteLobby failed", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *SQL_DB) GetLobby(ctx context.Context, code string) (*Lobby, error) {
+	// Retrieve lobby with the motorcycles joined so far, in order of arrival
+
+	lobby := &Lobby{Code: code}
+	err := s.db.QueryRowContext(ctx, "SELECT Host, Name, MaxMotorcycles, Friendly, L.Weather FROM Lobbies L INNER JOIN Tracks T ON L.TrackId=T.Id WHERE Code=?", code).Scan(
+		&lobby.Host, &lobby.TrackName, &lobby.MaxMotorcycles, &lobby.Friendly, &lobby.Weather)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("lobby not found")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "GetLobby failed", "error", err)
+		return nil, err
+	}
+
+	rows, err := s.db.QueryContext(ctx, "SELECT PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, Paint, PaintColor, RaceNumber, RiderName FROM LobbyEntries WHERE Code=? ORDER BY JoinedAt, PlayerUsername", code)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetLobby failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var entry LobbyEntry
+		err = rows.Scan(&entry.Username, &entry.Id, &entry.Name, &entry.Level, &entry.Paint, &entry.PaintColor, &entry.RaceNumber, &entry.RiderName)
+		if err != nil {
+			slog.ErrorContext(ctx, "GetLobby failed", "error", err)
+			return nil, err
+		}
+		lobby.Entries = append(lobby.Entries, entry)
+	}
+
+	return lobby, rows.Err()
+}
+
+func (s *SQL_DB) GetPlayerLobbies(ctx context.Context, username string) ([]Lobby, error) {
+	// Retrieve lobbies joined by the player
+
+	rows, err := s.db.QueryContext(ctx, "SELECT Code FROM LobbyEntries WHERE PlayerUsername=? ORDER BY JoinedAt DESC", username)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetPlayerLobbies failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var codes []string
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code); err != nil {
+			slog.ErrorContext(ctx, "GetPlayerLobbies failed", "error", err)
+			return nil, err
+		}
+		codes = append(codes, code)
+	}
+	if err = rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "GetPlayerLobbies failed", "error", err)
+		return nil, err
+	}
+	rows.Close()
+
+	var lobbies []Lobby
+	for _, code := range codes {
+		lobby, err := s.GetLobby(ctx, code)
+		if err != nil {
+			return nil, err
+		}
+		lobbies = append(lobbies, *lobby)
+	}
+
+	return lobbies, nil
+}
+
+func (s *SQL_DB) CheckIsInLobby(ctx context.Context, username string, MotorcycleId int) (code string, track string, weather string, e error) {
+	// Check if motorcycle of user is waiting in a lobby and get its code, trackname and weather
+
+	row := s.db.QueryRowContext(ctx, "SELECT Code, TrackName, Weather FROM DetailedLobbies WHERE PlayerUsername=? AND MotorcycleId=?", username, MotorcycleId)
+	e = row.Scan(&code, &track, &weather)
+	if e == sql.ErrNoRows {
+		return "", "", "", nil
+	}
+
+	return code, track, weather, e
+}
+
+func (s *SQL_DB) CheckIsInChampionship(ctx context.Context, username string, MotorcycleId int) (ChampionshipId int, e error) {
+	// Check if motorcycle of user is entered in a championship still open or running, 0 if it is not
+
+	row := s.db.QueryRowContext(ctx, "SELECT C.Id FROM ChampionshipEntrants E INNER JOIN Championships C ON E.ChampionshipId=C.Id WHERE E.PlayerUsername=? AND E.MotorcycleId=? AND C.Status IN ('open', 'running') ORDER BY C.Id LIMIT 1", username, MotorcycleId)
+	e = row.Scan(&ChampionshipId)
+	if e == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return ChampionshipId, e
+}
+
+func (s *SQL_DB) CompleteLobbyRace(ctx context.Context, code string) ([]RaceResult, error) {
+	// Complete race of a lobby, started by the host or because the lobby is full
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.CompleteLobbyRace"))
+	defer cancel()
+
+	// Begin transaction
+	// steps: lock lobby, select results computing power for each participant, insert results into history and the events to publish, delete the lobby
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "CompleteLobbyRace failed", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var found string
+	err = tx.QueryRowContext(ctx, "SELECT Code FROM Lobbies WHERE Code=? FOR UPDATE", code).Scan(&found)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("lobby not found")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "CompleteLobbyRace failed", "error", err)
+		return nil, err
+	}
+
+	// Positions are computed among the motorcycles that joined, empty slots are not counted
+	rows, err := tx.QueryContext(ctx, "SELECT PlayerUsername, MotorcycleId, Position, MaxMotorcycles - FreeSlots, MotorcycleLevel, MotorcycleName, TrackName, CURRENT_TIMESTAMP AS Time, Weather, Friendly, Paint, PaintColor, RaceNumber, RiderName FROM DetailedLobbies WHERE Code=? ORDER BY Position", code)
+	if err != nil {
+		slog.ErrorContext(ctx, "CompleteLobbyRace failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var res []RaceResult
+	for rows.Next() {
+		var result RaceResult
+		err = rows.Scan(&result.Username, &result.MotorcycleId, &result.Position, &result.TotalMotorcycles, &result.MotorcycleLevel, &result.MotorcycleName, &result.TrackName, &result.Time, &result.Weather, &result.Friendly,
+			&result.Paint, &result.PaintColor, &result.RaceNumber, &result.RiderName)
+		if err != nil {
+			slog.ErrorContext(ctx, "CompleteLobbyRace failed", "error", err)
+			return nil, err
+		}
+		result.Wear = raceWear(result.Position)
+
+		res = append(res, result)
+	}
+
+	if err = rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "CompleteLobbyRace failed", "error", err)
+		return nil, err
+	}
+
+	rows.Close()
+
+	if len(res) < 2 {
+		return nil, errors.New("at least two motorcycles are required to race")
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO History (Position, TotalMotorcycles, PlayerUsername, TrackName, MotorcycleName, MotorcycleLevel, Weather, Paint, PaintColor, RaceNumber, RiderName) SELECT Position, MaxMotorcycles - FreeSlots, PlayerUsername, TrackName, MotorcycleName, MotorcycleLevel, Weather, Paint, PaintColor, RaceNumber, RiderName FROM DetailedLobbies WHERE Code=?", code)
+	if err != nil {
+		slog.ErrorContext(ctx, "CompleteLobbyRace failed", "error", err)
+		return nil, err
+	}
+
+	if err = insertRaceEvents(ctx, tx, res); err != nil {
+		slog.ErrorContext(ctx, "CompleteLobbyRace failed", "error", err)
+		return nil, err
+	}
+
+	if err = deleteLobby(ctx, tx, code); err != nil {
+		return nil, err
+	}
+
+	return res, tx.Commit()
+}
+
+func (s *SQL_DB) CreateChampionship(ctx context.Context, organizer string, name string, tracks []int, starts_at time.Time, interval time.Duration, max_entrants int) (id int, e error) {
+	if name == "" || len(tracks) == 0 || len(tracks) > maxChampionshipRounds {
+		return -1, errors.New("invalid championship")
+	}
+	if max_entrants < 2 || max_entrants > maxChampionshipEntrants || interval <= 0 {
+		return -1, errors.New("invalid championship")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.CreateChampionship"))
+	defer cancel()
+
+	// Begin transaction to create the championship
+	// steps: insert championship, insert a round for each track spaced by interval with its weather forecast
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateChampionship failed", "error", err)
+		return -1, err
+	}
+	defer tx.Rollback()
+
+	res, err := tx.ExecContext(ctx, "INSERT INTO Championships (Name, Organizer, MaxEntrants) VALUES (?, ?, ?)", name, organizer, max_entrants)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateChampionship failed", "error", err)
+		return -1, err
+	}
+	last_id, err := res.LastInsertId()
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateChampionship failed", "error", err)
+		return -1, err
+	}
+
+	for i, track := range tracks {
+		weather, err := randomWeather(ctx, tx)
+		if err != nil {
+			return -1, err
+		}
+
+		_, err = tx.ExecContext(ctx, "INSERT INTO ChampionshipRounds (ChampionshipId, Round, TrackId, StartsAt, Weather) VALUES (?, ?, ?, ?, ?)", last_id, i+1, track, starts_at.Add(time.Duration(i)*interval), weather)
+		if err != nil {
+			slog.ErrorContext(ctx, "CreateChampionship failed", "error", err)
+			return -1, err
+		}
+	}
+
+	return int(last_id), tx.Commit()
+}
+
+func (s *SQL_DB) RegisterChampionship(ctx context.Context, id int, username string, stats *MotorcycleStats) error {
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.RegisterChampionship"))
+	defer cancel()
+
+	// Begin transaction to register to the championship
+	// steps: lock championship, check it is open and not full, check the motorcycle is free, insert the motorcycle of the player
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "RegisterChampionship failed", "error", err)
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	var max_entrants int
+	err = tx.QueryRowContext(ctx, "SELECT Status, MaxEntrants FROM Championships WHERE Id=? FOR UPDATE", id).Scan(&status, &max_entrants)
+	if err == sql.ErrNoRows {
+		return errors.New("championship not found")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "RegisterChampionship failed", "error", err)
+		return err
+	}
+	if status != "open" {
+		return errors.New("championship registrations are closed")
+	}
+
+	var entrants int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM ChampionshipEntrants WHERE ChampionshipId=?", id).Scan(&entrants); err != nil {
+		slog.ErrorContext(ctx, "RegisterChampionship failed", "error", err)
+		return err
+	}
+	if entrants >= max_entrants {
+		return errors.New("championship is full")
+	}
+
+	if err = checkMotorcycleFree(ctx, tx, username, stats.Id); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO ChampionshipEntrants (ChampionshipId, PlayerUsername, MotorcycleId, MotorcycleName, MotorcycleLevel, MotorcycleEngine, MotorcycleBrakes, MotorcycleAgility, MotorcycleAerodynamics, Paint, PaintColor, RaceNumber, RiderName) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		id, username, stats.Id, stats.Name, stats.Level, stats.Engine, stats.Brakes, stats.Agility, stats.Aerodynamics, stats.Paint, stats.PaintColor, stats.RaceNumber, stats.RiderName)
+	if err != nil {
+		slog.ErrorContext(ctx, "RegisterChampionship failed", "error", err)
+		return err
+	}
+
+	return tx.Commit()
+}
+
+const championshipQuery = `SELECT Id, Name, Organizer, Status, MaxEntrants,
+	(SELECT COUNT(*) FROM ChampionshipEntrants E WHERE E.ChampionshipId=C.Id),
+	(SELECT COUNT(*) FROM ChampionshipRounds R WHERE R.ChampionshipId=C.Id),
+	(SELECT COUNT(*) FROM ChampionshipRounds R WHERE R.ChampionshipId=C.Id AND Completed),
+	(SELECT MIN(StartsAt) FROM ChampionshipRounds R WHERE R.ChampionshipId=C.Id),
+	Notified
+	FROM Championships C`
+
+func scanChampionship(row interface{ Scan(...any) error }) (*Championship, error) {
+	var c Championship
+	err := row.Scan(&c.Id, &c.Name, &c.Organizer, &c.Status, &c.MaxEntrants, &c.Entrants, &c.Rounds, &c.RoundsCompleted, &c.StartsAt, &c.Notified)
+	return &c, err
+}
+
+func (s *SQL_DB) GetChampionships(ctx context.Context) ([]Championship, error) {
+	// Retrieve championships, the ones still to be decided first
+
+	rows, err := s.db.QueryContext(ctx, championshipQuery+" ORDER BY Status IN ('completed', 'cancelled'), Id DESC")
+	if err != nil {
+		slog.ErrorContext(ctx, "GetChampionships failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var championships []Championship
+	for rows.Next() {
+		c, err := scanChampionship(rows)
+		if err != nil {
+			slog.ErrorContext(ctx, "GetChampionships failed", "error", err)
+			return nil, err
+		}
+		championships = append(championships, *c)
+	}
+
+	return championships, rows.Err()
+}
+
+func (s *SQL_DB) GetChampionship(ctx context.Context, id int) (*Championship, error) {
+	c, err := scanChampionship(s.db.QueryRowContext(ctx, championshipQuery+" WHERE Id=?", id))
+	if err == sql.ErrNoRows {
+		return nil, errors.New("championship not found")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "GetChampionship failed", "error", err)
+		return nil, err
+	}
+
+	return c, nil
+}
+
+func (s *SQL_DB) queryRounds(ctx context.Context, query string, args ...any) ([]ChampionshipRound, error) {
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		slog.ErrorContext(ctx, "queryRounds failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rounds []ChampionshipRound
+	for rows.Next() {
+		var round ChampionshipRound
+		var winner sql.NullString
+		if err := rows.Scan(&round.ChampionshipId, &round.Round, &round.TrackName, &round.StartsAt, &round.Completed, &winner, &round.Weather); err != nil {
+			slog.ErrorContext(ctx, "queryRounds failed", "error", err)
+			return nil, err
+		}
+		round.Winner = winner.String
+		rounds = append(rounds, round)
+	}
+
+	return rounds, rows.Err()
+}
+
+func (s *SQL_DB) GetChampionshipSchedule(ctx context.Context, id int) ([]ChampionshipRound, error) {
+	// Retrieve rounds of the championship with the winner of the completed ones
+
+	return s.queryRounds(ctx, "SELECT ChampionshipId, Round, Name, StartsAt, Completed, Winner, R.Weather FROM ChampionshipRounds R INNER JOIN Tracks T ON R.TrackId=T.Id WHERE ChampionshipId=? ORDER BY Round", id)
+}
+
+func (s *SQL_DB) GetDueRounds(ctx context.Context) ([]ChampionshipRound, error) {
+	// Retrieve rounds whose start time has come, oldest first
+
+	return s.queryRounds(ctx, "SELECT ChampionshipId, Round, T.Name, StartsAt, Completed, Winner, R.Weather FROM ChampionshipRounds R INNER JOIN Tracks T ON R.TrackId=T.Id INNER JOIN Championships C ON R.ChampionshipId=C.Id WHERE NOT Completed AND StartsAt <= CURRENT_TIMESTAMP AND Status IN ('open', 'running') ORDER BY StartsAt, ChampionshipId, Round")
+}
+
+func (s *SQL_DB) GetChampionshipStandings(ctx context.Context, id int) ([]ChampionshipStanding, error) {
+	return queryStandings(ctx, s.db, id)
+}
+
+// Both *sql.DB and *sql.Tx
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+func queryStandings(ctx context.Context, q querier, id int) ([]ChampionshipStanding, error) {
+	// Standings by points, wins break ties
+
+	rows, err := q.QueryContext(ctx, "SELECT PlayerUsername, MotorcycleName, Points, Wins, RANK() OVER (ORDER BY Points DESC, Wins DESC) AS Position FROM ChampionshipEntrants WHERE ChampionshipId=? ORDER BY Position, PlayerUsername", id)
+	if err != nil {
+		slog.ErrorContext(ctx, "queryStandings failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var standings []ChampionshipStanding
+	for rows.Next() {
+		var standing ChampionshipStanding
+		if err := rows.Scan(&standing.Username, &standing.MotorcycleName, &standing.Points, &standing.Wins, &standing.Position); err != nil {
+			slog.ErrorContext(ctx, "queryStandings failed", "error", err)
+			return nil, err
+		}
+		standings = append(standings, standing)
+	}
+
+	return standings, rows.Err()
+}
+
+func queryRoundResults(ctx context.Context, q querier, id int, round int) ([]RaceResult, error) {
+	// Results of a round from the stats every motorcycle had at registration
+
+	rows, err := q.QueryContext(ctx, "SELECT PlayerUsername, MotorcycleId, Position, Entrants, MotorcycleLevel, MotorcycleName, TrackName, CURRENT_TIMESTAMP AS Time, Weather, Paint, PaintColor, RaceNumber, RiderName FROM DetailedChampionships WHERE ChampionshipId=? AND Round=? ORDER BY Position", id, round)
+	if err != nil {
+		slog.ErrorContext(ctx, "queryRoundResults failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var results []RaceResult
+	for rows.Next() {
+		var result RaceResult
+		err = rows.Scan(&result.Username, &result.MotorcycleId, &result.Position, &result.TotalMotorcycles, &result.MotorcycleLevel, &result.MotorcycleName, &result.TrackName, &result.Time, &result.Weather,
+			&result.Paint, &result.PaintColor, &result.RaceNumber, &result.RiderName)
+		if err != nil {
+			slog.ErrorContext(ctx, "queryRoundResults failed", "error", err)
+			return nil, err
+		}
+		result.Wear = raceWear(result.Position)
+
+		results = append(results, result)
+	}
+
+	return results, rows.Err()
+}
+
+func (s *SQL_DB) CompleteChampionshipRound(ctx context.Context, id int, round int) (results []RaceResult, standings []ChampionshipStanding, e error) {
+	// Race a round of the championship with every registered motorcycle
+	// standings are returned only when the last round is completed
+	// a round already completed by another replica returns no results
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.CompleteChampionshipRound"))
+	defer cancel()
+
+	// Begin transaction
+	// steps: lock championship, compute results, insert them into history and the events to publish, give points, complete round and championship
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "CompleteChampionshipRound failed", "error", err)
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx, "SELECT Status FROM Championships WHERE Id=? FOR UPDATE", id).Scan(&status)
+	if err == sql.ErrNoRows {
+		return nil, nil, errors.New("championship not found")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "CompleteChampionshipRound failed", "error", err)
+		return nil, nil, err
+	}
+
+	var completed bool
+	if err = tx.QueryRowContext(ctx, "SELECT Completed FROM ChampionshipRounds WHERE ChampionshipId=? AND Round=?", id, round).Scan(&completed); err != nil {
+		slog.ErrorContext(ctx, "CompleteChampionshipRound failed", "error", err)
+		return nil, nil, err
+	}
+	if completed || (status != "open" && status != "running") {
+		return nil, nil, nil
+	}
+
+	if results, err = queryRoundResults(ctx, tx, id, round); err != nil {
+		return nil, nil, err
+	}
+
+	// Not enough riders registered before the first round
+	if len(results) < 2 {
+		if _, err = tx.ExecContext(ctx, "UPDATE Championships SET Status='cancelled' WHERE Id=?", id); err != nil {
+			slog.ErrorContext(ctx, "CompleteChampionshipRound failed", "error", err)
+			return nil, nil, err
+		}
+		return nil, nil, tx.Commit()
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO History (Position, TotalMotorcycles, PlayerUsername, TrackName, MotorcycleName, MotorcycleLevel, Weather, Paint, PaintColor, RaceNumber, RiderName) SELECT Position, Entrants, PlayerUsername, TrackName, MotorcycleName, MotorcycleLevel, Weather, Paint, PaintColor, RaceNumber, RiderName FROM DetailedChampionships WHERE ChampionshipId=? AND Round=?", id, round)
+	if err != nil {
+		slog.ErrorContext(ctx, "CompleteChampionshipRound failed", "error", err)
+		return nil, nil, err
+	}
+
+	if err = insertRaceEvents(ctx, tx, results); err != nil {
+		slog.ErrorContext(ctx, "CompleteChampionshipRound failed", "error", err)
+		return nil, nil, err
+	}
+
+	for _, result := range results {
+		wins := 0
+		if result.Position == 1 {
+			wins = 1
+		}
+		_, err = tx.ExecContext(ctx, "UPDATE ChampionshipEntrants SET Points=Points+?, Wins=Wins+? WHERE ChampionshipId=? AND PlayerUsername=?", roundPoints(result.Position), wins, id, result.Username)
+		if err != nil {
+			slog.ErrorContext(ctx, "CompleteChampionshipRound failed", "error", err)
+			return nil, nil, err
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, "UPDATE ChampionshipRounds SET Completed=TRUE, Winner=? WHERE ChampionshipId=? AND Round=?", results[0].Username, id, round)
+	if err != nil {
+		slog.ErrorContext(ctx, "CompleteChampionshipRound failed", "error", err)
+		return nil, nil, err
+	}
+
+	var left int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM ChampionshipRounds WHERE ChampionshipId=? AND NOT Completed", id).Scan(&left); err != nil {
+		slog.ErrorContext(ctx, "CompleteChampionshipRound failed", "error", err)
+		return nil, nil, err
+	}
+
+	status = "running"
+	if left == 0 {
+		status = "completed"
+		if standings, err = queryStandings(ctx, tx, id); err != nil {
+			return nil, nil, err
+		}
+	}
+	// The replica racing the round notifies its results, the others wait before trying again
+	if _, err = tx.ExecContext(ctx, "UPDATE Championships SET Status=?, ClaimedAt=CURRENT_TIMESTAMP WHERE Id=?", status, id); err != nil {
+		slog.ErrorContext(ctx, "CompleteChampionshipRound failed", "error", err)
+		return nil, nil, err
+	}
+
+	return results, standings, tx.Commit()
+}
+
+// Time given to a replica to notify the results of a championship before another one tries again
+const championshipClaim = "1 MINUTE"
+
+func (s *SQL_DB) GetUnnotifiedChampionships(ctx context.Context) ([]int, error) {
+	// Retrieve championships with results not received by the Orchestrator and no replica notifying them
+
+	rows, err := s.db.QueryContext(ctx, "SELECT Id FROM Championships C WHERE (ClaimedAt IS NULL OR ClaimedAt <= CURRENT_TIMESTAMP - INTERVAL "+championshipClaim+") AND ((Status='completed' AND NOT Notified) OR EXISTS (SELECT * FROM ChampionshipRounds R WHERE R.ChampionshipId=C.Id AND Completed AND NOT R.Notified)) ORDER BY Id")
+	if err != nil {
+		slog.ErrorContext(ctx, "GetUnnotifiedChampionships failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			slog.ErrorContext(ctx, "GetUnnotifiedChampionships failed", "error", err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
+
+func (s *SQL_DB) ClaimChampionship(ctx context.Context, id int) (bool, error) {
+	// Claim the notification of the results, false if another replica is notifying them
+
+	res, err := s.db.ExecContext(ctx, "UPDATE Championships SET ClaimedAt=CURRENT_TIMESTAMP WHERE Id=? AND (ClaimedAt IS NULL OR ClaimedAt <= CURRENT_TIMESTAMP - INTERVAL "+championshipClaim+")", id)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimChampionship failed", "error", err)
+		return false, err
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimChampionship failed", "error", err)
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func (s *SQL_DB) GetUnnotifiedRounds(ctx context.Context, id int) ([]ChampionshipRound, error) {
+	// Retrieve completed rounds whose results have not been received by the Orchestrator
+
+	return s.queryRounds(ctx, "SELECT ChampionshipId, Round, Name, StartsAt, Completed, Winner, R.Weather FROM ChampionshipRounds R INNER JOIN Tracks T ON R.TrackId=T.Id WHERE ChampionshipId=? AND Completed AND NOT Notified ORDER BY Round", id)
+}
+
+func (s *SQL_DB) GetRoundResults(ctx context.Context, id int, round int) ([]RaceResult, error) {
+	return queryRoundResults(ctx, s.db, id, round)
+}
+
+func (s *SQL_DB) MarkRoundNotified(ctx context.Context, id int, round int) error {
+	// Record that the Orchestrator received the results of a round
+
+	_, err := s.db.ExecContext(ctx, "UPDATE ChampionshipRounds SET Notified=TRUE WHERE ChampionshipId=? AND Round=?", id, round)
+	if err != nil {
+		slog.ErrorContext(ctx, "MarkRoundNotified failed", "error", err)
+	}
+
+	return err
+}
+
+func (s *SQL_DB) MarkChampionshipNotified(ctx context.Context, id int) error {
+	// Record that the Orchestrator received the final standings
+
+	_, err := s.db.ExecContext(ctx, "UPDATE Championships SET Notified=TRUE WHERE Id=?", id)
+	if err != nil {
+		slog.ErrorContext(ctx, "MarkChampionshipNotified failed", "error", err)
+	}
+
+	return err
+}
+
+func insertRaceEvents(ctx context.Context, tx *sql.Tx, results []RaceResult) error {
+	// Events are written in the transaction of the race, so they are published even if the service stops right after it
+
+	for _, result := range results {
+		_, err := tx.ExecContext(ctx, "INSERT INTO RaceEvents (PlayerUsername, TrackName, Position, TotalMotorcycles, MotorcycleId, MotorcycleName) VALUES (?, ?, ?, ?, ?, ?)",
+			result.Username, result.TrackName, result.Position, result.TotalMotorcycles, result.MotorcycleId, result.MotorcycleName)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Time given to a replica to publish the events it claimed before another one tries again
+const raceEventClaim = "1 MINUTE"
+
+func (s *SQL_DB) ClaimRaceEvents(ctx context.Context) ([]RaceEvent, error) {
+	// Claim the events not yet published and not being published by another replica, in the order of the races
+
+	// Begin transaction
+	// steps: lock the events to publish, claim them
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimRaceEvents failed", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, "SELECT Id, PlayerUsername, TrackName, Position, TotalMotorcycles, MotorcycleId, MotorcycleName FROM RaceEvents WHERE ClaimedAt IS NULL OR ClaimedAt <= CURRENT_TIMESTAMP - INTERVAL "+raceEventClaim+" ORDER BY Id FOR UPDATE")
+	if err != nil {
+		slog.ErrorContext(ctx, "ClaimRaceEvents failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []RaceEvent
+	for rows.Next() {
+		var event RaceEvent
+		if err = rows.Scan(&event.Id, &event.Username, &event.TrackName, &event.Position, &event.TotalMotorcycles, &event.MotorcycleId, &event.MotorcycleName); err != nil {
+			slog.ErrorContext(ctx, "ClaimRaceEvents failed", "error", err)
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	if err = rows.Err(); err != nil {
+		slog.ErrorContext(ctx, "ClaimRaceEvents failed", "error", err)
+		return nil, err
+	}
+
+	rows.Close()
+
+	for _, event := range events {
+		if _, err = tx.ExecContext(ctx, "UPDATE RaceEvents SET ClaimedAt=CURRENT_TIMESTAMP WHERE Id=?", event.Id); err != nil {
+			slog.ErrorContext(ctx, "ClaimRaceEvents failed", "error", err)
+			return nil, err
+		}
+	}
+
+	return events, tx.Commit()
+}
+
+func (s *SQL_DB) DeleteRaceEvent(ctx context.Context, id int) error {
+	// Remove an event received by the event bus
+
+	_, err := s.db.ExecContext(ctx, "DELETE FROM RaceEvents WHERE Id=?", id)
+	if err != nil {
+		slog.ErrorContext(ctx, "DeleteRaceEvent failed", "error", err)
+	}
+
+	return err
+}
+
+func (s *SQL_DB) RunTimeTrial(ctx context.Context, username string, stats *MotorcycleStats, track int) (*TimeTrialResult, error) {
+	// Time trials are raced alone, Matchmaking is never used
+
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Racing.RunTimeTrial"))
+	defer cancel()
+
+	// Begin transaction
+	// steps: lock the track, check the rate of laps, simulate the lap with the values of the track, read personal best and track record, insert the lap
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		slog.ErrorContext(ctx, "RunTimeTrial failed", "error", err)
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	// Laps on the same track wait for each other, so the record is beaten by one of them only
+	result := &TimeTrialResult{}
+	var engine, brakes, agility, aerodynamics int
+	err = tx.QueryRowContext(ctx, "SELECT Name, EngineValue, BrakesValue, AgilityValue, AerodynamicsValue FROM Tracks WHERE Id=? FOR UPDATE", track).Scan(&result.TrackName, &engine, &brakes, &agility, &aerodynamics)
+	if err == sql.ErrNoRows {
+		return nil, errors.New("track not found")
+	} else if err != nil {
+		slog.ErrorContext(ctx, "RunTimeTrial failed", "error", err)
+		return nil, err
+	}
+
+	var recent int
+	if err = tx.QueryRowContext(ctx, "SELECT COUNT(*) FROM TimeTrials WHERE PlayerUsername=? AND Time > CURRENT_TIMESTAMP - INTERVAL ? SECOND", username, timeTrialCooldown).Scan(&recent); err != nil {
+		slog.ErrorContext(ctx, "RunTimeTrial failed", "error", err)
+		return nil, err
+	}
+	if recent > 0 {
+		return nil, errors.New("wait before the next time trial")
+	}
+
+	power := stats.Engine*engine + stats.Brakes*brakes + stats.Agility*agility + stats.Aerodynamics*aerodynamics
+	result.LapTime = lapTime(power, engine+brakes+agility+aerodynamics, 2*rand.Float64()-1)
+
+	var personal_best sql.NullInt64
+	if err = tx.QueryRowContext(ctx, "SELECT MIN(LapTime) FROM TimeTrials WHERE PlayerUsername=? AND TrackId=?", username, track).Scan(&personal_best); err != nil {
+		slog.ErrorContext(ctx, "RunTimeTrial failed", "error", err)
+		return nil, err
+	}
+	result.PersonalBest = int(personal_best.Int64)
+
+	err = tx.QueryRowContext(ctx, "SELECT LapTime, PlayerUsername FROM TimeTrials WHERE TrackId=? ORDER BY LapTime, Id LIMIT 1", track).Scan(&result.TrackRecord, &result.RecordHolder)
+	if err != nil && err != sql.ErrNoRows {
+		slog.ErrorContext(ctx, "RunTimeTrial failed", "error", err)
+		return nil, err
+	}
+
+	result.NewPersonalBest = result.PersonalBest == 0 || result.LapTime < result.PersonalBest
+	result.NewTrackRecord = result.TrackRecord == 0 || result.LapTime < result.TrackRecord
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO TimeTrials (PlayerUsername, TrackId, MotorcycleId, MotorcycleName, MotorcycleLevel, LapTime) VALUES (?, ?, ?, ?, ?, ?)",
+		username, track, stats.Id, stats.Name, stats.Level, result.LapTime)
+	if err != nil {
+		slog.ErrorContext(ctx, "RunTimeTrial failed", "error", err)
+		return nil, err
+	}
+
+	return result, tx.Commit()
+}
+
+func (s *SQL_DB) GetTimeTrialLeaderboard(ctx context.Context, track int) ([]TimeTrialRecord, error) {
+	// Retrieve the best lap of each player on the track, fastest first
+
+	rows, err := s.db.QueryContext(ctx, "SELECT PlayerUsername, Position, LapTime, MotorcycleName, MotorcycleLevel, Time FROM TimeTrialBests WHERE TrackId=? ORDER BY Position, PlayerUsername LIMIT ?", track, timeTrialBoardLimit)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetTimeTrialLeaderboard failed", "error", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []TimeTrialRecord
+	for rows.Next() {
+		var record TimeTrialRecord
+		if err := rows.Scan(&record.Username, &record.Position, &record.LapTime, &record.MotorcycleName, &record.MotorcycleLevel, &record.Time); err != nil {
+			slog.ErrorContext(ctx, "GetTimeTrialLeaderboard failed", "error", err)
+			return nil, err
+		}
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
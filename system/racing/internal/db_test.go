@@ -1,11 +1,13 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
 
 	_ "github.com/go-sql-driver/mysql"
 
 	"testing"
+	"time"
 )
 
 func NewSQLConnection(t *testing.T) *sql.DB {
@@ -25,7 +27,7 @@ func TestDBCheckIsRacing(t *testing.T) {
 	defer conn.Close()
 
 	db := NewSQL_DB(conn)
-	track, err := db.CheckIsRacing("user", 3)
+	track, _, err := db.CheckIsRacing(context.Background(), "user", 3)
 
 	if err != nil || track == "" {
 		return
@@ -34,6 +36,23 @@ func TestDBCheckIsRacing(t *testing.T) {
 	t.Errorf("Wrong information, user not racing but got a valid response")
 }
 
+func TestDBCheckIsInChampionship(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	id, err := db.CheckIsInChampionship(context.Background(), "slow", 2)
+	if err != nil || id != 1 {
+		t.Errorf("Motorcycle entered in a championship not found")
+	}
+
+	id, err = db.CheckIsInChampionship(context.Background(), "slow", 1)
+	if err != nil || id != 0 {
+		t.Errorf("Motorcycle not entered found in a championship")
+	}
+}
+
 func TestDBMatchmaking(t *testing.T) {
 	conn := NewSQLConnection(t)
 	defer conn.Close()
@@ -46,6 +65,7 @@ func TestDBMatchmaking(t *testing.T) {
 		Brakes:       100,
 		Agility:      100,
 		Aerodynamics: 100,
+		Livery:       Livery{Paint: "Racing Red", PaintColor: "#c8102e", RaceNumber: 46, RiderName: "Vale"},
 	}
 
 	stats2 := MotorcycleStats{
@@ -59,40 +79,466 @@ func TestDBMatchmaking(t *testing.T) {
 	}
 
 	db := NewSQL_DB(conn)
-	track, left, err := db.StartMatchmaking("user", &stats1)
+	track, left, err := db.StartMatchmaking(context.Background(), "user", &stats1)
 
 	if err != nil || track == -1 || left != 1 {
 		t.Errorf("Wrong response starting matchmaking motorcycle 1")
 	}
 
-	tr, err := db.CheckIsRacing("user", 1)
+	tr, weather, err := db.CheckIsRacing(context.Background(), "user", 1)
 
-	if err != nil || tr != "Mugello" {
+	if err != nil || tr != "Mugello" || weather != "dry" {
 		t.Errorf("Wrong information user racing")
 	}
 
-	track, left, err = db.StartMatchmaking("user", &stats2)
+	if queues, err := db.GetMatchmakingQueues(context.Background()); err != nil || queues["Mugello"] != 1 {
+		t.Errorf("Wrong matchmaking queue: %v", queues)
+	}
+
+	// The same motorcycle cannot wait for two races
+	if _, _, err = db.StartMatchmaking(context.Background(), "user", &stats1); err == nil {
+		t.Errorf("Motorcycle joined matchmaking twice")
+	}
+
+	track, left, err = db.StartMatchmaking(context.Background(), "user", &stats2)
 
 	if err != nil || track == -1 || left != 0 {
 		t.Errorf("Wrong response starting matchmaking motorcycle 2")
 	}
 
-	tr, err = db.CheckIsRacing("user", 2)
+	tr, _, err = db.CheckIsRacing(context.Background(), "user", 2)
 
 	if err != nil || tr != "Mugello" {
 		t.Errorf("Wrong information user racing")
 	}
 
-	results, err := db.CompleteRace(1)
+	results, err := db.CompleteRace(context.Background(), 1)
 
 	if err != nil || results == nil {
 		t.Log(err)
 		t.Errorf("Got error while completing race but should not")
 	}
 
-	if results[0].Position == 1 && results[0].MotorcycleId == 1 && results[1].Position == 2 && results[1].MotorcycleId == 2 {
+	if results[0].Position != 1 || results[0].MotorcycleId != 1 || results[1].Position != 2 || results[1].MotorcycleId != 2 {
+		t.Errorf("Wrong position of motorcycles after race")
+		return
+	}
+
+	if results[0].Wear <= 0 || results[1].Wear <= results[0].Wear {
+		t.Errorf("Wrong wear of motorcycles after race")
+	}
+
+	if results[0].Livery != stats1.Livery || results[1].Livery != (Livery{}) {
+		t.Errorf("Livery not carried into race results")
+	}
+
+	if results[0].Waited < 0 || results[1].Waited < 0 {
+		t.Errorf("Wrong matchmaking wait")
+	}
+
+	if queues, err := db.GetMatchmakingQueues(context.Background()); err != nil || queues["Mugello"] != 0 {
+		t.Errorf("Matchmaking queue not emptied after race: %v", queues)
+	}
+
+	// Results are stored with the race to be published on the event bus
+	events, err := db.ClaimRaceEvents(context.Background())
+	if err != nil || len(events) != 2 || events[0].Username != "user" || events[0].MotorcycleId != 1 || events[0].Position != 1 {
+		t.Errorf("Wrong race events after race: %v", events)
+		return
+	}
+
+	// Claimed events are not published by another replica
+	if others, err := db.ClaimRaceEvents(context.Background()); err != nil || len(others) != 0 {
+		t.Errorf("Race events claimed twice")
+	}
+
+	if err = db.DeleteRaceEvent(context.Background(), events[0].Id); err != nil {
+		t.Errorf("Unable to delete race event: %v", err)
+	}
+}
+
+func TestDBGetRecentRaces(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+	results, err := db.GetRecentRaces(context.Background())
+
+	if err != nil {
+		t.Errorf("Got error while getting recent races but should not")
+		return
+	}
+
+	// Only the latest race of each player is shown
+	players := map[string]bool{}
+	for _, r := range results {
+		if players[r.Username] {
+			t.Errorf("More than one race of %s", r.Username)
+		}
+		players[r.Username] = true
+	}
+}
+
+func TestDBGetPlayersRecentRaces(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// user raced twice in the matchmaking test, nobody never raced
+	results, err := db.GetPlayersRecentRaces(context.Background(), []string{"user", "nobody"})
+	if err != nil || len(results) != 1 || results[0].Username != "user" {
+		t.Errorf("Unable to get recent races of players")
+	}
+
+	results, err = db.GetPlayersRecentRaces(context.Background(), nil)
+	if err != nil || len(results) != 0 {
+		t.Errorf("Recent races of nobody should be empty")
+	}
+}
+
+func TestDBCreateLobby(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+	stats := MotorcycleStats{Id: 3, Name: "KTM SuperDuke 1290 RR", Level: 1, Engine: 5, Brakes: 5, Agility: 5, Aerodynamics: 5}
+
+	code, err := db.CreateLobby(context.Background(), "alice", &stats, 1, true)
+	if err != nil || len(code) != lobbyCodeLength {
+		t.Errorf("Unable to create lobby: %v", err)
+		return
+	}
+
+	lobby, err := db.GetLobby(context.Background(), code)
+	if err != nil || lobby.Host != "alice" || lobby.TrackName != "Mugello" || !lobby.Friendly || len(lobby.Entries) != 1 || lobby.Entries[0].Id != 3 {
+		t.Errorf("Wrong lobby after creation")
+	}
+
+	code2, track, weather, err := db.CheckIsInLobby(context.Background(), "alice", 3)
+	if err != nil || code2 != code || track != "Mugello" || weather != lobby.Weather || weather == "" {
+		t.Errorf("Motorcycle waiting in lobby not found")
+	}
+
+	// The same motorcycle cannot wait for two races
+	if _, err = db.CreateLobby(context.Background(), "alice", &stats, 1, false); err == nil {
+		t.Errorf("Motorcycle joined two lobbies")
+	}
+	if _, err = db.CreateLobby(context.Background(), "bob", &stats, 42, false); err == nil {
+		t.Errorf("Lobby created on unknown track")
+	}
+
+	// Host leaving closes the lobby
+	if err = db.LeaveLobby(context.Background(), code, "alice"); err != nil {
+		t.Errorf("Unable to leave lobby: %v", err)
+	}
+	if _, err = db.GetLobby(context.Background(), code); err == nil {
+		t.Errorf("Lobby not closed when left by the host")
+	}
+}
+
+func TestDBLobbyRace(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+	stats := MotorcycleStats{Id: 2, Name: "Yamaha R1", Level: 3, Engine: 20, Brakes: 20, Agility: 20, Aerodynamics: 20}
+
+	// Only the host is waiting
+	if _, err := db.CompleteLobbyRace(context.Background(), "ABC234"); err == nil {
+		t.Errorf("Race completed with only one motorcycle")
+	}
+
+	left, err := db.JoinLobby(context.Background(), "ABC234", "guest", &stats)
+	if err != nil || left != 0 {
+		t.Errorf("Unable to join lobby: %v", err)
 		return
 	}
+	if _, err = db.JoinLobby(context.Background(), "ABC234", "late", &MotorcycleStats{Id: 1}); err == nil {
+		t.Errorf("Joined a full lobby")
+	}
+
+	results, err := db.CompleteLobbyRace(context.Background(), "ABC234")
+	if err != nil || len(results) != 2 {
+		t.Errorf("Unable to complete lobby race: %v", err)
+		return
+	}
+	if results[0].Username != "guest" || results[0].Position != 1 || results[0].TotalMotorcycles != 2 || results[0].Friendly {
+		t.Errorf("Wrong results of lobby race")
+	}
+
+	if _, err = db.GetLobby(context.Background(), "ABC234"); err == nil {
+		t.Errorf("Lobby not closed after the race")
+	}
+	history, err := db.GetHistory(context.Background(), "guest")
+	if err != nil || len(history) != 1 {
+		t.Errorf("Lobby race not in history")
+	}
+}
 
-	t.Errorf("Wrong position of motorcycles after race")
+func TestDBCreateChampionship(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	id, err := db.CreateChampionship(context.Background(), "host", "Summer Cup", []int{1, 1}, time.Now().Add(time.Hour), time.Hour, 4)
+	if err != nil {
+		t.Errorf("Unable to create championship: %v", err)
+		return
+	}
+
+	schedule, err := db.GetChampionshipSchedule(context.Background(), id)
+	if err != nil || len(schedule) != 2 || schedule[1].Round != 2 || !schedule[1].StartsAt.After(schedule[0].StartsAt) || schedule[0].Weather == "" {
+		t.Errorf("Wrong championship schedule")
+	}
+
+	stats := MotorcycleStats{Id: 1, Name: "Ducati Panigale V4", Level: 1, Engine: 10, Brakes: 10, Agility: 10, Aerodynamics: 10}
+	if err = db.RegisterChampionship(context.Background(), id, "alice", &stats); err != nil {
+		t.Errorf("Unable to register to championship: %v", err)
+	}
+	if err = db.RegisterChampionship(context.Background(), id, "alice", &stats); err == nil {
+		t.Errorf("Registered twice to the same championship")
+	}
+
+	// A motorcycle entered in a championship cannot race elsewhere until it ends
+	other, err := db.CreateChampionship(context.Background(), "host", "Winter Cup", []int{1}, time.Now().Add(time.Hour), time.Hour, 4)
+	if err != nil {
+		t.Errorf("Unable to create championship: %v", err)
+		return
+	}
+	if err = db.RegisterChampionship(context.Background(), other, "alice", &stats); err == nil {
+		t.Errorf("Motorcycle registered to two championships")
+	}
+	if _, _, err = db.StartMatchmaking(context.Background(), "alice", &stats); err == nil {
+		t.Errorf("Motorcycle entered in a championship joined matchmaking")
+	}
+
+	championship, err := db.GetChampionship(context.Background(), id)
+	if err != nil || championship.Status != "open" || championship.Entrants != 1 || championship.Rounds != 2 {
+		t.Errorf("Wrong championship after registration")
+	}
+
+	if _, err = db.CreateChampionship(context.Background(), "host", "Empty Cup", nil, time.Now(), time.Hour, 4); err == nil {
+		t.Errorf("Championship created without rounds")
+	}
+}
+
+func TestDBCompleteChampionshipRound(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// Only the first round of the Spring Cup has started
+	due, err := db.GetDueRounds(context.Background())
+	if err != nil || len(due) != 1 || due[0].ChampionshipId != 1 || due[0].Round != 1 {
+		t.Errorf("Wrong due rounds")
+		return
+	}
+
+	results, standings, err := db.CompleteChampionshipRound(context.Background(), 1, 1)
+	if err != nil || len(results) != 2 || results[0].Username != "fast" || results[0].TotalMotorcycles != 2 || standings != nil {
+		t.Errorf("Unable to complete first round: %v", err)
+		return
+	}
+
+	// Another replica completing the same round does nothing
+	results, _, err = db.CompleteChampionshipRound(context.Background(), 1, 1)
+	if err != nil || results != nil {
+		t.Errorf("Round completed twice")
+	}
+
+	if err = db.RegisterChampionship(context.Background(), 1, "late", &MotorcycleStats{Id: 1}); err == nil {
+		t.Errorf("Registered to a running championship")
+	}
+
+	_, standings, err = db.CompleteChampionshipRound(context.Background(), 1, 2)
+	if err != nil || len(standings) != 2 || standings[0].Username != "fast" || standings[0].Points != 50 || standings[0].Wins != 2 || standings[1].Points != 40 {
+		t.Errorf("Wrong final standings: %v", err)
+	}
+
+	championship, err := db.GetChampionship(context.Background(), 1)
+	if err != nil || championship.Status != "completed" || championship.RoundsCompleted != 2 {
+		t.Errorf("Championship not completed after the last round")
+	}
+
+	// A single rider is not enough for a championship
+	results, _, err = db.CompleteChampionshipRound(context.Background(), 2, 1)
+	if err != nil || results != nil {
+		t.Errorf("Round raced with a single rider")
+	}
+	championship, err = db.GetChampionship(context.Background(), 2)
+	if err != nil || championship.Status != "cancelled" {
+		t.Errorf("Championship without riders not cancelled")
+	}
+}
+
+func TestDBNotifyChampionship(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// Already completed rounds are not raced again, but still wait for their notification
+	if _, _, err := db.CompleteChampionshipRound(context.Background(), 1, 1); err != nil {
+		t.Errorf("Unable to complete round: %v", err)
+		return
+	}
+
+	// The replica racing the round is still notifying it
+	ids, err := db.GetUnnotifiedChampionships(context.Background())
+	if err != nil || len(ids) != 0 {
+		t.Errorf("Championship claimed by a replica retried")
+	}
+	if claimed, err := db.ClaimChampionship(context.Background(), 1); err != nil || claimed {
+		t.Errorf("Championship claimed twice")
+	}
+
+	// Until its claim expires
+	if _, err = conn.Exec("UPDATE Championships SET ClaimedAt=CURRENT_TIMESTAMP - INTERVAL 2 MINUTE WHERE Id=1"); err != nil {
+		t.Errorf("Unable to expire claim: %v", err)
+		return
+	}
+	ids, err = db.GetUnnotifiedChampionships(context.Background())
+	if err != nil || len(ids) != 1 || ids[0] != 1 {
+		t.Errorf("Unnotified championship not found")
+		return
+	}
+	if claimed, err := db.ClaimChampionship(context.Background(), 1); err != nil || !claimed {
+		t.Errorf("Unable to claim championship")
+	}
+
+	rounds, err := db.GetUnnotifiedRounds(context.Background(), 1)
+	if err != nil || len(rounds) == 0 || rounds[0].Round != 1 {
+		t.Errorf("Unnotified round not found")
+		return
+	}
+	results, err := db.GetRoundResults(context.Background(), 1, 1)
+	if err != nil || len(results) != 2 || results[0].Username != "fast" {
+		t.Errorf("Wrong round results")
+	}
+
+	for _, round := range rounds {
+		if err = db.MarkRoundNotified(context.Background(), 1, round.Round); err != nil {
+			t.Errorf("Unable to mark round as notified: %v", err)
+		}
+	}
+	rounds, err = db.GetUnnotifiedRounds(context.Background(), 1)
+	if err != nil || len(rounds) != 0 {
+		t.Errorf("Round still unnotified")
+	}
+
+	if err = db.MarkChampionshipNotified(context.Background(), 1); err != nil {
+		t.Errorf("Unable to mark championship as notified: %v", err)
+	}
+	championship, err := db.GetChampionship(context.Background(), 1)
+	if err != nil || !championship.Notified {
+		t.Errorf("Championship still unnotified")
+	}
+}
+
+func TestDBRunTimeTrial(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	var matchmaking int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM Matchmaking").Scan(&matchmaking); err != nil {
+		t.Errorf("Unable to count matchmaking: %v", err)
+		return
+	}
+
+	// Much faster than the Yamaha of slow, much slower than the Ducati of fast
+	stats := MotorcycleStats{Id: 2, Name: "Yamaha R1", Level: 2, Engine: 10, Brakes: 10, Agility: 10, Aerodynamics: 10}
+	result, err := db.RunTimeTrial(context.Background(), "slow", &stats, 1)
+	if err != nil {
+		t.Errorf("Unable to run time trial: %v", err)
+		return
+	}
+	if result.PersonalBest != 140000 || !result.NewPersonalBest || result.TrackRecord != 70000 || result.RecordHolder != "fast" || result.NewTrackRecord {
+		t.Errorf("Wrong comparison with ghost and track record: %+v", result)
+	}
+
+	board, err := db.GetTimeTrialLeaderboard(context.Background(), 1)
+	if err != nil || len(board) != 2 || board[0].Username != "fast" || board[1].Username != "slow" || board[1].LapTime != result.LapTime {
+		t.Errorf("Wrong time trial leaderboard")
+	}
+
+	var after int
+	if err = conn.QueryRow("SELECT COUNT(*) FROM Matchmaking").Scan(&after); err != nil || after != matchmaking {
+		t.Errorf("Time trial changed matchmaking")
+	}
+
+	if _, err = db.RunTimeTrial(context.Background(), "slow", &stats, 42); err == nil {
+		t.Errorf("Time trial on unknown track")
+	}
+
+	if _, err = db.RunTimeTrial(context.Background(), "slow", &stats, 1); err == nil {
+		t.Errorf("Time trial run again without waiting")
+	}
+}
+
+func TestLapTime(t *testing.T) {
+	// Stronger motorcycles are faster and the rider never deviates more than lapVariation
+	if lapTime(40, 4, 0) <= lapTime(80, 4, 0) {
+		t.Errorf("More power should mean a faster lap")
+	}
+	if lapTime(0, 4, 0) != baseLapTime {
+		t.Errorf("Wrong lap of the base motorcycle")
+	}
+	ideal := lapTime(40, 4, 0)
+	if lapTime(40, 4, 1) > int(float64(ideal)*(1+lapVariation))+1 || lapTime(40, 4, -1) < int(float64(ideal)*(1-lapVariation))-1 {
+		t.Errorf("Rider deviation too large")
+	}
+}
+
+func TestDBWeather(t *testing.T) {
+	conn := NewSQLConnection(t)
+	defer conn.Close()
+
+	db := NewSQL_DB(conn)
+
+	// Fastest on a dry track thanks to the engine
+	engine := MotorcycleStats{Id: 1, Name: "KTM", Level: 1, Engine: 40, Brakes: 5, Agility: 5, Aerodynamics: 5}
+	// Fastest in the wet thanks to brakes and agility
+	brakes := MotorcycleStats{Id: 2, Name: "Ducati", Level: 1, Engine: 5, Brakes: 20, Agility: 20, Aerodynamics: 5}
+
+	if _, err := conn.Exec("UPDATE Tracks SET Weather='wet' WHERE Id=1"); err != nil {
+		t.Errorf("Unable to change weather: %v", err)
+		return
+	}
+
+	if _, _, err := db.StartMatchmaking(context.Background(), "engine", &engine); err != nil {
+		t.Errorf("Unable to start matchmaking: %v", err)
+	}
+	if _, _, err := db.StartMatchmaking(context.Background(), "brakes", &brakes); err != nil {
+		t.Errorf("Unable to start matchmaking: %v", err)
+	}
+
+	_, weather, err := db.CheckIsRacing(context.Background(), "engine", 1)
+	if err != nil || weather != "wet" {
+		t.Errorf("Wrong weather announced")
+	}
+
+	results, err := db.CompleteRace(context.Background(), 1)
+	if err != nil || len(results) != 2 {
+		t.Errorf("Unable to complete race: %v", err)
+		return
+	}
+	if results[0].Username != "brakes" || results[0].Position != 1 || results[0].Weather != "wet" {
+		t.Errorf("Wet weather not applied to the race")
+	}
+
+	history, err := db.GetHistory(context.Background(), "brakes")
+	if err != nil || len(history) != 1 || history[0].Weather != "wet" {
+		t.Errorf("Weather not stored in history")
+	}
+
+	// A new forecast is generated for the next race
+	tracks, err := db.GetTracks(context.Background())
+	if err != nil || len(tracks) != 1 || (tracks[0].Weather != "dry" && tracks[0].Weather != "wet" && tracks[0].Weather != "windy") {
+		t.Errorf("Wrong forecast after race")
+	}
 }
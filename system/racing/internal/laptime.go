@@ -0,0 +1,26 @@
+package internal
+
+// Lap of a motorcycle with an average weighted stat of zero
+const baseLapTime = 180000 // milliseconds
+
+// Weighted stat that halves the lap time of the base motorcycle
+const lapStatHalving = 20
+
+// Largest deviation of the rider from the ideal lap, as a fraction of the lap
+const lapVariation = 0.02
+
+// Lap time in milliseconds of a motorcycle on a track, where power is the stats of the motorcycle weighted by
+// the values of the track and weights their sum; noise in [-1, 1] is the deviation of the rider from the ideal lap
+func lapTime(power int, weights int, noise float64) int {
+	if weights <= 0 {
+		weights = 1
+	}
+
+	stat := float64(power) / float64(weights)
+	if stat < 0 {
+		stat = 0
+	}
+
+	ideal := baseLapTime * lapStatHalving / (lapStatHalving + stat)
+	return int(ideal * (1 + lapVariation*noise))
+}
@@ -0,0 +1,50 @@
+package internal
+
+import (
+	"context"
+	"log/slog"
+
+	"racing/telemetry"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var matchmakingWait = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "racing_matchmaking_wait_seconds",
+	Help:    "Time spent in matchmaking before the race started, by track.",
+	Buckets: []float64{1, 5, 15, 30, 60, 120, 300, 600, 1800, 3600},
+}, []string{"track"})
+
+// Depth of the matchmaking queues, read from the database when scraped since the queues are shared by all the replicas
+type MatchmakingCollector struct {
+	db    RacingDB
+	depth *prometheus.Desc
+}
+
+func NewMatchmakingCollector(db RacingDB) *MatchmakingCollector {
+	return &MatchmakingCollector{
+		db:    db,
+		depth: prometheus.NewDesc("racing_matchmaking_queue_depth", "Motorcycles waiting in matchmaking, by track.", []string{"track"}, nil),
+	}
+}
+
+func (c *MatchmakingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.depth
+}
+
+func (c *MatchmakingCollector) Collect(ch chan<- prometheus.Metric) {
+	// A slow database never blocks the scrape
+	ctx, cancel := context.WithTimeout(context.Background(), telemetry.Deadline("Racing.GetMatchmakingQueues"))
+	defer cancel()
+
+	queues, err := c.db.GetMatchmakingQueues(ctx)
+	if err != nil {
+		slog.Error("Collect failed", "error", err)
+		return
+	}
+
+	for track, waiting := range queues {
+		ch <- prometheus.MustNewConstMetric(c.depth, prometheus.GaugeValue, float64(waiting), track)
+	}
+}
@@ -2,9 +2,12 @@ package internal
 
 import (
 	"context"
-	"log"
+	"errors"
+	"log/slog"
 	"time"
 
+	"racing/telemetry"
+
 	pb "racing/proto"
 
 	"google.golang.org/grpc"
@@ -17,111 +20,684 @@ type Server struct {
 	pb.UnimplementedStillAliveServer
 	db           RacingDB
 	orchestrator *grpc.ClientConn
+	events       *grpc.ClientConn
+}
+
+func NewServer(conn RacingDB, orchestrator *grpc.ClientConn, events *grpc.ClientConn) *Server {
+	return &Server{db: conn, orchestrator: orchestrator, events: events}
 }
 
-func NewServer(conn RacingDB, orchestrator *grpc.ClientConn) *Server {
-	return &Server{db: conn, orchestrator: orchestrator}
+func resultToPb(r *RaceResult) *pb.RaceResult {
+	return &pb.RaceResult{
+		Username:         r.Username,
+		MotorcycleId:     int32(r.MotorcycleId),
+		PositionInRace:   int32(r.Position),
+		TotalMotorcycles: int32(r.TotalMotorcycles),
+		TrackName:        r.TrackName,
+		MotorcycleName:   r.MotorcycleName,
+		MotorcycleLevel:  int32(r.MotorcycleLevel),
+		Time:             timestamppb.New(r.Time),
+		Wear:             int32(r.Wear),
+		Friendly:         r.Friendly,
+		Weather:          r.Weather,
+		Livery: &pb.Livery{
+			Paint:      r.Paint,
+			PaintColor: r.PaintColor,
+			RaceNumber: int32(r.RaceNumber),
+			RiderName:  r.RiderName,
+		},
+	}
+}
+
+func statsFromPb(in *pb.RaceMotorcycle) *MotorcycleStats {
+	return &MotorcycleStats{
+		Id:           int(in.MotorcycleId),
+		Name:         in.MotorcycleName,
+		Level:        int(in.Level),
+		Engine:       int(in.Engine),
+		Brakes:       int(in.Brakes),
+		Aerodynamics: int(in.Aerodynamics),
+		Agility:      int(in.Agility),
+		Livery: Livery{
+			Paint:      in.GetLivery().GetPaint(),
+			PaintColor: in.GetLivery().GetPaintColor(),
+			RaceNumber: int(in.GetLivery().GetRaceNumber()),
+			RiderName:  in.GetLivery().GetRiderName(),
+		},
+	}
+}
+
+func lobbyToPb(l *Lobby) *pb.LobbyInfo {
+	lobby := &pb.LobbyInfo{
+		Code:           l.Code,
+		Host:           l.Host,
+		TrackName:      l.TrackName,
+		MaxMotorcycles: int32(l.MaxMotorcycles),
+		Friendly:       l.Friendly,
+		Weather:        l.Weather,
+	}
+	for _, e := range l.Entries {
+		lobby.Entries = append(lobby.Entries, &pb.LobbyEntry{
+			Username:        e.Username,
+			MotorcycleId:    int32(e.Id),
+			MotorcycleName:  e.Name,
+			MotorcycleLevel: int32(e.Level),
+			Livery: &pb.Livery{
+				Paint:      e.Paint,
+				PaintColor: e.PaintColor,
+				RaceNumber: int32(e.RaceNumber),
+				RiderName:  e.RiderName,
+			},
+		})
+	}
+	return lobby
 }
 
 func (s *Server) CheckIsRacing(ctx context.Context, in *pb.PlayerMotorcycle) (*pb.RacingStatus, error) {
-	track, _ := s.db.CheckIsRacing(in.Username, int(in.MotorcycleId))
+	track, weather, err := s.db.CheckIsRacing(ctx, in.Username, int(in.MotorcycleId))
+	if err != nil {
+		slog.ErrorContext(ctx, "CheckIsRacing failed", "error", err)
+		return nil, err
+	}
+
+	// Motorcycles waiting in a private lobby are racing too
+	code := ""
+	if track == "" {
+		code, track, weather, err = s.db.CheckIsInLobby(ctx, in.Username, int(in.MotorcycleId))
+		if err != nil {
+			slog.ErrorContext(ctx, "CheckIsRacing failed", "error", err)
+			return nil, err
+		}
+	}
+
+	// So are the motorcycles entered in a championship until its last round
+	championship, err := s.db.CheckIsInChampionship(ctx, in.Username, int(in.MotorcycleId))
+	if err != nil {
+		slog.ErrorContext(ctx, "CheckIsRacing failed", "error", err)
+		return nil, err
+	}
 
-	log.Printf("Checking racing (%s:%d), status %t", in.Username, in.MotorcycleId, track != "")
+	racing := track != "" || championship != 0
+	slog.DebugContext(ctx, "Checking racing", "username", in.Username, "motorcycle_id", in.MotorcycleId, "racing", racing)
 
-	return &pb.RacingStatus{IsRacing: track != "", TrackName: track}, nil
+	return &pb.RacingStatus{IsRacing: racing, TrackName: track, LobbyCode: code, Weather: weather, ChampionshipId: int32(championship)}, nil
 }
 
 func (s *Server) GetHistory(in *pb.PlayerUsername, stream pb.Racing_GetHistoryServer) error {
-	results, err := s.db.GetHistory(in.Username)
+	results, err := s.db.GetHistory(stream.Context(), in.Username)
 
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(stream.Context(), "GetHistory failed", "error", err)
 		return err
 	}
 
-	log.Printf("Retrieving history (%s)", in.Username)
+	slog.DebugContext(stream.Context(), "Retrieving history", "username", in.Username)
 
 	for _, v := range results {
-		stream.Send(&pb.RaceResult{
+		stream.Send(resultToPb(&v))
+	}
+
+	return nil
+}
+
+func (s *Server) StartMatchmaking(ctx context.Context, in *pb.RaceMotorcycle) (*emptypb.Empty, error) {
+	track, left, err := s.db.StartMatchmaking(ctx, in.Username, statsFromPb(in))
+
+	if err != nil {
+		slog.ErrorContext(ctx, "StartMatchmaking failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Starting matchmaking", "username", in.Username, "motorcycle_id", in.MotorcycleId)
+
+	// complete race since no motorcycles are required to join the race,
+	// the motorcycle already joined so the race is completed even if the caller gave up
+	if left == 0 {
+		ctx := context.WithoutCancel(ctx)
+		results, err := s.db.CompleteRace(ctx, track)
+
+		slog.InfoContext(ctx, "Race ended")
+
+		if err != nil {
+			slog.ErrorContext(ctx, "StartMatchmaking failed", "error", err)
+			return nil, err
+		}
+
+		for _, r := range results {
+			matchmakingWait.WithLabelValues(r.TrackName).Observe(r.Waited.Seconds())
+		}
+
+		// Results are published on the event bus even if the Orchestrator does not receive them
+		s.PublishRaceEvents(ctx)
+
+		err = s.notifyEndRace(ctx, 0, 0, results)
+	}
+
+	return nil, err
+}
+
+func (s *Server) notifyEndRace(ctx context.Context, championship int, round int, results []RaceResult) error {
+	// Championship and round are 0 for the other races, the Orchestrator rewards a round only once even if notified again
+
+	c := pb.NewOrchestratorClient(s.orchestrator)
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), telemetry.Deadline("Orchestrator.NotifyEndRace"))
+	defer cancel()
+
+	// Notify to Orchestrator the results
+	stream, err := c.NotifyEndRace(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "notifyEndRace failed", "error", err)
+		return err
+	}
+
+	slog.InfoContext(ctx, "Sending results")
+
+	for _, v := range results {
+		result := resultToPb(&v)
+		result.ChampionshipId = int32(championship)
+		result.Round = int32(round)
+		err = stream.Send(result)
+
+		if err != nil {
+			slog.ErrorContext(ctx, "notifyEndRace failed", "error", err)
+			return err
+		}
+	}
+
+	// Results are received only once the Orchestrator acknowledges them
+	if _, err = stream.CloseAndRecv(); err != nil {
+		slog.ErrorContext(ctx, "notifyEndRace failed", "error", err)
+		return err
+	}
+
+	return nil
+}
+
+func (s *Server) PublishRaceEvents(ctx context.Context) error {
+	// Publish on the event bus the results stored with the races, for the services that react to them.
+	// An event is deleted only once the bus receives it, otherwise it is published again after its claim expires
+
+	ctx = context.WithoutCancel(ctx)
+
+	events, err := s.db.ClaimRaceEvents(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "PublishRaceEvents failed", "error", err)
+		return err
+	}
+
+	c := pb.NewEventsClient(s.events)
+	for _, v := range events {
+		publish_ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Events.PublishEvent"))
+		_, err := c.PublishEvent(publish_ctx, &pb.DomainEvent{
+			Type:             pb.DomainEventType_RACE_COMPLETED,
 			Username:         v.Username,
-			MotorcycleId:     int32(v.MotorcycleId),
-			PositionInRace:   int32(v.Position),
-			TotalMotorcycles: int32(v.TotalMotorcycles),
 			TrackName:        v.TrackName,
+			Position:         int32(v.Position),
+			TotalMotorcycles: int32(v.TotalMotorcycles),
+			MotorcycleId:     int32(v.MotorcycleId),
 			MotorcycleName:   v.MotorcycleName,
-			MotorcycleLevel:  int32(v.MotorcycleLevel),
-			Time:             timestamppb.New(v.Time),
 		})
+		cancel()
+
+		if err != nil {
+			slog.ErrorContext(ctx, "PublishRaceEvents failed", "error", err)
+			continue
+		}
+
+		s.db.DeleteRaceEvent(ctx, v.Id)
 	}
 
 	return nil
 }
 
-func (s *Server) StartMatchmaking(ctx context.Context, in *pb.RaceMotorcycle) (*emptypb.Empty, error) {
-	track, left, err := s.db.StartMatchmaking(in.Username, &MotorcycleStats{
-		Id:           int(in.MotorcycleId),
-		Name:         in.MotorcycleName,
-		Level:        int(in.Level),
-		Engine:       int(in.Engine),
-		Brakes:       int(in.Brakes),
-		Aerodynamics: int(in.Aerodynamics),
-		Agility:      int(in.Agility),
-	})
+func (s *Server) GetRecentRaces(_ *emptypb.Empty, stream pb.Racing_GetRecentRacesServer) error {
+	results, err := s.db.GetRecentRaces(stream.Context())
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetRecentRaces failed", "error", err)
+		return err
+	}
+
+	for _, v := range results {
+		stream.Send(resultToPb(&v))
+	}
+
+	return nil
+}
+
+func (s *Server) GetPlayersRecentRaces(in *pb.PlayerList, stream pb.Racing_GetPlayersRecentRacesServer) error {
+	results, err := s.db.GetPlayersRecentRaces(stream.Context(), in.Usernames)
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetPlayersRecentRaces failed", "error", err)
+		return err
+	}
+
+	for _, v := range results {
+		stream.Send(resultToPb(&v))
+	}
+
+	return nil
+}
+
+func (s *Server) GetTracks(_ *emptypb.Empty, stream pb.Racing_GetTracksServer) error {
+	tracks, err := s.db.GetTracks(stream.Context())
 
 	if err != nil {
-		log.Println(err)
+		slog.ErrorContext(stream.Context(), "GetTracks failed", "error", err)
+		return err
+	}
+
+	for _, t := range tracks {
+		stream.Send(&pb.Track{Id: int32(t.Id), Name: t.Name, MaxMotorcycles: int32(t.MaxMotorcycles), Weather: t.Weather})
+	}
+
+	return nil
+}
+
+func (s *Server) CreateLobby(ctx context.Context, in *pb.LobbySettings) (*pb.LobbyInfo, error) {
+	if in.Motorcycle == nil {
+		return nil, errors.New("motorcycle of the host required")
+	}
+
+	code, err := s.db.CreateLobby(ctx, in.Motorcycle.Username, statsFromPb(in.Motorcycle), int(in.TrackId), in.Friendly)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateLobby failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Lobby created", "code", code, "username", in.Motorcycle.Username)
+
+	lobby, err := s.db.GetLobby(ctx, code)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateLobby failed", "error", err)
+		return nil, err
+	}
+
+	return lobbyToPb(lobby), nil
+}
+
+func (s *Server) JoinLobby(ctx context.Context, in *pb.LobbyMotorcycle) (*emptypb.Empty, error) {
+	if in.Motorcycle == nil {
+		return nil, errors.New("motorcycle required")
+	}
+
+	left, err := s.db.JoinLobby(ctx, in.Code, in.Motorcycle.Username, statsFromPb(in.Motorcycle))
+	if err != nil {
+		slog.ErrorContext(ctx, "JoinLobby failed", "error", err)
 		return nil, err
 	}
 
-	log.Printf("Starting matchmaking (%s:%d)", in.Username, in.MotorcycleId)
+	slog.InfoContext(ctx, "Lobby joined", "code", in.Code, "username", in.Motorcycle.Username)
 
-	// complete race since no motorcycles are required to join the race
+	// complete race since the lobby is full, even if the caller gave up
 	if left == 0 {
-		results, err := s.db.CompleteRace(track)
+		return nil, s.completeLobbyRace(context.WithoutCancel(ctx), in.Code)
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) LeaveLobby(ctx context.Context, in *pb.LobbyPlayer) (*emptypb.Empty, error) {
+	err := s.db.LeaveLobby(ctx, in.Code, in.Username)
+	if err != nil {
+		slog.ErrorContext(ctx, "LeaveLobby failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Lobby left", "code", in.Code, "username", in.Username)
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) StartLobbyRace(ctx context.Context, in *pb.LobbyPlayer) (*emptypb.Empty, error) {
+	lobby, err := s.db.GetLobby(ctx, in.Code)
+	if err != nil {
+		slog.ErrorContext(ctx, "StartLobbyRace failed", "error", err)
+		return nil, err
+	}
+
+	if lobby.Host != in.Username {
+		return nil, errors.New("only the host can start the race")
+	}
 
-		log.Printf("Race ended")
+	return nil, s.completeLobbyRace(ctx, in.Code)
+}
+
+func (s *Server) completeLobbyRace(ctx context.Context, code string) error {
+	results, err := s.db.CompleteLobbyRace(ctx, code)
+	if err != nil {
+		slog.ErrorContext(ctx, "completeLobbyRace failed", "error", err)
+		return err
+	}
+
+	slog.InfoContext(ctx, "Lobby race ended", "code", code)
+
+	// Results are published on the event bus even if the Orchestrator does not receive them
+	s.PublishRaceEvents(ctx)
+
+	return s.notifyEndRace(ctx, 0, 0, results)
+}
+
+func (s *Server) GetLobby(ctx context.Context, in *pb.LobbyReference) (*pb.LobbyInfo, error) {
+	lobby, err := s.db.GetLobby(ctx, in.Code)
+	if err != nil {
+		slog.ErrorContext(ctx, "GetLobby failed", "error", err)
+		return nil, err
+	}
+
+	return lobbyToPb(lobby), nil
+}
+
+func (s *Server) GetPlayerLobbies(in *pb.PlayerUsername, stream pb.Racing_GetPlayerLobbiesServer) error {
+	lobbies, err := s.db.GetPlayerLobbies(stream.Context(), in.Username)
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetPlayerLobbies failed", "error", err)
+		return err
+	}
+
+	for _, v := range lobbies {
+		stream.Send(lobbyToPb(&v))
+	}
+
+	return nil
+}
+
+func championshipToPb(c *Championship) *pb.ChampionshipInfo {
+	return &pb.ChampionshipInfo{
+		Id:              int32(c.Id),
+		Name:            c.Name,
+		Organizer:       c.Organizer,
+		Status:          c.Status,
+		MaxEntrants:     int32(c.MaxEntrants),
+		Entrants:        int32(c.Entrants),
+		Rounds:          int32(c.Rounds),
+		RoundsCompleted: int32(c.RoundsCompleted),
+		StartsAt:        timestamppb.New(c.StartsAt),
+	}
+}
+
+func standingToPb(s *ChampionshipStanding) *pb.ChampionshipStanding {
+	return &pb.ChampionshipStanding{
+		Username:       s.Username,
+		MotorcycleName: s.MotorcycleName,
+		Points:         int32(s.Points),
+		Wins:           int32(s.Wins),
+		Position:       int32(s.Position),
+	}
+}
+
+func (s *Server) CreateChampionship(ctx context.Context, in *pb.ChampionshipSettings) (*pb.ChampionshipInfo, error) {
+	tracks := make([]int, 0, len(in.TrackIds))
+	for _, t := range in.TrackIds {
+		tracks = append(tracks, int(t))
+	}
+
+	id, err := s.db.CreateChampionship(ctx, in.Organizer, in.Name, tracks, in.StartsAt.AsTime(), time.Duration(in.RoundIntervalMinutes)*time.Minute, int(in.MaxEntrants))
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateChampionship failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Championship created", "championship", in.Name, "username", in.Organizer)
+
+	championship, err := s.db.GetChampionship(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "CreateChampionship failed", "error", err)
+		return nil, err
+	}
+
+	return championshipToPb(championship), nil
+}
+
+func (s *Server) RegisterChampionship(ctx context.Context, in *pb.ChampionshipEntry) (*emptypb.Empty, error) {
+	if in.Motorcycle == nil {
+		return nil, errors.New("motorcycle required")
+	}
+
+	err := s.db.RegisterChampionship(ctx, int(in.ChampionshipId), in.Motorcycle.Username, statsFromPb(in.Motorcycle))
+	if err != nil {
+		slog.ErrorContext(ctx, "RegisterChampionship failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Registered to championship", "championship_id", in.ChampionshipId, "username", in.Motorcycle.Username)
+
+	return &emptypb.Empty{}, nil
+}
+
+func (s *Server) GetChampionships(_ *emptypb.Empty, stream pb.Racing_GetChampionshipsServer) error {
+	championships, err := s.db.GetChampionships(stream.Context())
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetChampionships failed", "error", err)
+		return err
+	}
+
+	for _, v := range championships {
+		stream.Send(championshipToPb(&v))
+	}
+
+	return nil
+}
+
+func (s *Server) GetChampionship(ctx context.Context, in *pb.ChampionshipReference) (*pb.ChampionshipInfo, error) {
+	championship, err := s.db.GetChampionship(ctx, int(in.ChampionshipId))
+	if err != nil {
+		slog.ErrorContext(ctx, "GetChampionship failed", "error", err)
+		return nil, err
+	}
+
+	return championshipToPb(championship), nil
+}
+
+func (s *Server) GetChampionshipSchedule(in *pb.ChampionshipReference, stream pb.Racing_GetChampionshipScheduleServer) error {
+	rounds, err := s.db.GetChampionshipSchedule(stream.Context(), int(in.ChampionshipId))
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetChampionshipSchedule failed", "error", err)
+		return err
+	}
+
+	for _, v := range rounds {
+		stream.Send(&pb.ChampionshipRound{
+			Round:     int32(v.Round),
+			TrackName: v.TrackName,
+			StartsAt:  timestamppb.New(v.StartsAt),
+			Completed: v.Completed,
+			Winner:    v.Winner,
+			Weather:   v.Weather,
+		})
+	}
+
+	return nil
+}
+
+func (s *Server) GetChampionshipStandings(in *pb.ChampionshipReference, stream pb.Racing_GetChampionshipStandingsServer) error {
+	standings, err := s.db.GetChampionshipStandings(stream.Context(), int(in.ChampionshipId))
 
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetChampionshipStandings failed", "error", err)
+		return err
+	}
+
+	for _, v := range standings {
+		stream.Send(standingToPb(&v))
+	}
+
+	return nil
+}
+
+func (s *Server) RunDueRounds(ctx context.Context) error {
+	// Race the championship rounds whose start time has come
+	// every replica runs it, a round is raced only by the first one getting to it
+	// results are notified until the Orchestrator receives them
+
+	rounds, err := s.db.GetDueRounds(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "RunDueRounds failed", "error", err)
+		return err
+	}
+
+	for _, round := range rounds {
+		results, standings, err := s.db.CompleteChampionshipRound(ctx, round.ChampionshipId, round.Round)
 		if err != nil {
-			log.Println(err)
-			return nil, err
+			slog.ErrorContext(ctx, "RunDueRounds failed", "error", err)
+			continue
+		}
+		if results == nil {
+			continue
+		}
+
+		slog.InfoContext(ctx, "Championship round ended", "championship_id", round.ChampionshipId, "round", round.Round)
+
+		// Results are published on the event bus even if the Orchestrator does not receive them
+		s.PublishRaceEvents(ctx)
+
+		if err = s.notifyRound(ctx, round.ChampionshipId, round.Round, results); err != nil {
+			continue
 		}
 
-		c := pb.NewOrchestratorClient(s.orchestrator)
-		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-		defer cancel()
+		if standings != nil {
+			s.notifyChampionship(ctx, round.ChampionshipId, standings)
+		}
+	}
+
+	return s.renotifyChampionships(ctx)
+}
+
+func (s *Server) renotifyChampionships(ctx context.Context) error {
+	// Notify again the results the Orchestrator did not receive, in the order they were raced
+
+	ids, err := s.db.GetUnnotifiedChampionships(ctx)
+	if err != nil {
+		slog.ErrorContext(ctx, "renotifyChampionships failed", "error", err)
+		return err
+	}
+
+	for _, id := range ids {
+		claimed, err := s.db.ClaimChampionship(ctx, id)
+		if err != nil || !claimed {
+			continue
+		}
 
-		// Notify to Orchestrator the results
-		stream, err := c.NotifyEndRace(ctx)
+		rounds, err := s.db.GetUnnotifiedRounds(ctx, id)
 		if err != nil {
-			log.Println(err)
-			return nil, err
+			continue
 		}
 
-		log.Printf("Sending results")
-
-		for _, v := range results {
-			err = stream.Send(&pb.RaceResult{
-				Username:         v.Username,
-				MotorcycleId:     int32(v.MotorcycleId),
-				PositionInRace:   int32(v.Position),
-				TotalMotorcycles: int32(v.TotalMotorcycles),
-				TrackName:        v.TrackName,
-				MotorcycleName:   v.MotorcycleName,
-				MotorcycleLevel:  int32(v.MotorcycleLevel),
-				Time:             timestamppb.New(v.Time),
-			})
-
-			if err != nil {
-				log.Println(err)
-				return nil, err
+		notified := true
+		for _, round := range rounds {
+			results, err := s.db.GetRoundResults(ctx, id, round.Round)
+			if err != nil || s.notifyRound(ctx, id, round.Round, results) != nil {
+				notified = false
+				break
 			}
 		}
+		if !notified {
+			continue
+		}
+
+		championship, err := s.db.GetChampionship(ctx, id)
+		if err != nil || championship.Status != "completed" || championship.Notified {
+			continue
+		}
 
-		stream.CloseSend()
-		time.Sleep(time.Second)
+		standings, err := s.db.GetChampionshipStandings(ctx, id)
+		if err != nil {
+			continue
+		}
+		s.notifyChampionship(ctx, id, standings)
 	}
 
-	return nil, err
+	return nil
+}
+
+func (s *Server) notifyRound(ctx context.Context, id int, round int, results []RaceResult) error {
+	if err := s.notifyEndRace(ctx, id, round, results); err != nil {
+		return err
+	}
+
+	return s.db.MarkRoundNotified(ctx, id, round)
+}
+
+func (s *Server) notifyChampionship(ctx context.Context, id int, standings []ChampionshipStanding) error {
+	if err := s.notifyEndChampionship(ctx, id, standings); err != nil {
+		return err
+	}
+
+	return s.db.MarkChampionshipNotified(ctx, id)
+}
+
+func (s *Server) notifyEndChampionship(ctx context.Context, id int, standings []ChampionshipStanding) error {
+	championship, err := s.db.GetChampionship(ctx, id)
+	if err != nil {
+		slog.ErrorContext(ctx, "notifyEndChampionship failed", "error", err)
+		return err
+	}
+
+	result := &pb.ChampionshipResult{ChampionshipId: int32(id), Name: championship.Name}
+	for _, v := range standings {
+		result.Standings = append(result.Standings, standingToPb(&v))
+	}
+
+	c := pb.NewOrchestratorClient(s.orchestrator)
+	ctx, cancel := context.WithTimeout(ctx, telemetry.Deadline("Orchestrator.NotifyEndChampionship"))
+	defer cancel()
+
+	// Notify to Orchestrator the final standings
+	if _, err = c.NotifyEndChampionship(ctx, result); err != nil {
+		slog.ErrorContext(ctx, "notifyEndChampionship failed", "error", err)
+	}
+
+	return err
+}
+
+func (s *Server) RunTimeTrial(ctx context.Context, in *pb.TimeTrialRequest) (*pb.TimeTrialResult, error) {
+	if in.Motorcycle == nil {
+		return nil, errors.New("motorcycle required")
+	}
+
+	result, err := s.db.RunTimeTrial(ctx, in.Motorcycle.Username, statsFromPb(in.Motorcycle), int(in.TrackId))
+	if err != nil {
+		slog.ErrorContext(ctx, "RunTimeTrial failed", "error", err)
+		return nil, err
+	}
+
+	slog.InfoContext(ctx, "Time trial lap", "username", in.Motorcycle.Username, "track", result.TrackName, "lap_time_ms", result.LapTime)
+
+	return &pb.TimeTrialResult{
+		TrackName:       result.TrackName,
+		LapTimeMs:       int32(result.LapTime),
+		PersonalBestMs:  int32(result.PersonalBest),
+		TrackRecordMs:   int32(result.TrackRecord),
+		RecordHolder:    result.RecordHolder,
+		NewPersonalBest: result.NewPersonalBest,
+		NewTrackRecord:  result.NewTrackRecord,
+	}, nil
+}
+
+func (s *Server) GetTimeTrialLeaderboard(in *pb.TrackReference, stream pb.Racing_GetTimeTrialLeaderboardServer) error {
+	records, err := s.db.GetTimeTrialLeaderboard(stream.Context(), int(in.TrackId))
+
+	if err != nil {
+		slog.ErrorContext(stream.Context(), "GetTimeTrialLeaderboard failed", "error", err)
+		return err
+	}
+
+	for _, v := range records {
+		stream.Send(&pb.TimeTrialRecord{
+			Username:        v.Username,
+			Position:        int32(v.Position),
+			LapTimeMs:       int32(v.LapTime),
+			MotorcycleName:  v.MotorcycleName,
+			MotorcycleLevel: int32(v.MotorcycleLevel),
+			Time:            timestamppb.New(v.Time),
+		})
+	}
+
+	return nil
 }
 
 func (s *Server) StillAlive(ctx context.Context, _ *emptypb.Empty) (*emptypb.Empty, error) {
@@ -2,34 +2,46 @@ package main
 
 import (
 	"context"
-	"database/sql"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 
 	"fmt"
 	"log"
+	"log/slog"
 	"net"
 	"os"
 	"time"
 
 	"racing/internal"
+	"racing/telemetry"
 
 	pb "racing/proto"
 
+	"github.com/prometheus/client_golang/prometheus"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/credentials/insecure"
 )
 
 func main() {
+	// Structured log of the service, level set by LOG_LEVEL
+	telemetry.InitLogging("racing")
+
 	// Listener for Service
 	lis, err := net.Listen("tcp", fmt.Sprintf(":%s", os.Getenv("SERVICE_PORT")))
 	if err != nil {
 		log.Fatalf("failed to listen: %v", err)
 	}
-	log.Printf("server listening at %v", lis.Addr())
+	slog.Info("server listening", "address", lis.Addr().String())
+
+	// Metrics of the service for Prometheus
+	go telemetry.ServeMetrics(os.Getenv("METRICS_PORT"))
+
+	// Traces of the service, exported as set by TRACING_EXPORTER
+	shutdown := telemetry.InitTracing("racing")
+	defer shutdown(context.Background())
 
 	// Database connection for Racing DB
-	db, err := sql.Open("mysql", "root:admin@tcp(racing_db:3306)/Racing?parseTime=true")
+	db, err := telemetry.OpenDB(mysql.MySQLDriver{}, "root:admin@tcp(racing_db:3306)/Racing?parseTime=true")
 	if err != nil {
 		log.Fatalf("failed to connect to db: %s", err)
 	}
@@ -39,22 +51,33 @@ func main() {
 	}
 
 	// Connecting with Orchestrator
-	log.Printf("Trying to connect to Orchestrator")
-	conn, err := grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	slog.Info("Trying to connect to Orchestrator")
+	conn, err := grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()), telemetry.DialOption())
 	for err != nil {
 		// Wait if unable to connect to orchestrator
-		log.Print(err.Error())
+		slog.Warn("unable to connect to Orchestrator", "error", err)
 		time.Sleep(500 * time.Millisecond)
-		conn, err = grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()))
+		conn, err = grpc.NewClient(fmt.Sprintf("orchestrator:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()), telemetry.DialOption())
 	}
 
+	// Connecting with the event bus, connections are made when the first event is published
+	events, err := grpc.NewClient(fmt.Sprintf("events:%s", os.Getenv("SERVICE_PORT")), grpc.WithTransportCredentials(insecure.NewCredentials()), telemetry.DialOption())
+	if err != nil {
+		log.Fatalf("failed to connect to event bus: %v", err)
+	}
+	defer events.Close()
+
 	// Creating gRPC server
-	s := grpc.NewServer()
-	server := internal.NewServer(internal.NewSQL_DB(db), conn)
+	s := grpc.NewServer(telemetry.ServerOption())
+	racing_db := internal.NewSQL_DB(db)
+	server := internal.NewServer(racing_db, conn, events)
+	prometheus.MustRegister(internal.NewMatchmakingCollector(racing_db))
 	pb.RegisterRacingServer(s, server)
 	pb.RegisterStillAliveServer(s, server)
 
 	go registerToOrchestrator(conn)
+	go runChampionships(server)
+	go publishRaceEvents(server)
 
 	if err := s.Serve(lis); err != nil {
 		log.Fatalf("failed to serve: %v", err)
@@ -64,16 +87,38 @@ func main() {
 
 func registerToOrchestrator(conn *grpc.ClientConn) {
 	c := pb.NewOrchestratorClient(conn)
-	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
-	defer cancel()
 
-	// Call to registration of the service
-	_, err := c.RegisterRacing(ctx, nil)
-	for err != nil {
+	// Call to registration of the service, every attempt with its own deadline
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), telemetry.Deadline("Orchestrator.RegisterRacing"))
+		_, err := c.RegisterRacing(ctx, nil)
+		cancel()
+		if err == nil {
+			break
+		}
+
 		// Wait if errors during registration
-		log.Print(err.Error())
+		slog.Warn("registration to Orchestrator failed", "error", err)
 		time.Sleep(500 * time.Millisecond)
-		_, err = c.RegisterRacing(ctx, nil)
 	}
-	log.Printf("Registered to Orchestrator")
+	slog.Info("Registered to Orchestrator")
+}
+
+func runChampionships(server *internal.Server) {
+	for {
+		if err := server.RunDueRounds(context.Background()); err != nil {
+			slog.Error("RunDueRounds failed", "error", err)
+		}
+		time.Sleep(30 * time.Second)
+	}
+}
+
+func publishRaceEvents(server *internal.Server) {
+	// Events left unpublished by a failure of the event bus are published again
+	for {
+		if err := server.PublishRaceEvents(context.Background()); err != nil {
+			slog.Error("PublishRaceEvents failed", "error", err)
+		}
+		time.Sleep(30 * time.Second)
+	}
 }
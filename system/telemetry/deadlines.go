@@ -0,0 +1,68 @@
+package telemetry
+
+import (
+	"log/slog"
+	"maps"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Deadline of an operation when none is configured
+const defaultDeadline = time.Second
+
+// Operations needing more than the default deadline, a lobby race may be run before they return
+// and the Orchestrator rewards every rider before acknowledging the results
+var operationDeadlines = map[string]time.Duration{
+	"Racing.JoinLobby":                   3 * time.Second,
+	"Racing.StartLobbyRace":              3 * time.Second,
+	"Orchestrator.NotifyEndRace":         3 * time.Second,
+	"Orchestrator.NotifyEndChampionship": 3 * time.Second,
+}
+
+type deadlineConfig struct {
+	fallback    time.Duration
+	byOperation map[string]time.Duration
+}
+
+var deadlines = sync.OnceValue(func() deadlineConfig {
+	// RPC_DEADLINE sets the deadline of every operation, RPC_DEADLINES overrides it for single operations
+	// as a comma separated list of Service.Method=duration, e.g. Racing.StartMatchmaking=5s,Garage.BuyListing=2s.
+	// Invalid durations are logged and ignored
+
+	config := deadlineConfig{fallback: defaultDeadline, byOperation: maps.Clone(operationDeadlines)}
+
+	if value := os.Getenv("RPC_DEADLINE"); value != "" {
+		if d, err := time.ParseDuration(value); err == nil && d > 0 {
+			config.fallback = d
+		} else {
+			slog.Warn("invalid RPC_DEADLINE, using the default one", "value", value, "deadline", defaultDeadline)
+		}
+	}
+
+	for _, entry := range strings.Split(os.Getenv("RPC_DEADLINES"), ",") {
+		operation, value, found := strings.Cut(strings.TrimSpace(entry), "=")
+		if !found {
+			continue
+		}
+		if d, err := time.ParseDuration(strings.TrimSpace(value)); err == nil && d > 0 {
+			config.byOperation[strings.TrimSpace(operation)] = d
+		} else {
+			slog.Warn("invalid deadline in RPC_DEADLINES", "operation", operation, "value", value)
+		}
+	}
+
+	return config
+})
+
+func Deadline(operation string) time.Duration {
+	// Deadline of an operation, named Service.Method like the gRPC call that performs it.
+	// The caller of an RPC and the service handling it use the same name, so both wait the same time
+
+	config := deadlines()
+	if d, ok := config.byOperation[operation]; ok {
+		return d
+	}
+	return config.fallback
+}
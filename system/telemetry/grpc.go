@@ -0,0 +1,122 @@
+package telemetry
+
+import (
+	"context"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/stats"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	grpcServerHandling = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_server_handling_seconds",
+		Help:    "Latency of the gRPC calls handled by the server, by method and status code.",
+		Buckets: latencyBuckets,
+	}, []string{"method", "code"})
+
+	grpcClientHandling = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "grpc_client_handling_seconds",
+		Help:    "Latency of the gRPC calls made by the client, by method and status code.",
+		Buckets: latencyBuckets,
+	}, []string{"method", "code"})
+)
+
+func ServerOption() grpc.ServerOption {
+	// Option of gRPC servers tracing and measuring every call handled, the correlation ID of the caller is kept in the context
+	return grpc.StatsHandler(handlers{correlationHandler{client: false}, otelgrpc.NewServerHandler(), &metricsHandler{histogram: grpcServerHandling}})
+}
+
+func DialOption() grpc.DialOption {
+	// Option of gRPC clients tracing and measuring every call made, the trace context and the correlation ID are sent in the metadata
+	return grpc.WithStatsHandler(handlers{correlationHandler{client: true}, otelgrpc.NewClientHandler(), &metricsHandler{histogram: grpcClientHandling}})
+}
+
+// Stats handlers called in order, the context tagged by one is passed to the next
+type handlers []stats.Handler
+
+func (hs handlers) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	for _, h := range hs {
+		ctx = h.TagRPC(ctx, info)
+	}
+	return ctx
+}
+
+func (hs handlers) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	for _, h := range hs {
+		h.HandleRPC(ctx, s)
+	}
+}
+
+func (hs handlers) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	for _, h := range hs {
+		ctx = h.TagConn(ctx, info)
+	}
+	return ctx
+}
+
+func (hs handlers) HandleConn(ctx context.Context, s stats.ConnStats) {
+	for _, h := range hs {
+		h.HandleConn(ctx, s)
+	}
+}
+
+// The correlation ID travels in the metadata of the calls, the client sends the one of its context
+// and the server puts the received one in the context of the handler
+type correlationHandler struct {
+	client bool
+}
+
+func (h correlationHandler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	if h.client {
+		if id := CorrelationID(ctx); id != "" {
+			return metadata.AppendToOutgoingContext(ctx, correlationMetadata, id)
+		}
+		return ctx
+	}
+
+	if ids := metadata.ValueFromIncomingContext(ctx, correlationMetadata); len(ids) > 0 {
+		return WithCorrelationID(ctx, ids[0])
+	}
+	return ctx
+}
+
+func (h correlationHandler) HandleRPC(context.Context, stats.RPCStats) {}
+
+func (h correlationHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h correlationHandler) HandleConn(context.Context, stats.ConnStats) {}
+
+type methodKey struct{}
+
+// Calls are measured from their beginning to their end, streams included,
+// errors are told apart by the status code label, OK for successful calls
+type metricsHandler struct {
+	histogram *prometheus.HistogramVec
+}
+
+func (h *metricsHandler) TagRPC(ctx context.Context, info *stats.RPCTagInfo) context.Context {
+	return context.WithValue(ctx, methodKey{}, info.FullMethodName)
+}
+
+func (h *metricsHandler) HandleRPC(ctx context.Context, s stats.RPCStats) {
+	end, ok := s.(*stats.End)
+	if !ok {
+		return
+	}
+
+	method, _ := ctx.Value(methodKey{}).(string)
+	h.histogram.WithLabelValues(method, status.Code(end.Error).String()).Observe(end.EndTime.Sub(end.BeginTime).Seconds())
+}
+
+func (h *metricsHandler) TagConn(ctx context.Context, _ *stats.ConnTagInfo) context.Context {
+	return ctx
+}
+
+func (h *metricsHandler) HandleConn(context.Context, stats.ConnStats) {}
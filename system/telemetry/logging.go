@@ -0,0 +1,109 @@
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"strings"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// HTTP header and gRPC metadata key carrying the correlation ID of a user action
+const (
+	CorrelationHeader   = "X-Correlation-ID"
+	correlationMetadata = "x-correlation-id"
+)
+
+// Attributes never written in clear, whatever the level or the caller
+var sensitiveKeys = map[string]bool{
+	"password": true,
+	"email":    true,
+	"phone":    true,
+	"token":    true,
+	"secret":   true,
+	"session":  true,
+	"cookie":   true,
+}
+
+const redacted = "[REDACTED]"
+
+func InitLogging(service string) {
+	// Log of the process as JSON lines on stdout, with at least the level set by LOG_LEVEL (debug, info, warn, error), info by default.
+	// Every line carries the service, and the correlation and trace IDs of the context it is logged with.
+	// The standard log package is redirected to the same logger
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(os.Getenv("LOG_LEVEL"))); err != nil {
+		level = slog.LevelInfo
+	}
+
+	handler := slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level, ReplaceAttr: redact})
+	slog.SetDefault(slog.New(contextHandler{handler}).With("service", service))
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+type correlationKey struct{}
+
+// Random bytes of a correlation ID, written in lowercase hex
+const correlationBytes = 8
+
+func NewCorrelationID() string {
+	b := make([]byte, correlationBytes)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func ValidCorrelationID(id string) bool {
+	// Only IDs in the format of NewCorrelationID are accepted from clients, they end up in logs and in gRPC metadata
+
+	if len(id) != 2*correlationBytes {
+		return false
+	}
+	for _, c := range id {
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func WithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, id)
+}
+
+func CorrelationID(ctx context.Context) string {
+	id, _ := ctx.Value(correlationKey{}).(string)
+	return id
+}
+
+// Handler adding to every record the IDs found in its context
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := CorrelationID(ctx); id != "" {
+		r.AddAttrs(slog.String("correlation_id", id))
+	}
+	if span := trace.SpanContextFromContext(ctx); span.IsValid() {
+		r.AddAttrs(slog.String("trace_id", span.TraceID().String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
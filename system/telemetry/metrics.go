@@ -0,0 +1,26 @@
+// Package telemetry is shared by every module of the system, it is copied into
+// each of them by `make update_telemetry` like the protobuf definitions.
+package telemetry
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Buckets of the latency histograms, in seconds
+var latencyBuckets = []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5}
+
+func ServeMetrics(port string) {
+	// Expose the metrics of the process on /metrics for Prometheus to scrape
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+
+	slog.Info("metrics listening", "port", port)
+	if err := http.ListenAndServe(fmt.Sprintf(":%s", port), mux); err != nil {
+		slog.Error("failed to serve metrics", "error", err)
+	}
+}
@@ -0,0 +1,247 @@
+package telemetry
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var dbQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name:    "db_query_duration_seconds",
+	Help:    "Latency of the database queries, by statement and table.",
+	Buckets: latencyBuckets,
+}, []string{"statement", "table", "error"})
+
+func OpenDB(d driver.DriverContext, dsn string) (*sql.DB, error) {
+	// Same as sql.Open, with every query of the connection pool timed and traced
+
+	c, err := d.OpenConnector(dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	return sql.OpenDB(&timedConnector{Connector: c}), nil
+}
+
+// Queries are labelled by their statement and the first table they name,
+// the labels of a query are computed once since queries are constant strings
+var (
+	queryLabels sync.Map
+	tableRegexp = regexp.MustCompile(`(?i)\b(?:FROM|INTO|UPDATE|JOIN)\s+([A-Za-z_]\w*)`)
+)
+
+func labelsOf(query string) [2]string {
+	if l, ok := queryLabels.Load(query); ok {
+		return l.([2]string)
+	}
+
+	var l [2]string
+	if fields := strings.Fields(query); len(fields) > 0 {
+		l[0] = strings.ToUpper(fields[0])
+	}
+	if m := tableRegexp.FindStringSubmatch(query); m != nil {
+		l[1] = m[1]
+	}
+
+	queryLabels.Store(query, l)
+	return l
+}
+
+func observeQuery(ctx context.Context, query string, start time.Time, err error) {
+	// Record the query in the metrics and as a span of the trace in ctx, once it is done
+
+	end := time.Now()
+	l := labelsOf(query)
+
+	_, span := tracer.Start(ctx, strings.TrimSpace(l[0]+" "+l[1]),
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithTimestamp(start),
+		trace.WithAttributes(attribute.String("db.query.text", query)))
+	failed := "false"
+	if err != nil {
+		failed = "true"
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End(trace.WithTimestamp(end))
+
+	dbQueryDuration.WithLabelValues(l[0], l[1], failed).Observe(end.Sub(start).Seconds())
+}
+
+type timedConnector struct {
+	driver.Connector
+}
+
+func (c *timedConnector) Connect(ctx context.Context) (driver.Conn, error) {
+	conn, err := c.Connector.Connect(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &timedConn{Conn: conn}, nil
+}
+
+// Connection forwarding to the driver the optional interfaces it implements
+type timedConn struct {
+	driver.Conn
+}
+
+func (c *timedConn) BeginTx(ctx context.Context, opts driver.TxOptions) (driver.Tx, error) {
+	if b, ok := c.Conn.(driver.ConnBeginTx); ok {
+		return b.BeginTx(ctx, opts)
+	}
+	return c.Conn.Begin()
+}
+
+func (c *timedConn) Prepare(query string) (driver.Stmt, error) {
+	return c.PrepareContext(context.Background(), query)
+}
+
+func (c *timedConn) PrepareContext(ctx context.Context, query string) (driver.Stmt, error) {
+	start := time.Now()
+	var stmt driver.Stmt
+	var err error
+	if p, ok := c.Conn.(driver.ConnPrepareContext); ok {
+		stmt, err = p.PrepareContext(ctx, query)
+	} else {
+		stmt, err = c.Conn.Prepare(query)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &timedStmt{Stmt: stmt, query: query, prepared: start}, nil
+}
+
+func (c *timedConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	e, ok := c.Conn.(driver.ExecerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	// Queries with arguments are usually skipped by the driver and run as statements
+	start := time.Now()
+	res, err := e.ExecContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		observeQuery(ctx, query, start, err)
+	}
+	return res, err
+}
+
+func (c *timedConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	q, ok := c.Conn.(driver.QueryerContext)
+	if !ok {
+		return nil, driver.ErrSkip
+	}
+
+	start := time.Now()
+	rows, err := q.QueryContext(ctx, query, args)
+	if err != driver.ErrSkip {
+		observeQuery(ctx, query, start, err)
+	}
+	return rows, err
+}
+
+func (c *timedConn) Ping(ctx context.Context) error {
+	if p, ok := c.Conn.(driver.Pinger); ok {
+		return p.Ping(ctx)
+	}
+	return nil
+}
+
+func (c *timedConn) ResetSession(ctx context.Context) error {
+	if r, ok := c.Conn.(driver.SessionResetter); ok {
+		return r.ResetSession(ctx)
+	}
+	return nil
+}
+
+func (c *timedConn) IsValid() bool {
+	if v, ok := c.Conn.(driver.Validator); ok {
+		return v.IsValid()
+	}
+	return true
+}
+
+func (c *timedConn) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := c.Conn.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+// Statement timed from its preparation the first time it runs, queries with arguments
+// are prepared by the driver just before running them
+type timedStmt struct {
+	driver.Stmt
+	query    string
+	prepared time.Time
+}
+
+func (s *timedStmt) start() time.Time {
+	if start := s.prepared; !start.IsZero() {
+		s.prepared = time.Time{}
+		return start
+	}
+	return time.Now()
+}
+
+func (s *timedStmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	start := s.start()
+	var res driver.Result
+	var err error
+	if e, ok := s.Stmt.(driver.StmtExecContext); ok {
+		res, err = e.ExecContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			res, err = s.Stmt.Exec(values)
+		}
+	}
+	observeQuery(ctx, s.query, start, err)
+	return res, err
+}
+
+func (s *timedStmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	start := s.start()
+	var rows driver.Rows
+	var err error
+	if q, ok := s.Stmt.(driver.StmtQueryContext); ok {
+		rows, err = q.QueryContext(ctx, args)
+	} else {
+		var values []driver.Value
+		if values, err = namedToValues(args); err == nil {
+			rows, err = s.Stmt.Query(values)
+		}
+	}
+	observeQuery(ctx, s.query, start, err)
+	return rows, err
+}
+
+func (s *timedStmt) CheckNamedValue(nv *driver.NamedValue) error {
+	if n, ok := s.Stmt.(driver.NamedValueChecker); ok {
+		return n.CheckNamedValue(nv)
+	}
+	return driver.ErrSkip
+}
+
+func namedToValues(args []driver.NamedValue) ([]driver.Value, error) {
+	values := make([]driver.Value, len(args))
+	for i, a := range args {
+		if a.Name != "" {
+			return nil, driver.ErrSkip
+		}
+		values[i] = a.Value
+	}
+	return values, nil
+}
@@ -0,0 +1,49 @@
+package telemetry
+
+import (
+	"context"
+	"log/slog"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+var tracer = otel.Tracer("telemetry")
+
+func InitTracing(service string) (shutdown func(context.Context) error) {
+	// Export the spans of the process as set by TRACING_EXPORTER:
+	// otlp sends them to the collector at OTEL_EXPORTER_OTLP_ENDPOINT, stdout prints them, anything else disables tracing.
+	// The trace context is propagated in any case, so a trace is not broken by a process that does not export it.
+	// The returned function flushes the spans not exported yet
+
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch os.Getenv("TRACING_EXPORTER") {
+	case "otlp":
+		exporter, err = otlptracegrpc.New(context.Background())
+	case "stdout":
+		exporter, err = stdouttrace.New()
+	default:
+		return func(context.Context) error { return nil }
+	}
+	if err != nil {
+		slog.Error("failed to create trace exporter", "error", err)
+		return func(context.Context) error { return nil }
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewWithAttributes(semconv.SchemaURL, semconv.ServiceName(service))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown
+}